@@ -0,0 +1,154 @@
+package bots
+
+import (
+	"bytes"
+	_ "embed"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	RuleNoUserAgent      = "heuristic:no_user_agent"
+	RuleNoAcceptLanguage = "heuristic:no_accept_language"
+)
+
+//go:embed rules.txt
+var builtinRules []byte
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Classifier tags clicks of crawlers, scanners and health checkers by the
+// rules file and a few header heuristics. The rules file is reloaded when it
+// changes, so rules can be updated without a restart.
+type Classifier struct {
+	mu    sync.RWMutex
+	rules *rules
+	stamp fileStamp
+}
+
+var (
+	classifier     *Classifier
+	classifierOnce sync.Once
+)
+
+// Init loads the configured rules and starts watching them for changes
+func Init() *Classifier {
+	classifierOnce.Do(func() {
+		classifier = NewClassifier()
+		if err := classifier.Reload(); err != nil {
+			utils.Log.Error("Failed to load bot rules, using the built-in ones: ", err)
+		}
+		go classifier.maintain()
+	})
+	return classifier
+}
+
+// NewClassifier creates a classifier with the built-in rules, Reload loads
+// the BOT_RULES file instead
+func NewClassifier() *Classifier {
+	builtin, err := parseRules(bytes.NewReader(builtinRules), "rules.txt")
+	if err != nil {
+		panic(err) // The embedded file is fixed at build time
+	}
+	return &Classifier{rules: builtin}
+}
+
+var _ model.BotClassifier = (*Classifier)(nil)
+
+func (c *Classifier) Classify(ip string, header http.Header) model.BotVerdict {
+	userAgent := strings.TrimSpace(header.Get("User-Agent"))
+	if userAgent == "" {
+		return model.BotVerdict{Bot: true, Rule: RuleNoUserAgent}
+	}
+
+	c.mu.RLock()
+	current := c.rules
+	c.mu.RUnlock()
+
+	if rule := current.match(ip, header); rule != "" {
+		return model.BotVerdict{Bot: true, Rule: rule}
+	}
+
+	// Browsers always send their languages, tools posing as one often don't
+	if strings.HasPrefix(userAgent, "Mozilla/") && header.Get("Accept-Language") == "" {
+		return model.BotVerdict{Bot: true, Rule: RuleNoAcceptLanguage}
+	}
+
+	return model.BotVerdict{}
+}
+
+// IsUnfurlCrawler tells whether the user agent is a bot of a chat app or
+// social network building a link card, by the unfurl rules
+func (c *Classifier) IsUnfurlCrawler(userAgent string) bool {
+	c.mu.RLock()
+	current := c.rules
+	c.mu.RUnlock()
+
+	return current.unfurl(userAgent) != nil
+}
+
+// Reload reads the BOT_RULES file again if it changed. A broken file keeps
+// the rules loaded before.
+func (c *Classifier) Reload() error {
+	path := config.Config.BOT.Rules
+	if path == "" {
+		return nil
+	}
+
+	info, statErr := os.Stat(path)
+	if statErr != nil {
+		return statErr
+	}
+	stamp := fileStamp{modTime: info.ModTime(), size: info.Size()}
+
+	c.mu.RLock()
+	unchanged := c.stamp == stamp
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+
+	loaded, parseErr := parseRules(file, path)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	c.mu.Lock()
+	c.rules = loaded
+	c.stamp = stamp
+	c.mu.Unlock()
+
+	utils.Log.Info("Loaded ", loaded.size(), " bot rules from ", path)
+	return nil
+}
+
+func (c *Classifier) maintain() {
+	if config.Config.BOT.ReloadInterval <= 0 || config.Config.BOT.Rules == "" {
+		return
+	}
+
+	ticker := time.NewTicker(config.Config.BOT.ReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := c.Reload(); err != nil {
+			utils.Log.Error("Failed to reload bot rules: ", err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package bots
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func init() {
+	utils.InitLogger()
+}
+
+// useRulesFile points BOT_RULES to a file with content for the test
+func useRulesFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Config.BOT.Rules
+	t.Cleanup(func() { config.Config.BOT.Rules = old })
+	config.Config.BOT.Rules = path
+	return path
+}
+
+func TestIsUnfurlCrawler(t *testing.T) {
+	c := NewClassifier()
+
+	tests := []struct {
+		userAgent string
+		want      bool
+	}{
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"TelegramBot (like TwitterBot)", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", false},
+		{"curl/8.4.0", false},
+		{"Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := c.IsUnfurlCrawler(test.userAgent); got != test.want {
+			t.Errorf("IsUnfurlCrawler(%q) = %v, want %v", test.userAgent, got, test.want)
+		}
+	}
+
+	// Unfurl crawlers are bots too
+	header := http.Header{"User-Agent": {"Twitterbot/1.0"}}
+	if verdict := c.Classify("203.0.113.7", header); !verdict.Bot || !strings.HasPrefix(verdict.Rule, "unfurl:") {
+		t.Errorf("Twitterbot classified as %+v, want an unfurl rule", verdict)
+	}
+}
+
+func TestIsUnfurlCrawlerFollowsReloadedRules(t *testing.T) {
+	path := useRulesFile(t, "unfurl  examplecardbot\n")
+
+	c := NewClassifier()
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsUnfurlCrawler("ExampleCardBot/1.0") {
+		t.Error("crawler of the rules file not recognized")
+	}
+	// The file replaces the built-in rules
+	if c.IsUnfurlCrawler("Slackbot-LinkExpanding 1.0") {
+		t.Error("built-in unfurl rule still used")
+	}
+
+	writeRules(t, path, "ua  examplecardbot\n", time.Second)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if c.IsUnfurlCrawler("ExampleCardBot/1.0") {
+		t.Error("crawler still gets the card after its unfurl rule became a ua rule")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	useRulesFile(t, "ua  ^scanner/\nip  10.9.0.0/16\nheader  X-Scan\n")
+	c := NewClassifier()
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	browser := "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0"
+	tests := []struct {
+		name   string
+		ip     string
+		header http.Header
+		want   string
+	}{
+		{"person", "203.0.113.7", http.Header{"User-Agent": {browser}, "Accept-Language": {"en"}}, ""},
+		{"user agent rule", "203.0.113.7", http.Header{"User-Agent": {"scanner/1.0"}}, "ua:^scanner/"},
+		{"ip rule", "10.9.3.4", http.Header{"User-Agent": {browser}, "Accept-Language": {"en"}}, "ip:10.9.0.0/16"},
+		{"header rule", "203.0.113.7", http.Header{"User-Agent": {browser}, "Accept-Language": {"en"}, "X-Scan": {"1"}}, "header:X-Scan"},
+		{"no user agent", "203.0.113.7", http.Header{"Accept-Language": {"en"}}, RuleNoUserAgent},
+		{"blank user agent", "203.0.113.7", http.Header{"User-Agent": {"  "}}, RuleNoUserAgent},
+		{"browser without languages", "203.0.113.7", http.Header{"User-Agent": {browser}}, RuleNoAcceptLanguage},
+		// Only browsers are expected to send their languages
+		{"app without languages", "203.0.113.7", http.Header{"User-Agent": {"MyApp/2.0"}}, ""},
+	}
+	for _, test := range tests {
+		verdict := c.Classify(test.ip, test.header)
+		if verdict.Rule != test.want || verdict.Bot != (test.want != "") {
+			t.Errorf("%s: %+v, want rule %q", test.name, verdict, test.want)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := useRulesFile(t, "ua  googlebot\n")
+	googlebot := http.Header{"User-Agent": {"Googlebot/2.1"}}
+	curl := http.Header{"User-Agent": {"curl/8.4.0"}}
+
+	c := NewClassifier()
+	if !c.Classify("203.0.113.7", curl).Bot {
+		t.Fatal("built-in rules not used before the first reload")
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if verdict := c.Classify("203.0.113.7", googlebot); verdict.Rule != "ua:googlebot" {
+		t.Errorf("googlebot: %+v", verdict)
+	}
+	// The file replaces the built-in rules
+	if verdict := c.Classify("203.0.113.7", curl); verdict.Bot {
+		t.Errorf("curl: %+v, want the built-in rules replaced", verdict)
+	}
+
+	// A broken file keeps the rules loaded before
+	writeRules(t, path, "bogus rule\n", time.Second)
+	if err := c.Reload(); err == nil {
+		t.Fatal("broken rules file loaded")
+	}
+	if verdict := c.Classify("203.0.113.7", googlebot); verdict.Rule != "ua:googlebot" {
+		t.Errorf("googlebot after a broken file: %+v", verdict)
+	}
+
+	writeRules(t, path, "ua  ^curl/\n", 2*time.Second)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Classify("203.0.113.7", googlebot).Bot || !c.Classify("203.0.113.7", curl).Bot {
+		t.Error("changed rules file not picked up")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Reload(); err == nil {
+		t.Error("missing rules file not reported")
+	}
+	if !c.Classify("203.0.113.7", curl).Bot {
+		t.Error("missing rules file dropped the rules")
+	}
+}
+
+// writeRules replaces the rules file and moves its modification time ahead,
+// so Reload notices the change on filesystems with coarse timestamps too
+func writeRules(t *testing.T, path string, content string, ahead time.Duration) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(ahead)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
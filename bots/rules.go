@@ -0,0 +1,189 @@
+package bots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// headerRule matches clicks sending a header, or the header containing value
+type headerRule struct {
+	name  string
+	value string // Lowercase, empty matches any value
+}
+
+// rules are loaded from a rules file, replaced as a whole on reload
+type rules struct {
+	userAgents []*regexp.Regexp
+	unfurls    []*regexp.Regexp // Crawlers building link cards, bots as well
+	humans     []*regexp.Regexp // Browsers the user agent rules would take for bots
+	nets       []*net.IPNet
+	headers    []headerRule
+}
+
+// parseRules reads rules files. Every line is a rule kind followed by its
+// argument: "ua <regexp>", "unfurl <regexp>", "human <regexp>",
+// "ip <address or CIDR>" or "header <Name>[: value]".
+// Blank lines and everything after # are skipped.
+func parseRules(reader io.Reader, name string) (*rules, error) {
+	parsed := &rules{}
+
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		kind, argument := line, ""
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			kind, argument = line[:i], strings.TrimSpace(line[i:])
+		}
+		if argument == "" {
+			return nil, fmt.Errorf("%s:%d: %s rule without argument", name, lineNumber, kind)
+		}
+
+		if err := parsed.add(kind, argument); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+func (r *rules) add(kind, argument string) error {
+	switch kind {
+	case "ua":
+		pattern, err := regexp.Compile("(?i)" + argument)
+		if err != nil {
+			return err
+		}
+		r.userAgents = append(r.userAgents, pattern)
+
+	case "unfurl":
+		pattern, err := regexp.Compile("(?i)" + argument)
+		if err != nil {
+			return err
+		}
+		r.unfurls = append(r.unfurls, pattern)
+
+	case "human":
+		pattern, err := regexp.Compile("(?i)" + argument)
+		if err != nil {
+			return err
+		}
+		r.humans = append(r.humans, pattern)
+
+	case "ip":
+		if !strings.Contains(argument, "/") {
+			if ip := net.ParseIP(argument); ip != nil && ip.To4() != nil {
+				argument += "/32"
+			} else {
+				argument += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(argument)
+		if err != nil {
+			return err
+		}
+		r.nets = append(r.nets, ipNet)
+
+	case "header":
+		name, value, _ := strings.Cut(argument, ":")
+		r.headers = append(r.headers, headerRule{
+			name:  http.CanonicalHeaderKey(strings.TrimSpace(name)),
+			value: strings.ToLower(strings.TrimSpace(value)),
+		})
+
+	default:
+		return fmt.Errorf("unknown rule %q", kind)
+	}
+	return nil
+}
+
+// match returns the rule the click matches, empty if it matches none
+func (r *rules) match(ip string, header http.Header) string {
+	if r == nil {
+		return ""
+	}
+
+	userAgent := header.Get("User-Agent")
+	if pattern := r.unfurl(userAgent); pattern != nil {
+		return "unfurl:" + pattern.String()[len("(?i)"):]
+	}
+	if !r.human(userAgent) {
+		for _, pattern := range r.userAgents {
+			if pattern.MatchString(userAgent) {
+				return "ua:" + pattern.String()[len("(?i)"):]
+			}
+		}
+	}
+
+	if parsedIP := net.ParseIP(ip); parsedIP != nil {
+		for _, ipNet := range r.nets {
+			if ipNet.Contains(parsedIP) {
+				return "ip:" + ipNet.String()
+			}
+		}
+	}
+
+	for _, rule := range r.headers {
+		values := header.Values(rule.name)
+		if len(values) == 0 {
+			continue
+		}
+		if rule.value == "" {
+			return "header:" + rule.name
+		}
+		for _, value := range values {
+			if strings.Contains(strings.ToLower(value), rule.value) {
+				return "header:" + rule.name
+			}
+		}
+	}
+
+	return ""
+}
+
+// unfurl returns the unfurl rule the user agent matches, nil if none
+func (r *rules) unfurl(userAgent string) *regexp.Regexp {
+	if r == nil {
+		return nil
+	}
+	for _, pattern := range r.unfurls {
+		if pattern.MatchString(userAgent) {
+			return pattern
+		}
+	}
+	return nil
+}
+
+// human tells whether a human rule exempts the user agent from the ua rules
+func (r *rules) human(userAgent string) bool {
+	for _, pattern := range r.humans {
+		if pattern.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rules) size() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.userAgents) + len(r.unfurls) + len(r.humans) + len(r.nets) + len(r.headers)
+}
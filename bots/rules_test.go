@@ -0,0 +1,95 @@
+package bots
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	parsed, err := parseRules(strings.NewReader(`
+# Comment lines and blank lines are skipped
+
+ua      ^curl/     # trailing comments too
+unfurl  slackbot
+human   \bcubot\b
+ip      192.0.2.7
+ip      2001:db8::1
+ip      198.51.100.0/24
+header  X-Scan
+header  Purpose: Prefetch
+`), "test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.size() != 8 {
+		t.Errorf("%d rules, want 8", parsed.size())
+	}
+	if got := parsed.userAgents[0].String(); got != "(?i)^curl/" {
+		t.Errorf("ua rule %q, want the pattern without the comment", got)
+	}
+	for i, want := range []string{"192.0.2.7/32", "2001:db8::1/128", "198.51.100.0/24"} {
+		if got := parsed.nets[i].String(); got != want {
+			t.Errorf("ip rule %d is %s, want %s", i, got, want)
+		}
+	}
+	want := []headerRule{{name: "X-Scan"}, {name: "Purpose", value: "prefetch"}}
+	for i, rule := range parsed.headers {
+		if rule != want[i] {
+			t.Errorf("header rule %d is %+v, want %+v", i, rule, want[i])
+		}
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"ua a\nbogus rule\n", "test.txt:2: unknown rule"},
+		{"\n\nua\n", "test.txt:3: ua rule without argument"},
+		{"ua (unclosed\n", "test.txt:1: error parsing regexp"},
+		{"ip 192.0.2.300\n", "test.txt:1: invalid CIDR"},
+	}
+	for _, test := range tests {
+		_, err := parseRules(strings.NewReader(test.content), "test.txt")
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("parsing %q: %v, want %q", test.content, err, test.want)
+		}
+	}
+}
+
+func TestBuiltinRules(t *testing.T) {
+	builtin, err := parseRules(strings.NewReader(string(builtinRules)), "rules.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "ua:"},
+		{"Mozilla/5.0 (compatible; DuckDuckBot-Https/1.1; https://duckduckgo.com/duckduckbot)", "ua:"},
+		{"curl/8.4.0", "ua:"},
+		{"python-requests/2.31.0", "ua:"},
+		{"Pingdom.com_bot_version_1.4_(http://www.pingdom.com/)", "ua:"},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "unfurl:"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/128.0.0.0 Safari/537.36", ""},
+		// Phones named like bots are people
+		{"Mozilla/5.0 (Linux; Android 10; CUBOT P40) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", ""},
+		{"Mozilla/5.0 (Linux; Android 12; CUBOT KINGKONG 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Mobile Safari/537.36", ""},
+	}
+	for _, test := range tests {
+		got := builtin.match("203.0.113.7", http.Header{"User-Agent": {test.userAgent}})
+		if !strings.HasPrefix(got, test.want) || (test.want == "") != (got == "") {
+			t.Errorf("%q matched %q, want a %q rule", test.userAgent, got, test.want)
+		}
+	}
+
+	prefetch := http.Header{"User-Agent": {"Mozilla/5.0"}, "Sec-Purpose": {"prefetch;prerender"}}
+	if got := builtin.match("203.0.113.7", prefetch); got != "header:Sec-Purpose" {
+		t.Errorf("prefetch matched %q", got)
+	}
+}
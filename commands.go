@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// runCommand runs a one-off maintenance command instead of the server
+func runCommand(store *model.Store, args []string) error {
+	switch args[0] {
+	case "anonymize":
+		return anonymizeCommand(store, args[1:])
+	}
+	return fmt.Errorf("unknown command %q, known commands: anonymize", args[0])
+}
+
+// anonymizeCommand applies the click privacy modes to stored clicks, and
+// -mode or CLICK_PRIVACY to the addresses of audit events and resolved abuse
+// reports, e.g. after CLICK_PRIVACY was made stricter
+func anonymizeCommand(store *model.Store, args []string) error {
+	flags := flag.NewFlagSet("anonymize", flag.ContinueOnError)
+	mode := flags.String("mode", "", "Apply this mode to every click instead of the mode of each link owner, and to audit events and resolved abuse reports instead of CLICK_PRIVACY (full, truncated, hashed or none)")
+	before := flags.String("before", "", "Only clicks before this day, YYYY-MM-DD (UTC). Defaults to all clicks.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	privacy := model.ClickPrivacy(*mode)
+	if privacy != "" && !privacy.IsValid() {
+		return fmt.Errorf("unknown mode %q", *mode)
+	}
+	until := time.Now().UTC()
+	if *before != "" {
+		day, parseErr := time.Parse(model.VisitorDayFormat, *before)
+		if parseErr != nil {
+			return fmt.Errorf("before must be YYYY-MM-DD: %w", parseErr)
+		}
+		until = day
+	}
+
+	changed, err := model.AnonymizeClicks(context.Background(), store, privacy, until)
+	utils.Log.Info("Anonymized ", changed, " clicks before ", until.Format(time.RFC3339))
+	if err != nil {
+		return err
+	}
+
+	changed, err = model.AnonymizeAuditAndReports(context.Background(), store, privacy, until)
+	utils.Log.Info("Anonymized ", changed, " audit events and abuse reports before ", until.Format(time.RFC3339))
+	return err
+}
@@ -2,13 +2,20 @@ package config
 
 import (
 	"log"
+	"time"
 
 	"github.com/caarlos0/env/v11"
 	"github.com/joho/godotenv"
 )
 
 type otpConfig struct {
-	ExpiryMinutes int64 `env:"OTP_EXPIRY_MINUTES" envDefault:"5"`
+	ExpiryMinutes int64  `env:"OTP_EXPIRY_MINUTES" envDefault:"5"`
+	MaxAttempts   int    `env:"OTP_MAX_ATTEMPTS" envDefault:"5"` // Checks, right or wrong, before an OTP is invalidated
+	Length        int    `env:"OTP_LENGTH" envDefault:"6"`
+	Alphabet      string `env:"OTP_ALPHABET" envDefault:"0123456789"`
+	Secret        string `env:"OTP_SECRET"` // HMAC key for stored codes, falls back to ENCRYPTION_KEY
+
+	MagicLinkExpiryMinutes int64 `env:"MAGIC_LINK_EXPIRY_MINUTES" envDefault:"10"`
 }
 
 type dbConfig struct {
@@ -26,21 +33,32 @@ type smtpConfig struct {
 }
 
 type appConfig struct {
-	Name           string `env:"APP_NAME" envDefault:"URL Shortner - Go"`
-	Host           string `env:"HOST,required" envDefault:""`
-	SwaggerHost    string `env:"SWAGGER_HOST" envDefault:""`
-	Port           string `env:"PORT,required" envDefault:"8000"`
-	TrustedProxies string `env:"TRUSTED_ORIGINS" envDefault:""`
-	EnableHTTPS    bool   `env:"ENABLE_HTTPS" envDefault:"false"`
-	ProjectID      string `env:"PROJECT_ID,required"`
-	EncryptionKey  string `env:"ENCRYPTION_KEY,required"` // Must be 16, 24 or 32 bytes long
+	Name           string   `env:"APP_NAME" envDefault:"URL Shortner - Go"`
+	Host           string   `env:"HOST,required" envDefault:""`
+	SwaggerHost    string   `env:"SWAGGER_HOST" envDefault:""`
+	Port           string   `env:"PORT,required" envDefault:"8000"`
+	TrustedProxies string   `env:"TRUSTED_ORIGINS" envDefault:""`
+	EnableHTTPS    bool     `env:"ENABLE_HTTPS" envDefault:"false"`
+	ProjectID      string   `env:"PROJECT_ID,required"`
+	EncryptionKey  string   `env:"ENCRYPTION_KEY,required"`              // Must be 16, 24 or 32 bytes long
+	StorageDriver  string   `env:"STORAGE_DRIVER" envDefault:"postgres"` // postgres | memory
+	ProblemJSON    bool     `env:"PROBLEM_JSON" envDefault:"false"`      // Always answer errors as RFC 7807 application/problem+json
+	AdminEmails    []string `env:"ADMIN_EMAILS" envSeparator:","`        // Users promoted to admin on startup
 }
 
 type JWTConfig struct {
-	SecretKey            string `env:"JWT_SECRET,required"`
+	SecretKey            string `env:"JWT_SECRET"` // Legacy HS256 tokens are accepted while it is set
 	ExpiryMinutes        int64  `env:"JWT_EXPIRY_MINUTES" envDefault:"15"`
-	RefreshSecretKey     string `env:"JWT_REFRESH_SECRET,required"`
+	RefreshSecretKey     string `env:"JWT_REFRESH_SECRET"`                            // Legacy HS256 refresh tokens are accepted while it is set
 	RefreshExpiryMinutes int64  `env:"JWT_REFRESH_EXPIRY_MINUTES" envDefault:"14400"` // 10 days
+
+	Algorithm   string        `env:"JWT_ALGORITHM" envDefault:"RS256"`   // RS256 | EdDSA, used for newly generated keys
+	KeysDir     string        `env:"JWT_KEYS_DIR" envDefault:"keys/jwt"` // PEM private keys, shared by all instances
+	KeyRotation time.Duration `env:"JWT_KEY_ROTATION" envDefault:"720h"` // 0 disables scheduled rotation
+	Issuer      string        `env:"JWT_ISSUER"`                         // Defaults to the app URL
+	Audience    string        `env:"JWT_AUDIENCE" envDefault:"url-shortner"`
+
+	UserStatusCacheTTL time.Duration `env:"JWT_USER_STATUS_CACHE_TTL" envDefault:"30s"` // How long access tokens trust a read user status, 0 reads it on every request
 }
 
 type redisConfig struct {
@@ -51,16 +69,139 @@ type redisConfig struct {
 
 type grpcConfig struct {
 	EmailServiceAddr string `env:"GRPC_EMAIL_SERVICE_ADDR" envDefault:"localhost:8011"`
+	SmsServiceAddr   string `env:"GRPC_SMS_SERVICE_ADDR" envDefault:""`
+}
+
+type smsConfig struct {
+	Provider  string `env:"SMS_PROVIDER"` // http | grpc | log (writes codes to the log, local dev only), empty disables phone OTPs
+	From      string `env:"SMS_FROM" envDefault:""`
+	HTTPURL   string `env:"SMS_HTTP_URL" envDefault:""`
+	HTTPToken string `env:"SMS_HTTP_TOKEN" envDefault:""`
+}
+
+type timeoutConfig struct {
+	DBRead  time.Duration `env:"TIMEOUT_DB_READ" envDefault:"3s"`
+	DBWrite time.Duration `env:"TIMEOUT_DB_WRITE" envDefault:"5s"`
+	Redis   time.Duration `env:"TIMEOUT_REDIS" envDefault:"1s"`
+	Mail    time.Duration `env:"TIMEOUT_MAIL" envDefault:"10s"`
+}
+
+// bruteForceConfig controls failed login tracking. After FreeAttempts failures
+// an account has to wait BaseDelay, doubling with every further failure up to
+// MaxDelay, and is locked for Lockout once MaxAttempts is reached.
+type bruteForceConfig struct {
+	FreeAttempts  int64         `env:"LOGIN_FREE_ATTEMPTS" envDefault:"3"`
+	MaxAttempts   int64         `env:"LOGIN_MAX_ATTEMPTS" envDefault:"10"`
+	IPMaxAttempts int64         `env:"LOGIN_IP_MAX_ATTEMPTS" envDefault:"50"`
+	Window        time.Duration `env:"LOGIN_FAILURE_WINDOW" envDefault:"15m"`
+	BaseDelay     time.Duration `env:"LOGIN_BASE_DELAY" envDefault:"1s"`
+	MaxDelay      time.Duration `env:"LOGIN_MAX_DELAY" envDefault:"1m"`
+	Lockout       time.Duration `env:"LOGIN_LOCKOUT" envDefault:"15m"`
+}
+
+type totpConfig struct {
+	Issuer         string `env:"TOTP_ISSUER" envDefault:""` // Shown in authenticator apps, defaults to APP_NAME
+	Skew           int    `env:"TOTP_SKEW" envDefault:"1"`  // Accepted 30s steps of clock drift either way
+	RecoveryCodes  int    `env:"TOTP_RECOVERY_CODES" envDefault:"10"`
+	RecoverySecret string `env:"TOTP_RECOVERY_SECRET" envDefault:""` // HMAC key for recovery codes, derived from ENCRYPTION_KEY when empty
+}
+
+// privacyConfig controls data exports, account deletion and what clicks store
+type privacyConfig struct {
+	ExportDir            string        `env:"EXPORT_DIR" envDefault:"exports"`
+	ExportExpiry         time.Duration `env:"EXPORT_EXPIRY" envDefault:"24h"`           // How long download links work
+	ExportCooldown       time.Duration `env:"EXPORT_COOLDOWN" envDefault:"1h"`          // One export per user this often
+	EmailReuseCooldown   time.Duration `env:"DELETED_EMAIL_COOLDOWN" envDefault:"720h"` // Emails of deleted accounts can't sign up again for this long
+	ClickPrivacy         string        `env:"CLICK_PRIVACY" envDefault:"full"`          // full, truncated, hashed or none; users can pick their own
+	ClickIPHashKey       string        `env:"CLICK_IP_HASH_KEY" envDefault:""`          // HMAC key of hashed addresses, derived from ENCRYPTION_KEY when empty
+	ClickIPHashRotation  time.Duration `env:"CLICK_IP_HASH_ROTATION" envDefault:"720h"` // Hashed addresses only match within this period, 0 never rotates
+	ClickPrivacyCacheTTL time.Duration `env:"CLICK_PRIVACY_CACHE_TTL" envDefault:"1m"`  // How long redirects remember the mode of a link owner
+	HonorDoNotTrack      bool          `env:"HONOR_DO_NOT_TRACK" envDefault:"true"`     // Store no IP address or user agent of clicks sending DNT or Sec-GPC
+	AuditRetention       time.Duration `env:"AUDIT_RETENTION" envDefault:"8760h"`       // Audit events are purged after this long, 0 keeps them
+}
+
+// bulkConfig limits bulk link creation
+type bulkConfig struct {
+	MaxRows        int   `env:"BULK_MAX_ROWS" envDefault:"500"`             // Links per request
+	MaxUploadBytes int64 `env:"BULK_MAX_UPLOAD_BYTES" envDefault:"1048576"` // Size of the JSON or CSV body
+}
+
+// safetyConfig controls the checks destinations of new links go through.
+// List files are reloaded when they change.
+type safetyConfig struct {
+	Blocklists     []string      `env:"SAFETY_BLOCKLISTS" envSeparator:","`      // Files of blocked domains, IP addresses and CIDR ranges
+	Allowlists     []string      `env:"SAFETY_ALLOWLISTS" envSeparator:","`      // Files of domains that skip all checks
+	HashPrefixes   string        `env:"SAFETY_HASH_PREFIXES"`                    // File of hex SHA-256 prefixes of unsafe URL expressions
+	ReloadInterval time.Duration `env:"SAFETY_RELOAD_INTERVAL" envDefault:"30s"` // How often list files are checked for changes
+	MaxRedirects   int           `env:"SAFETY_MAX_REDIRECTS" envDefault:"5"`     // Longer redirect chains are flagged, 0 doesn't follow redirects
+	RescanInterval time.Duration `env:"SAFETY_RESCAN_INTERVAL" envDefault:"24h"` // Links are checked again this often, 0 disables rescans
+}
+
+// previewConfig controls fetching the metadata of destinations for link
+// previews
+type previewConfig struct {
+	FetchTimeout time.Duration `env:"PREVIEW_FETCH_TIMEOUT" envDefault:"5s"`
+	CacheTTL     time.Duration `env:"PREVIEW_CACHE_TTL" envDefault:"1h"`     // How long fetched metadata is reused
+	MaxBytes     int64         `env:"PREVIEW_MAX_BYTES" envDefault:"524288"` // Read from the destination page at most
+}
+
+// botConfig controls how clicks of crawlers, scanners and health checkers
+// are told apart from clicks of people. The rules file is reloaded when it
+// changes.
+type botConfig struct {
+	Rules          string        `env:"BOT_RULES"`                            // File of user agent patterns, IP ranges and headers, replaces the built-in rules
+	ReloadInterval time.Duration `env:"BOT_RELOAD_INTERVAL" envDefault:"30s"` // How often the rules file is checked for changes
+}
+
+// analyticsConfig controls how clicks are rolled up and how long they are
+// kept
+type analyticsConfig struct {
+	VisitorRetention      time.Duration `env:"ANALYTICS_VISITOR_RETENTION" envDefault:"2160h"`       // Daily unique visitor counts are kept this long
+	VisitorTotalRetention time.Duration `env:"ANALYTICS_VISITOR_TOTAL_RETENTION" envDefault:"8760h"` // All-time unique visitor counts of links without visitors for this long are dropped, 0 keeps them
+	CountryHeader         string        `env:"ANALYTICS_COUNTRY_HEADER" envDefault:"CF-IPCountry"`   // Set by the CDN or proxy in front of the app
+	RollupInterval        time.Duration `env:"ANALYTICS_ROLLUP_INTERVAL" envDefault:"5m"`            // How often clicks are rolled up, 0 disables the aggregator
+	PurgeInterval         time.Duration `env:"ANALYTICS_PURGE_INTERVAL" envDefault:"1h"`             // How often old clicks and hourly rollups are purged, 0 disables purging
+	RawRetention          time.Duration `env:"ANALYTICS_RAW_RETENTION" envDefault:"2160h"`           // Raw clicks are purged after this long, 0 keeps them
+	HourlyRetention       time.Duration `env:"ANALYTICS_HOURLY_RETENTION" envDefault:"720h"`         // Hourly rollups are purged after this long, 0 keeps them
+	PurgeBatch            int           `env:"ANALYTICS_PURGE_BATCH" envDefault:"5000"`              // Rows deleted per statement when purging
+}
+
+// abuseConfig controls public abuse reports
+type abuseConfig struct {
+	AutoBlockReports int `env:"ABUSE_AUTO_BLOCK_REPORTS" envDefault:"5"` // Distinct signed-in reporters that get a link blocked, 0 leaves it to moderators
+}
+
+// liveConfig controls the live click streams
+type liveConfig struct {
+	Buffer            int           `env:"LIVE_BUFFER" envDefault:"64"`              // Clicks kept for a slow viewer before they are dropped
+	HeartbeatInterval time.Duration `env:"LIVE_HEARTBEAT_INTERVAL" envDefault:"15s"` // Comments keeping idle streams open through proxies
+	WriteTimeout      time.Duration `env:"LIVE_WRITE_TIMEOUT" envDefault:"10s"`      // Viewers not taking an event within this long are disconnected
+	MaxDuration       time.Duration `env:"LIVE_MAX_DURATION" envDefault:"1h"`        // Streams end after this long and clients reconnect, 0 keeps them open
+	MaxStreams        int           `env:"LIVE_MAX_STREAMS" envDefault:"5"`          // Streams a user can have open at once on each instance, 0 for no limit
 }
 
 type AllConfig struct {
-	APP   appConfig
-	DB    dbConfig
-	SMTP  smtpConfig
-	OTP   otpConfig
-	JWT   JWTConfig
-	REDIS redisConfig
-	GRPC  grpcConfig
+	APP       appConfig
+	DB        dbConfig
+	SMTP      smtpConfig
+	OTP       otpConfig
+	JWT       JWTConfig
+	REDIS     redisConfig
+	GRPC      grpcConfig
+	SMS       smsConfig
+	TIMEOUT   timeoutConfig
+	RATELIMIT rateLimitConfig
+	LOGIN     bruteForceConfig
+	TOTP      totpConfig
+	OIDC      oidcConfig
+	PRIVACY   privacyConfig
+	BULK      bulkConfig
+	SAFETY    safetyConfig
+	ABUSE     abuseConfig
+	PREVIEW   previewConfig
+	BOT       botConfig
+	ANALYTICS analyticsConfig
+	LIVE      liveConfig
 }
 
 var Config AllConfig
@@ -74,4 +215,7 @@ func LoadConfig() {
 		log.Fatalf("❌ Failed to load env: %v", err)
 		panic(err)
 	}
+	if err := Config.OTP.validate(); err != nil {
+		log.Fatalf("❌ Invalid OTP settings: %v", err)
+	}
 }
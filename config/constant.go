@@ -2,3 +2,5 @@ package config
 
 const TIME_FORMAT = "02 Jan 2006, 03:04 PM"
 const JWT_LOGGED_IN_USER = "loggedInUserId"
+const JWT_LOGGED_IN_USER_PLAN = "loggedInUserPlan"
+const JWT_LOGGED_IN_USER_ROLE = "loggedInUserRole"
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// OIDCProvider is one OpenID Connect identity provider, configured through
+// OIDC_<NAME>_* variables for every name listed in OIDC_PROVIDERS
+type OIDCProvider struct {
+	Name         string
+	Issuer       string   // OIDC_<NAME>_ISSUER, discovery runs against <issuer>/.well-known/openid-configuration
+	ClientID     string   // OIDC_<NAME>_CLIENT_ID
+	ClientSecret string   // OIDC_<NAME>_CLIENT_SECRET, empty for public clients
+	RedirectURL  string   // OIDC_<NAME>_REDIRECT_URL, defaults to <host>/auth/oidc/<name>/callback
+	Scopes       []string // OIDC_<NAME>_SCOPES, space separated, defaults to "openid email profile"
+}
+
+type oidcConfig struct {
+	Providers []string `env:"OIDC_PROVIDERS" envSeparator:"," envDefault:""`
+}
+
+// OIDCProviders returns the configured providers. Incomplete ones are skipped
+// and reported in skipped, for the caller to log.
+func OIDCProviders() (providers []OIDCProvider, skipped []error) {
+	for _, name := range Config.OIDC.Providers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := OIDCProvider{
+			Name:         name,
+			Issuer:       strings.TrimRight(os.Getenv(prefix+"ISSUER"), "/"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			skipped = append(skipped, fmt.Errorf("OIDC provider %s is missing %sISSUER or %sCLIENT_ID, skipping", name, prefix, prefix))
+			continue
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"openid", "email", "profile"}
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, skipped
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Codes are typed from emails and texts, longer ones are left to magic links
+const (
+	minOtpLength = 4
+	maxOtpLength = 32
+)
+
+// validate rejects OTP settings that would generate unusable codes, so a
+// typo fails at startup instead of at the first login
+func (c otpConfig) validate() error {
+	if c.Length < minOtpLength || c.Length > maxOtpLength {
+		return fmt.Errorf("OTP_LENGTH must be between %d and %d, got %d", minOtpLength, maxOtpLength, c.Length)
+	}
+	if utf8.RuneCountInString(c.Alphabet) < 2 {
+		return fmt.Errorf("OTP_ALPHABET needs at least 2 characters, got %q", c.Alphabet)
+	}
+	for _, char := range c.Alphabet {
+		if char == utf8.RuneError || unicode.IsSpace(char) || !unicode.IsPrint(char) {
+			return fmt.Errorf("OTP_ALPHABET must only contain printable characters without spaces, got %q", c.Alphabet)
+		}
+	}
+	return nil
+}
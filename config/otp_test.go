@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestOtpConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		alphabet string
+		valid    bool
+	}{
+		{"digits", 6, "0123456789", true},
+		{"letters", 8, "ABCDEFGHJKLMNPQRSTUVWXYZ", true},
+		{"non-ASCII", 6, "αβγδ", true},
+		{"too short", 3, "0123456789", false},
+		{"too long", 33, "0123456789", false},
+		{"zero length", 0, "0123456789", false},
+		{"empty alphabet", 6, "", false},
+		{"single character", 6, "7", false},
+		{"space", 6, "0123 456789", false},
+		{"control character", 6, "01234\t56789", false},
+		{"invalid UTF-8", 6, "01\xff", false},
+	}
+	for _, test := range tests {
+		err := otpConfig{Length: test.length, Alphabet: test.alphabet}.validate()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: %v, want valid %v", test.name, err, test.valid)
+		}
+	}
+}
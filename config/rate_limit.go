@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimitRule is parsed from "<rate>/<period>[:<burst>]", e.g. "20/1m:5"
+// allows 20 requests per minute with bursts of up to 5. Burst defaults to
+// the rate.
+type RateLimitRule struct {
+	Rate   int
+	Burst  int
+	Period time.Duration
+}
+
+func (r *RateLimitRule) UnmarshalText(text []byte) error {
+	value := strings.TrimSpace(string(text))
+
+	rateStr, rest, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("invalid rate limit %q, expected <rate>/<period>[:<burst>]", value)
+	}
+
+	periodStr, burstStr, hasBurst := strings.Cut(rest, ":")
+
+	rate, err := strconv.Atoi(rateStr)
+	if err != nil || rate <= 0 {
+		return fmt.Errorf("invalid rate in rate limit %q", value)
+	}
+
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return fmt.Errorf("invalid period in rate limit %q", value)
+	}
+
+	burst := rate
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return fmt.Errorf("invalid burst in rate limit %q", value)
+		}
+	}
+
+	r.Rate, r.Period, r.Burst = rate, period, burst
+	return nil
+}
+
+type rateLimitConfig struct {
+	Default    RateLimitRule `env:"RATE_LIMIT_DEFAULT" envDefault:"20/1m"`
+	Redirect   RateLimitRule `env:"RATE_LIMIT_REDIRECT" envDefault:"300/1m:60"`
+	OtpSend    RateLimitRule `env:"RATE_LIMIT_OTP_SEND" envDefault:"5/10m:2"`
+	Login      RateLimitRule `env:"RATE_LIMIT_LOGIN" envDefault:"10/1m:5"`
+	LinkCreate RateLimitRule `env:"RATE_LIMIT_LINK_CREATE" envDefault:"30/1m:10"`
+	Analytics  RateLimitRule `env:"RATE_LIMIT_ANALYTICS" envDefault:"60/1m:20"`
+	Report     RateLimitRule `env:"RATE_LIMIT_REPORT" envDefault:"10/1h:3"`
+	// Multiplies the limits of authenticated policies by the user's plan, e.g. "free:1,pro:5"
+	PlanMultipliers map[string]int `env:"RATE_LIMIT_PLAN_MULTIPLIERS" envDefault:"free:1,pro:5,business:20"`
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type AnalyticsStore struct {
+	db *sql.DB
+}
+
+func (s *AnalyticsStore) Save(ctx context.Context, a *model.Analytics) error {
+	query := `INSERT INTO analytics (url_id, ip_address, user_agent, referrer, is_bot, bot_rule, referrer_domain, country, device, visitor_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created_at`
+
+	logStr := fmt.Sprintf("Save analytics in DB : %s, URL ID: %d, Timestamp: %s", query, a.UrlID, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, a.UrlID, a.IPAddress, a.UserAgent, a.Referrer, a.Bot, a.BotRule,
+		a.ReferrerDomain, a.Country, a.Device, a.VisitorHash, time.Now().UTC()).Scan(&a.ID, &a.CreatedAt)
+
+	if rowErr != nil {
+		return fmt.Errorf("Error while trying to save analytics - %w !", ContextErr(writeCtx, rowErr))
+	}
+
+	// Increment click count in url table, bots are counted apart
+	updateQuery := `UPDATE url SET click_count = click_count + 1 WHERE id = $1`
+	if a.Bot {
+		updateQuery = `UPDATE url SET bot_click_count = bot_click_count + 1 WHERE id = $1`
+	}
+	_, updateErr := s.db.ExecContext(writeCtx, updateQuery, a.UrlID)
+	if updateErr != nil {
+		return fmt.Errorf("Error while trying to update click count - %w !", ContextErr(writeCtx, updateErr))
+	}
+
+	return nil
+}
+
+func (s *AnalyticsStore) ListByUser(ctx context.Context, userID int64) ([]model.Analytics, error) {
+	var events []model.Analytics
+
+	query := `SELECT a.id, a.url_id, a.ip_address, a.user_agent, COALESCE(a.referrer, ''), a.is_bot, a.bot_rule, a.referrer_domain, a.country, a.device, a.created_at
+		FROM analytics a JOIN url u ON u.id = a.url_id WHERE u.user_id = $1 ORDER BY a.id`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, userID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to list analytics - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event model.Analytics
+		scanErr := rows.Scan(&event.ID, &event.UrlID, &event.IPAddress, &event.UserAgent, &event.Referrer, &event.Bot, &event.BotRule, &event.ReferrerDomain, &event.Country, &event.Device, &event.CreatedAt)
+		if scanErr != nil {
+			return nil, fmt.Errorf("Error while trying to scan analytics - %w !", scanErr)
+		}
+		events = append(events, event)
+	}
+
+	return events, ContextErr(readCtx, rows.Err())
+}
+
+func (s *AnalyticsStore) CountDaily(ctx context.Context, urlID int64, from time.Time, to time.Time) ([]model.DailyClicks, error) {
+	var days []model.DailyClicks
+
+	query := `SELECT to_char(date_trunc('day', created_at), 'YYYY-MM-DD'),
+		COUNT(*) FILTER (WHERE NOT is_bot), COUNT(*) FILTER (WHERE is_bot)
+		FROM analytics WHERE url_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY 1 ORDER BY 1`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, urlID, from.UTC(), to.UTC())
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to count clicks - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day model.DailyClicks
+		if err := rows.Scan(&day.Day, &day.Clicks, &day.BotClicks); err != nil {
+			return nil, fmt.Errorf("Error while trying to scan clicks - %w !", err)
+		}
+		days = append(days, day)
+	}
+
+	return days, ContextErr(readCtx, rows.Err())
+}
+
+func (s *AnalyticsStore) Oldest(ctx context.Context) (time.Time, error) {
+	var oldest sql.NullTime
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, `SELECT MIN(created_at) FROM analytics`).Scan(&oldest)
+	if rowErr != nil {
+		return time.Time{}, fmt.Errorf("Error while trying to find the oldest click - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return oldest.Time, nil
+}
+
+func (s *AnalyticsStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `DELETE FROM analytics WHERE id IN (SELECT id FROM analytics WHERE created_at < $1 ORDER BY created_at LIMIT $2)`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(writeCtx, query, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Error while trying to purge clicks - %w !", ContextErr(writeCtx, err))
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *AnalyticsStore) ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]model.ClickClient, error) {
+	var clients []model.ClickClient
+
+	query := `SELECT a.id, u.user_id, a.ip_address, a.user_agent FROM analytics a JOIN url u ON u.id = a.url_id
+		WHERE a.id > $1 AND a.created_at < $2 ORDER BY a.id LIMIT $3`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, afterID, before.UTC(), limit)
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to list click clients - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var client model.ClickClient
+		if err := rows.Scan(&client.ID, &client.UserID, &client.IPAddress, &client.UserAgent); err != nil {
+			return nil, fmt.Errorf("Error while trying to scan click clients - %w !", err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, ContextErr(readCtx, rows.Err())
+}
+
+func (s *AnalyticsStore) UpdateClients(ctx context.Context, clients []model.ClickClient) error {
+	query := `UPDATE analytics SET ip_address = $1, user_agent = $2 WHERE id = $3`
+
+	logStr := fmt.Sprintf("Anonymize %d clicks in DB : %s, Timestamp: %s", len(clients), query, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	stmt, prepareErr := tx.PrepareContext(writeCtx, query)
+	if prepareErr != nil {
+		return fmt.Errorf("Error while trying to anonymize clicks - %w !", ContextErr(writeCtx, prepareErr))
+	}
+	defer stmt.Close()
+
+	for _, client := range clients {
+		if _, err := stmt.ExecContext(writeCtx, client.IPAddress, client.UserAgent, client.ID); err != nil {
+			return fmt.Errorf("Error while trying to anonymize clicks - %w !", ContextErr(writeCtx, err))
+		}
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type AuditStore struct {
+	db *sql.DB
+}
+
+func (s *AuditStore) Save(ctx context.Context, event *model.AuditEvent) error {
+	query := `INSERT INTO audit_events (user_id, actor_id, action, target_type, target_id, ip_address, user_agent, changes, details, created_at)
+		VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+
+	changes, changesErr := nullableJSON(event.Changes)
+	if changesErr != nil {
+		return changesErr
+	}
+	details, detailsErr := nullableJSON(event.Details)
+	if detailsErr != nil {
+		return detailsErr
+	}
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, event.UserID, event.ActorID, event.Action, event.TargetType, event.TargetID,
+		event.IPAddress, event.UserAgent, changes, details, event.CreatedAt).Scan(&event.ID)
+	if rowErr != nil {
+		return fmt.Errorf("Error while trying to save audit event - %w !", ContextErr(writeCtx, rowErr))
+	}
+
+	return nil
+}
+
+// Purge marks its transaction for the append-only trigger, which rejects
+// deletes otherwise
+func (s *AuditStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `DELETE FROM audit_events WHERE id IN (SELECT id FROM audit_events WHERE created_at < $1 ORDER BY id LIMIT $2)`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return 0, ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(writeCtx, `SET LOCAL audit_events.maintenance = 'on'`); err != nil {
+		return 0, fmt.Errorf("Error while trying to purge audit events - %w !", ContextErr(writeCtx, err))
+	}
+
+	result, err := tx.ExecContext(writeCtx, query, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Error while trying to purge audit events - %w !", ContextErr(writeCtx, err))
+	}
+	purged, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return 0, rowsErr
+	}
+
+	return purged, ContextErr(writeCtx, tx.Commit())
+}
+
+func (s *AuditStore) ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]model.ClickClient, error) {
+	var clients []model.ClickClient
+
+	query := `SELECT id, COALESCE(user_id, 0), ip_address, user_agent FROM audit_events
+		WHERE id > $1 AND created_at < $2 ORDER BY id LIMIT $3`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, afterID, before.UTC(), limit)
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to list audit event clients - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var client model.ClickClient
+		if err := rows.Scan(&client.ID, &client.UserID, &client.IPAddress, &client.UserAgent); err != nil {
+			return nil, fmt.Errorf("Error while trying to scan audit event clients - %w !", err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, ContextErr(readCtx, rows.Err())
+}
+
+// UpdateClients marks its transaction for the append-only trigger like Purge
+func (s *AuditStore) UpdateClients(ctx context.Context, clients []model.ClickClient) error {
+	query := `UPDATE audit_events SET ip_address = $1, user_agent = $2 WHERE id = $3`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(writeCtx, `SET LOCAL audit_events.maintenance = 'on'`); err != nil {
+		return fmt.Errorf("Error while trying to anonymize audit events - %w !", ContextErr(writeCtx, err))
+	}
+
+	stmt, prepareErr := tx.PrepareContext(writeCtx, query)
+	if prepareErr != nil {
+		return fmt.Errorf("Error while trying to anonymize audit events - %w !", ContextErr(writeCtx, prepareErr))
+	}
+	defer stmt.Close()
+
+	for _, client := range clients {
+		if _, err := stmt.ExecContext(writeCtx, client.IPAddress, client.UserAgent, client.ID); err != nil {
+			return fmt.Errorf("Error while trying to anonymize audit events - %w !", ContextErr(writeCtx, err))
+		}
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
+
+func (s *AuditStore) Search(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	var events []model.AuditEvent
+
+	var args []interface{}
+	var conditions []string
+	addCondition := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != 0 {
+		addCondition("user_id=$%d", filter.UserID)
+	}
+	if filter.ActorID != 0 {
+		addCondition("actor_id=$%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action=$%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type=$%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id=$%d", filter.TargetID)
+	}
+	if filter.IPAddress != "" {
+		addCondition("ip_address=$%d", filter.IPAddress)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at>=$%d", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at<$%d", filter.Until.UTC())
+	}
+
+	query := `SELECT id, COALESCE(user_id, 0), COALESCE(actor_id, 0), action, target_type, target_id, ip_address, user_agent, changes, details, created_at FROM audit_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(readCtx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search audit events: %w", ContextErr(readCtx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event model.AuditEvent
+		var changes, details []byte
+		if err := rows.Scan(&event.ID, &event.UserID, &event.ActorID, &event.Action, &event.TargetType, &event.TargetID,
+			&event.IPAddress, &event.UserAgent, &changes, &details, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", ContextErr(readCtx, err))
+		}
+
+		if err := unmarshalNullable(changes, &event.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode audit changes: %w", err)
+		}
+		if err := unmarshalNullable(details, &event.Details); err != nil {
+			return nil, fmt.Errorf("failed to decode audit details: %w", err)
+		}
+
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit events: %w", ContextErr(readCtx, err))
+	}
+
+	return events, nil
+}
+
+// nullableJSON encodes empty maps as NULL. JSON is passed as text, lib/pq
+// would send []byte in binary format which jsonb does not accept.
+func nullableJSON[M ~map[string]V, V any](m M) (any, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func unmarshalNullable(data []byte, out any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"kgoel085.com/url-shortner/config"
+)
+
+// ReadContext bounds a read query by the configured DB read timeout
+func ReadContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.Config.TIMEOUT.DBRead)
+}
+
+// WriteContext bounds an insert/update by the configured DB write timeout
+func WriteContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.Config.TIMEOUT.DBWrite)
+}
+
+// RedisContext bounds a redis call by the configured redis timeout
+func RedisContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.Config.TIMEOUT.Redis)
+}
+
+// ContextErr makes sure a driver error caused by a cancelled or timed out
+// context can be detected with errors.Is(err, context.DeadlineExceeded).
+// lib/pq reports cancellations as a plain server error, so the context error
+// has to be attached by hand.
+func ContextErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// escapeLike escapes LIKE wildcards so user input only matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
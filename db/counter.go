@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+)
+
+// RedisCounterStore implements model.CounterStore with plain redis keys
+type RedisCounterStore struct {
+	client *redis.Client
+}
+
+func NewRedisCounterStore(client *redis.Client) *RedisCounterStore {
+	return &RedisCounterStore{client: client}
+}
+
+func (s *RedisCounterStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	// EXPIRE NX would need Redis 7, so the TTL is set when the key has none
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(redisCtx, key)
+	pttl := pipe.PTTL(redisCtx, key)
+	if _, err := pipe.Exec(redisCtx); err != nil {
+		return 0, ContextErr(redisCtx, err)
+	}
+
+	if pttl.Val() == -1 { // No expiry, i.e. the window just started
+		if err := s.client.PExpire(redisCtx, key, ttl).Err(); err != nil {
+			return 0, ContextErr(redisCtx, err)
+		}
+	}
+
+	return incr.Val(), nil
+}
+
+func (s *RedisCounterStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	ttl, err := s.client.PTTL(redisCtx, key).Result()
+	if err != nil {
+		return 0, ContextErr(redisCtx, err)
+	}
+	if ttl < 0 { // -2 missing key, -1 no expiry
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (s *RedisCounterStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	return ContextErr(redisCtx, s.client.Set(redisCtx, key, value, ttl).Err())
+}
+
+func (s *RedisCounterStore) Delete(ctx context.Context, keys ...string) error {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	return ContextErr(redisCtx, s.client.Del(redisCtx, keys...).Err())
+}
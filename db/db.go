@@ -9,9 +9,8 @@ import (
 	"kgoel085.com/url-shortner/utils"
 )
 
-var DB *sql.DB
-
-func InitDB() {
+// InitDB connects to Postgres and makes sure all tables exist
+func InitDB() *sql.DB {
 	config := config.Config.DB
 	dbUrl := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%v sslmode=%s",
@@ -23,30 +22,195 @@ func InitDB() {
 		config.SSLMode,
 	)
 
-	var err error
-	DB, err = sql.Open("postgres", dbUrl)
+	conn, err := sql.Open("postgres", dbUrl)
 	if err != nil {
 		errStr := err.Error()
 		println("Error opening database: " + errStr)
 		panic(errStr)
 	}
-	utils.Log.Info("DB PINGED: ", DB.Ping())
+	utils.Log.Info("DB PINGED: ", conn.Ping())
+
+	conn.SetMaxOpenConns(10)
+	conn.SetMaxIdleConns(5)
+
+	createTables(conn)
+	return conn
+}
+
+func createTables(conn *sql.DB) {
+	createUserTable(conn)
+	createOtpTable(conn)
+	hashPlaintextOtps(conn)
+	createUrlTable(conn)
+	createAnalyticsTable(conn)
+	createAnalyticsRollupTables(conn)
+	createRefreshTokenTable(conn)
+	createTotpTables(conn)
+	createIdentityTable(conn)
+	createTombstoneTable(conn)
+	createAuditTable(conn)
+	createAbuseReportTable(conn)
+}
+
+func createAbuseReportTable(conn *sql.DB) {
+	createAbuseReportTable := `
+	CREATE TABLE IF NOT EXISTS abuse_reports (
+		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		url_id BIGINT NOT NULL,
+		reason TEXT NOT NULL,
+		details TEXT NOT NULL DEFAULT '',
+		reporter_email TEXT NOT NULL DEFAULT '',
+		reporter_ip TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'open',
+		created_at TIMESTAMP NOT NULL,
+		resolved_at TIMESTAMP,
+		resolved_by BIGINT,
+		FOREIGN KEY (url_id) REFERENCES url(id)
+	);
+
+	ALTER TABLE abuse_reports ADD COLUMN IF NOT EXISTS reporter_id BIGINT;
+
+	-- Reports of deleted accounts have no address
+	DROP INDEX IF EXISTS abuse_reports_open_reporter_idx;
+	CREATE UNIQUE INDEX IF NOT EXISTS abuse_reports_open_ip_idx ON abuse_reports (url_id, reporter_ip) WHERE status = 'open' AND reporter_ip <> '';
+	CREATE UNIQUE INDEX IF NOT EXISTS abuse_reports_open_user_idx ON abuse_reports (url_id, reporter_id) WHERE status = 'open' AND reporter_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS abuse_reports_status_idx ON abuse_reports (status, id DESC);`
+
+	_, err := conn.Exec(createAbuseReportTable)
+	if err != nil {
+		errStr := fmt.Sprintf("Error creating abuse_reports table: %v", err)
+		utils.Log.Error(errStr)
+		panic(errStr)
+	} else {
+		utils.Log.Info("Table `abuse_reports` created or already exists")
+	}
+}
+
+func createAuditTable(conn *sql.DB) {
+	createAuditTable := `
+	CREATE TABLE IF NOT EXISTS audit_events (
+		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		user_id BIGINT,
+		actor_id BIGINT,
+		action TEXT NOT NULL,
+		target_type TEXT NOT NULL DEFAULT '',
+		target_id TEXT NOT NULL DEFAULT '',
+		ip_address TEXT NOT NULL,
+		user_agent TEXT NOT NULL,
+		changes JSONB,
+		details JSONB,
+		created_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS audit_events_user_id_idx ON audit_events (user_id, id DESC);
+	CREATE INDEX IF NOT EXISTS audit_events_actor_id_idx ON audit_events (actor_id, id DESC);
+	CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
+
+	-- Only the retention purge may delete events, account deletion and the
+	-- anonymize command rewrite them, they mark their transaction
+	CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+	BEGIN
+			IF current_setting('audit_events.maintenance', true) = 'on' THEN
+					IF TG_OP = 'DELETE' THEN
+							RETURN OLD;
+					END IF;
+					RETURN NEW;
+			END IF;
+			RAISE EXCEPTION 'audit_events is append-only';
+	END$$ LANGUAGE plpgsql;
+
+	DO $$
+	BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'audit_events_append_only') THEN
+					CREATE TRIGGER audit_events_append_only BEFORE UPDATE OR DELETE ON audit_events
+					FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+			END IF;
+	END$$;`
+
+	_, err := conn.Exec(createAuditTable)
+	if err != nil {
+		errStr := fmt.Sprintf("Error creating audit_events table: %v", err)
+		utils.Log.Error(errStr)
+		panic(errStr)
+	} else {
+		utils.Log.Info("Table `audit_events` created or already exists")
+	}
+}
+
+func createTombstoneTable(conn *sql.DB) {
+	createTombstoneTable := `
+	CREATE TABLE IF NOT EXISTS user_tombstones (
+		email_hash TEXT PRIMARY KEY,
+		deleted_at TIMESTAMP NOT NULL
+	);`
+
+	_, err := conn.Exec(createTombstoneTable)
+	if err != nil {
+		errStr := fmt.Sprintf("Error creating user_tombstones table: %v", err)
+		utils.Log.Error(errStr)
+		panic(errStr)
+	} else {
+		utils.Log.Info("Table `user_tombstones` created or already exists")
+	}
+}
 
-	DB.SetMaxOpenConns(10)
-	DB.SetMaxIdleConns(5)
+func createIdentityTable(conn *sql.DB) {
+	createIdentityTable := `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		UNIQUE(provider, subject)
+	);`
 
-	createTables()
+	_, err := conn.Exec(createIdentityTable)
+	if err != nil {
+		errStr := fmt.Sprintf("Error creating user_identities table: %v", err)
+		utils.Log.Error(errStr)
+		panic(errStr)
+	} else {
+		utils.Log.Info("Table `user_identities` created or already exists")
+	}
 }
 
-func createTables() {
-	createUserTable()
-	createOtpTable()
-	createUrlTable()
-	createAnalyticsTable()
-	createRefreshTokenTable()
+func createTotpTables(conn *sql.DB) {
+	createTotpTables := `
+	CREATE TABLE IF NOT EXISTS user_totp (
+		user_id BIGINT PRIMARY KEY,
+		secret TEXT,
+		pending_secret TEXT,
+		enabled BOOLEAN NOT NULL DEFAULT FALSE,
+		last_used_step BIGINT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL,
+		enabled_at TIMESTAMP,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS user_recovery_codes (
+		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+		user_id BIGINT NOT NULL,
+		code_hash TEXT NOT NULL,
+		used_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		UNIQUE(user_id, code_hash)
+	);`
+
+	_, err := conn.Exec(createTotpTables)
+	if err != nil {
+		errStr := fmt.Sprintf("Error creating totp tables: %v", err)
+		utils.Log.Error(errStr)
+		panic(errStr)
+	} else {
+		utils.Log.Info("Tables `user_totp`, `user_recovery_codes` created or already exist")
+	}
 }
 
-func createRefreshTokenTable() {
+func createRefreshTokenTable(conn *sql.DB) {
 	createRefreshTokenTable := `
 	CREATE TABLE IF NOT EXISTS refresh_tokens (
 		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
@@ -59,7 +223,7 @@ func createRefreshTokenTable() {
 		UNIQUE(user_id, token)
 	);`
 
-	_, err := DB.Exec(createRefreshTokenTable)
+	_, err := conn.Exec(createRefreshTokenTable)
 	if err != nil {
 		errStr := fmt.Sprintf("Error creating refresh_tokens table: %v", err)
 		utils.Log.Error(errStr)
@@ -69,7 +233,7 @@ func createRefreshTokenTable() {
 	}
 }
 
-func createAnalyticsTable() {
+func createAnalyticsTable(conn *sql.DB) {
 	createAnalyticsTable := `
 	CREATE TABLE IF NOT EXISTS analytics (
 		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
@@ -79,9 +243,18 @@ func createAnalyticsTable() {
 		referrer TEXT,
 		created_at TIMESTAMP NOT NULL,
 		FOREIGN KEY (url_id) REFERENCES url(id)
-	);`
+	);
+
+	ALTER TABLE analytics ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE analytics ADD COLUMN IF NOT EXISTS bot_rule TEXT NOT NULL DEFAULT '';
+	ALTER TABLE analytics ADD COLUMN IF NOT EXISTS referrer_domain TEXT NOT NULL DEFAULT '';
+	ALTER TABLE analytics ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT '';
+	ALTER TABLE analytics ADD COLUMN IF NOT EXISTS device TEXT NOT NULL DEFAULT '';
+	ALTER TABLE analytics ADD COLUMN IF NOT EXISTS visitor_hash TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS analytics_created_at_idx ON analytics (created_at);
+	CREATE INDEX IF NOT EXISTS analytics_url_id_created_at_idx ON analytics (url_id, created_at);`
 
-	_, err := DB.Exec(createAnalyticsTable)
+	_, err := conn.Exec(createAnalyticsTable)
 	if err != nil {
 		errStr := fmt.Sprintf("Error creating analytics table: %v", err)
 		utils.Log.Error(errStr)
@@ -91,7 +264,49 @@ func createAnalyticsTable() {
 	}
 }
 
-func createOtpTable() {
+// createAnalyticsRollupTables creates the hourly and daily rollups of the
+// clicks. Every click is counted once per dimension, see model.Dimension*.
+func createAnalyticsRollupTables(conn *sql.DB) {
+	createRollupTables := `
+	CREATE TABLE IF NOT EXISTS analytics_hourly (
+		url_id BIGINT NOT NULL REFERENCES url(id),
+		bucket TIMESTAMP NOT NULL,
+		dimension TEXT NOT NULL,
+		value TEXT NOT NULL,
+		clicks BIGINT NOT NULL DEFAULT 0,
+		bot_clicks BIGINT NOT NULL DEFAULT 0,
+		unique_visitors BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (url_id, bucket, dimension, value)
+	);
+	CREATE INDEX IF NOT EXISTS analytics_hourly_bucket_idx ON analytics_hourly (bucket);
+
+	CREATE TABLE IF NOT EXISTS analytics_daily (
+		url_id BIGINT NOT NULL REFERENCES url(id),
+		bucket TIMESTAMP NOT NULL,
+		dimension TEXT NOT NULL,
+		value TEXT NOT NULL,
+		clicks BIGINT NOT NULL DEFAULT 0,
+		bot_clicks BIGINT NOT NULL DEFAULT 0,
+		unique_visitors BIGINT NOT NULL DEFAULT 0,
+		PRIMARY KEY (url_id, bucket, dimension, value)
+	);
+
+	CREATE TABLE IF NOT EXISTS analytics_rollup_state (
+		id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+		rolled_up_until TIMESTAMP NOT NULL
+	);`
+
+	_, err := conn.Exec(createRollupTables)
+	if err != nil {
+		errStr := fmt.Sprintf("Error creating analytics rollup tables: %v", err)
+		utils.Log.Error(errStr)
+		panic(errStr)
+	} else {
+		utils.Log.Info("Tables `analytics_hourly`, `analytics_daily` created or already exist")
+	}
+}
+
+func createOtpTable(conn *sql.DB) {
 	createOtpTable := `
 	DO $$
 	BEGIN
@@ -115,9 +330,13 @@ func createOtpTable() {
 		status otp_status NOT NULL DEFAULT 'pending',
 		token UUID NOT NULL DEFAULT gen_random_uuid(),
 		created_at TIMESTAMP NOT NULL
-	);`
+	);
+
+	ALTER TABLE otp ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+	ALTER TABLE otp ADD COLUMN IF NOT EXISTS fingerprint TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS otp_plaintext_idx ON otp (id) WHERE otp NOT LIKE '` + utils.OtpHashPrefix + `%';`
 
-	_, err := DB.Exec(createOtpTable)
+	_, err := conn.Exec(createOtpTable)
 	if err != nil {
 		errStr := fmt.Sprintf("Error creating otp table: %v", err)
 		utils.Log.Error(errStr)
@@ -125,9 +344,19 @@ func createOtpTable() {
 	} else {
 		utils.Log.Info("Table `otp` created or already exists")
 	}
+
+	// New enum values can't be added in the same statement batch they are created in
+	for _, action := range []string{"magic_login", "change_email", "delete_account"} {
+		_, err = conn.Exec(fmt.Sprintf(`ALTER TYPE otp_action_type ADD VALUE IF NOT EXISTS '%s'`, action))
+		if err != nil {
+			errStr := fmt.Sprintf("Error updating otp_action_type: %v", err)
+			utils.Log.Error(errStr)
+			panic(errStr)
+		}
+	}
 }
 
-func createUrlTable() {
+func createUrlTable(conn *sql.DB) {
 	createUrlTable := `
 	DO $$
 	BEGIN
@@ -146,9 +375,18 @@ func createUrlTable() {
 		expiry_at TIMESTAMP,
 		click_count BIGINT NOT NULL DEFAULT 0,
 		FOREIGN KEY (user_id) REFERENCES users(id)
-	);`
+	);
 
-	_, err := DB.Exec(createUrlTable)
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS bot_click_count BIGINT NOT NULL DEFAULT 0;
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS og_title TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS og_description TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS og_image TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS safety_reason TEXT NOT NULL DEFAULT '';
+	ALTER TABLE url ADD COLUMN IF NOT EXISTS scanned_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS url_scanned_at_idx ON url (scanned_at NULLS FIRST);`
+
+	_, err := conn.Exec(createUrlTable)
 	if err != nil {
 		errStr := fmt.Sprintf("Error creating urls table: %v", err)
 		utils.Log.Error(errStr)
@@ -156,17 +394,33 @@ func createUrlTable() {
 	} else {
 		utils.Log.Info("Table `urls` created or already exists")
 	}
+
+	// New enum values can't be added in the same statement batch they are created in
+	for _, status := range []string{"disabled", "quarantined", "blocked"} {
+		_, err = conn.Exec(fmt.Sprintf(`ALTER TYPE url_status ADD VALUE IF NOT EXISTS '%s'`, status))
+		if err != nil {
+			errStr := fmt.Sprintf("Error updating url_status: %v", err)
+			utils.Log.Error(errStr)
+			panic(errStr)
+		}
+	}
 }
 
-func createUserTable() {
+func createUserTable(conn *sql.DB) {
 	createUserTable := `CREATE TABLE IF NOT EXISTS users (
 		id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
 		email TEXT NOT NULL UNIQUE,
 		password TEXT NOT NULL,
 		created_at TIMESTAMP NOT NULL
-	);`
+	);
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS plan TEXT NOT NULL DEFAULT 'free';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active';
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS click_privacy TEXT NOT NULL DEFAULT '';
+	CREATE INDEX IF NOT EXISTS users_email_lower_idx ON users (lower(email));`
 
-	_, err := DB.Exec(createUserTable)
+	_, err := conn.Exec(createUserTable)
 	if err != nil {
 		errStr := fmt.Sprintf("Error creating users table: %v", err)
 		utils.Log.Error(errStr)
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type IdentityStore struct {
+	db *sql.DB
+}
+
+func (s *IdentityStore) GetByProviderSubject(ctx context.Context, provider string, subject string) (model.UserIdentity, error) {
+	var identity model.UserIdentity
+
+	query := `SELECT id, user_id, provider, subject, email, created_at FROM user_identities WHERE provider=$1 AND subject=$2`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, query, provider, subject).Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if rowErr != nil {
+		if rowErr == sql.ErrNoRows {
+			return identity, model.ErrIdentityNotFound
+		}
+		return identity, fmt.Errorf("Error while trying to get identity - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return identity, nil
+}
+
+func (s *IdentityStore) Save(ctx context.Context, identity *model.UserIdentity) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	logStr := fmt.Sprintf("Save identity in DB : UserID: %d, Provider: %s, Timestamp: %s", identity.UserID, identity.Provider, identity.CreatedAt)
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email, identity.CreatedAt).Scan(&identity.ID)
+	if isUniqueViolation(rowErr) {
+		return utils.Conflict("identity_exists", "This identity is already linked to a user")
+	}
+	if rowErr != nil {
+		return fmt.Errorf("Error while trying to save identity - %w !", ContextErr(writeCtx, rowErr))
+	}
+
+	return nil
+}
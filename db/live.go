@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	redis "github.com/redis/go-redis/v9"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// RedisClickStream implements model.ClickStream with Redis pub/sub, one
+// channel per link. The viewers of a link on this process share one
+// subscription, so a link watched in many tabs holds one connection. Clicks
+// nobody watches are dropped by Redis.
+type RedisClickStream struct {
+	client *redis.Client
+
+	mu       sync.RWMutex
+	nextID   int
+	channels map[int64]*liveSubscription
+}
+
+// liveSubscription is the subscription to the channel of one link and the
+// viewers it delivers to
+type liveSubscription struct {
+	pubsub  *redis.PubSub
+	viewers map[int]func(model.LiveClick)
+}
+
+func NewRedisClickStream(client *redis.Client) *RedisClickStream {
+	return &RedisClickStream{client: client, channels: map[int64]*liveSubscription{}}
+}
+
+func liveChannel(urlID int64) string {
+	return fmt.Sprintf("clicks:live:%d", urlID)
+}
+
+func (s *RedisClickStream) Publish(ctx context.Context, click model.LiveClick) error {
+	payload, marshalErr := json.Marshal(click)
+	if marshalErr != nil {
+		return marshalErr
+	}
+
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	if err := s.client.Publish(redisCtx, liveChannel(click.UrlID), payload).Err(); err != nil {
+		return fmt.Errorf("Error while trying to publish click - %w !", ContextErr(redisCtx, err))
+	}
+	return nil
+}
+
+func (s *RedisClickStream) Subscribe(ctx context.Context, urlID int64, deliver func(model.LiveClick)) (func() error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shared := s.channels[urlID]
+	if shared == nil {
+		pubsub, err := s.subscribe(ctx, urlID)
+		if err != nil {
+			return nil, err
+		}
+		shared = &liveSubscription{pubsub: pubsub, viewers: map[int]func(model.LiveClick){}}
+		s.channels[urlID] = shared
+		go s.forward(urlID, shared)
+	}
+
+	s.nextID++
+	id := s.nextID
+	shared.viewers[id] = deliver
+
+	return func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(shared.viewers, id)
+		if len(shared.viewers) > 0 || s.channels[urlID] != shared {
+			return nil
+		}
+		// The last viewer is gone
+		delete(s.channels, urlID)
+		return shared.pubsub.Close()
+	}, nil
+}
+
+func (s *RedisClickStream) subscribe(ctx context.Context, urlID int64) (*redis.PubSub, error) {
+	// The subscription outlives the call, only confirming it has a deadline
+	pubsub := s.client.Subscribe(context.WithoutCancel(ctx), liveChannel(urlID))
+
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	if _, err := pubsub.Receive(redisCtx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("Error while trying to subscribe to clicks - %w !", ContextErr(redisCtx, err))
+	}
+	return pubsub, nil
+}
+
+// forward delivers the clicks of the channel to its viewers until the last
+// one unsubscribes and closes it
+func (s *RedisClickStream) forward(urlID int64, shared *liveSubscription) {
+	for message := range shared.pubsub.Channel() {
+		var click model.LiveClick
+		if err := json.Unmarshal([]byte(message.Payload), &click); err != nil {
+			utils.Log.Error("Invalid live click: ", err)
+			continue
+		}
+		click.UrlID = urlID
+
+		s.mu.RLock()
+		for _, deliver := range shared.viewers {
+			deliver(click)
+		}
+		s.mu.RUnlock()
+	}
+}
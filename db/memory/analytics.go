@@ -0,0 +1,178 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type AnalyticsStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	events []model.Analytics
+	urls   *UrlStore
+}
+
+// NewAnalyticsStore keeps click counts of the given url store in sync
+func NewAnalyticsStore(urls *UrlStore) *AnalyticsStore {
+	return &AnalyticsStore{urls: urls}
+}
+
+func (s *AnalyticsStore) Save(ctx context.Context, a *model.Analytics) error {
+	s.mu.Lock()
+	s.nextID++
+	a.ID = s.nextID
+	a.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	s.events = append(s.events, *a)
+	s.mu.Unlock()
+
+	s.urls.incrementClicks(a.UrlID, a.Bot)
+	return nil
+}
+
+func (s *AnalyticsStore) ListByUser(ctx context.Context, userID int64) ([]model.Analytics, error) {
+	urlIDs := s.urls.idsByUser(userID)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []model.Analytics
+	for _, event := range s.events {
+		if urlIDs[event.UrlID] {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (s *AnalyticsStore) CountDaily(ctx context.Context, urlID int64, from time.Time, to time.Time) ([]model.DailyClicks, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byDay := map[string]*model.DailyClicks{}
+	var days []string
+	for _, event := range s.events {
+		createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err != nil || event.UrlID != urlID || createdAt.Before(from) || !createdAt.Before(to) {
+			continue
+		}
+
+		day := model.VisitorDay(createdAt)
+		if byDay[day] == nil {
+			byDay[day] = &model.DailyClicks{Day: day}
+			days = append(days, day)
+		}
+		if event.Bot {
+			byDay[day].BotClicks++
+		} else {
+			byDay[day].Clicks++
+		}
+	}
+
+	sort.Strings(days)
+	counts := make([]model.DailyClicks, 0, len(days))
+	for _, day := range days {
+		counts = append(counts, *byDay[day])
+	}
+	return counts, nil
+}
+
+func (s *AnalyticsStore) Oldest(ctx context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var oldest time.Time
+	for _, event := range s.events {
+		createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err == nil && (oldest.IsZero() || createdAt.Before(oldest)) {
+			oldest = createdAt
+		}
+	}
+	return oldest, nil
+}
+
+func (s *AnalyticsStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	kept := s.events[:0]
+	for _, event := range s.events {
+		createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err == nil && createdAt.Before(before) && purged < int64(limit) {
+			purged++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	s.events = kept
+	return purged, nil
+}
+
+func (s *AnalyticsStore) ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]model.ClickClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []model.ClickClient
+	for _, event := range s.events { // Kept by ID
+		createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err != nil || event.ID <= afterID || !createdAt.Before(before) {
+			continue
+		}
+		clients = append(clients, model.ClickClient{ID: event.ID, UserID: s.urls.ownerOf(event.UrlID), IPAddress: event.IPAddress, UserAgent: event.UserAgent})
+		if len(clients) == limit {
+			break
+		}
+	}
+	return clients, nil
+}
+
+func (s *AnalyticsStore) UpdateClients(ctx context.Context, clients []model.ClickClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byID := make(map[int64]model.ClickClient, len(clients))
+	for _, client := range clients {
+		byID[client.ID] = client
+	}
+	for i, event := range s.events {
+		if client, ok := byID[event.ID]; ok {
+			s.events[i].IPAddress = client.IPAddress
+			s.events[i].UserAgent = client.UserAgent
+		}
+	}
+	return nil
+}
+
+// between returns the clicks from from up to before to
+func (s *AnalyticsStore) between(from time.Time, to time.Time) []model.Analytics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []model.Analytics
+	for _, event := range s.events {
+		createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err == nil && !createdAt.Before(from) && createdAt.Before(to) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (s *AnalyticsStore) deleteUser(user model.User) {
+	urlIDs := s.urls.idsByUser(user.ID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.events[:0]
+	for _, event := range s.events {
+		if !urlIDs[event.UrlID] {
+			kept = append(kept, event)
+		}
+	}
+	s.events = kept
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type AuditStore struct {
+	mu     sync.RWMutex
+	events []model.AuditEvent
+	nextID int64
+}
+
+func NewAuditStore() *AuditStore {
+	return &AuditStore{}
+}
+
+func (s *AuditStore) Save(ctx context.Context, event *model.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	event.ID = s.nextID
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func (s *AuditStore) Purge(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	kept := s.events[:0]
+	for _, event := range s.events {
+		if event.CreatedAt.Before(before) && purged < int64(limit) {
+			purged++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	s.events = kept
+	return purged, nil
+}
+
+func (s *AuditStore) ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]model.ClickClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []model.ClickClient
+	for _, event := range s.events { // Kept by ID
+		if event.ID <= afterID || !event.CreatedAt.Before(before) {
+			continue
+		}
+		clients = append(clients, model.ClickClient{ID: event.ID, UserID: event.UserID, IPAddress: event.IPAddress, UserAgent: event.UserAgent})
+		if len(clients) == limit {
+			break
+		}
+	}
+	return clients, nil
+}
+
+func (s *AuditStore) UpdateClients(ctx context.Context, clients []model.ClickClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byID := make(map[int64]model.ClickClient, len(clients))
+	for _, client := range clients {
+		byID[client.ID] = client
+	}
+	for i, event := range s.events {
+		if client, ok := byID[event.ID]; ok {
+			s.events[i].IPAddress = client.IPAddress
+			s.events[i].UserAgent = client.UserAgent
+		}
+	}
+	return nil
+}
+
+func (s *AuditStore) deleteUser(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, event := range s.events {
+		if event.UserID != user.ID && event.ActorID != user.ID {
+			continue
+		}
+		event.IPAddress, event.UserAgent = "", ""
+		event.Changes = withoutKey(event.Changes, "email")
+		event.Details = withoutKey(event.Details, "email")
+		s.events[i] = event
+	}
+}
+
+// withoutKey returns a copy of m without the key, events share their maps
+// with callers
+func withoutKey[V any](m map[string]V, key string) map[string]V {
+	if _, ok := m[key]; !ok {
+		return m
+	}
+	copied := maps.Clone(m)
+	delete(copied, key)
+	return copied
+}
+
+func (s *AuditStore) Search(ctx context.Context, filter model.AuditFilter) ([]model.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []model.AuditEvent
+	for i := len(s.events) - 1; i >= 0; i-- { // Newest first
+		event := s.events[i]
+		if filter.UserID != 0 && event.UserID != filter.UserID {
+			continue
+		}
+		if filter.ActorID != 0 && event.ActorID != filter.ActorID {
+			continue
+		}
+		if filter.Action != "" && event.Action != filter.Action {
+			continue
+		}
+		if filter.TargetType != "" && event.TargetType != filter.TargetType {
+			continue
+		}
+		if filter.TargetID != "" && event.TargetID != filter.TargetID {
+			continue
+		}
+		if filter.IPAddress != "" && event.IPAddress != filter.IPAddress {
+			continue
+		}
+		if !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until) {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return paginate(events, filter.Pagination), nil
+}
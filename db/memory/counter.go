@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type counter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+type CounterStore struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	now      func() time.Time
+}
+
+func NewCounterStore() *CounterStore {
+	return &CounterStore{counters: make(map[string]*counter), now: time.Now}
+}
+
+// get returns the live counter for key, dropping it when expired
+func (s *CounterStore) get(key string) *counter {
+	c, ok := s.counters[key]
+	if !ok {
+		return nil
+	}
+	if !c.expiresAt.IsZero() && !s.now().Before(c.expiresAt) {
+		delete(s.counters, key)
+		return nil
+	}
+	return c
+}
+
+func (s *CounterStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.get(key)
+	if c == nil {
+		c = &counter{}
+		if ttl > 0 {
+			c.expiresAt = s.now().Add(ttl)
+		}
+		s.counters[key] = c
+	}
+
+	c.value++
+	return c.value, nil
+}
+
+func (s *CounterStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.get(key)
+	if c == nil || c.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return c.expiresAt.Sub(s.now()), nil
+}
+
+func (s *CounterStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &counter{value: value}
+	if ttl > 0 {
+		c.expiresAt = s.now().Add(ttl)
+	}
+	s.counters[key] = c
+	return nil
+}
+
+func (s *CounterStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.counters, key)
+	}
+	return nil
+}
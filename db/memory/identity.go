@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type IdentityStore struct {
+	mu         sync.Mutex
+	nextID     int64
+	identities map[string]model.UserIdentity
+}
+
+func NewIdentityStore() *IdentityStore {
+	return &IdentityStore{identities: make(map[string]model.UserIdentity)}
+}
+
+func identityKey(provider string, subject string) string {
+	return provider + "|" + subject
+}
+
+func (s *IdentityStore) GetByProviderSubject(ctx context.Context, provider string, subject string) (model.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if identity, ok := s.identities[identityKey(provider, subject)]; ok {
+		return identity, nil
+	}
+	return model.UserIdentity{}, model.ErrIdentityNotFound
+}
+
+func (s *IdentityStore) Save(ctx context.Context, identity *model.UserIdentity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := identityKey(identity.Provider, identity.Subject)
+	if _, ok := s.identities[key]; ok {
+		return utils.Conflict("identity_exists", "This identity is already linked to a user")
+	}
+
+	s.nextID++
+	identity.ID = s.nextID
+	s.identities[key] = *identity
+	return nil
+}
+
+func (s *IdentityStore) deleteUser(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, identity := range s.identities {
+		if identity.UserID == user.ID {
+			delete(s.identities, key)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+// ClickStream delivers clicks to the viewers of this process
+type ClickStream struct {
+	mu      sync.RWMutex
+	nextID  int
+	viewers map[int64]map[int]func(model.LiveClick)
+}
+
+func NewClickStream() *ClickStream {
+	return &ClickStream{viewers: map[int64]map[int]func(model.LiveClick){}}
+}
+
+func (s *ClickStream) Publish(ctx context.Context, click model.LiveClick) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, deliver := range s.viewers[click.UrlID] {
+		deliver(click)
+	}
+	return nil
+}
+
+func (s *ClickStream) Subscribe(ctx context.Context, urlID int64, deliver func(model.LiveClick)) (func() error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	id := s.nextID
+	if s.viewers[urlID] == nil {
+		s.viewers[urlID] = map[int]func(model.LiveClick){}
+	}
+	s.viewers[urlID][id] = deliver
+
+	return func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.viewers[urlID], id)
+		if len(s.viewers[urlID]) == 0 {
+			delete(s.viewers, urlID)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type OtpStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	otps   map[int64]*model.Otp
+}
+
+func NewOtpStore() *OtpStore {
+	return &OtpStore{otps: make(map[int64]*model.Otp)}
+}
+
+func (s *OtpStore) GetPendingByToken(ctx context.Context, token string, action string) (model.Otp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, otp := range s.otps {
+		if otp.Token == token && string(otp.Action) == action && otp.Status == model.OtpStatusPending {
+			return *otp, nil
+		}
+	}
+	return model.Otp{}, model.ErrOtpNotFound
+}
+
+func (s *OtpStore) GetLatestPending(ctx context.Context, key string, otpType model.OtpType, action model.OtpActionType) (model.Otp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var latest *model.Otp
+	for _, otp := range s.otps {
+		if otp.Key != key || otp.Type != otpType || otp.Action != action || otp.Status != model.OtpStatusPending {
+			continue
+		}
+		if latest == nil || otp.CreatedAt.After(latest.CreatedAt) {
+			latest = otp
+		}
+	}
+
+	if latest == nil {
+		return model.Otp{}, model.ErrOtpNotFound
+	}
+	return *latest, nil
+}
+
+func (s *OtpStore) ReserveAttempt(ctx context.Context, id int64, maxAttempts int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otp, ok := s.otps[id]
+	if !ok || otp.Status != model.OtpStatusPending || otp.Attempts >= maxAttempts {
+		return 0, nil
+	}
+
+	otp.Attempts++
+	return otp.Attempts, nil
+}
+
+func (s *OtpStore) Save(ctx context.Context, otp *model.Otp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	otp.ID = s.nextID
+	otp.Token = newUUID()
+
+	stored := *otp
+	stored.OtpCode = "" // Only the hash is kept, like in Postgres
+	s.otps[otp.ID] = &stored
+	return nil
+}
+
+func (s *OtpStore) Consume(ctx context.Context, id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	otp, ok := s.otps[id]
+	if !ok || otp.Status != model.OtpStatusPending {
+		return false, nil
+	}
+
+	otp.Status = model.OtpStatusSuccess
+	return true, nil
+}
+
+func (s *OtpStore) UpdateStatus(ctx context.Context, id int64, status model.OtpStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if otp, ok := s.otps[id]; ok {
+		otp.Status = status
+	}
+	return nil
+}
+
+func (s *OtpStore) deleteUser(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, otp := range s.otps {
+		if strings.EqualFold(otp.Key, user.Email) {
+			delete(s.otps, id)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+// RateLimiter is a single-process GCRA limiter with the same semantics as
+// the redis_rate backed limiter
+type RateLimiter struct {
+	mu  sync.Mutex
+	tat map[string]time.Time // Theoretical arrival time per key
+	now func() time.Time
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{tat: make(map[string]time.Time), now: time.Now}
+}
+
+func (r *RateLimiter) Allow(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitResult, error) {
+	return r.AllowN(ctx, key, limit, 1)
+}
+
+func (r *RateLimiter) AllowN(ctx context.Context, key string, limit model.RateLimit, n int) (model.RateLimitResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := model.RateLimitResult{Limit: limit}
+	if limit.Rate <= 0 || limit.Period <= 0 { // No limit configured
+		result.Allowed = true
+		return result, nil
+	}
+
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+
+	now := r.now()
+	interval := limit.Period / time.Duration(limit.Rate)
+	tolerance := interval * time.Duration(burst)
+
+	tat := r.tat[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(interval * time.Duration(n))
+	allowAt := newTat.Add(-tolerance)
+
+	diff := now.Sub(allowAt)
+	if diff < 0 {
+		result.RetryAfter = -diff
+		result.ResetAfter = tat.Sub(now)
+		return result, nil
+	}
+
+	r.tat[key] = newTat
+	result.Remaining = int(diff / interval)
+	result.Allowed = true
+	result.ResetAfter = newTat.Sub(now)
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type RefreshTokenStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	tokens map[int64]*model.UserRefreshToken
+}
+
+func NewRefreshTokenStore() *RefreshTokenStore {
+	return &RefreshTokenStore{tokens: make(map[int64]*model.UserRefreshToken)}
+}
+
+func (s *RefreshTokenStore) GetByToken(ctx context.Context, token string) (model.UserRefreshToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, refreshToken := range s.tokens {
+		if refreshToken.Token == token {
+			return *refreshToken, nil
+		}
+	}
+	return model.UserRefreshToken{}, model.ErrRefreshTokenNotFound
+}
+
+func (s *RefreshTokenStore) MarkAllUsed(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, refreshToken := range s.tokens {
+		if refreshToken.UserID == userID {
+			refreshToken.IsUsed = true
+		}
+	}
+	return nil
+}
+
+func (s *RefreshTokenStore) Save(ctx context.Context, token *model.UserRefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	token.ID = s.nextID
+
+	stored := *token
+	s.tokens[token.ID] = &stored
+	return nil
+}
+
+func (s *RefreshTokenStore) deleteUser(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.UserID == user.ID {
+			delete(s.tokens, id)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type AbuseReportStore struct {
+	mu      sync.RWMutex
+	nextID  int64
+	reports []model.AbuseReport
+	urls    *UrlStore
+}
+
+// NewAbuseReportStore removes reports together with the links of the given
+// url store
+func NewAbuseReportStore(urls *UrlStore) *AbuseReportStore {
+	return &AbuseReportStore{urls: urls}
+}
+
+func (s *AbuseReportStore) Save(ctx context.Context, report *model.AbuseReport) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.reports {
+		if existing.UrlID != report.UrlID || existing.Status != model.AbuseReportStatusOpen {
+			continue
+		}
+		if (existing.ReporterIP != "" && existing.ReporterIP == report.ReporterIP) || (report.ReporterID != 0 && existing.ReporterID == report.ReporterID) {
+			return model.ErrAbuseReportExists
+		}
+	}
+
+	s.nextID++
+	report.ID = s.nextID
+	s.reports = append(s.reports, *report)
+	return nil
+}
+
+func (s *AbuseReportStore) GetByID(ctx context.Context, id int64) (model.AbuseReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, report := range s.reports {
+		if report.ID == id {
+			return report, nil
+		}
+	}
+	return model.AbuseReport{}, model.ErrAbuseReportNotFound
+}
+
+func (s *AbuseReportStore) Search(ctx context.Context, filter model.AbuseReportFilter) ([]model.AbuseReport, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var reports []model.AbuseReport
+	for i := len(s.reports) - 1; i >= 0; i-- { // Newest first
+		report := s.reports[i]
+		if filter.Status != "" && report.Status != filter.Status {
+			continue
+		}
+		if filter.Code != "" && report.Code != filter.Code {
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	return paginate(reports, filter.Pagination), nil
+}
+
+func (s *AbuseReportStore) CountVerifiedReporters(ctx context.Context, urlID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reporters := map[int64]bool{}
+	for _, report := range s.reports {
+		if report.UrlID == urlID && report.Status == model.AbuseReportStatusOpen && report.ReporterID != 0 {
+			reporters[report.ReporterID] = true
+		}
+	}
+	return len(reporters), nil
+}
+
+func (s *AbuseReportStore) Resolve(ctx context.Context, urlID int64, status model.AbuseReportStatus, resolvedBy int64, resolvedAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var resolved int64
+	for i := range s.reports {
+		report := &s.reports[i]
+		if report.UrlID != urlID || report.Status != model.AbuseReportStatusOpen {
+			continue
+		}
+		report.Status = status
+		report.ResolvedBy = resolvedBy
+		report.ResolvedAt = resolvedAt
+		resolved++
+	}
+	return resolved, nil
+}
+
+func (s *AbuseReportStore) ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]model.ClickClient, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []model.ClickClient
+	for _, report := range s.reports { // Kept by ID
+		if report.ID <= afterID || !report.CreatedAt.Before(before) || report.Status == model.AbuseReportStatusOpen {
+			continue
+		}
+		clients = append(clients, model.ClickClient{ID: report.ID, UserID: report.ReporterID, IPAddress: report.ReporterIP})
+		if len(clients) == limit {
+			break
+		}
+	}
+	return clients, nil
+}
+
+func (s *AbuseReportStore) UpdateClients(ctx context.Context, clients []model.ClickClient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byID := make(map[int64]model.ClickClient, len(clients))
+	for _, client := range clients {
+		byID[client.ID] = client
+	}
+	for i, report := range s.reports {
+		if client, ok := byID[report.ID]; ok && report.Status != model.AbuseReportStatusOpen {
+			s.reports[i].ReporterIP = client.IPAddress
+		}
+	}
+	return nil
+}
+
+func (s *AbuseReportStore) deleteUser(user model.User) {
+	urlIDs := s.urls.idsByUser(user.ID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.reports[:0]
+	for _, report := range s.reports {
+		if urlIDs[report.UrlID] {
+			continue
+		}
+		if report.ReporterID == user.ID || strings.EqualFold(report.ReporterEmail, user.Email) {
+			report.ReporterIP, report.ReporterEmail, report.ReporterID = "", "", 0
+		}
+		kept = append(kept, report)
+	}
+	s.reports = kept
+}
@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type rollupKey struct {
+	urlID     int64
+	bucket    time.Time
+	dimension string
+	value     string
+}
+
+type RollupStore struct {
+	mu        sync.RWMutex
+	watermark time.Time
+	buckets   map[model.RollupInterval]map[rollupKey]model.AnalyticsBucket
+	analytics *AnalyticsStore
+}
+
+// NewRollupStore rolls up the clicks of the given analytics store
+func NewRollupStore(analytics *AnalyticsStore) *RollupStore {
+	return &RollupStore{
+		buckets: map[model.RollupInterval]map[rollupKey]model.AnalyticsBucket{
+			model.RollupHour: {},
+			model.RollupDay:  {},
+		},
+		analytics: analytics,
+	}
+}
+
+func truncateTo(t time.Time, interval model.RollupInterval) time.Time {
+	if interval == model.RollupHour {
+		return t.UTC().Truncate(time.Hour)
+	}
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func (s *RollupStore) Rollup(ctx context.Context, interval model.RollupInterval, from time.Time, to time.Time) error {
+	buckets, ok := s.buckets[interval]
+	if !ok {
+		return fmt.Errorf("unknown rollup interval %q", interval)
+	}
+
+	computed := map[rollupKey]*model.AnalyticsBucket{}
+	visitors := map[rollupKey]map[string]bool{}
+	for _, event := range s.analytics.between(from, to) {
+		createdAt, _ := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		values := map[string]string{
+			model.DimensionTotal:    "",
+			model.DimensionReferrer: event.ReferrerDomain,
+			model.DimensionCountry:  event.Country,
+			model.DimensionDevice:   event.Device,
+		}
+
+		for dimension, value := range values {
+			key := rollupKey{urlID: event.UrlID, bucket: truncateTo(createdAt, interval), dimension: dimension, value: value}
+			if computed[key] == nil {
+				computed[key] = &model.AnalyticsBucket{UrlID: key.urlID, Bucket: key.bucket, Dimension: dimension, Value: value}
+				visitors[key] = map[string]bool{}
+			}
+			if event.Bot {
+				computed[key].BotClicks++
+				continue
+			}
+			computed[key].Clicks++
+			if event.VisitorHash != "" {
+				visitors[key][event.VisitorHash] = true
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, bucket := range computed {
+		bucket.UniqueVisitors = int64(len(visitors[key]))
+		buckets[key] = *bucket
+	}
+	return nil
+}
+
+func (s *RollupStore) Watermark(ctx context.Context) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.watermark, nil
+}
+
+func (s *RollupStore) SetWatermark(ctx context.Context, watermark time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watermark = watermark.UTC()
+	return nil
+}
+
+func (s *RollupStore) Series(ctx context.Context, urlID int64, interval model.RollupInterval, from time.Time, to time.Time) ([]model.AnalyticsBucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var series []model.AnalyticsBucket
+	for key, bucket := range s.buckets[interval] {
+		if key.urlID == urlID && key.dimension == model.DimensionTotal && !key.bucket.Before(from) && key.bucket.Before(to) {
+			series = append(series, bucket)
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool { return series[i].Bucket.Before(series[j].Bucket) })
+	return series, nil
+}
+
+func (s *RollupStore) Breakdown(ctx context.Context, urlID int64, dimension string, from time.Time, to time.Time, limit int) ([]model.AnalyticsBucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	byValue := map[string]*model.AnalyticsBucket{}
+	for key, bucket := range s.buckets[model.RollupDay] {
+		if key.urlID != urlID || key.dimension != dimension || key.bucket.Before(from) || !key.bucket.Before(to) {
+			continue
+		}
+		if byValue[key.value] == nil {
+			byValue[key.value] = &model.AnalyticsBucket{UrlID: urlID, Dimension: dimension, Value: key.value}
+		}
+		byValue[key.value].Clicks += bucket.Clicks
+		byValue[key.value].BotClicks += bucket.BotClicks
+	}
+
+	breakdown := make([]model.AnalyticsBucket, 0, len(byValue))
+	for _, bucket := range byValue {
+		breakdown = append(breakdown, *bucket)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Clicks != breakdown[j].Clicks {
+			return breakdown[i].Clicks > breakdown[j].Clicks
+		}
+		if breakdown[i].BotClicks != breakdown[j].BotClicks {
+			return breakdown[i].BotClicks > breakdown[j].BotClicks
+		}
+		return breakdown[i].Value < breakdown[j].Value
+	})
+	return breakdown[:min(limit, len(breakdown))], nil
+}
+
+func (s *RollupStore) Purge(ctx context.Context, interval model.RollupInterval, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for key := range s.buckets[interval] {
+		if purged >= int64(limit) {
+			break
+		}
+		if key.bucket.Before(before) {
+			delete(s.buckets[interval], key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (s *RollupStore) deleteUser(user model.User) {
+	urlIDs := s.analytics.urls.idsByUser(user.ID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, buckets := range s.buckets {
+		for key := range buckets {
+			if urlIDs[key.urlID] {
+				delete(buckets, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+// StatsStore aggregates over the other in-memory stores
+type StatsStore struct {
+	users     *UserStore
+	urls      *UrlStore
+	analytics *AnalyticsStore
+}
+
+func NewStatsStore(users *UserStore, urls *UrlStore, analytics *AnalyticsStore) *StatsStore {
+	return &StatsStore{users: users, urls: urls, analytics: analytics}
+}
+
+func (s *StatsStore) System(ctx context.Context, since time.Time) (model.SystemStats, error) {
+	stats := model.SystemStats{
+		UsersByRole:   map[model.UserRole]int64{},
+		UsersByStatus: map[model.UserStatus]int64{},
+		UrlsByStatus:  map[model.UrlStatus]int64{},
+		GeneratedAt:   time.Now().UTC(),
+	}
+
+	for _, user := range s.users.all() {
+		stats.Users++
+		stats.UsersByRole[user.Role]++
+		stats.UsersByStatus[user.Status]++
+		if !user.CreatedAt.Before(since) {
+			stats.NewUsers24h++
+		}
+	}
+
+	for _, url := range s.urls.all() {
+		stats.Urls++
+		stats.UrlsByStatus[url.Status]++
+		stats.Clicks += url.ClickCount
+		stats.BotClicks += url.BotClickCount
+		if !url.CreatedAt.Before(since) {
+			stats.NewUrls24h++
+		}
+	}
+
+	s.analytics.mu.RLock()
+	defer s.analytics.mu.RUnlock()
+	for _, event := range s.analytics.events {
+		createdAt, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err != nil || createdAt.Before(since) {
+			continue
+		}
+		if event.Bot {
+			stats.BotClicks24h++
+		} else {
+			stats.Clicks24h++
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,63 @@
+// Package memory provides in-memory implementations of every model store.
+// It needs no external services, which makes it suitable for local
+// development and hermetic tests. Data is lost when the process exits.
+package memory
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+// NewStore wires the in-memory implementations of every store
+func NewStore() *model.Store {
+	urls := NewUrlStore()
+	users := NewUserStore()
+	analytics := NewAnalyticsStore(urls)
+	otps := NewOtpStore()
+	refreshTokens := NewRefreshTokenStore()
+	totps := NewTotpStore()
+	identities := NewIdentityStore()
+	reports := NewAbuseReportStore(urls)
+	rollups := NewRollupStore(analytics)
+	audits := NewAuditStore()
+
+	// Clicks, rollups and reports go before links, they are found through them
+	users.cascade(analytics, rollups, reports, urls, otps, refreshTokens, totps, identities, audits)
+
+	return &model.Store{
+		Urls:          urls,
+		Users:         users,
+		Otps:          otps,
+		Analytics:     analytics,
+		RefreshTokens: refreshTokens,
+		RateLimiter:   NewRateLimiter(),
+		Counters:      NewCounterStore(),
+		Totps:         totps,
+		Identities:    identities,
+		Stats:         NewStatsStore(users, urls, analytics),
+		Audits:        audits,
+		AbuseReports:  reports,
+		Visitors:      NewVisitorStore(),
+		Rollups:       rollups,
+		Live:          NewClickStream(),
+	}
+}
+
+// newUUID returns a random (v4) UUID, mirroring Postgres gen_random_uuid()
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// paginate returns the requested window of items
+func paginate[T any](items []T, p model.Pagination) []T {
+	if p.Offset >= len(items) {
+		return nil
+	}
+	return items[p.Offset:min(p.Offset+p.Limit, len(items))]
+}
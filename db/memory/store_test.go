@@ -0,0 +1,147 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestUserStoreSave(t *testing.T) {
+	tests := []struct {
+		name    string
+		emails  []string
+		wantErr error
+	}{
+		{"distinct emails", []string{"a@example.com", "b@example.com"}, nil},
+		{"same email", []string{"a@example.com", "a@example.com"}, model.ErrUserExists},
+		{"email differing in case", []string{"a@example.com", "A@Example.com"}, model.ErrUserExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewUserStore()
+
+			var err error
+			for _, email := range tt.emails {
+				if err = store.Save(context.Background(), &model.User{Email: email}); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUrlStoreSave(t *testing.T) {
+	tests := []struct {
+		name    string
+		codes   []string
+		wantErr error
+	}{
+		{"distinct codes", []string{"abc", "def"}, nil},
+		{"same code", []string{"abc", "abc"}, model.ErrUrlCodeExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewUrlStore()
+
+			var err error
+			for _, code := range tt.codes {
+				// Deleted links keep their code
+				if err = store.Save(context.Background(), &model.Url{Code: code, Status: model.UrlStatusDeleted}); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Save() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUrlStoreSaveAll(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  []string
+		batch     []string
+		wantIndex int // -1 when the batch is saved
+		wantCount int
+	}{
+		{"all new", nil, []string{"a", "b", "c"}, -1, 3},
+		{"taken code", []string{"b"}, []string{"a", "b", "c"}, 1, 1},
+		{"duplicate in batch", nil, []string{"a", "b", "a"}, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewUrlStore()
+			for _, code := range tt.existing {
+				if err := store.Save(ctx, &model.Url{Code: code}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			batch := make([]*model.Url, len(tt.batch))
+			for i, code := range tt.batch {
+				batch[i] = &model.Url{Code: code}
+			}
+			err := store.SaveAll(ctx, batch)
+
+			if tt.wantIndex < 0 {
+				if err != nil {
+					t.Fatalf("SaveAll() error = %v", err)
+				}
+			} else {
+				var saveErr *model.UrlSaveError
+				if !errors.As(err, &saveErr) || saveErr.Index != tt.wantIndex || !errors.Is(err, model.ErrUrlCodeExists) {
+					t.Fatalf("SaveAll() error = %v, want code exists at %d", err, tt.wantIndex)
+				}
+			}
+
+			// A failed batch saves none of its URLs
+			if got := len(store.all()); got != tt.wantCount {
+				t.Fatalf("stored %d URLs, want %d", got, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllowN(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter()
+	limiter.now = func() time.Time { return now }
+	limit := model.RateLimit{Rate: 5, Burst: 5, Period: 5 * time.Minute}
+
+	steps := []struct {
+		n             int
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{3, true, 2},
+		{3, false, 0}, // All or nothing
+		{2, true, 0},
+		{1, false, 0},
+	}
+	for i, step := range steps {
+		res, err := limiter.AllowN(context.Background(), "key", limit, step.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Allowed != step.wantAllowed || res.Remaining != step.wantRemaining {
+			t.Fatalf("step %d: allowed %v with %d remaining, want %v with %d", i+1, res.Allowed, res.Remaining, step.wantAllowed, step.wantRemaining)
+		}
+	}
+
+	// One request is replenished per minute
+	now = now.Add(2 * time.Minute)
+	if res, _ := limiter.AllowN(context.Background(), "key", limit, 2); !res.Allowed {
+		t.Errorf("2 requests after 2 minutes refused, retry after %s", res.RetryAfter)
+	}
+}
@@ -0,0 +1,130 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type recoveryCode struct {
+	hash string
+	used bool
+}
+
+type TotpStore struct {
+	mu            sync.Mutex
+	totps         map[int64]*model.UserTotp
+	recoveryCodes map[int64][]*recoveryCode
+}
+
+func NewTotpStore() *TotpStore {
+	return &TotpStore{
+		totps:         make(map[int64]*model.UserTotp),
+		recoveryCodes: make(map[int64][]*recoveryCode),
+	}
+}
+
+func (s *TotpStore) Get(ctx context.Context, userID int64) (model.UserTotp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if totp, ok := s.totps[userID]; ok {
+		return *totp, nil
+	}
+	return model.UserTotp{}, model.ErrTotpNotFound
+}
+
+func (s *TotpStore) SavePending(ctx context.Context, userID int64, pendingSecret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totps[userID]
+	if !ok {
+		totp = &model.UserTotp{UserID: userID, CreatedAt: time.Now().UTC()}
+		s.totps[userID] = totp
+	}
+
+	totp.PendingSecret = pendingSecret
+	return nil
+}
+
+func (s *TotpStore) Activate(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totps[userID]
+	if !ok || totp.PendingSecret == "" {
+		return model.ErrTotpNotFound
+	}
+
+	totp.Secret = totp.PendingSecret
+	totp.PendingSecret = ""
+	totp.Enabled = true
+	totp.EnabledAt = time.Now().UTC()
+	totp.LastUsedStep = step
+
+	codes := make([]*recoveryCode, 0, len(recoveryCodeHashes))
+	for _, hash := range recoveryCodeHashes {
+		codes = append(codes, &recoveryCode{hash: hash})
+	}
+	s.recoveryCodes[userID] = codes
+	return nil
+}
+
+func (s *TotpStore) Delete(ctx context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.totps, userID)
+	delete(s.recoveryCodes, userID)
+	return nil
+}
+
+func (s *TotpStore) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	totp, ok := s.totps[userID]
+	if !ok || totp.LastUsedStep >= step {
+		return false, nil
+	}
+
+	totp.LastUsedStep = step
+	return true, nil
+}
+
+func (s *TotpStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes[userID] {
+		if code.hash == codeHash && !code.used {
+			code.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *TotpStore) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, code := range s.recoveryCodes[userID] {
+		if !code.used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *TotpStore) deleteUser(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.totps, user.ID)
+	delete(s.recoveryCodes, user.ID)
+}
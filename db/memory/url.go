@@ -0,0 +1,274 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type UrlStore struct {
+	mu     sync.RWMutex
+	nextID int64
+	urls   map[int64]*model.Url
+}
+
+func NewUrlStore() *UrlStore {
+	return &UrlStore{urls: make(map[int64]*model.Url)}
+}
+
+func (s *UrlStore) GetByCode(ctx context.Context, code string) (model.Url, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, url := range s.urls {
+		if url.Code == code {
+			return *url, nil
+		}
+	}
+	return model.Url{}, model.ErrUrlNotFound
+}
+
+func (s *UrlStore) ListByUser(ctx context.Context, userID int64, filter model.GetUrlByUserFilter) ([]model.Url, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var urls []model.Url
+	for _, url := range s.urls {
+		if url.UserID != userID {
+			continue
+		}
+		if filter.Status.IsValid() && url.Status != filter.Status {
+			continue
+		}
+		urls = append(urls, *url)
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		return urls[i].CreatedAt.After(urls[j].CreatedAt)
+	})
+	return urls, nil
+}
+
+func (s *UrlStore) Save(ctx context.Context, u *model.Url) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, url := range s.urls {
+		if url.Code == u.Code {
+			return model.ErrUrlCodeExists
+		}
+	}
+
+	s.nextID++
+	u.ID = s.nextID
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+
+	stored := *u
+	s.urls[u.ID] = &stored
+	return nil
+}
+
+func (s *UrlStore) SaveAll(ctx context.Context, urls []*model.Url) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	codes := make(map[string]bool, len(s.urls))
+	for _, url := range s.urls {
+		codes[url.Code] = true
+	}
+	for i, u := range urls {
+		if codes[u.Code] {
+			return &model.UrlSaveError{Index: i, Err: model.ErrUrlCodeExists}
+		}
+		codes[u.Code] = true
+	}
+
+	for _, u := range urls {
+		s.nextID++
+		u.ID = s.nextID
+		if u.CreatedAt.IsZero() {
+			u.CreatedAt = time.Now().UTC()
+		}
+
+		stored := *u
+		s.urls[u.ID] = &stored
+	}
+	return nil
+}
+
+func (s *UrlStore) EachByUser(ctx context.Context, userID int64, fn func(model.Url) error) error {
+	s.mu.RLock()
+	var urls []model.Url
+	for _, url := range s.urls {
+		if url.UserID == userID {
+			urls = append(urls, *url)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ID < urls[j].ID })
+	for _, url := range urls {
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UrlStore) UpdateStatus(ctx context.Context, id int64, status model.UrlStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if url, ok := s.urls[id]; ok {
+		url.Status = status
+	}
+	return nil
+}
+
+func (s *UrlStore) UpdateSafety(ctx context.Context, id int64, status model.UrlStatus, reason string, scannedAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok || (url.Status != model.UrlStatusActive && url.Status != model.UrlStatusQuarantined) {
+		return false, nil
+	}
+	url.Status = status
+	url.SafetyReason = reason
+	url.ScannedAt = scannedAt
+	return true, nil
+}
+
+func (s *UrlStore) ReplaceStatus(ctx context.Context, id int64, from []model.UrlStatus, status model.UrlStatus, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok || !slices.Contains(from, url.Status) {
+		return false, nil
+	}
+	url.Status = status
+	url.SafetyReason = reason
+	return true, nil
+}
+
+func (s *UrlStore) ListForScan(ctx context.Context, scannedBefore time.Time, limit int) ([]model.Url, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var urls []model.Url
+	for _, url := range s.urls {
+		if url.Status != model.UrlStatusActive && url.Status != model.UrlStatusQuarantined {
+			continue
+		}
+		if !url.ScannedAt.Before(scannedBefore) {
+			continue
+		}
+		urls = append(urls, *url)
+	}
+
+	sort.Slice(urls, func(i, j int) bool {
+		if !urls[i].ScannedAt.Equal(urls[j].ScannedAt) {
+			return urls[i].ScannedAt.Before(urls[j].ScannedAt)
+		}
+		return urls[i].ID < urls[j].ID
+	})
+	if len(urls) > limit {
+		urls = urls[:limit]
+	}
+	return urls, nil
+}
+
+func (s *UrlStore) incrementClicks(id int64, bot bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	url, ok := s.urls[id]
+	if !ok {
+		return
+	}
+	if bot {
+		url.BotClickCount++
+	} else {
+		url.ClickCount++
+	}
+}
+
+func (s *UrlStore) Search(ctx context.Context, filter model.UrlSearchFilter) ([]model.Url, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+
+	var urls []model.Url
+	for _, url := range s.urls {
+		if query != "" && !strings.Contains(strings.ToLower(url.Code), query) && !strings.Contains(strings.ToLower(url.Url), query) {
+			continue
+		}
+		if filter.UserID != 0 && url.UserID != filter.UserID {
+			continue
+		}
+		if filter.Status.IsValid() && url.Status != filter.Status {
+			continue
+		}
+		urls = append(urls, *url)
+	}
+
+	sort.Slice(urls, func(i, j int) bool { return urls[i].ID > urls[j].ID })
+	return paginate(urls, filter.Pagination), nil
+}
+
+// all returns a snapshot of every URL
+func (s *UrlStore) all() []model.Url {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	urls := make([]model.Url, 0, len(s.urls))
+	for _, url := range s.urls {
+		urls = append(urls, *url)
+	}
+	return urls
+}
+
+func (s *UrlStore) deleteUser(user model.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, url := range s.urls {
+		if url.UserID == user.ID {
+			delete(s.urls, id)
+		}
+	}
+}
+
+// idsByUser returns the ids of the user's URLs
+func (s *UrlStore) idsByUser(userID int64) map[int64]bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make(map[int64]bool)
+	for id, url := range s.urls {
+		if url.UserID == userID {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// ownerOf returns the user of the URL, 0 when it is gone
+func (s *UrlStore) ownerOf(id int64) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if url, ok := s.urls[id]; ok {
+		return url.UserID
+	}
+	return 0
+}
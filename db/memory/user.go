@@ -0,0 +1,202 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type UserStore struct {
+	mu         sync.RWMutex
+	nextID     int64
+	users      map[int64]*model.User
+	tombstones map[string]model.UserTombstone
+	owned      []userData
+}
+
+// userData is implemented by stores holding data that belongs to a user
+type userData interface {
+	deleteUser(user model.User)
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{users: make(map[int64]*model.User), tombstones: make(map[string]model.UserTombstone)}
+}
+
+// cascade makes Delete remove the user's data from the given stores, in order
+func (s *UserStore) cascade(stores ...userData) {
+	s.owned = append(s.owned, stores...)
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			return *user, nil
+		}
+	}
+	return model.User{}, model.ErrUserNotFound
+}
+
+func (s *UserStore) GetByID(ctx context.Context, id int64) (model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, ok := s.users[id]; ok {
+		return *user, nil
+	}
+	return model.User{}, model.ErrUserNotFound
+}
+
+func (s *UserStore) Save(ctx context.Context, u *model.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, u.Email) {
+			return model.ErrUserExists
+		}
+	}
+
+	s.nextID++
+	u.ID = s.nextID
+	u.CreatedAt = time.Now().UTC()
+
+	stored := *u
+	s.users[u.ID] = &stored
+	return nil
+}
+
+func (s *UserStore) Search(ctx context.Context, filter model.UserSearchFilter) ([]model.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	query := strings.ToLower(filter.Query)
+
+	var users []model.User
+	for _, user := range s.users {
+		if query != "" && !strings.Contains(strings.ToLower(user.Email), query) {
+			continue
+		}
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if filter.Status != "" && user.Status != filter.Status {
+			continue
+		}
+		users = append(users, *user)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID > users[j].ID })
+	return paginate(users, filter.Pagination), nil
+}
+
+func (s *UserStore) UpdateRole(ctx context.Context, id int64, role model.UserRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+func (s *UserStore) UpdateStatus(ctx context.Context, id int64, status model.UserStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+	user.Status = status
+	return nil
+}
+
+func (s *UserStore) UpdateClickPrivacy(ctx context.Context, id int64, privacy model.ClickPrivacy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+	user.ClickPrivacy = privacy
+	return nil
+}
+
+// all returns a snapshot of every user
+func (s *UserStore) all() []model.User {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]model.User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, *user)
+	}
+	return users
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+	user.Password = passwordHash
+	return nil
+}
+
+func (s *UserStore) UpdateEmail(ctx context.Context, id int64, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return model.ErrUserNotFound
+	}
+
+	for _, other := range s.users {
+		if other.ID != id && strings.EqualFold(other.Email, email) {
+			return model.ErrUserExists
+		}
+	}
+	user.Email = email
+	return nil
+}
+
+func (s *UserStore) Delete(ctx context.Context, user model.User, tombstone model.UserTombstone) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.ID]; !ok {
+		return model.ErrUserNotFound
+	}
+
+	for _, store := range s.owned {
+		store.deleteUser(user)
+	}
+	delete(s.users, user.ID)
+	s.tombstones[tombstone.EmailHash] = tombstone
+	return nil
+}
+
+func (s *UserStore) GetTombstone(ctx context.Context, emailHash string) (model.UserTombstone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if tombstone, ok := s.tombstones[emailHash]; ok {
+		return tombstone, nil
+	}
+	return model.UserTombstone{}, model.ErrTombstoneNotFound
+}
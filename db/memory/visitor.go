@@ -0,0 +1,118 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type visitorKey struct {
+	urlID int64
+	day   string // Empty for all-time
+}
+
+// VisitorStore counts unique visitors exactly with sets, where Redis
+// estimates them with HyperLogLogs
+type VisitorStore struct {
+	mu        sync.Mutex
+	saltDay   string
+	salt      []byte
+	totalSalt []byte
+	visitors  map[visitorKey]map[string]bool
+}
+
+func NewVisitorStore() *VisitorStore {
+	return &VisitorStore{visitors: map[visitorKey]map[string]bool{}}
+}
+
+var _ model.VisitorStore = (*VisitorStore)(nil)
+
+func (s *VisitorStore) DailySalt(ctx context.Context, day string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only the salt of the current day is kept
+	if s.saltDay != day {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		s.saltDay, s.salt = day, salt
+	}
+	return s.salt, nil
+}
+
+func (s *VisitorStore) TotalSalt(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.totalSalt == nil {
+		salt := make([]byte, 32)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		s.totalSalt = salt
+	}
+	return s.totalSalt, nil
+}
+
+func (s *VisitorStore) Add(ctx context.Context, urlID int64, day string, dayVisitor string, totalVisitor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, visitor := range map[visitorKey]string{{urlID: urlID, day: day}: dayVisitor, {urlID: urlID}: totalVisitor} {
+		if s.visitors[key] == nil {
+			s.visitors[key] = map[string]bool{}
+		}
+		s.visitors[key][visitor] = true
+	}
+	return nil
+}
+
+func (s *VisitorStore) Delete(ctx context.Context, urlIDs []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := make(map[int64]bool, len(urlIDs))
+	for _, urlID := range urlIDs {
+		deleted[urlID] = true
+	}
+	for key := range s.visitors {
+		if deleted[key.urlID] {
+			delete(s.visitors, key)
+		}
+	}
+	return nil
+}
+
+func (s *VisitorStore) CountTotal(ctx context.Context, urlIDs []int64) (map[int64]int64, error) {
+	return s.count(urlIDs, ""), nil
+}
+
+func (s *VisitorStore) CountDay(ctx context.Context, urlIDs []int64, day string) (map[int64]int64, error) {
+	return s.count(urlIDs, day), nil
+}
+
+func (s *VisitorStore) CountDays(ctx context.Context, urlID int64, days []string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[string]int64, len(days))
+	for _, day := range days {
+		counts[day] = int64(len(s.visitors[visitorKey{urlID: urlID, day: day}]))
+	}
+	return counts, nil
+}
+
+func (s *VisitorStore) count(urlIDs []int64, day string) map[int64]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counts := make(map[int64]int64, len(urlIDs))
+	for _, urlID := range urlIDs {
+		counts[urlID] = int64(len(s.visitors[visitorKey{urlID: urlID, day: day}]))
+	}
+	return counts
+}
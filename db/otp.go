@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type OtpStore struct {
+	db *sql.DB
+}
+
+func (s *OtpStore) GetPendingByToken(ctx context.Context, token string, action string) (model.Otp, error) {
+	var otp model.Otp
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	row := s.db.QueryRowContext(readCtx, "SELECT id, key, type, action, otp, token, status, attempts, fingerprint, created_at FROM otp WHERE token = $1 AND action = $2 AND status = $3", token, action, model.OtpStatusPending)
+
+	scanErr := row.Scan(&otp.ID, &otp.Key, &otp.Type, &otp.Action, &otp.OtpHash, &otp.Token, &otp.Status, &otp.Attempts, &otp.Fingerprint, &otp.CreatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return otp, model.ErrOtpNotFound
+		}
+		return otp, ContextErr(readCtx, scanErr)
+	}
+
+	return otp, nil
+}
+
+func (s *OtpStore) GetLatestPending(ctx context.Context, key string, otpType model.OtpType, action model.OtpActionType) (model.Otp, error) {
+	var otp model.Otp
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	row := s.db.QueryRowContext(readCtx, "SELECT id, key, type, action, otp, token, status, attempts, fingerprint, created_at FROM otp WHERE key = $1 AND type=$2 AND action=$3 AND status = $4 ORDER BY created_at DESC LIMIT 1", key, otpType, action, model.OtpStatusPending)
+
+	scanErr := row.Scan(&otp.ID, &otp.Key, &otp.Type, &otp.Action, &otp.OtpHash, &otp.Token, &otp.Status, &otp.Attempts, &otp.Fingerprint, &otp.CreatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return otp, model.ErrOtpNotFound
+		}
+		return otp, ContextErr(readCtx, scanErr)
+	}
+
+	return otp, nil
+}
+
+func (s *OtpStore) ReserveAttempt(ctx context.Context, id int64, maxAttempts int) (int, error) {
+	var attempts int
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	query := "UPDATE otp SET attempts = attempts + 1 WHERE id=$1 AND status=$2 AND attempts < $3 RETURNING attempts"
+	rowErr := s.db.QueryRowContext(writeCtx, query, id, model.OtpStatusPending, maxAttempts).Scan(&attempts)
+	if rowErr == sql.ErrNoRows {
+		return 0, nil
+	}
+	if rowErr != nil {
+		return 0, ContextErr(writeCtx, rowErr)
+	}
+
+	return attempts, nil
+}
+
+func (s *OtpStore) Save(ctx context.Context, otp *model.Otp) error {
+	insertQuery := `INSERT INTO otp (key, type, action, otp, created_at, status, fingerprint) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, token`
+	logStr := fmt.Sprintf("Insert OTP in DB : %s, Key: %s, Type: %s, Action: %s, Timestamp: %s", insertQuery, otp.Key, otp.Type, otp.Action, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, insertQuery, otp.Key, otp.Type, otp.Action, otp.OtpHash, otp.CreatedAt, otp.Status, otp.Fingerprint).Scan(&otp.ID, &otp.Token)
+	if rowErr != nil {
+		return ContextErr(writeCtx, rowErr)
+	}
+
+	return nil
+}
+
+func (s *OtpStore) Consume(ctx context.Context, id int64) (bool, error) {
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, updateErr := s.db.ExecContext(writeCtx, "UPDATE otp SET status=$1 WHERE id=$2 AND status=$3", model.OtpStatusSuccess, id, model.OtpStatusPending)
+	if updateErr != nil {
+		return false, ContextErr(writeCtx, updateErr)
+	}
+
+	rows, rowsErr := result.RowsAffected()
+	return rows == 1, rowsErr
+}
+
+func (s *OtpStore) UpdateStatus(ctx context.Context, id int64, status model.OtpStatus) error {
+	utils.Log.Info("Updating OTP status to ", status, "UPDATE otp SET status=$1 WHERE id=$2")
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	_, updateErr := s.db.ExecContext(writeCtx, "UPDATE otp SET status=$1 WHERE id=$2", status, id)
+	if updateErr != nil {
+		return ContextErr(writeCtx, updateErr)
+	}
+
+	return nil
+}
+
+// plaintextOtpBatch is how many plaintext OTPs are hashed per transaction
+const plaintextOtpBatch = 500
+
+// hashPlaintextOtps replaces codes stored before OTPs were hashed with their
+// hash, so pending OTPs sent by an older release can still be verified. The
+// partial index otp_plaintext_idx holds only those rows, once they are
+// hashed startups find it empty instead of scanning the table.
+func hashPlaintextOtps(conn *sql.DB) {
+	hashed := 0
+	for {
+		count, err := hashPlaintextOtpBatch(conn)
+		if err != nil {
+			utils.Log.Error("Error hashing plaintext OTPs: ", err)
+			return
+		}
+		hashed += count
+		if count < plaintextOtpBatch {
+			break
+		}
+	}
+
+	if hashed > 0 {
+		utils.Log.Info("Hashed ", hashed, " plaintext OTPs")
+	}
+}
+
+func hashPlaintextOtpBatch(conn *sql.DB) (int, error) {
+	tx, err := conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The prefix is spelled out so the planner can use the partial index
+	rows, err := tx.Query(`SELECT id, otp FROM otp WHERE otp NOT LIKE '`+utils.OtpHashPrefix+`%' ORDER BY id LIMIT $1 FOR UPDATE`, plaintextOtpBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	plaintext := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var code string
+		if scanErr := rows.Scan(&id, &code); scanErr != nil {
+			rows.Close()
+			return 0, scanErr
+		}
+		plaintext[id] = code
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, code := range plaintext {
+		if _, updateErr := tx.Exec("UPDATE otp SET otp=$1 WHERE id=$2", utils.HashOtp(code), id); updateErr != nil {
+			return 0, fmt.Errorf("OTP %d: %w", id, updateErr)
+		}
+	}
+	return len(plaintext), tx.Commit()
+}
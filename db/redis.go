@@ -2,61 +2,71 @@ package db
 
 import (
 	"context"
-	"fmt"
-	"time"
 
 	"github.com/go-redis/redis_rate/v10"
-	limiter "github.com/go-redis/redis_rate/v10"
 	redis "github.com/redis/go-redis/v9"
 	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
 	"kgoel085.com/url-shortner/utils"
 )
 
-var (
-	RedisClient  *redis.Client
-	RedisLimiter *redis_rate.Limiter
-)
-
-func InitRedis() {
+// InitRedis connects to Redis and verifies the connection
+func InitRedis() *redis.Client {
 	addr := config.Config.REDIS.Addr
 	password := config.Config.REDIS.Password
 	db := config.Config.REDIS.DB
 
-	RedisClient = redis.NewClient(&redis.Options{
+	client := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: password,
 		DB:       db,
 	})
 
-	redisPing, redisErr := RedisClient.Ping(context.Background()).Result()
+	redisPing, redisErr := client.Ping(context.Background()).Result()
 	if redisErr != nil {
 		panic(redisErr)
 	}
 	utils.Log.Info("Redis Ping:", redisPing)
 
-	RedisLimiter = redis_rate.NewLimiter(RedisClient)
+	return client
+}
 
+// RedisRateLimiter implements model.RateLimiter on top of redis_rate (GCRA)
+type RedisRateLimiter struct {
+	limiter *redis_rate.Limiter
 }
 
-func CheckRateLimitInTimeUnit(ctx context.Context, key string, requests int, timeUnit time.Duration) (bool, error) {
-	var limit limiter.Limit
-	switch timeUnit {
-	case time.Minute:
-		limit = limiter.PerMinute(requests)
-	case time.Hour:
-		limit = limiter.PerHour(requests)
-	case time.Second:
-		limit = limiter.PerSecond(requests)
-	default:
-		return false, fmt.Errorf("unsupported time unit")
-	}
-	return checkRateLimiter(ctx, key, limit)
+func NewRedisRateLimiter(client *redis.Client) *RedisRateLimiter {
+	return &RedisRateLimiter{limiter: redis_rate.NewLimiter(client)}
+}
+
+func (r *RedisRateLimiter) Allow(ctx context.Context, key string, limit model.RateLimit) (model.RateLimitResult, error) {
+	return r.AllowN(ctx, key, limit, 1)
 }
 
-func checkRateLimiter(ctx context.Context, key string, limit limiter.Limit) (bool, error) {
-	res, err := RedisLimiter.Allow(ctx, key, limit)
+func (r *RedisRateLimiter) AllowN(ctx context.Context, key string, limit model.RateLimit, n int) (model.RateLimitResult, error) {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	res, err := r.limiter.AllowN(redisCtx, key, redis_rate.Limit{
+		Rate:   limit.Rate,
+		Burst:  limit.Burst,
+		Period: limit.Period,
+	}, n)
 	if err != nil {
-		return false, err
+		return model.RateLimitResult{Limit: limit}, ContextErr(redisCtx, err)
 	}
-	return res.Allowed > 0, nil
+
+	retryAfter := res.RetryAfter
+	if retryAfter < 0 { // redis_rate reports -1 when the request was allowed
+		retryAfter = 0
+	}
+
+	return model.RateLimitResult{
+		Limit:      limit,
+		Allowed:    res.Allowed > 0,
+		Remaining:  res.Remaining,
+		RetryAfter: retryAfter,
+		ResetAfter: res.ResetAfter,
+	}, nil
 }
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type RefreshTokenStore struct {
+	db *sql.DB
+}
+
+func (s *RefreshTokenStore) GetByToken(ctx context.Context, token string) (model.UserRefreshToken, error) {
+	var refreshToken model.UserRefreshToken
+
+	query := `SELECT id, token, expires_at, user_id, created_at, is_used FROM refresh_tokens WHERE token=$1`
+
+	logStr := fmt.Sprintf("Get refresh token from DB : %s, Timestamp: %s", query, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, query, token).Scan(&refreshToken.ID, &refreshToken.Token, &refreshToken.ExpiresAt, &refreshToken.UserID, &refreshToken.CreatedAt, &refreshToken.IsUsed)
+	if rowErr != nil {
+		if rowErr == sql.ErrNoRows {
+			return refreshToken, model.ErrRefreshTokenNotFound
+		}
+		return refreshToken, fmt.Errorf("Error while trying to get refresh token - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return refreshToken, nil
+}
+
+func (s *RefreshTokenStore) MarkAllUsed(ctx context.Context, userID int64) error {
+	markUsedQuery := `UPDATE refresh_tokens SET is_used = TRUE WHERE user_id = $1`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	_, markUsedErr := s.db.ExecContext(writeCtx, markUsedQuery, userID)
+	if markUsedErr != nil {
+		return fmt.Errorf("Error marking old refresh tokens as used - %w !", ContextErr(writeCtx, markUsedErr))
+	}
+
+	return nil
+}
+
+func (s *RefreshTokenStore) Save(ctx context.Context, token *model.UserRefreshToken) error {
+	query := `INSERT INTO refresh_tokens (user_id, token, expires_at, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	logStr := fmt.Sprintf("Save refresh token in DB : %s, UserID: %d, ExpiresAt: %s", query, token.UserID, token.ExpiresAt)
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, token.UserID, token.Token, token.ExpiresAt, token.CreatedAt).Scan(&token.ID)
+	if rowErr != nil {
+		return ContextErr(writeCtx, rowErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const abuseReportColumns = `r.id, r.url_id, u.code, r.reason, r.details, COALESCE(r.reporter_id, 0), r.reporter_email, r.reporter_ip, r.status, r.created_at, r.resolved_at, COALESCE(r.resolved_by, 0)`
+
+type AbuseReportStore struct {
+	db *sql.DB
+}
+
+func scanAbuseReport(row interface{ Scan(...any) error }, report *model.AbuseReport) error {
+	var resolvedAt sql.NullTime
+
+	scanErr := row.Scan(&report.ID, &report.UrlID, &report.Code, &report.Reason, &report.Details, &report.ReporterID, &report.ReporterEmail,
+		&report.ReporterIP, &report.Status, &report.CreatedAt, &resolvedAt, &report.ResolvedBy)
+	if scanErr != nil {
+		return scanErr
+	}
+	report.ResolvedAt = resolvedAt.Time
+	return nil
+}
+
+func (s *AbuseReportStore) Save(ctx context.Context, report *model.AbuseReport) error {
+	query := `INSERT INTO abuse_reports (url_id, reason, details, reporter_id, reporter_email, reporter_ip, status, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, 0), $5, $6, $7, $8) RETURNING id`
+
+	utils.Log.Info("Save abuse report in DB : UrlID: ", report.UrlID, ", Reason: ", report.Reason)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, report.UrlID, report.Reason, report.Details, report.ReporterID, report.ReporterEmail,
+		report.ReporterIP, report.Status, report.CreatedAt).Scan(&report.ID)
+	if isUniqueViolation(rowErr) {
+		return model.ErrAbuseReportExists
+	}
+	if rowErr != nil {
+		return fmt.Errorf("Error while trying to save abuse report - %w !", ContextErr(writeCtx, rowErr))
+	}
+
+	return nil
+}
+
+func (s *AbuseReportStore) GetByID(ctx context.Context, id int64) (model.AbuseReport, error) {
+	var report model.AbuseReport
+
+	query := `SELECT ` + abuseReportColumns + ` FROM abuse_reports r JOIN url u ON u.id = r.url_id WHERE r.id = $1`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := scanAbuseReport(s.db.QueryRowContext(readCtx, query, id), &report)
+	if rowErr != nil {
+		if rowErr == sql.ErrNoRows {
+			return report, model.ErrAbuseReportNotFound
+		}
+		return report, fmt.Errorf("Error while trying to get abuse report - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return report, nil
+}
+
+func (s *AbuseReportStore) Search(ctx context.Context, filter model.AbuseReportFilter) ([]model.AbuseReport, error) {
+	var reports []model.AbuseReport
+
+	var args []interface{}
+	var conditions []string
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("r.status=$%d", len(args)))
+	}
+	if filter.Code != "" {
+		args = append(args, filter.Code)
+		conditions = append(conditions, fmt.Sprintf("u.code=$%d", len(args)))
+	}
+
+	query := `SELECT ` + abuseReportColumns + ` FROM abuse_reports r JOIN url u ON u.id = r.url_id`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY r.id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(readCtx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search abuse reports: %w", ContextErr(readCtx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var report model.AbuseReport
+		if err := scanAbuseReport(rows, &report); err != nil {
+			return nil, fmt.Errorf("failed to scan abuse report: %w", ContextErr(readCtx, err))
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read abuse reports: %w", ContextErr(readCtx, err))
+	}
+
+	return reports, nil
+}
+
+func (s *AbuseReportStore) CountVerifiedReporters(ctx context.Context, urlID int64) (int, error) {
+	var count int
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, `SELECT COUNT(DISTINCT reporter_id) FROM abuse_reports WHERE url_id = $1 AND status = $2 AND reporter_id IS NOT NULL`,
+		urlID, model.AbuseReportStatusOpen).Scan(&count)
+	if rowErr != nil {
+		return 0, fmt.Errorf("Error while trying to count abuse reports - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return count, nil
+}
+
+func (s *AbuseReportStore) Resolve(ctx context.Context, urlID int64, status model.AbuseReportStatus, resolvedBy int64, resolvedAt time.Time) (int64, error) {
+	query := `UPDATE abuse_reports SET status = $1, resolved_by = NULLIF($2, 0), resolved_at = $3 WHERE url_id = $4 AND status = $5`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(writeCtx, query, status, resolvedBy, resolvedAt, urlID, model.AbuseReportStatusOpen)
+	if err != nil {
+		return 0, fmt.Errorf("Error while trying to resolve abuse reports - %w !", ContextErr(writeCtx, err))
+	}
+
+	return result.RowsAffected()
+}
+
+func (s *AbuseReportStore) ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]model.ClickClient, error) {
+	var clients []model.ClickClient
+
+	query := `SELECT id, COALESCE(reporter_id, 0), reporter_ip FROM abuse_reports
+		WHERE id > $1 AND created_at < $2 AND status <> 'open' ORDER BY id LIMIT $3`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, afterID, before.UTC(), limit)
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to list abuse report clients - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var client model.ClickClient
+		if err := rows.Scan(&client.ID, &client.UserID, &client.IPAddress); err != nil {
+			return nil, fmt.Errorf("Error while trying to scan abuse report clients - %w !", err)
+		}
+		clients = append(clients, client)
+	}
+
+	return clients, ContextErr(readCtx, rows.Err())
+}
+
+// UpdateClients leaves reports reopened in the meantime alone, open reports
+// keep their address
+func (s *AbuseReportStore) UpdateClients(ctx context.Context, clients []model.ClickClient) error {
+	query := `UPDATE abuse_reports SET reporter_ip = $1 WHERE id = $2 AND status <> 'open'`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	stmt, prepareErr := tx.PrepareContext(writeCtx, query)
+	if prepareErr != nil {
+		return fmt.Errorf("Error while trying to anonymize abuse reports - %w !", ContextErr(writeCtx, prepareErr))
+	}
+	defer stmt.Close()
+
+	for _, client := range clients {
+		if _, err := stmt.ExecContext(writeCtx, client.IPAddress, client.ID); err != nil {
+			return fmt.Errorf("Error while trying to anonymize abuse reports - %w !", ContextErr(writeCtx, err))
+		}
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+// rollupTables are the tables of the rollup intervals with the date_trunc
+// unit of their buckets
+var rollupTables = map[model.RollupInterval]struct{ table, unit string }{
+	model.RollupHour: {"analytics_hourly", "hour"},
+	model.RollupDay:  {"analytics_daily", "day"},
+}
+
+type RollupStore struct {
+	db *sql.DB
+}
+
+func rollupTable(interval model.RollupInterval) (string, string, error) {
+	t, ok := rollupTables[interval]
+	if !ok {
+		return "", "", fmt.Errorf("unknown rollup interval %q", interval)
+	}
+	return t.table, t.unit, nil
+}
+
+func (s *RollupStore) Rollup(ctx context.Context, interval model.RollupInterval, from time.Time, to time.Time) error {
+	table, unit, tableErr := rollupTable(interval)
+	if tableErr != nil {
+		return tableErr
+	}
+
+	// Every click is counted once for each dimension
+	query := fmt.Sprintf(`INSERT INTO %s (url_id, bucket, dimension, value, clicks, bot_clicks, unique_visitors)
+		SELECT a.url_id, date_trunc('%s', a.created_at), d.dimension, d.value,
+			COUNT(*) FILTER (WHERE NOT a.is_bot),
+			COUNT(*) FILTER (WHERE a.is_bot),
+			COUNT(DISTINCT a.visitor_hash) FILTER (WHERE NOT a.is_bot AND a.visitor_hash <> '')
+		FROM analytics a
+		CROSS JOIN LATERAL (VALUES ('%s', ''), ('%s', a.referrer_domain), ('%s', a.country), ('%s', a.device)) AS d (dimension, value)
+		WHERE a.created_at >= $1 AND a.created_at < $2
+		GROUP BY 1, 2, 3, 4
+		ON CONFLICT (url_id, bucket, dimension, value) DO UPDATE
+			SET clicks = EXCLUDED.clicks, bot_clicks = EXCLUDED.bot_clicks, unique_visitors = EXCLUDED.unique_visitors`,
+		table, unit, model.DimensionTotal, model.DimensionReferrer, model.DimensionCountry, model.DimensionDevice)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(writeCtx, query, from.UTC(), to.UTC())
+	if err != nil {
+		return fmt.Errorf("Error while trying to roll up clicks - %w !", ContextErr(writeCtx, err))
+	}
+
+	return nil
+}
+
+func (s *RollupStore) Watermark(ctx context.Context) (time.Time, error) {
+	var watermark time.Time
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, `SELECT rolled_up_until FROM analytics_rollup_state`).Scan(&watermark)
+	if rowErr == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if rowErr != nil {
+		return time.Time{}, fmt.Errorf("Error while trying to get the rollup watermark - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return watermark.UTC(), nil
+}
+
+func (s *RollupStore) SetWatermark(ctx context.Context, watermark time.Time) error {
+	query := `INSERT INTO analytics_rollup_state (rolled_up_until) VALUES ($1)
+		ON CONFLICT (id) DO UPDATE SET rolled_up_until = EXCLUDED.rolled_up_until`
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(writeCtx, query, watermark.UTC())
+	if err != nil {
+		return fmt.Errorf("Error while trying to set the rollup watermark - %w !", ContextErr(writeCtx, err))
+	}
+
+	return nil
+}
+
+func (s *RollupStore) Series(ctx context.Context, urlID int64, interval model.RollupInterval, from time.Time, to time.Time) ([]model.AnalyticsBucket, error) {
+	var buckets []model.AnalyticsBucket
+
+	table, _, tableErr := rollupTable(interval)
+	if tableErr != nil {
+		return nil, tableErr
+	}
+
+	query := fmt.Sprintf(`SELECT bucket, clicks, bot_clicks, unique_visitors FROM %s
+		WHERE url_id = $1 AND dimension = $2 AND bucket >= $3 AND bucket < $4 ORDER BY bucket`, table)
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, urlID, model.DimensionTotal, from.UTC(), to.UTC())
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to read rollups - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bucket := model.AnalyticsBucket{UrlID: urlID, Dimension: model.DimensionTotal}
+		if err := rows.Scan(&bucket.Bucket, &bucket.Clicks, &bucket.BotClicks, &bucket.UniqueVisitors); err != nil {
+			return nil, fmt.Errorf("Error while trying to scan rollups - %w !", err)
+		}
+		bucket.Bucket = bucket.Bucket.UTC()
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, ContextErr(readCtx, rows.Err())
+}
+
+func (s *RollupStore) Breakdown(ctx context.Context, urlID int64, dimension string, from time.Time, to time.Time, limit int) ([]model.AnalyticsBucket, error) {
+	var buckets []model.AnalyticsBucket
+
+	query := `SELECT value, SUM(clicks), SUM(bot_clicks) FROM analytics_daily
+		WHERE url_id = $1 AND dimension = $2 AND bucket >= $3 AND bucket < $4
+		GROUP BY value ORDER BY SUM(clicks) DESC, SUM(bot_clicks) DESC, value LIMIT $5`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, queryErr := s.db.QueryContext(readCtx, query, urlID, dimension, from.UTC(), to.UTC(), limit)
+	if queryErr != nil {
+		return nil, fmt.Errorf("Error while trying to read rollups - %w !", ContextErr(readCtx, queryErr))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bucket := model.AnalyticsBucket{UrlID: urlID, Dimension: dimension}
+		if err := rows.Scan(&bucket.Value, &bucket.Clicks, &bucket.BotClicks); err != nil {
+			return nil, fmt.Errorf("Error while trying to scan rollups - %w !", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, ContextErr(readCtx, rows.Err())
+}
+
+func (s *RollupStore) Purge(ctx context.Context, interval model.RollupInterval, before time.Time, limit int) (int64, error) {
+	table, _, tableErr := rollupTable(interval)
+	if tableErr != nil {
+		return 0, tableErr
+	}
+
+	query := fmt.Sprintf(`DELETE FROM %[1]s WHERE ctid IN (SELECT ctid FROM %[1]s WHERE bucket < $1 LIMIT $2)`, table)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(writeCtx, query, before.UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("Error while trying to purge rollups - %w !", ContextErr(writeCtx, err))
+	}
+
+	return result.RowsAffected()
+}
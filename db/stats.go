@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+type StatsStore struct {
+	db *sql.DB
+}
+
+func (s *StatsStore) System(ctx context.Context, since time.Time) (model.SystemStats, error) {
+	stats := model.SystemStats{
+		UsersByRole:   map[model.UserRole]int64{},
+		UsersByStatus: map[model.UserStatus]int64{},
+		UrlsByStatus:  map[model.UrlStatus]int64{},
+		GeneratedAt:   time.Now().UTC(),
+	}
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	countErr := s.db.QueryRowContext(readCtx, `SELECT
+		(SELECT COUNT(*) FROM users WHERE created_at >= $1),
+		(SELECT COUNT(*) FROM url WHERE created_at >= $1),
+		(SELECT COALESCE(SUM(click_count), 0) FROM url),
+		(SELECT COALESCE(SUM(bot_click_count), 0) FROM url),
+		(SELECT COUNT(*) FILTER (WHERE NOT is_bot) FROM analytics WHERE created_at >= $1),
+		(SELECT COUNT(*) FILTER (WHERE is_bot) FROM analytics WHERE created_at >= $1)`, since).
+		Scan(&stats.NewUsers24h, &stats.NewUrls24h, &stats.Clicks, &stats.BotClicks, &stats.Clicks24h, &stats.BotClicks24h)
+	if countErr != nil {
+		return stats, fmt.Errorf("Error while trying to count stats - %w !", ContextErr(readCtx, countErr))
+	}
+
+	userRows, err := s.db.QueryContext(readCtx, `SELECT role, status, COUNT(*) FROM users GROUP BY role, status`)
+	if err != nil {
+		return stats, fmt.Errorf("Error while trying to count users - %w !", ContextErr(readCtx, err))
+	}
+	defer userRows.Close()
+
+	for userRows.Next() {
+		var role model.UserRole
+		var status model.UserStatus
+		var count int64
+		if err := userRows.Scan(&role, &status, &count); err != nil {
+			return stats, fmt.Errorf("Error while trying to count users - %w !", ContextErr(readCtx, err))
+		}
+		stats.Users += count
+		stats.UsersByRole[role] += count
+		stats.UsersByStatus[status] += count
+	}
+	if err := userRows.Err(); err != nil {
+		return stats, fmt.Errorf("Error while trying to count users - %w !", ContextErr(readCtx, err))
+	}
+
+	urlRows, err := s.db.QueryContext(readCtx, `SELECT status, COUNT(*) FROM url GROUP BY status`)
+	if err != nil {
+		return stats, fmt.Errorf("Error while trying to count URLs - %w !", ContextErr(readCtx, err))
+	}
+	defer urlRows.Close()
+
+	for urlRows.Next() {
+		var status model.UrlStatus
+		var count int64
+		if err := urlRows.Scan(&status, &count); err != nil {
+			return stats, fmt.Errorf("Error while trying to count URLs - %w !", ContextErr(readCtx, err))
+		}
+		stats.Urls += count
+		stats.UrlsByStatus[status] = count
+	}
+	if err := urlRows.Err(); err != nil {
+		return stats, fmt.Errorf("Error while trying to count URLs - %w !", ContextErr(readCtx, err))
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"database/sql"
+
+	redis "github.com/redis/go-redis/v9"
+	"kgoel085.com/url-shortner/model"
+)
+
+// NewStore wires the Postgres and Redis backed implementations of every store
+func NewStore(conn *sql.DB, redisClient *redis.Client) *model.Store {
+	return &model.Store{
+		Urls:          &UrlStore{db: conn},
+		Users:         &UserStore{db: conn},
+		Otps:          &OtpStore{db: conn},
+		Analytics:     &AnalyticsStore{db: conn},
+		RefreshTokens: &RefreshTokenStore{db: conn},
+		RateLimiter:   NewRedisRateLimiter(redisClient),
+		Counters:      NewRedisCounterStore(redisClient),
+		Totps:         &TotpStore{db: conn},
+		Identities:    &IdentityStore{db: conn},
+		Stats:         &StatsStore{db: conn},
+		Audits:        &AuditStore{db: conn},
+		AbuseReports:  &AbuseReportStore{db: conn},
+		Visitors:      NewRedisVisitorStore(redisClient),
+		Rollups:       &RollupStore{db: conn},
+		Live:          NewRedisClickStream(redisClient),
+	}
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type TotpStore struct {
+	db *sql.DB
+}
+
+func (s *TotpStore) Get(ctx context.Context, userID int64) (model.UserTotp, error) {
+	var totp model.UserTotp
+	var secret, pendingSecret sql.NullString
+	var enabledAt sql.NullTime
+
+	query := `SELECT user_id, secret, pending_secret, enabled, last_used_step, created_at, enabled_at FROM user_totp WHERE user_id=$1`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, query, userID).Scan(&totp.UserID, &secret, &pendingSecret, &totp.Enabled, &totp.LastUsedStep, &totp.CreatedAt, &enabledAt)
+	if rowErr != nil {
+		if rowErr == sql.ErrNoRows {
+			return totp, model.ErrTotpNotFound
+		}
+		return totp, fmt.Errorf("Error while trying to get TOTP - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	totp.Secret = secret.String
+	totp.PendingSecret = pendingSecret.String
+	if enabledAt.Valid {
+		totp.EnabledAt = enabledAt.Time
+	}
+
+	return totp, nil
+}
+
+func (s *TotpStore) SavePending(ctx context.Context, userID int64, pendingSecret string) error {
+	query := `INSERT INTO user_totp (user_id, pending_secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET pending_secret = EXCLUDED.pending_secret`
+
+	logStr := fmt.Sprintf("Save pending TOTP in DB : UserID: %d, Timestamp: %s", userID, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	_, execErr := s.db.ExecContext(writeCtx, query, userID, pendingSecret, time.Now().UTC())
+	if execErr != nil {
+		return fmt.Errorf("Error while trying to save TOTP - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	return nil
+}
+
+func (s *TotpStore) Activate(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) error {
+	utils.Log.Info("Activate TOTP in DB : UserID: ", userID)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	result, updateErr := tx.ExecContext(writeCtx, `UPDATE user_totp SET secret = pending_secret, pending_secret = NULL, enabled = TRUE, enabled_at = $2, last_used_step = $3
+		WHERE user_id = $1 AND pending_secret IS NOT NULL`, userID, time.Now().UTC(), step)
+	if updateErr != nil {
+		return fmt.Errorf("Error while trying to activate TOTP - %w !", ContextErr(writeCtx, updateErr))
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return model.ErrTotpNotFound
+	}
+
+	if _, deleteErr := tx.ExecContext(writeCtx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); deleteErr != nil {
+		return fmt.Errorf("Error while trying to delete recovery codes - %w !", ContextErr(writeCtx, deleteErr))
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, insertErr := tx.ExecContext(writeCtx, `INSERT INTO user_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)`, userID, hash, time.Now().UTC())
+		if insertErr != nil {
+			return fmt.Errorf("Error while trying to save recovery codes - %w !", ContextErr(writeCtx, insertErr))
+		}
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
+
+func (s *TotpStore) Delete(ctx context.Context, userID int64) error {
+	utils.Log.Info("Delete TOTP in DB : UserID: ", userID)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(writeCtx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("Error while trying to delete recovery codes - %w !", ContextErr(writeCtx, err))
+	}
+	if _, err := tx.ExecContext(writeCtx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("Error while trying to delete TOTP - %w !", ContextErr(writeCtx, err))
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
+
+func (s *TotpStore) UseStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, execErr := s.db.ExecContext(writeCtx, `UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`, userID, step)
+	if execErr != nil {
+		return false, fmt.Errorf("Error while trying to update TOTP step - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	rows, rowsErr := result.RowsAffected()
+	return rows == 1, rowsErr
+}
+
+func (s *TotpStore) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, execErr := s.db.ExecContext(writeCtx, `UPDATE user_recovery_codes SET used_at = $3 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash, time.Now().UTC())
+	if execErr != nil {
+		return false, fmt.Errorf("Error while trying to use recovery code - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	rows, rowsErr := result.RowsAffected()
+	return rows == 1, rowsErr
+}
+
+func (s *TotpStore) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	var count int
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, `SELECT COUNT(*) FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL`, userID).Scan(&count)
+	if rowErr != nil {
+		return 0, ContextErr(readCtx, rowErr)
+	}
+
+	return count, nil
+}
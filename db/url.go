@@ -0,0 +1,342 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type UrlStore struct {
+	db *sql.DB
+}
+
+const urlColumns = `id, user_id, url, code, status, created_at, expiry_at, click_count, bot_click_count, description, og_title, og_description, og_image, safety_reason, scanned_at`
+
+func scanUrl(row interface{ Scan(...any) error }, url *model.Url) error {
+	var expiryAt, scannedAt sql.NullTime
+
+	scanErr := row.Scan(&url.ID, &url.UserID, &url.Url, &url.Code, &url.Status, &url.CreatedAt, &expiryAt, &url.ClickCount, &url.BotClickCount, &url.Description, &url.OgTitle, &url.OgDescription, &url.OgImage, &url.SafetyReason, &scannedAt)
+	if scanErr != nil {
+		return scanErr
+	}
+	url.ScannedAt = scannedAt.Time
+
+	if expiryAt.Valid {
+		url.ExpiryAt = expiryAt.Time
+	} else {
+		url.ExpiryAt = time.Time{}
+	}
+	return nil
+}
+
+func (s *UrlStore) GetByCode(ctx context.Context, code string) (model.Url, error) {
+	var url model.Url
+	query := `SELECT ` + urlColumns + ` FROM url WHERE code=$1`
+
+	logStr := fmt.Sprintf("Get URL by Code from DB : %s, Code: %s, Timestamp: %s", query, code, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := scanUrl(s.db.QueryRowContext(readCtx, query, code), &url)
+	if rowErr != nil {
+		if rowErr == sql.ErrNoRows {
+			return url, model.ErrUrlNotFound
+		}
+		return url, fmt.Errorf("Error while trying to get URL by code - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return url, nil
+}
+
+func (s *UrlStore) ListByUser(ctx context.Context, userID int64, filter model.GetUrlByUserFilter) ([]model.Url, error) {
+	var urls []model.Url
+
+	var args []interface{}
+	var conditions []string
+
+	// Base query
+	query := `SELECT ` + urlColumns + ` FROM url WHERE user_id=$1`
+	args = append(args, userID)
+
+	// Add status filter if provided
+	if filter.Status.IsValid() {
+		conditions = append(conditions, fmt.Sprintf("status=$%d", len(args)+1))
+		args = append(args, filter.Status)
+	}
+
+	// Append additional conditions
+	if len(conditions) > 0 {
+		query += " AND " + strings.Join(conditions, " AND ")
+	}
+
+	query += ` ORDER BY created_at DESC`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(readCtx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs by user: %w", ContextErr(readCtx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url model.Url
+		if err := scanUrl(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", ContextErr(readCtx, err))
+		}
+
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs: %w", ContextErr(readCtx, err))
+	}
+
+	logStr := fmt.Sprintf("Get URLs by user from DB : %s, UserID: %d, Timestamp: %s", query, userID, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	return urls, nil
+}
+
+func (s *UrlStore) Save(ctx context.Context, u *model.Url) error {
+	query := `INSERT INTO url (user_id, url, code, status, created_at, expiry_at, description, og_title, og_description, og_image, safety_reason, scanned_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`
+
+	logStr := fmt.Sprintf("Save URL in DB : %s, Code: %s, Timestamp: %s", query, u.Code, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, u.UserID, u.Url, u.Code, u.Status, u.CreatedAt, u.ExpiryAt, u.Description, u.OgTitle, u.OgDescription, u.OgImage, u.SafetyReason, nullTime(u.ScannedAt)).Scan(&u.ID, &u.CreatedAt)
+	if isUniqueViolation(rowErr) {
+		return model.ErrUrlCodeExists
+	}
+	if rowErr != nil {
+		return fmt.Errorf("Error while trying to save URL - %w !", ContextErr(writeCtx, rowErr))
+	}
+
+	return nil
+}
+
+func (s *UrlStore) SaveAll(ctx context.Context, urls []*model.Url) error {
+	query := `INSERT INTO url (user_id, url, code, status, created_at, expiry_at, description, og_title, og_description, og_image, safety_reason, scanned_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`
+
+	logStr := fmt.Sprintf("Save %d URLs in DB : %s, Timestamp: %s", len(urls), query, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	stmt, prepareErr := tx.PrepareContext(writeCtx, query)
+	if prepareErr != nil {
+		return fmt.Errorf("Error while trying to save URLs - %w !", ContextErr(writeCtx, prepareErr))
+	}
+	defer stmt.Close()
+
+	for i, u := range urls {
+		rowErr := stmt.QueryRowContext(writeCtx, u.UserID, u.Url, u.Code, u.Status, u.CreatedAt, u.ExpiryAt, u.Description, u.OgTitle, u.OgDescription, u.OgImage, u.SafetyReason, nullTime(u.ScannedAt)).Scan(&u.ID, &u.CreatedAt)
+		if isUniqueViolation(rowErr) {
+			return &model.UrlSaveError{Index: i, Err: model.ErrUrlCodeExists}
+		}
+		if rowErr != nil {
+			return &model.UrlSaveError{Index: i, Err: fmt.Errorf("Error while trying to save URL - %w !", ContextErr(writeCtx, rowErr))}
+		}
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
+
+func (s *UrlStore) EachByUser(ctx context.Context, userID int64, fn func(model.Url) error) error {
+	query := `SELECT ` + urlColumns + ` FROM url WHERE user_id=$1 ORDER BY id`
+
+	logStr := fmt.Sprintf("Export URLs by user from DB : %s, UserID: %d, Timestamp: %s", query, userID, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	// No read timeout, exports run for as long as the client keeps reading
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to export URLs: %w", ContextErr(ctx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url model.Url
+		if err := scanUrl(rows, &url); err != nil {
+			return fmt.Errorf("failed to scan URL: %w", ContextErr(ctx, err))
+		}
+
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read URLs: %w", ContextErr(ctx, err))
+	}
+
+	return nil
+}
+
+func (s *UrlStore) UpdateStatus(ctx context.Context, id int64, status model.UrlStatus) error {
+	query := `UPDATE url SET status=$1 WHERE id=$2`
+
+	logStr := fmt.Sprintf("Update URL status in DB : %s, ID: %d, New Status: %s, Timestamp: %s", query, id, status, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	_, execErr := s.db.ExecContext(writeCtx, query, status, id)
+	if execErr != nil {
+		return fmt.Errorf("Error while trying to update URL status - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	return nil
+}
+
+func (s *UrlStore) UpdateSafety(ctx context.Context, id int64, status model.UrlStatus, reason string, scannedAt time.Time) (bool, error) {
+	// Links disabled, blocked or expired while they were checked stay that way
+	query := `UPDATE url SET status=$1, safety_reason=$2, scanned_at=$3 WHERE id=$4 AND status IN ('active', 'quarantined')`
+
+	logStr := fmt.Sprintf("Update URL safety in DB : %s, ID: %d, Status: %s, Timestamp: %s", query, id, status, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, execErr := s.db.ExecContext(writeCtx, query, status, reason, scannedAt, id)
+	if execErr != nil {
+		return false, fmt.Errorf("Error while trying to update URL safety - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	rows, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return false, fmt.Errorf("Error while trying to update URL safety - %w !", rowsErr)
+	}
+	return rows > 0, nil
+}
+
+func (s *UrlStore) ReplaceStatus(ctx context.Context, id int64, from []model.UrlStatus, status model.UrlStatus, reason string) (bool, error) {
+	args := []any{status, reason, id}
+	placeholders := make([]string, len(from))
+	for i, fromStatus := range from {
+		args = append(args, fromStatus)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	query := `UPDATE url SET status=$1, safety_reason=$2 WHERE id=$3 AND status IN (` + strings.Join(placeholders, ", ") + `)`
+
+	logStr := fmt.Sprintf("Replace URL status in DB : %s, ID: %d, New Status: %s, Timestamp: %s", query, id, status, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, execErr := s.db.ExecContext(writeCtx, query, args...)
+	if execErr != nil {
+		return false, fmt.Errorf("Error while trying to replace URL status - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	rows, rowsErr := result.RowsAffected()
+	if rowsErr != nil {
+		return false, fmt.Errorf("Error while trying to replace URL status - %w !", rowsErr)
+	}
+	return rows > 0, nil
+}
+
+func (s *UrlStore) ListForScan(ctx context.Context, scannedBefore time.Time, limit int) ([]model.Url, error) {
+	var urls []model.Url
+
+	query := `SELECT ` + urlColumns + ` FROM url WHERE status IN ('active', 'quarantined') AND (scanned_at IS NULL OR scanned_at < $1)
+		ORDER BY scanned_at NULLS FIRST, id LIMIT $2`
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(readCtx, query, scannedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list URLs for scan: %w", ContextErr(readCtx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url model.Url
+		if err := scanUrl(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", ContextErr(readCtx, err))
+		}
+
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs: %w", ContextErr(readCtx, err))
+	}
+
+	return urls, nil
+}
+
+func (s *UrlStore) Search(ctx context.Context, filter model.UrlSearchFilter) ([]model.Url, error) {
+	var urls []model.Url
+
+	var args []interface{}
+	var conditions []string
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(code ILIKE $%d OR url ILIKE $%d)", len(args), len(args)))
+	}
+	if filter.UserID != 0 {
+		args = append(args, filter.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id=$%d", len(args)))
+	}
+	if filter.Status.IsValid() {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status=$%d", len(args)))
+	}
+
+	query := `SELECT ` + urlColumns + ` FROM url`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(readCtx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search URLs: %w", ContextErr(readCtx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url model.Url
+		if err := scanUrl(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", ContextErr(readCtx, err))
+		}
+
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read URLs: %w", ContextErr(readCtx, err))
+	}
+
+	return urls, nil
+}
+
+// nullTime stores zero times as NULL
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
@@ -0,0 +1,279 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type UserStore struct {
+	db *sql.DB
+}
+
+const userColumns = `id, email, password, plan, role, status, created_at, click_privacy`
+
+func scanUser(row interface{ Scan(...any) error }, user *model.User) error {
+	return row.Scan(&user.ID, &user.Email, &user.Password, &user.Plan, &user.Role, &user.Status, &user.CreatedAt, &user.ClickPrivacy)
+}
+
+func (s *UserStore) GetByEmail(ctx context.Context, email string) (model.User, error) {
+	// Exact match ignoring case, ILIKE would treat _ and % in the email as wildcards
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(email) = lower($1)`
+
+	logStr := fmt.Sprintf("Check User via EMAIL: %s, %s", query, email)
+	utils.Log.Info(logStr)
+
+	return s.getUser(ctx, query, email)
+}
+
+func (s *UserStore) GetByID(ctx context.Context, id int64) (model.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+
+	logStr := fmt.Sprintf("Check User via ID: %s, %d", query, id)
+	utils.Log.Info(logStr)
+
+	return s.getUser(ctx, query, id)
+}
+
+func (s *UserStore) getUser(ctx context.Context, query string, arg any) (model.User, error) {
+	var user model.User
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	err := scanUser(s.db.QueryRowContext(readCtx, query, arg), &user)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return user, model.ErrUserNotFound
+		}
+		return user, ContextErr(readCtx, err)
+	}
+
+	return user, nil
+}
+
+func (s *UserStore) Save(ctx context.Context, u *model.User) error {
+	query := `INSERT INTO users (email, password, plan, role, status, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+
+	logStr := fmt.Sprintf("Save user in DB : %s, Email: %s, Timestamp: %s", query, u.Email, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(writeCtx, query, u.Email, u.Password, u.Plan, u.Role, u.Status, time.Now().UTC()).Scan(&u.ID, &u.CreatedAt)
+	if isUniqueViolation(rowErr) {
+		return model.ErrUserExists
+	}
+
+	return ContextErr(writeCtx, rowErr)
+}
+
+func (s *UserStore) Search(ctx context.Context, filter model.UserSearchFilter) ([]model.User, error) {
+	var users []model.User
+
+	var args []interface{}
+	var conditions []string
+
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("email ILIKE $%d", len(args)))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("role=$%d", len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status=$%d", len(args)))
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(readCtx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search users: %w", ContextErr(readCtx, err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user model.User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", ContextErr(readCtx, err))
+		}
+
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", ContextErr(readCtx, err))
+	}
+
+	return users, nil
+}
+
+func (s *UserStore) UpdateRole(ctx context.Context, id int64, role model.UserRole) error {
+	query := `UPDATE users SET role=$1 WHERE id=$2`
+
+	logStr := fmt.Sprintf("Update user role in DB : %s, ID: %d, New Role: %s, Timestamp: %s", query, id, role, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	return s.update(ctx, query, role, id)
+}
+
+func (s *UserStore) UpdateStatus(ctx context.Context, id int64, status model.UserStatus) error {
+	query := `UPDATE users SET status=$1 WHERE id=$2`
+
+	logStr := fmt.Sprintf("Update user status in DB : %s, ID: %d, New Status: %s, Timestamp: %s", query, id, status, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	return s.update(ctx, query, status, id)
+}
+
+func (s *UserStore) update(ctx context.Context, query string, args ...any) error {
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	result, execErr := s.db.ExecContext(writeCtx, query, args...)
+	if execErr != nil {
+		return fmt.Errorf("Error while trying to update user - %w !", ContextErr(writeCtx, execErr))
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return model.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *UserStore) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	query := `UPDATE users SET password=$1 WHERE id=$2`
+
+	logStr := fmt.Sprintf("Update user password in DB : ID: %d, Timestamp: %s", id, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	return s.update(ctx, query, passwordHash, id)
+}
+
+func (s *UserStore) UpdateClickPrivacy(ctx context.Context, id int64, privacy model.ClickPrivacy) error {
+	query := `UPDATE users SET click_privacy=$1 WHERE id=$2`
+
+	logStr := fmt.Sprintf("Update user click privacy in DB : %s, ID: %d, New Mode: %s, Timestamp: %s", query, id, privacy, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	return s.update(ctx, query, privacy, id)
+}
+
+func (s *UserStore) UpdateEmail(ctx context.Context, id int64, email string) error {
+	query := `UPDATE users SET email=$1 WHERE id=$2`
+
+	logStr := fmt.Sprintf("Update user email in DB : %s, ID: %d, Timestamp: %s", query, id, time.Now().UTC())
+	utils.Log.Info(logStr)
+
+	updateErr := s.update(ctx, query, email, id)
+	if isUniqueViolation(updateErr) {
+		return model.ErrUserExists
+	}
+	return updateErr
+}
+
+func (s *UserStore) Delete(ctx context.Context, user model.User, tombstone model.UserTombstone) error {
+	utils.Log.Info("Delete user in DB : ID: ", user.ID)
+
+	writeCtx, cancel := WriteContext(ctx)
+	defer cancel()
+
+	tx, txErr := s.db.BeginTx(writeCtx, nil)
+	if txErr != nil {
+		return ContextErr(writeCtx, txErr)
+	}
+	defer tx.Rollback()
+
+	// Children first, the foreign keys have no ON DELETE CASCADE
+	deletes := []struct {
+		table string
+		query string
+		arg   any
+	}{
+		{"analytics", `DELETE FROM analytics WHERE url_id IN (SELECT id FROM url WHERE user_id = $1)`, user.ID},
+		{"analytics_hourly", `DELETE FROM analytics_hourly WHERE url_id IN (SELECT id FROM url WHERE user_id = $1)`, user.ID},
+		{"analytics_daily", `DELETE FROM analytics_daily WHERE url_id IN (SELECT id FROM url WHERE user_id = $1)`, user.ID},
+		{"abuse_reports", `DELETE FROM abuse_reports WHERE url_id IN (SELECT id FROM url WHERE user_id = $1)`, user.ID},
+		{"url", `DELETE FROM url WHERE user_id = $1`, user.ID},
+		{"refresh_tokens", `DELETE FROM refresh_tokens WHERE user_id = $1`, user.ID},
+		{"user_recovery_codes", `DELETE FROM user_recovery_codes WHERE user_id = $1`, user.ID},
+		{"user_totp", `DELETE FROM user_totp WHERE user_id = $1`, user.ID},
+		{"user_identities", `DELETE FROM user_identities WHERE user_id = $1`, user.ID},
+		{"otp", `DELETE FROM otp WHERE key ILIKE $1`, escapeLike(user.Email)},
+	}
+	for _, d := range deletes {
+		if _, err := tx.ExecContext(writeCtx, d.query, d.arg); err != nil {
+			return fmt.Errorf("Error while trying to delete %s - %w !", d.table, ContextErr(writeCtx, err))
+		}
+	}
+
+	// Audit events and reports filed by the user are kept without what
+	// identifies them. The append-only trigger lets marked transactions through.
+	anonymizes := []struct {
+		table string
+		query string
+		args  []any
+	}{
+		{"audit_events", `SET LOCAL audit_events.maintenance = 'on'`, nil},
+		{"audit_events", `UPDATE audit_events SET ip_address = '', user_agent = '', changes = changes - 'email', details = details - 'email'
+			WHERE user_id = $1 OR actor_id = $1`, []any{user.ID}},
+		{"abuse_reports", `UPDATE abuse_reports SET reporter_ip = '', reporter_email = '', reporter_id = NULL
+			WHERE reporter_id = $1 OR lower(reporter_email) = lower($2)`, []any{user.ID, user.Email}},
+	}
+	for _, a := range anonymizes {
+		if _, err := tx.ExecContext(writeCtx, a.query, a.args...); err != nil {
+			return fmt.Errorf("Error while trying to anonymize %s - %w !", a.table, ContextErr(writeCtx, err))
+		}
+	}
+
+	result, deleteErr := tx.ExecContext(writeCtx, `DELETE FROM users WHERE id = $1`, user.ID)
+	if deleteErr != nil {
+		return fmt.Errorf("Error while trying to delete user - %w !", ContextErr(writeCtx, deleteErr))
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return model.ErrUserNotFound
+	}
+
+	_, tombstoneErr := tx.ExecContext(writeCtx, `INSERT INTO user_tombstones (email_hash, deleted_at) VALUES ($1, $2)
+		ON CONFLICT (email_hash) DO UPDATE SET deleted_at = EXCLUDED.deleted_at`, tombstone.EmailHash, tombstone.DeletedAt)
+	if tombstoneErr != nil {
+		return fmt.Errorf("Error while trying to save tombstone - %w !", ContextErr(writeCtx, tombstoneErr))
+	}
+
+	return ContextErr(writeCtx, tx.Commit())
+}
+
+func (s *UserStore) GetTombstone(ctx context.Context, emailHash string) (model.UserTombstone, error) {
+	var tombstone model.UserTombstone
+
+	readCtx, cancel := ReadContext(ctx)
+	defer cancel()
+
+	rowErr := s.db.QueryRowContext(readCtx, `SELECT email_hash, deleted_at FROM user_tombstones WHERE email_hash = $1`, emailHash).
+		Scan(&tombstone.EmailHash, &tombstone.DeletedAt)
+	if rowErr != nil {
+		if rowErr == sql.ErrNoRows {
+			return tombstone, model.ErrTombstoneNotFound
+		}
+		return tombstone, fmt.Errorf("Error while trying to get tombstone - %w !", ContextErr(readCtx, rowErr))
+	}
+
+	return tombstone, nil
+}
@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	redis "github.com/redis/go-redis/v9"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+)
+
+// Salts outlive their day a little, clicks can arrive just after midnight
+const visitorSaltTTL = 25 * time.Hour
+
+const visitorTotalSaltKey = "visitors:salt:total"
+
+// RedisVisitorStore implements model.VisitorStore with HyperLogLogs, one per
+// link and day plus an all-time one per link. Every click moves the expiry of
+// both, plain EXPIRE works on any Redis version.
+type RedisVisitorStore struct {
+	client *redis.Client
+
+	mu        sync.Mutex
+	saltDay   string // Day of the cached salt
+	salt      []byte
+	totalSalt []byte
+}
+
+func NewRedisVisitorStore(client *redis.Client) *RedisVisitorStore {
+	return &RedisVisitorStore{client: client}
+}
+
+func visitorDayKey(urlID int64, day string) string {
+	return fmt.Sprintf("visitors:%d:%s", urlID, day)
+}
+
+func visitorTotalKey(urlID int64) string {
+	return fmt.Sprintf("visitors:%d", urlID)
+}
+
+// visitorSalt returns the salt at key, the first instance to need it picks
+// it and the others read it
+func (s *RedisVisitorStore) visitorSalt(ctx context.Context, key string, ttl time.Duration) ([]byte, error) {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	created, setErr := s.client.SetNX(redisCtx, key, salt, ttl).Result()
+	if setErr != nil {
+		return nil, ContextErr(redisCtx, setErr)
+	}
+	if !created {
+		stored, getErr := s.client.Get(redisCtx, key).Bytes()
+		if getErr != nil {
+			return nil, ContextErr(redisCtx, getErr)
+		}
+		salt = stored
+	}
+	return salt, nil
+}
+
+func (s *RedisVisitorStore) DailySalt(ctx context.Context, day string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.saltDay == day {
+		return s.salt, nil
+	}
+
+	salt, err := s.visitorSalt(ctx, "visitors:salt:"+day, visitorSaltTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.saltDay, s.salt = day, salt
+	return salt, nil
+}
+
+func (s *RedisVisitorStore) TotalSalt(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.totalSalt != nil {
+		return s.totalSalt, nil
+	}
+
+	salt, err := s.visitorSalt(ctx, visitorTotalSaltKey, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s.totalSalt = salt
+	return salt, nil
+}
+
+func (s *RedisVisitorStore) Add(ctx context.Context, urlID int64, day string, dayVisitor string, totalVisitor string) error {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	dayKey, totalKey := visitorDayKey(urlID, day), visitorTotalKey(urlID)
+	pipe := s.client.TxPipeline()
+	pipe.PFAdd(redisCtx, dayKey, dayVisitor)
+	// Day keys only get clicks of their day, their expiry moves by a day at most
+	pipe.Expire(redisCtx, dayKey, config.Config.ANALYTICS.VisitorRetention)
+	pipe.PFAdd(redisCtx, totalKey, totalVisitor)
+	if retention := config.Config.ANALYTICS.VisitorTotalRetention; retention > 0 {
+		pipe.Expire(redisCtx, totalKey, retention)
+	}
+	_, err := pipe.Exec(redisCtx)
+	return ContextErr(redisCtx, err)
+}
+
+// Delete drops the all-time key and the day keys still within
+// ANALYTICS_VISITOR_RETENTION, older ones have expired
+func (s *RedisVisitorStore) Delete(ctx context.Context, urlIDs []int64) error {
+	if len(urlIDs) == 0 {
+		return nil
+	}
+
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	today := time.Now().UTC()
+	days := int(config.Config.ANALYTICS.VisitorRetention/(24*time.Hour)) + 1
+
+	pipe := s.client.Pipeline()
+	for _, urlID := range urlIDs {
+		keys := []string{visitorTotalKey(urlID)}
+		for i := 0; i <= days; i++ {
+			keys = append(keys, visitorDayKey(urlID, model.VisitorDay(today.AddDate(0, 0, -i))))
+		}
+		pipe.Unlink(redisCtx, keys...)
+	}
+	_, err := pipe.Exec(redisCtx)
+	return ContextErr(redisCtx, err)
+}
+
+func (s *RedisVisitorStore) CountTotal(ctx context.Context, urlIDs []int64) (map[int64]int64, error) {
+	return s.count(ctx, urlIDs, visitorTotalKey)
+}
+
+func (s *RedisVisitorStore) CountDay(ctx context.Context, urlIDs []int64, day string) (map[int64]int64, error) {
+	return s.count(ctx, urlIDs, func(urlID int64) string { return visitorDayKey(urlID, day) })
+}
+
+func (s *RedisVisitorStore) CountDays(ctx context.Context, urlID int64, days []string) (map[string]int64, error) {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	counts := make(map[string]*redis.IntCmd, len(days))
+	for _, day := range days {
+		counts[day] = pipe.PFCount(redisCtx, visitorDayKey(urlID, day))
+	}
+	if _, err := pipe.Exec(redisCtx); err != nil {
+		return nil, ContextErr(redisCtx, err)
+	}
+
+	result := make(map[string]int64, len(days))
+	for day, count := range counts {
+		result[day] = count.Val()
+	}
+	return result, nil
+}
+
+func (s *RedisVisitorStore) count(ctx context.Context, urlIDs []int64, key func(int64) string) (map[int64]int64, error) {
+	redisCtx, cancel := RedisContext(ctx)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	counts := make(map[int64]*redis.IntCmd, len(urlIDs))
+	for _, urlID := range urlIDs {
+		counts[urlID] = pipe.PFCount(redisCtx, key(urlID))
+	}
+	if _, err := pipe.Exec(redisCtx); err != nil {
+		return nil, ContextErr(redisCtx, err)
+	}
+
+	result := make(map[int64]int64, len(urlIDs))
+	for urlID, count := range counts {
+		result[urlID] = count.Val()
+	}
+	return result, nil
+}
@@ -15,56 +15,112 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/app/ping": {
+        "/.well-known/jwks.json": {
             "get": {
-                "description": "Health check endpoint. Returns \"pong\" if the server is running.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Public keys our JWTs are signed with, looked up by the ` + "`" + `kid` + "`" + ` header. Keys are rotated on a schedule, so verifiers should refresh this on unknown kids.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "App"
+                    "Auth"
                 ],
-                "summary": "Ping",
+                "summary": "JSON Web Key Set",
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"pong\\\"}",
+                        "description": "Success\" \"Example: {\\\"keys\\\": [{\\\"kty\\\": \\\"RSA\\\", \\\"kid\\\": \\\"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs\\\", \\\"use\\\": \\\"sig\\\", \\\"alg\\\": \\\"RS256\\\", \\\"n\\\": \\\"...\\\", \\\"e\\\": \\\"AQAB\\\"}]}",
                         "schema": {
-                            "$ref": "#/definitions/model.APIResponse"
+                            "$ref": "#/definitions/utils.JwkSet"
                         }
                     }
                 }
             }
         },
-        "/otp/send": {
-            "post": {
-                "description": "Sends an OTP to the user for verification.",
-                "consumes": [
-                    "application/json"
+        "/admin/audit": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Lists audit events of all users, newest first. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "OTP"
+                    "Admin"
                 ],
-                "summary": "Send OTP",
+                "summary": "Search Audit Log",
                 "parameters": [
                     {
-                        "description": "OTP request payload",
-                        "name": "otpRequest",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.SendOtp"
-                        }
+                        "type": "integer",
+                        "description": "User the event concerns",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "User who caused the event",
+                        "name": "actor_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action, e.g. login_failed or link_status_change",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "url",
+                            "otp"
+                        ],
+                        "type": "string",
+                        "description": "Target type",
+                        "name": "target_type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Target ID",
+                        "name": "target_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Client IP address",
+                        "name": "ip",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Events at or after this time (RFC 3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Events before this time (RFC 3339)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, up to 200",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"OTP sent successfully\\\", \\\"data\\\": {\\\"id\\\": \\\"123\\\", \\\"token\\\": \\\"abcde12345\\\"}}",
+                        "description": "Success",
                         "schema": {
                             "allOf": [
                                 {
@@ -74,15 +130,15 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model.SendOTPResponse"
+                                            "$ref": "#/definitions/model.AuditEventsResponse"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Invalid OTP type or action type\\\"}",
+                    "403": {
+                        "description": "Forbidden\" \"Example: {\\\"code\\\": \\\"forbidden\\\", \\\"message\\\": \\\"You don't have permission to access this resource\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -90,39 +146,74 @@ const docTemplate = `{
                 }
             }
         },
-        "/otp/verify": {
-            "post": {
-                "description": "Verifies the OTP code sent to the user.",
-                "consumes": [
-                    "application/json"
+        "/admin/reports": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Lists abuse reports, newest first. status=open is the moderation queue. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "OTP"
+                    "Admin"
                 ],
-                "summary": "Verify OTP",
+                "summary": "Search Abuse Reports",
                 "parameters": [
                     {
-                        "description": "OTP verify payload",
-                        "name": "otpVerifyRequest",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.VerifyOtp"
-                        }
+                        "enum": [
+                            "open",
+                            "dismissed",
+                            "actioned"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Short code of the reported link",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, up to 200",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"OTP verified successfully\\\"}",
+                        "description": "Success",
                         "schema": {
-                            "$ref": "#/definitions/model.APIResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.AbuseReportsResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Invalid OTP code\\\"}",
+                    "403": {
+                        "description": "Forbidden\" \"Example: {\\\"code\\\": \\\"forbidden\\\", \\\"message\\\": \\\"You don't have permission to access this resource\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -130,35 +221,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/url/list": {
+        "/admin/reports/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get all shortened URLs for the authenticated user.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Returns the report with the reported link and all reports on it. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "URL"
+                    "Admin"
                 ],
-                "summary": "List User URLs",
+                "summary": "Get Abuse Report",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Filter by URL status",
-                        "name": "status",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "Report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"URLs fetched successfully\\\", \\\"data\\\": {\\\"urls\\\": [{\\\"code\\\": \\\"abc123\\\", \\\"url\\\": \\\"https://example.com\\\"}]}}",
+                        "description": "Success",
                         "schema": {
                             "allOf": [
                                 {
@@ -168,15 +257,15 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model.GetUrlsByUserResponse"
+                                            "$ref": "#/definitions/model.AbuseReportResponse"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Invalid URL status !\\\"}",
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"report_not_found\\\", \\\"message\\\": \\\"Report not found\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -184,38 +273,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/url/register": {
+        "/admin/reports/{id}/disable": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Create a new short URL for the authenticated user.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "Blocks the reported link, closes its open reports and notifies the owner. Visitors see a warning page. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "URL"
+                    "Admin"
                 ],
-                "summary": "Register Short URL",
+                "summary": "Block Reported Link",
                 "parameters": [
                     {
-                        "description": "Create short URL payload",
-                        "name": "createUrl",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.CreateShortUrl"
-                        }
+                        "type": "integer",
+                        "description": "Report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"Short URL created successfully\\\", \\\"data\\\": {\\\"short_url\\\": \\\"https://short.ly/abc123\\\"}}",
+                        "description": "Success",
                         "schema": {
                             "allOf": [
                                 {
@@ -225,15 +309,15 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model.CreateShortUrlResponse"
+                                            "$ref": "#/definitions/model.AbuseReportResolution"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"url\\\", \\\"error\\\": \\\"invalid URL\\\"}]}",
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"report_not_found\\\", \\\"message\\\": \\\"Report not found\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -241,33 +325,33 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/login": {
+        "/admin/reports/{id}/dismiss": {
             "post": {
-                "description": "Login with email, password, and OTP. Returns JWT token on success.",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Closes all open reports on the reported link as unfounded. A link blocked automatically is reactivated. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "User Login",
+                "summary": "Dismiss Abuse Reports",
                 "parameters": [
                     {
-                        "description": "Login payload",
-                        "name": "loginUser",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.LoginUser"
-                        }
+                        "type": "integer",
+                        "description": "Report ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User logged in successfully !\\\", \\\"data\\\": {\\\"token\\\": \\\"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\\\"}}",
+                        "description": "Success",
                         "schema": {
                             "allOf": [
                                 {
@@ -277,15 +361,15 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                            "$ref": "#/definitions/model.AbuseReportResolution"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"email\\\", \\\"error\\\": \\\"invalid email\\\"}]}",
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"report_not_found\\\", \\\"message\\\": \\\"Report not found\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -293,27 +377,24 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/refresh-token": {
-            "post": {
+        "/admin/stats": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Refresh token. Returns JWT token on success.",
-                "consumes": [
-                    "application/json"
-                ],
+                "description": "System wide counts of users, links and clicks. Needs the admin role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "User Refresh Token",
+                "summary": "System Stats",
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User logged in successfully !\\\", \\\"data\\\": {\\\"token\\\": \\\"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\\\"}}",
+                        "description": "Success",
                         "schema": {
                             "allOf": [
                                 {
@@ -323,15 +404,15 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                            "$ref": "#/definitions/model.SystemStats"
                                         }
                                     }
                                 }
                             ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"email\\\", \\\"error\\\": \\\"invalid email\\\"}]}",
+                    "403": {
+                        "description": "Forbidden\" \"Example: {\\\"code\\\": \\\"forbidden\\\", \\\"message\\\": \\\"You don't have permission to access this resource\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -339,39 +420,84 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/sign-up": {
-            "post": {
-                "description": "Register a new user with email, password, and OTP verification.",
-                "consumes": [
-                    "application/json"
+        "/admin/urls": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Lists links of all users, newest first. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "User Sign Up",
+                "summary": "Search Links",
                 "parameters": [
                     {
-                        "description": "Sign up payload",
-                        "name": "userToSignUp",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.SignUpUser"
-                        }
+                        "type": "string",
+                        "description": "Part of the code or destination URL",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Owner",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "inactive",
+                            "deleted",
+                            "expired",
+                            "disabled",
+                            "quarantined",
+                            "blocked"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, up to 200",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User signed up successfully !\\\"}",
+                    "200": {
+                        "description": "Success",
                         "schema": {
-                            "$ref": "#/definitions/model.APIResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.AdminUrlsResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"otp_code\\\", \\\"error\\\": \\\"invalid OTP\\\"}]}",
+                    "403": {
+                        "description": "Forbidden\" \"Example: {\\\"code\\\": \\\"forbidden\\\", \\\"message\\\": \\\"You don't have permission to access this resource\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -379,39 +505,51 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/verify-credentials": {
+        "/admin/urls/{code}/deactivate": {
             "post": {
-                "description": "Verifies user email and password.",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Disables a link so it stops redirecting. Owners can't reactivate it. Needs the support role.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Auth"
+                    "Admin"
                 ],
-                "summary": "Verify User Credentials",
+                "summary": "Deactivate Link",
                 "parameters": [
                     {
-                        "description": "User credentials payload",
-                        "name": "userCreds",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/model.UserCredentials"
-                        }
+                        "type": "string",
+                        "description": "Short code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User credentials are valid.\\\"}",
+                        "description": "Success",
                         "schema": {
-                            "$ref": "#/definitions/model.APIResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Url"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"password\\\", \\\"error\\\": \\\"password too weak\\\"}]}",
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -419,9 +557,209 @@ const docTemplate = `{
                 }
             }
         },
-        "/{code}": {
+        "/admin/urls/{code}/reactivate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Re-enables a link disabled by staff or blocked after abuse reports. Needs the support role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reactivate Link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.Url"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Not disabled\" \"Example: {\\\"code\\\": \\\"url_not_disabled\\\", \\\"message\\\": \\\"Only disabled or blocked links can be reactivated\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists users, newest first. Needs the support role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Search Users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Part of the email",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "user",
+                            "support",
+                            "admin"
+                        ],
+                        "type": "string",
+                        "description": "Role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "suspended"
+                        ],
+                        "type": "string",
+                        "description": "Status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, up to 200",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.AdminUsersResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden\" \"Example: {\\\"code\\\": \\\"forbidden\\\", \\\"message\\\": \\\"You don't have permission to access this resource\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}": {
             "get": {
-                "description": "Redirects to the original URL using the short code.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns a user with all their links. Needs the support role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get User",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.AdminUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"user_not_found\\\", \\\"message\\\": \\\"User not found\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{id}/role": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Grants or revokes a staff role. Admins can't change their own role. Needs the admin role.",
                 "consumes": [
                     "application/json"
                 ],
@@ -429,52 +767,3170 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "URL"
+                    "Admin"
                 ],
-                "summary": "Redirect Short URL",
+                "summary": "Change User Role",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Short URL code",
-                        "name": "code",
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "New role",
+                        "name": "role",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateUserRole"
+                        }
                     }
                 ],
                 "responses": {
-                    "400": {
-                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"URL has expired\\\"}",
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.User"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Own role\" \"Example: {\\\"code\\\": \\\"own_role\\\", \\\"message\\\": \\\"You can't change your own role\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"user_not_found\\\", \\\"message\\\": \\\"User not found\\\"}",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.APIResponse": {
-            "type": "object",
-            "properties": {
-                "data": {},
-                "message": {
-                    "type": "string",
-                    "example": "User logged in successfully !"
+        },
+        "/admin/users/{id}/suspend": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Blocks the user from logging in and revokes their refresh tokens. Issued access tokens are refused within JWT_USER_STATUS_CACHE_TTL. Staff accounts can't be suspended. Needs the support role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Suspend User",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.User"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Staff account\" \"Example: {\\\"code\\\": \\\"user_is_staff\\\", \\\"message\\\": \\\"Staff accounts can't be suspended, change their role first\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"user_not_found\\\", \\\"message\\\": \\\"User not found\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
                 }
             }
         },
-        "model.CreateShortUrl": {
-            "type": "object",
+        "/admin/users/{id}/unsuspend": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lets a suspended user log in again. Needs the support role.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Unsuspend User",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.User"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"user_not_found\\\", \\\"message\\\": \\\"User not found\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/app/ping": {
+            "get": {
+                "description": "Health check endpoint. Returns \"pong\" if the server is running.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "App"
+                ],
+                "summary": "Ping",
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"pong\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc": {
+            "get": {
+                "description": "Lists the OpenID Connect providers users can sign in with.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "List SSO Providers",
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"Success\\\", \\\"data\\\": {\\\"providers\\\": [\\\"google\\\"]}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/routes.OidcProvidersResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/{provider}/callback": {
+            "get": {
+                "description": "Completes an SSO login and returns a JWT token pair. Unknown identities are linked to the user with the same verified email, or a new user is created. Accounts with TOTP enabled get a model.TotpChallengeResponse instead, to be completed at /user/login/2fa.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "SSO Callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name from OIDC_PROVIDERS",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Authorization code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "State from the login redirect",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User logged in successfully !\\\", \\\"data\\\": {\\\"token\\\": \\\"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\\\"}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid state\" \"Example: {\\\"code\\\": \\\"sso_state_invalid\\\", \\\"message\\\": \\\"Sign-in request is invalid or has expired. Please try again.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Login failed\" \"Example: {\\\"code\\\": \\\"sso_login_failed\\\", \\\"message\\\": \\\"Sign-in with the identity provider failed\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Unverified email\" \"Example: {\\\"code\\\": \\\"sso_email_unverified\\\", \\\"message\\\": \\\"Your identity provider did not confirm a verified email address\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/oidc/{provider}/login": {
+            "get": {
+                "description": "Redirects to the identity provider to sign in. The request state is kept in a short-lived encrypted cookie.",
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "SSO Login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Provider name from OIDC_PROVIDERS",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the identity provider"
+                    },
+                    "404": {
+                        "description": "Unknown provider\" \"Example: {\\\"code\\\": \\\"sso_provider_not_found\\\", \\\"message\\\": \\\"Unknown sign-in provider\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Provider unavailable\" \"Example: {\\\"code\\\": \\\"sso_provider_unavailable\\\", \\\"message\\\": \\\"Identity provider is not reachable\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/otp/send": {
+            "post": {
+                "description": "Sends an OTP to the user for verification. Email OTPs are mailed, phone OTPs (E.164 key) go out by SMS. Login OTPs for emails without an account get the same answer but are never sent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OTP"
+                ],
+                "summary": "Send OTP",
+                "parameters": [
+                    {
+                        "description": "OTP request payload",
+                        "name": "otpRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SendOtp"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"OTP sent successfully\\\", \\\"data\\\": {\\\"id\\\": \\\"123\\\", \\\"token\\\": \\\"abcde12345\\\"}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.SendOTPResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Invalid OTP type or action type\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "OTP sent recently\" \"Example: {\\\"code\\\": \\\"otp_recently_sent\\\", \\\"message\\\": \\\"OTP already sent recently\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "No SMS provider configured\" \"Example: {\\\"code\\\": \\\"otp_type_unavailable\\\", \\\"message\\\": \\\"OTPs of this type can't be delivered, try another type\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/otp/verify": {
+            "post": {
+                "description": "Verifies the OTP code sent to the user.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OTP"
+                ],
+                "summary": "Verify OTP",
+                "parameters": [
+                    {
+                        "description": "OTP verify payload",
+                        "name": "otpVerifyRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.VerifyOtp"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"OTP verified successfully\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Invalid OTP code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "OTP expired\" \"Example: {\\\"code\\\": \\\"otp_expired\\\", \\\"message\\\": \\\"OTP has expired. Please request a new one.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/report/{code}": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reports a short link as malicious. No login needed, one open report per link and IP address (IPv6 per /64 network) or user. Links are blocked once ABUSE_AUTO_BLOCK_REPORTS signed-in users reported them, anonymous reports only go to the moderation queue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Report Link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Report",
+                        "name": "report",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateAbuseReport"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"Thanks, the link was reported\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"reason\\\", \\\"error\\\": \\\"must be one of phishing malware spam other\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Already reported\" \"Example: {\\\"code\\\": \\\"report_exists\\\", \\\"message\\\": \\\"You already reported this link\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/url/bulk": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates many short URLs at once from a JSON array or a CSV file, sent as the text/csv body or as the \"file\" field of a multipart form. CSV files need a header row with a url column and optionally code and expires_at; Bitly and TinyURL exports are accepted as is. Every row gets a result with the created code or its error. With mode=transaction nothing is created unless all rows succeed. Every row counts against the link creation rate limit, so an upload can have at most as many rows as the plan's burst and is refused with 429 when fewer links are left.",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Bulk Create Short URLs",
+                "parameters": [
+                    {
+                        "enum": [
+                            "best_effort",
+                            "transaction"
+                        ],
+                        "type": "string",
+                        "default": "best_effort",
+                        "description": "best_effort creates the valid rows, transaction all rows or none",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Links to create, when sending JSON",
+                        "name": "links",
+                        "in": "body",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.CreateShortUrl"
+                            }
+                        }
+                    },
+                    {
+                        "type": "file",
+                        "description": "CSV file, when sending a multipart form",
+                        "name": "file",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.BulkCreateUrlsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid upload\" \"Example: {\\\"code\\\": \\\"bulk_exceeds_rate_limit\\\", \\\"message\\\": \\\"Up to 10 links can be created at once on your plan\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Transaction failed, nothing was created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.BulkCreateUrlsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/url/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Downloads all links of the authenticated user with their click counts, oldest first. The CSV export can be uploaded to /url/bulk again.",
+                "produces": [
+                    "text/csv",
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Export Short URLs",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "json"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "File format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Links, as CSV unless format=json",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.UrlWithShortCode"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/url/list": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get all shortened URLs for the authenticated user. click_count leaves out clicks of crawlers, scanners and health checkers unless include_bots=true, which adds them and reports them in bot_click_count. unique_visitors has the unique visitors of today and all-time, see /url/{code}/analytics.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "List User URLs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Filter by URL status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count clicks of bots too",
+                        "name": "include_bots",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"URLs fetched successfully\\\", \\\"data\\\": {\\\"urls\\\": [{\\\"code\\\": \\\"abc123\\\", \\\"url\\\": \\\"https://example.com\\\"}]}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.GetUrlsByUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Invalid URL status !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/url/register": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new short URL for the authenticated user.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Register Short URL",
+                "parameters": [
+                    {
+                        "description": "Create short URL payload",
+                        "name": "createUrl",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CreateShortUrl"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"Short URL created successfully\\\", \\\"data\\\": {\\\"short_url\\\": \\\"https://short.ly/abc123\\\"}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.CreateShortUrlResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"url\\\", \\\"error\\\": \\\"invalid URL\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Code taken\" \"Example: {\\\"code\\\": \\\"url_code_exists\\\", \\\"message\\\": \\\"URL code already exists !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/url/{code}/analytics": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Clicks and unique visitors of one of your links per day, the last 30 days unless from and to are given (up to 366 days). Clicks of bots are left out unless include_bots=true. Unique visitors are estimated from a hash of IP address and user agent with a salt that changes daily, so all-time counts a visitor once per day they came. Ranges over 7 days or past the raw click retention are read from daily rollups, which lag up to ANALYTICS_ROLLUP_INTERVAL behind. interval=hour adds clicks per hour for ranges of up to 7 days. Top referrer domains, countries and devices always come from the rollups.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Link Analytics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "First day, YYYY-MM-DD (UTC)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last day, YYYY-MM-DD (UTC), defaults to today",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Count clicks of bots too",
+                        "name": "include_bots",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "day",
+                            "hour"
+                        ],
+                        "type": "string",
+                        "description": "Add clicks per hour",
+                        "name": "interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.UrlAnalytics"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid range\" \"Example: {\\\"code\\\": \\\"analytics_range_invalid\\\", \\\"message\\\": \\\"from must not be after to\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/url/{code}/live": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Streams the clicks on one of your links as they happen, as Server-Sent Events. Every click is a ` + "`" + `click` + "`" + ` event with the referrer domain, country, device and whether it came from a bot; nothing identifying the visitor is sent. Comments are sent every LIVE_HEARTBEAT_INTERVAL to keep the connection open. Clicks a slow reader can't keep up with are dropped and reported in a ` + "`" + `dropped` + "`" + ` event, readers not taking events within LIVE_WRITE_TIMEOUT are disconnected. Streams end after LIVE_MAX_DURATION with an ` + "`" + `end` + "`" + ` event and clients reconnect after the ` + "`" + `retry` + "`" + ` delay; they also end with an ` + "`" + `end` + "`" + ` event when the link is deleted or the account suspended. Each user can have LIVE_MAX_STREAMS streams open at once. Links are addressed by their code like the other /url routes. Clicks made while not connected are not replayed.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Live Clicks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "event: click\\ndata: {\\\"code\\\":\\\"abc\\\",\\\"at\\\":\\\"2026-01-01T12:00:00Z\\\",\\\"bot\\\":false,\\\"country\\\":\\\"DE\\\",\\\"device\\\":\\\"mobile\\\"}",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many streams\" \"Example: {\\\"code\\\": \\\"live_streams_exceeded\\\", \\\"message\\\": \\\"Too many live streams open at once, close one first\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/totp": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Whether TOTP two-factor authentication is enabled and how many recovery codes are left.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "TOTP Status",
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.TotpStatusResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/totp/disable": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Turns off TOTP two-factor authentication after checking a current TOTP or recovery code. Login falls back to email OTPs.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Disable TOTP",
+                "parameters": [
+                    {
+                        "description": "Current TOTP or recovery code",
+                        "name": "secondFactor",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SecondFactor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"Two-factor authentication disabled\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Not enabled\" \"Example: {\\\"code\\\": \\\"totp_not_enabled\\\", \\\"message\\\": \\\"Two-factor authentication is not enabled for this account\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code\" \"Example: {\\\"code\\\": \\\"recovery_code_invalid\\\", \\\"message\\\": \\\"Invalid or already used recovery code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/totp/enable": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Confirms the pending TOTP secret with a code from the authenticator app. Returns one-time recovery codes, shown only once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Enable TOTP",
+                "parameters": [
+                    {
+                        "description": "Code from the authenticator app",
+                        "name": "confirmTotp",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ConfirmTotp"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.TotpEnabledResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid code\" \"Example: {\\\"code\\\": \\\"totp_code_invalid\\\", \\\"message\\\": \\\"Invalid authenticator code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/totp/re-enroll": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replaces the authenticator after checking a current TOTP or recovery code. The old authenticator keeps working until the new one is confirmed with /user/2fa/totp/enable.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Re-enroll TOTP",
+                "parameters": [
+                    {
+                        "description": "Current TOTP or recovery code",
+                        "name": "secondFactor",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SecondFactor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.TotpSetupResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Not enabled\" \"Example: {\\\"code\\\": \\\"totp_not_enabled\\\", \\\"message\\\": \\\"Two-factor authentication is not enabled for this account\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid code\" \"Example: {\\\"code\\\": \\\"totp_code_invalid\\\", \\\"message\\\": \\\"Invalid authenticator code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/2fa/totp/setup": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates a TOTP secret to add to an authenticator app, via the otpauth URI or QR code. Confirm it with /user/2fa/totp/enable.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Set Up TOTP",
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.TotpSetupResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Already enabled\" \"Example: {\\\"code\\\": \\\"totp_already_enabled\\\", \\\"message\\\": \\\"Two-factor authentication is already enabled, re-enroll to change the authenticator\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/audit": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Lists the audit events of the logged in user, newest first: logins, failed logins, OTP requests, token refreshes, account and link changes.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Account Activity",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Action, e.g. login or login_failed",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Events at or after this time (RFC 3339)",
+                        "name": "since",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Events before this time (RFC 3339)",
+                        "name": "until",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, up to 200",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Results to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.AuditEventsResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/change-email": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Moves the account to a new email address. Request an OTP for the new address first via ` + "`" + `/otp/send` + "`" + ` with action ` + "`" + `change_email` + "`" + `. The previous address is notified. Signs out all other sessions and returns a new token pair.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Change Email",
+                "parameters": [
+                    {
+                        "description": "New email, password and OTP",
+                        "name": "changeEmail",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ChangeEmail"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid OTP\" \"Example: {\\\"code\\\": \\\"otp_invalid\\\", \\\"message\\\": \\\"Invalid OTP token\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password\" \"Example: {\\\"code\\\": \\\"invalid_credentials\\\", \\\"message\\\": \\\"Current password is incorrect\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Email taken\" \"Example: {\\\"code\\\": \\\"user_exists\\\", \\\"message\\\": \\\"user already exists !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited or locked out\" \"Example: {\\\"code\\\": \\\"login_locked\\\", \\\"message\\\": \\\"Too many failed login attempts, please try again later\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/change-password": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replaces the password after checking the current one. Signs out all other sessions and returns a new token pair.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Change Password",
+                "parameters": [
+                    {
+                        "description": "Current and new password",
+                        "name": "changePassword",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.ChangePassword"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"code\\\": \\\"password_unchanged\\\", \\\"message\\\": \\\"The new password must differ from the current one\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Wrong password\" \"Example: {\\\"code\\\": \\\"invalid_credentials\\\", \\\"message\\\": \\\"Current password is incorrect\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited or locked out\" \"Example: {\\\"code\\\": \\\"login_locked\\\", \\\"message\\\": \\\"Too many failed login attempts, please try again later\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/export": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Starts collecting the profile, links and click analytics of the user into a ZIP archive. A download link is emailed once it is ready. Clicks are exported without IP addresses or user agents. One export per ` + "`" + `EXPORT_COOLDOWN` + "`" + `.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Export Personal Data",
+                "responses": {
+                    "202": {
+                        "description": "Export started",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many exports\" \"Example: {\\\"code\\\": \\\"export_recently_requested\\\", \\\"message\\\": \\\"An export was requested recently. Please check your email.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/export/{token}": {
+            "get": {
+                "description": "Downloads an export archive using the token from the emailed link. Links expire after ` + "`" + `EXPORT_EXPIRY` + "`" + `.",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Download Personal Data",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Download token",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "ZIP archive with profile.json, links.json and analytics.json",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown link\" \"Example: {\\\"code\\\": \\\"export_not_found\\\", \\\"message\\\": \\\"Download link is invalid\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Expired link\" \"Example: {\\\"code\\\": \\\"export_expired\\\", \\\"message\\\": \\\"Download link has expired. Please request a new export.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/login": {
+            "post": {
+                "description": "Login with email, password, and OTP. Users with TOTP enabled can send ` + "`" + `totp_code` + "`" + ` or ` + "`" + `recovery_code` + "`" + ` instead of ` + "`" + `otp_token` + "`" + `/` + "`" + `otp_code` + "`" + `. Returns JWT token on success.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "User Login",
+                "parameters": [
+                    {
+                        "description": "Login payload",
+                        "name": "loginUser",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.LoginUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User logged in successfully !\\\", \\\"data\\\": {\\\"token\\\": \\\"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\\\"}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"email\\\", \\\"error\\\": \\\"invalid email\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid credentials\" \"Example: {\\\"code\\\": \\\"invalid_credentials\\\", \\\"message\\\": \\\"Invalid email or password !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "OTP expired\" \"Example: {\\\"code\\\": \\\"otp_attempts_exceeded\\\", \\\"message\\\": \\\"Too many wrong codes. Please request a new OTP.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited or locked out\" \"Example: {\\\"code\\\": \\\"login_locked\\\", \\\"message\\\": \\\"Too many failed login attempts, please try again later\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/login/2fa": {
+            "post": {
+                "description": "Exchanges the challenge returned by a magic link or SSO login to an account with TOTP enabled, together with a TOTP or recovery code, for the token pair.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Complete Two-Factor Login",
+                "parameters": [
+                    {
+                        "description": "Challenge and second factor",
+                        "name": "login",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.CompleteTotpLogin"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid or expired challenge, invalid code\" \"Example: {\\\"code\\\": \\\"totp_challenge_invalid\\\", \\\"message\\\": \\\"Sign-in has expired, please sign in again\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited or locked out\" \"Example: {\\\"code\\\": \\\"login_locked\\\", \\\"message\\\": \\\"Too many failed login attempts, please try again later\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/magic-link": {
+            "post": {
+                "description": "Emails a one-time sign-in link. The link only works on the device (browser) that requested it. Emails without an account get the same answer but no mail.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Request Magic Link",
+                "parameters": [
+                    {
+                        "description": "Magic link payload",
+                        "name": "magicLinkRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.MagicLinkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"Sign-in link sent\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"email\\\", \\\"error\\\": \\\"invalid email\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Link sent recently\" \"Example: {\\\"code\\\": \\\"otp_recently_sent\\\", \\\"message\\\": \\\"OTP already sent recently\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/magic/{token}": {
+            "get": {
+                "description": "Exchanges a magic link for a JWT token pair. Links are single-use, expire quickly and must be opened on the requesting device. Links opened while the account or IP is locked out are refused and keep working afterwards. Accounts with TOTP enabled get a model.TotpChallengeResponse instead, to be completed at /user/login/2fa.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Magic Link Login",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token from the emailed link",
+                        "name": "token",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User logged in successfully !\\\", \\\"data\\\": {\\\"token\\\": \\\"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\\\"}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid link\" \"Example: {\\\"code\\\": \\\"magic_link_invalid\\\", \\\"message\\\": \\\"Sign-in link is invalid or was already used\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Other device\" \"Example: {\\\"code\\\": \\\"magic_link_device_mismatch\\\", \\\"message\\\": \\\"Open the sign-in link on the device you requested it from\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Link expired\" \"Example: {\\\"code\\\": \\\"magic_link_expired\\\", \\\"message\\\": \\\"Sign-in link has expired. Please request a new one.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited or locked out\" \"Example: {\\\"code\\\": \\\"login_locked\\\", \\\"message\\\": \\\"Too many failed login attempts, please try again later\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/me": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the profile of the logged in user.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Current User",
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.UserProfileResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Permanently deletes the user with their links, click analytics, sessions, linked identities and pending export archives. Their audit events and abuse reports are kept without IP addresses, user agents and emails. Request an OTP for the account email first via ` + "`" + `/otp/send` + "`" + ` with action ` + "`" + `delete_account` + "`" + `. The email can't sign up again for ` + "`" + `DELETED_EMAIL_COOLDOWN` + "`" + `.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Delete Account",
+                "parameters": [
+                    {
+                        "description": "OTP confirming the deletion",
+                        "name": "deleteAccount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.DeleteAccount"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid OTP\" \"Example: {\\\"code\\\": \\\"otp_invalid\\\", \\\"message\\\": \\\"Invalid OTP token\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/privacy": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Sets how much of the IP address and user agent of visitors is stored with new clicks on your links: ` + "`" + `full` + "`" + `, ` + "`" + `truncated` + "`" + ` (IPv4 /24, IPv6 /48), ` + "`" + `hashed` + "`" + ` (keyed hash of the IP address) or ` + "`" + `none` + "`" + `. Empty follows the server default ` + "`" + `CLICK_PRIVACY` + "`" + `. Clicks of visitors sending ` + "`" + `DNT: 1` + "`" + ` or ` + "`" + `Sec-GPC: 1` + "`" + ` are always stored as ` + "`" + `none` + "`" + `.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Click Privacy",
+                "parameters": [
+                    {
+                        "description": "Privacy mode",
+                        "name": "clickPrivacy",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UpdateClickPrivacy"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.ClickPrivacySettings"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"code\\\": \\\"validation_failed\\\", \\\"message\\\": \\\"Request failed\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"unauthorized\\\", \\\"message\\\": \\\"Unauthorized !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/refresh-token": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Refresh token. Returns JWT token on success.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "User Refresh Token",
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User logged in successfully !\\\", \\\"data\\\": {\\\"token\\\": \\\"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\\\"}}",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.LoginUserResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"email\\\", \\\"error\\\": \\\"invalid email\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized\" \"Example: {\\\"code\\\": \\\"refresh_token_used\\\", \\\"message\\\": \\\"Unauthorized - Refresh token has been used\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/sign-up": {
+            "post": {
+                "description": "Register a new user with email, password, and OTP verification.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "User Sign Up",
+                "parameters": [
+                    {
+                        "description": "Sign up payload",
+                        "name": "userToSignUp",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.SignUpUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User signed up successfully !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"otp_code\\\", \\\"error\\\": \\\"invalid OTP\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "User exists\" \"Example: {\\\"code\\\": \\\"user_exists\\\", \\\"message\\\": \\\"user already exists !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/verify-credentials": {
+            "post": {
+                "description": "Verifies user email and password.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Auth"
+                ],
+                "summary": "Verify User Credentials",
+                "parameters": [
+                    {
+                        "description": "User credentials payload",
+                        "name": "userCreds",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.UserCredentials"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success\" \"Example: {\\\"message\\\": \\\"User credentials are valid.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/model.APIResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Validation error\" \"Example: {\\\"message\\\": \\\"Request failed\\\", \\\"errors\\\": [{\\\"field\\\": \\\"password\\\", \\\"error\\\": \\\"password too weak\\\"}]}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid credentials\" \"Example: {\\\"code\\\": \\\"invalid_credentials\\\", \\\"message\\\": \\\"Invalid email or password !\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited or locked out\" \"Example: {\\\"code\\\": \\\"login_locked\\\", \\\"message\\\": \\\"Too many failed login attempts, please try again later\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/{code}": {
+            "get": {
+                "description": "Redirects to the original URL using the short code. Links quarantined by the safety checks show browsers a warning page instead, its continue link carries a proceed token that works for a few minutes. Links blocked after abuse reports show a warning page without a way to continue. A code ending in + shows the preview of the link instead, see /{code}/preview. Crawlers of chat apps and social networks (Slackbot, Twitterbot, facebookexternalhit, ...) get a page with the Open Graph tags of the link and aren't counted as clicks.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Redirect Short URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Token of the warning page to continue to a quarantined destination",
+                        "name": "proceed",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Warning page of a quarantined link, or the Open Graph card for crawlers of chat apps and social networks",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Quarantined or blocked, for non-browser clients\" \"Example: {\\\"code\\\": \\\"url_quarantined\\\", \\\"message\\\": \\\"This link was flagged as unsafe. Open it in a browser to see the warning.\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Inactive or expired\" \"Example: {\\\"code\\\": \\\"url_expired\\\", \\\"message\\\": \\\"URL has expired\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/{code}/preview": {
+            "get": {
+                "description": "Shows where a short link goes without following it and without counting a click: destination, page title and description, favicon, the owner's description and the creation date. Browsers get an HTML page, other clients JSON. /{code}+ is the same. Destinations of quarantined links aren't requested.",
+                "produces": [
+                    "application/json",
+                    "text/html"
+                ],
+                "tags": [
+                    "URL"
+                ],
+                "summary": "Preview Short URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short URL code",
+                        "name": "code",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/model.APIResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/model.UrlPreview"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "403": {
+                        "description": "Blocked\" \"Example: {\\\"code\\\": \\\"url_blocked\\\", \\\"message\\\": \\\"This link was disabled after reports of abuse\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found\" \"Example: {\\\"code\\\": \\\"url_not_found\\\", \\\"message\\\": \\\"no URL found for the provided code\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "410": {
+                        "description": "Inactive or expired\" \"Example: {\\\"code\\\": \\\"url_expired\\\", \\\"message\\\": \\\"URL has expired\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Rate limited\" \"Example: {\\\"code\\\": \\\"rate_limited\\\", \\\"message\\\": \\\"Too Many Requests\\\"}",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.APIResponse": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "message": {
+                    "type": "string",
+                    "example": "User logged in successfully !"
+                }
+            }
+        },
+        "model.AbuseReason": {
+            "type": "string",
+            "enum": [
+                "phishing",
+                "malware",
+                "spam",
+                "other"
+            ],
+            "x-enum-varnames": [
+                "AbuseReasonPhishing",
+                "AbuseReasonMalware",
+                "AbuseReasonSpam",
+                "AbuseReasonOther"
+            ]
+        },
+        "model.AbuseReport": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "$ref": "#/definitions/model.AbuseReason"
+                },
+                "reporter_email": {
+                    "type": "string"
+                },
+                "reporter_id": {
+                    "description": "Signed-in reporter",
+                    "type": "integer"
+                },
+                "reporter_ip": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "resolved_by": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.AbuseReportStatus"
+                },
+                "url_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AbuseReportResolution": {
+            "type": "object",
+            "properties": {
+                "resolved": {
+                    "description": "Open reports closed by the action",
+                    "type": "integer"
+                },
+                "url": {
+                    "$ref": "#/definitions/model.Url"
+                }
+            }
+        },
+        "model.AbuseReportResponse": {
+            "type": "object",
+            "properties": {
+                "report": {
+                    "$ref": "#/definitions/model.AbuseReport"
+                },
+                "reports": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AbuseReport"
+                    }
+                },
+                "url": {
+                    "$ref": "#/definitions/model.UrlWithShortCode"
+                }
+            }
+        },
+        "model.AbuseReportStatus": {
+            "type": "string",
+            "enum": [
+                "open",
+                "dismissed",
+                "actioned"
+            ],
+            "x-enum-comments": {
+                "AbuseReportStatusActioned": "The link was blocked"
+            },
+            "x-enum-descriptions": [
+                "",
+                "",
+                "The link was blocked"
+            ],
+            "x-enum-varnames": [
+                "AbuseReportStatusOpen",
+                "AbuseReportStatusDismissed",
+                "AbuseReportStatusActioned"
+            ]
+        },
+        "model.AbuseReportsResponse": {
+            "type": "object",
+            "properties": {
+                "reports": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AbuseReport"
+                    }
+                }
+            }
+        },
+        "model.AdminUrlsResponse": {
+            "type": "object",
+            "properties": {
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UrlWithShortCode"
+                    }
+                }
+            }
+        },
+        "model.AdminUserResponse": {
+            "type": "object",
+            "properties": {
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UrlWithShortCode"
+                    }
+                },
+                "user": {
+                    "$ref": "#/definitions/model.User"
+                }
+            }
+        },
+        "model.AdminUsersResponse": {
+            "type": "object",
+            "properties": {
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.User"
+                    }
+                }
+            }
+        },
+        "model.AnalyticsBreakdown": {
+            "type": "object",
+            "properties": {
+                "bot_clicks": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.AuditAction": {
+            "type": "string",
+            "enum": [
+                "sign_up",
+                "login",
+                "login_failed",
+                "otp_sent",
+                "token_refresh",
+                "password_change",
+                "email_change",
+                "totp_enable",
+                "totp_disable",
+                "data_export",
+                "account_delete",
+                "click_privacy_change",
+                "user_suspend",
+                "user_unsuspend",
+                "user_role_change",
+                "link_create",
+                "link_status_change",
+                "link_report",
+                "report_dismiss"
+            ],
+            "x-enum-varnames": [
+                "AuditActionSignUp",
+                "AuditActionLogin",
+                "AuditActionLoginFailed",
+                "AuditActionOtpSent",
+                "AuditActionTokenRefresh",
+                "AuditActionPasswordChange",
+                "AuditActionEmailChange",
+                "AuditActionTotpEnable",
+                "AuditActionTotpDisable",
+                "AuditActionDataExport",
+                "AuditActionAccountDelete",
+                "AuditActionClickPrivacyChange",
+                "AuditActionUserSuspend",
+                "AuditActionUserUnsuspend",
+                "AuditActionUserRoleChange",
+                "AuditActionLinkCreate",
+                "AuditActionLinkStatusChange",
+                "AuditActionLinkReport",
+                "AuditActionReportDismiss"
+            ]
+        },
+        "model.AuditChange": {
+            "type": "object",
+            "properties": {
+                "after": {},
+                "before": {}
+            }
+        },
+        "model.AuditEvent": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/model.AuditAction"
+                },
+                "actor_id": {
+                    "type": "integer"
+                },
+                "changes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/model.AuditChange"
+                    }
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "details": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "target_id": {
+                    "type": "string"
+                },
+                "target_type": {
+                    "$ref": "#/definitions/model.AuditTargetType"
+                },
+                "user_agent": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.AuditEventsResponse": {
+            "type": "object",
+            "properties": {
+                "events": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AuditEvent"
+                    }
+                }
+            }
+        },
+        "model.AuditTargetType": {
+            "type": "string",
+            "enum": [
+                "user",
+                "url",
+                "otp"
+            ],
+            "x-enum-varnames": [
+                "AuditTargetUser",
+                "AuditTargetUrl",
+                "AuditTargetOtp"
+            ]
+        },
+        "model.BulkCreateUrlsResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.BulkUrlResult"
+                    }
+                }
+            }
+        },
+        "model.BulkUrlResult": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "error": {
+                    "$ref": "#/definitions/utils.ErrorResponse"
+                },
+                "row": {
+                    "description": "Starting at 1, CSV headers not counted",
+                    "type": "integer"
+                },
+                "short_url": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Quarantined when the safety checks flagged the link",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.UrlStatus"
+                        }
+                    ]
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ChangeEmail": {
+            "type": "object",
+            "required": [
+                "new_email",
+                "otp_code",
+                "otp_token",
+                "password"
+            ],
+            "properties": {
+                "new_email": {
+                    "type": "string",
+                    "example": "new@example.com"
+                },
+                "otp_code": {
+                    "type": "string"
+                },
+                "otp_token": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ChangePassword": {
+            "type": "object",
+            "required": [
+                "current_password",
+                "new_password"
+            ],
+            "properties": {
+                "current_password": {
+                    "type": "string"
+                },
+                "new_password": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.ClickPrivacy": {
+            "type": "string",
+            "enum": [
+                "full",
+                "truncated",
+                "hashed",
+                "none"
+            ],
+            "x-enum-comments": {
+                "ClickPrivacyFull": "IP address and user agent as sent",
+                "ClickPrivacyHashed": "Keyed hash of the IP address, pseudonymous rather than anonymous",
+                "ClickPrivacyNone": "Neither IP address nor user agent",
+                "ClickPrivacyTruncated": "IPv4 to /24, IPv6 to /48"
+            },
+            "x-enum-descriptions": [
+                "IP address and user agent as sent",
+                "IPv4 to /24, IPv6 to /48",
+                "Keyed hash of the IP address, pseudonymous rather than anonymous",
+                "Neither IP address nor user agent"
+            ],
+            "x-enum-varnames": [
+                "ClickPrivacyFull",
+                "ClickPrivacyTruncated",
+                "ClickPrivacyHashed",
+                "ClickPrivacyNone"
+            ]
+        },
+        "model.ClickPrivacySettings": {
+            "type": "object",
+            "properties": {
+                "click_privacy": {
+                    "$ref": "#/definitions/model.ClickPrivacy"
+                },
+                "effective": {
+                    "$ref": "#/definitions/model.ClickPrivacy"
+                }
+            }
+        },
+        "model.CompleteTotpLogin": {
+            "type": "object",
+            "required": [
+                "challenge"
+            ],
+            "properties": {
+                "challenge": {
+                    "type": "string",
+                    "example": "b64-encrypted-challenge"
+                },
+                "recovery_code": {
+                    "type": "string",
+                    "example": "ABCDE-23456"
+                },
+                "totp_code": {
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "model.ConfirmTotp": {
+            "type": "object",
+            "required": [
+                "code"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "model.CreateAbuseReport": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "details": {
+                    "type": "string",
+                    "maxLength": 1000
+                },
+                "email": {
+                    "type": "string"
+                },
+                "reason": {
+                    "enum": [
+                        "phishing",
+                        "malware",
+                        "spam",
+                        "other"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.AbuseReason"
+                        }
+                    ]
+                }
+            }
+        },
+        "model.CreateShortUrl": {
+            "type": "object",
+            "required": [
+                "url"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "description": {
+                    "description": "Description is shown on the preview page of the link",
+                    "type": "string",
+                    "maxLength": 300
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "og_description": {
+                    "type": "string",
+                    "maxLength": 300
+                },
+                "og_image": {
+                    "type": "string",
+                    "maxLength": 2048
+                },
+                "og_title": {
+                    "description": "Shown when the link is pasted into chat apps and social networks",
+                    "type": "string",
+                    "maxLength": 200
+                },
+                "url": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.CreateShortUrlResponse": {
+            "type": "object",
+            "properties": {
+                "safety_reason": {
+                    "type": "string"
+                },
+                "short_url": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.UrlStatus"
+                }
+            }
+        },
+        "model.DailyAnalytics": {
+            "type": "object",
+            "properties": {
+                "bot_clicks": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "day": {
+                    "type": "string"
+                },
+                "unique_visitors": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.DeleteAccount": {
+            "type": "object",
+            "required": [
+                "otp_code",
+                "otp_token"
+            ],
+            "properties": {
+                "otp_code": {
+                    "type": "string"
+                },
+                "otp_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.GetUrlsByUserResponse": {
+            "type": "object",
+            "properties": {
+                "urls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.UrlWithShortCode"
+                    }
+                }
+            }
+        },
+        "model.HourlyAnalytics": {
+            "type": "object",
+            "properties": {
+                "bot_clicks": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "hour": {
+                    "type": "string"
+                },
+                "unique_visitors": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.LoginUser": {
+            "type": "object",
             "required": [
-                "url"
+                "email",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "otp_code": {
+                    "type": "string"
+                },
+                "otp_token": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "recovery_code": {
+                    "type": "string",
+                    "example": "ABCDE-23456"
+                },
+                "totp_code": {
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "model.LoginUserResponse": {
+            "type": "object",
+            "properties": {
+                "refresh_token": {
+                    "type": "string",
+                    "example": "JWT Refresh Token"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "JWT Token"
+                }
+            }
+        },
+        "model.MagicLinkRequest": {
+            "type": "object",
+            "required": [
+                "email"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.OtpActionType": {
+            "type": "string",
+            "enum": [
+                "login",
+                "signup",
+                "reset_password",
+                "magic_login",
+                "change_email",
+                "delete_account"
+            ],
+            "x-enum-varnames": [
+                "OtpActionTypeLogin",
+                "OtpActionTypeSignUp",
+                "OtpActionTypeResetPassword",
+                "OtpActionTypeMagicLogin",
+                "OtpActionTypeChangeEmail",
+                "OtpActionTypeDeleteAccount"
+            ]
+        },
+        "model.OtpType": {
+            "type": "string",
+            "enum": [
+                "email",
+                "phone"
+            ],
+            "x-enum-varnames": [
+                "OtpTypeEmail",
+                "OtpTypePhone"
+            ]
+        },
+        "model.SecondFactor": {
+            "type": "object",
+            "properties": {
+                "recovery_code": {
+                    "type": "string",
+                    "example": "ABCDE-23456"
+                },
+                "totp_code": {
+                    "type": "string",
+                    "example": "123456"
+                }
+            }
+        },
+        "model.SendOTPResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SendOtp": {
+            "type": "object",
+            "required": [
+                "action",
+                "key",
+                "type"
+            ],
+            "properties": {
+                "action": {
+                    "$ref": "#/definitions/model.OtpActionType"
+                },
+                "key": {
+                    "description": "Email, or E.164 phone number for phone OTPs",
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "type": {
+                    "$ref": "#/definitions/model.OtpType"
+                }
+            }
+        },
+        "model.SignUpUser": {
+            "type": "object",
+            "required": [
+                "email",
+                "otp_code",
+                "otp_token",
+                "password"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "otp_code": {
+                    "type": "string"
+                },
+                "otp_token": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.SystemStats": {
+            "type": "object",
+            "properties": {
+                "bot_clicks": {
+                    "type": "integer"
+                },
+                "bot_clicks_24h": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "description": "Of people, bots are counted separately",
+                    "type": "integer"
+                },
+                "clicks_24h": {
+                    "description": "Of people",
+                    "type": "integer"
+                },
+                "generated_at": {
+                    "type": "string"
+                },
+                "new_urls_24h": {
+                    "type": "integer"
+                },
+                "new_users_24h": {
+                    "type": "integer"
+                },
+                "urls": {
+                    "type": "integer"
+                },
+                "urls_by_status": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "users": {
+                    "type": "integer"
+                },
+                "users_by_role": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "users_by_status": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                }
+            }
+        },
+        "model.TotpEnabledResponse": {
+            "type": "object",
+            "properties": {
+                "recovery_codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "ABCDE-23456"
+                    ]
+                }
+            }
+        },
+        "model.TotpSetupResponse": {
+            "type": "object",
+            "properties": {
+                "qr_code": {
+                    "type": "string",
+                    "example": "data:image/png;base64,..."
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "JBSWY3DPEHPK3PXP"
+                },
+                "uri": {
+                    "type": "string",
+                    "example": "otpauth://totp/App:user@example.com?secret=JBSWY3DPEHPK3PXP"
+                }
+            }
+        },
+        "model.TotpStatusResponse": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "recovery_codes_left": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.UniqueVisitors": {
+            "type": "object",
+            "properties": {
+                "today": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "model.UpdateClickPrivacy": {
+            "type": "object",
+            "properties": {
+                "click_privacy": {
+                    "enum": [
+                        "full",
+                        "truncated",
+                        "hashed",
+                        "none"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ClickPrivacy"
+                        }
+                    ],
+                    "example": "truncated"
+                }
+            }
+        },
+        "model.UpdateUserRole": {
+            "type": "object",
+            "required": [
+                "role"
+            ],
+            "properties": {
+                "role": {
+                    "enum": [
+                        "user",
+                        "support",
+                        "admin"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.UserRole"
+                        }
+                    ]
+                }
+            }
+        },
+        "model.Url": {
+            "type": "object",
+            "required": [
+                "code",
+                "created_at",
+                "id",
+                "status",
+                "url",
+                "user_id"
             ],
             "properties": {
+                "bot_click_count": {
+                    "description": "BotClickCount counts clicks of crawlers, scanners and health checkers",
+                    "type": "integer"
+                },
+                "click_count": {
+                    "description": "Clicks of people, see BotClickCount",
+                    "type": "integer"
+                },
                 "code": {
                     "type": "string"
                 },
+                "created_at": {
+                    "type": "string"
+                },
+                "description": {
+                    "description": "Description is chosen by the owner and shown on the preview page",
+                    "type": "string"
+                },
                 "expires_at": {
                     "type": "string"
                 },
+                "id": {
+                    "type": "integer"
+                },
+                "og_description": {
+                    "type": "string"
+                },
+                "og_image": {
+                    "type": "string"
+                },
+                "og_title": {
+                    "description": "Open Graph tags served to chat apps and social networks unfurling the\nlink, defaults are taken from the destination page",
+                    "type": "string"
+                },
+                "safety_reason": {
+                    "description": "SafetyReason says why the safety checks quarantined the link",
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.UrlStatus"
+                },
                 "url": {
                     "type": "string"
                 },
@@ -483,134 +3939,99 @@ const docTemplate = `{
                 }
             }
         },
-        "model.CreateShortUrlResponse": {
+        "model.UrlAnalytics": {
             "type": "object",
             "properties": {
-                "short_url": {
+                "bot_clicks": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "code": {
                     "type": "string"
-                }
-            }
-        },
-        "model.GetUrlsByUserResponse": {
-            "type": "object",
-            "properties": {
-                "urls": {
+                },
+                "countries": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/model.UrlWithShortCode"
+                        "$ref": "#/definitions/model.AnalyticsBreakdown"
                     }
-                }
-            }
-        },
-        "model.LoginUser": {
-            "type": "object",
-            "required": [
-                "email",
-                "otp_code",
-                "otp_token",
-                "password"
-            ],
-            "properties": {
-                "email": {
+                },
+                "days": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.DailyAnalytics"
+                    }
+                },
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AnalyticsBreakdown"
+                    }
+                },
+                "from": {
                     "type": "string"
                 },
-                "otp_code": {
+                "hours": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.HourlyAnalytics"
+                    }
+                },
+                "referrers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.AnalyticsBreakdown"
+                    }
+                },
+                "short_url": {
                     "type": "string"
                 },
-                "otp_token": {
+                "source": {
+                    "description": "Where days were read from, raw clicks or rollups",
                     "type": "string"
                 },
-                "password": {
+                "to": {
                     "type": "string"
-                }
-            }
-        },
-        "model.LoginUserResponse": {
-            "type": "object",
-            "properties": {
-                "refresh_token": {
-                    "type": "string",
-                    "example": "JWT Refresh Token"
                 },
-                "token": {
-                    "type": "string",
-                    "example": "JWT Token"
+                "unique_visitors": {
+                    "type": "integer"
                 }
             }
         },
-        "model.OtpActionType": {
-            "type": "string",
-            "enum": [
-                "login",
-                "signup",
-                "reset_password"
-            ],
-            "x-enum-varnames": [
-                "OtpActionTypeLogin",
-                "OtpActionTypeSignUp",
-                "OtpActionTypeResetPassword"
-            ]
-        },
-        "model.OtpType": {
-            "type": "string",
-            "enum": [
-                "email",
-                "phone"
-            ],
-            "x-enum-varnames": [
-                "OtpTypeEmail",
-                "OtpTypePhone"
-            ]
-        },
-        "model.SendOTPResponse": {
+        "model.UrlPreview": {
             "type": "object",
             "properties": {
-                "id": {
+                "code": {
                     "type": "string"
                 },
-                "token": {
+                "created_at": {
                     "type": "string"
-                }
-            }
-        },
-        "model.SendOtp": {
-            "type": "object",
-            "required": [
-                "action",
-                "key",
-                "type"
-            ],
-            "properties": {
-                "action": {
-                    "$ref": "#/definitions/model.OtpActionType"
                 },
-                "key": {
+                "description": {
+                    "description": "Chosen by the owner",
                     "type": "string"
                 },
-                "type": {
-                    "$ref": "#/definitions/model.OtpType"
-                }
-            }
-        },
-        "model.SignUpUser": {
-            "type": "object",
-            "required": [
-                "email",
-                "otp_code",
-                "otp_token",
-                "password"
-            ],
-            "properties": {
-                "email": {
+                "favicon": {
                     "type": "string"
                 },
-                "otp_code": {
+                "page_description": {
+                    "description": "From the destination page",
                     "type": "string"
                 },
-                "otp_token": {
+                "safety_reason": {
                     "type": "string"
                 },
-                "password": {
+                "short_url": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.UrlStatus"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
                     "type": "string"
                 }
             }
@@ -621,13 +4042,33 @@ const docTemplate = `{
                 "active",
                 "inactive",
                 "deleted",
-                "expired"
+                "expired",
+                "disabled",
+                "quarantined",
+                "blocked"
+            ],
+            "x-enum-comments": {
+                "UrlStatusBlocked": "Disabled after abuse reports, visitors see a warning page",
+                "UrlStatusDisabled": "Deactivated by staff",
+                "UrlStatusQuarantined": "Flagged by the safety checks, visitors see a warning first"
+            },
+            "x-enum-descriptions": [
+                "",
+                "",
+                "",
+                "",
+                "Deactivated by staff",
+                "Flagged by the safety checks, visitors see a warning first",
+                "Disabled after abuse reports, visitors see a warning page"
             ],
             "x-enum-varnames": [
                 "UrlStatusActive",
                 "UrlStatusInactive",
                 "UrlStatusDeleted",
-                "UrlStatusExpired"
+                "UrlStatusExpired",
+                "UrlStatusDisabled",
+                "UrlStatusQuarantined",
+                "UrlStatusBlocked"
             ]
         },
         "model.UrlWithShortCode": {
@@ -641,7 +4082,12 @@ const docTemplate = `{
                 "user_id"
             ],
             "properties": {
+                "bot_click_count": {
+                    "description": "BotClickCount counts clicks of crawlers, scanners and health checkers",
+                    "type": "integer"
+                },
                 "click_count": {
+                    "description": "Clicks of people, see BotClickCount",
                     "type": "integer"
                 },
                 "code": {
@@ -650,18 +4096,39 @@ const docTemplate = `{
                 "created_at": {
                     "type": "string"
                 },
+                "description": {
+                    "description": "Description is chosen by the owner and shown on the preview page",
+                    "type": "string"
+                },
                 "expires_at": {
                     "type": "string"
                 },
                 "id": {
                     "type": "integer"
                 },
+                "og_description": {
+                    "type": "string"
+                },
+                "og_image": {
+                    "type": "string"
+                },
+                "og_title": {
+                    "description": "Open Graph tags served to chat apps and social networks unfurling the\nlink, defaults are taken from the destination page",
+                    "type": "string"
+                },
+                "safety_reason": {
+                    "description": "SafetyReason says why the safety checks quarantined the link",
+                    "type": "string"
+                },
                 "short_url": {
                     "type": "string"
                 },
                 "status": {
                     "$ref": "#/definitions/model.UrlStatus"
                 },
+                "unique_visitors": {
+                    "$ref": "#/definitions/model.UniqueVisitors"
+                },
                 "url": {
                     "type": "string"
                 },
@@ -670,6 +4137,37 @@ const docTemplate = `{
                 }
             }
         },
+        "model.User": {
+            "type": "object",
+            "properties": {
+                "click_privacy": {
+                    "description": "How clicks on the user's links are stored, empty follows CLICK_PRIVACY",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ClickPrivacy"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "plan": {
+                    "$ref": "#/definitions/model.UserPlan"
+                },
+                "role": {
+                    "$ref": "#/definitions/model.UserRole"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.UserStatus"
+                }
+            }
+        },
         "model.UserCredentials": {
             "type": "object",
             "required": [
@@ -685,6 +4183,77 @@ const docTemplate = `{
                 }
             }
         },
+        "model.UserPlan": {
+            "type": "string",
+            "enum": [
+                "free",
+                "pro",
+                "business"
+            ],
+            "x-enum-varnames": [
+                "UserPlanFree",
+                "UserPlanPro",
+                "UserPlanBusiness"
+            ]
+        },
+        "model.UserProfileResponse": {
+            "type": "object",
+            "properties": {
+                "click_privacy": {
+                    "description": "How clicks on the user's links are stored, empty follows CLICK_PRIVACY",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.ClickPrivacy"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "plan": {
+                    "$ref": "#/definitions/model.UserPlan"
+                },
+                "role": {
+                    "$ref": "#/definitions/model.UserRole"
+                },
+                "status": {
+                    "$ref": "#/definitions/model.UserStatus"
+                },
+                "two_factor_enabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "model.UserRole": {
+            "type": "string",
+            "enum": [
+                "user",
+                "support",
+                "admin"
+            ],
+            "x-enum-varnames": [
+                "UserRoleUser",
+                "UserRoleSupport",
+                "UserRoleAdmin"
+            ]
+        },
+        "model.UserStatus": {
+            "type": "string",
+            "enum": [
+                "active",
+                "suspended"
+            ],
+            "x-enum-varnames": [
+                "UserStatusActive",
+                "UserStatusSuspended"
+            ]
+        },
         "model.VerifyOtp": {
             "type": "object",
             "required": [
@@ -704,6 +4273,17 @@ const docTemplate = `{
                 }
             }
         },
+        "routes.OidcProvidersResponse": {
+            "type": "object",
+            "properties": {
+                "providers": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "utils.ErrorDetail": {
             "type": "object",
             "properties": {
@@ -720,6 +4300,10 @@ const docTemplate = `{
         "utils.ErrorResponse": {
             "type": "object",
             "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "validation_failed"
+                },
                 "errors": {
                     "type": "array",
                     "items": {
@@ -731,6 +4315,46 @@ const docTemplate = `{
                     "example": "Request failed"
                 }
             }
+        },
+        "utils.Jwk": {
+            "type": "object",
+            "properties": {
+                "alg": {
+                    "type": "string"
+                },
+                "crv": {
+                    "type": "string"
+                },
+                "e": {
+                    "type": "string"
+                },
+                "kid": {
+                    "type": "string"
+                },
+                "kty": {
+                    "type": "string"
+                },
+                "n": {
+                    "type": "string"
+                },
+                "use": {
+                    "type": "string"
+                },
+                "x": {
+                    "type": "string"
+                }
+            }
+        },
+        "utils.JwkSet": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/utils.Jwk"
+                    }
+                }
+            }
         }
     },
     "securityDefinitions": {
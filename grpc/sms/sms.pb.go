@@ -0,0 +1,205 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        v6.32.1
+// source: proto/sms/sms.proto
+
+package sms
+
+import (
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Request message for sending a text message
+type SendSmsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ToPhone       string                 `protobuf:"bytes,1,opt,name=to_phone,json=toPhone,proto3" json:"to_phone,omitempty"` // E.164, e.g. +14155550123
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	ProjectId     string                 `protobuf:"bytes,3,opt,name=project_id,json=projectId,proto3" json:"project_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendSmsRequest) Reset() {
+	*x = SendSmsRequest{}
+	mi := &file_proto_sms_sms_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendSmsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendSmsRequest) ProtoMessage() {}
+
+func (x *SendSmsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sms_sms_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendSmsRequest.ProtoReflect.Descriptor instead.
+func (*SendSmsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_sms_sms_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SendSmsRequest) GetToPhone() string {
+	if x != nil {
+		return x.ToPhone
+	}
+	return ""
+}
+
+func (x *SendSmsRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *SendSmsRequest) GetProjectId() string {
+	if x != nil {
+		return x.ProjectId
+	}
+	return ""
+}
+
+// Response message for sending a text message
+type SendSmsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ToPhone       string                 `protobuf:"bytes,2,opt,name=to_phone,json=toPhone,proto3" json:"to_phone,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendSmsResponse) Reset() {
+	*x = SendSmsResponse{}
+	mi := &file_proto_sms_sms_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendSmsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendSmsResponse) ProtoMessage() {}
+
+func (x *SendSmsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_sms_sms_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendSmsResponse.ProtoReflect.Descriptor instead.
+func (*SendSmsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_sms_sms_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *SendSmsResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *SendSmsResponse) GetToPhone() string {
+	if x != nil {
+		return x.ToPhone
+	}
+	return ""
+}
+
+var File_proto_sms_sms_proto protoreflect.FileDescriptor
+
+const file_proto_sms_sms_proto_rawDesc = "" +
+	"\n" +
+	"\x13proto/sms/sms.proto\x12\x05proto\"d\n" +
+	"\x0eSendSmsRequest\x12\x19\n" +
+	"\bto_phone\x18\x01 \x01(\tR\atoPhone\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12\x1d\n" +
+	"\n" +
+	"project_id\x18\x03 \x01(\tR\tprojectId\"<\n" +
+	"\x0fSendSmsResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\bto_phone\x18\x02 \x01(\tR\atoPhone2F\n" +
+	"\n" +
+	"SmsService\x128\n" +
+	"\aSendSms\x12\x15.proto.SendSmsRequest\x1a\x16.proto.SendSmsResponseB)Z'kgoel085.com/url-shortner/proto/sms;smsb\x06proto3"
+
+var (
+	file_proto_sms_sms_proto_rawDescOnce sync.Once
+	file_proto_sms_sms_proto_rawDescData []byte
+)
+
+func file_proto_sms_sms_proto_rawDescGZIP() []byte {
+	file_proto_sms_sms_proto_rawDescOnce.Do(func() {
+		file_proto_sms_sms_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_sms_sms_proto_rawDesc), len(file_proto_sms_sms_proto_rawDesc)))
+	})
+	return file_proto_sms_sms_proto_rawDescData
+}
+
+var file_proto_sms_sms_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_sms_sms_proto_goTypes = []any{
+	(*SendSmsRequest)(nil),  // 0: proto.SendSmsRequest
+	(*SendSmsResponse)(nil), // 1: proto.SendSmsResponse
+}
+var file_proto_sms_sms_proto_depIdxs = []int32{
+	0, // 0: proto.SmsService.SendSms:input_type -> proto.SendSmsRequest
+	1, // 1: proto.SmsService.SendSms:output_type -> proto.SendSmsResponse
+	1, // [1:2] is the sub-list for method output_type
+	0, // [0:1] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_proto_sms_sms_proto_init() }
+func file_proto_sms_sms_proto_init() {
+	if File_proto_sms_sms_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_sms_sms_proto_rawDesc), len(file_proto_sms_sms_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_sms_sms_proto_goTypes,
+		DependencyIndexes: file_proto_sms_sms_proto_depIdxs,
+		MessageInfos:      file_proto_sms_sms_proto_msgTypes,
+	}.Build()
+	File_proto_sms_sms_proto = out.File
+	file_proto_sms_sms_proto_goTypes = nil
+	file_proto_sms_sms_proto_depIdxs = nil
+}
@@ -2,9 +2,11 @@ package mail
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"html/template"
+	"time"
 
 	_ "embed"
 
@@ -21,6 +23,12 @@ const (
 	MailTypeSignUp        MailType = "sign_up"
 	MailTypeSendOTP       MailType = "send_otp"
 	MailTypeURLRegistered MailType = "url_registered"
+	MailTypeSuspicious    MailType = "suspicious_login"
+	MailTypeMagicLink     MailType = "magic_link"
+	MailTypeEmailChanged  MailType = "email_changed"
+	MailTypeDataExport    MailType = "data_export"
+	MailTypeDeleted       MailType = "account_deleted"
+	MailTypeLinkBlocked   MailType = "link_blocked"
 )
 
 type MailOptions interface{}
@@ -55,6 +63,62 @@ type URLRegisteredMailOptions struct {
 	IMG_BASE_URL  template.URL
 }
 
+type SuspiciousLoginMailOptions struct {
+	AppConfigOptions
+	USER_EMAIL    string
+	ATTEMPTS      int64
+	IP_ADDRESS    string
+	USER_AGENT    string
+	LOCKED_UNTIL  string
+	SUPPORT_EMAIL string
+	IMG_BASE_URL  template.URL
+}
+
+type MagicLinkMailOptions struct {
+	AppConfigOptions
+	USER_EMAIL     string
+	MAGIC_LINK     string
+	EXPIRY_MINUTES int64
+	SUPPORT_EMAIL  string
+	IMG_BASE_URL   template.URL
+}
+
+type EmailChangedMailOptions struct {
+	AppConfigOptions
+	OLD_EMAIL     string
+	NEW_EMAIL     string
+	CHANGED_AT    string
+	SUPPORT_EMAIL string
+	IMG_BASE_URL  template.URL
+}
+
+type DataExportMailOptions struct {
+	AppConfigOptions
+	USER_EMAIL    string
+	DOWNLOAD_URL  string
+	EXPIRES_AT    string
+	SUPPORT_EMAIL string
+	IMG_BASE_URL  template.URL
+}
+
+type AccountDeletedMailOptions struct {
+	AppConfigOptions
+	USER_EMAIL    string
+	DELETED_AT    string
+	SUPPORT_EMAIL string
+	IMG_BASE_URL  template.URL
+}
+
+type LinkBlockedMailOptions struct {
+	AppConfigOptions
+	USER_EMAIL    string
+	SHORT_URL     string
+	ORIGINAL_URL  string
+	REASON        string
+	SUPPORT_EMAIL string
+	IMG_BASE_URL  template.URL
+}
+
 //go:embed template/sign-up-success.html
 var signUpTemplate string
 
@@ -64,6 +128,24 @@ var sendOtpTemplate string
 //go:embed template/url-registered.html
 var urlRegisteredTemplate string
 
+//go:embed template/suspicious-login.html
+var suspiciousLoginTemplate string
+
+//go:embed template/magic-link.html
+var magicLinkTemplate string
+
+//go:embed template/email-changed.html
+var emailChangedTemplate string
+
+//go:embed template/data-export.html
+var dataExportTemplate string
+
+//go:embed template/account-deleted.html
+var accountDeletedTemplate string
+
+//go:embed template/link-blocked.html
+var linkBlockedTemplate string
+
 //go:embed assets/logo.png
 var logoImg []byte
 
@@ -71,13 +153,19 @@ var mailTemplates = map[MailType]string{
 	MailTypeSignUp:        signUpTemplate,
 	MailTypeSendOTP:       sendOtpTemplate,
 	MailTypeURLRegistered: urlRegisteredTemplate,
+	MailTypeSuspicious:    suspiciousLoginTemplate,
+	MailTypeMagicLink:     magicLinkTemplate,
+	MailTypeEmailChanged:  emailChangedTemplate,
+	MailTypeDataExport:    dataExportTemplate,
+	MailTypeDeleted:       accountDeletedTemplate,
+	MailTypeLinkBlocked:   linkBlockedTemplate,
 }
 
 func logoBase64() string {
 	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(logoImg)
 }
 
-func sendMail(mailType MailType, opts MailOptions, toEmail string, subject string) error {
+func sendMail(ctx context.Context, mailType MailType, opts MailOptions, toEmail string, subject string) error {
 	tmplStr := mailTemplates[mailType]
 
 	switch mailType {
@@ -103,6 +191,48 @@ func sendMail(mailType MailType, opts MailOptions, toEmail string, subject strin
 		}
 		urlRegisteredOpts.APP_NAME = config.Config.APP.Name
 		opts = urlRegisteredOpts
+	case MailTypeSuspicious:
+		suspiciousOpts, ok := opts.(SuspiciousLoginMailOptions)
+		if !ok {
+			return fmt.Errorf("opts must be SuspiciousLoginMailOptions for MailTypeSuspicious")
+		}
+		suspiciousOpts.APP_NAME = config.Config.APP.Name
+		opts = suspiciousOpts
+	case MailTypeMagicLink:
+		magicLinkOpts, ok := opts.(MagicLinkMailOptions)
+		if !ok {
+			return fmt.Errorf("opts must be MagicLinkMailOptions for MailTypeMagicLink")
+		}
+		magicLinkOpts.APP_NAME = config.Config.APP.Name
+		opts = magicLinkOpts
+	case MailTypeEmailChanged:
+		emailChangedOpts, ok := opts.(EmailChangedMailOptions)
+		if !ok {
+			return fmt.Errorf("opts must be EmailChangedMailOptions for MailTypeEmailChanged")
+		}
+		emailChangedOpts.APP_NAME = config.Config.APP.Name
+		opts = emailChangedOpts
+	case MailTypeDataExport:
+		dataExportOpts, ok := opts.(DataExportMailOptions)
+		if !ok {
+			return fmt.Errorf("opts must be DataExportMailOptions for MailTypeDataExport")
+		}
+		dataExportOpts.APP_NAME = config.Config.APP.Name
+		opts = dataExportOpts
+	case MailTypeDeleted:
+		deletedOpts, ok := opts.(AccountDeletedMailOptions)
+		if !ok {
+			return fmt.Errorf("opts must be AccountDeletedMailOptions for MailTypeDeleted")
+		}
+		deletedOpts.APP_NAME = config.Config.APP.Name
+		opts = deletedOpts
+	case MailTypeLinkBlocked:
+		blockedOpts, ok := opts.(LinkBlockedMailOptions)
+		if !ok {
+			return fmt.Errorf("opts must be LinkBlockedMailOptions for MailTypeLinkBlocked")
+		}
+		blockedOpts.APP_NAME = config.Config.APP.Name
+		opts = blockedOpts
 	default:
 		return fmt.Errorf("unknown mail type: %s", mailType)
 	}
@@ -125,6 +255,7 @@ func sendMail(mailType MailType, opts MailOptions, toEmail string, subject strin
 
 	utils.Log.Info("Sending email to ", toEmail, " from ", fromMail, " via GRPC")
 	err := email.SendEmailViaGRPC(
+		ctx,
 		email.GrpcSendEmailRequest{
 			ToEmail:   toEmail,
 			Subject:   subject,
@@ -140,7 +271,7 @@ func sendMail(mailType MailType, opts MailOptions, toEmail string, subject strin
 	return nil
 }
 
-func SendSignedUpUserMail(u model.User) error {
+func SendSignedUpUserMail(ctx context.Context, u model.User) error {
 	data := SignUpMailOptions{
 		USER_EMAIL:    u.Email,
 		LOGIN_URL:     fmt.Sprintf("http://%s:%s/user/login", config.Config.APP.Host, config.Config.APP.Port),
@@ -151,10 +282,10 @@ func SendSignedUpUserMail(u model.User) error {
 		},
 	}
 
-	return sendMail(MailTypeSignUp, data, u.Email, "Welcome to "+config.Config.APP.Name)
+	return sendMail(ctx, MailTypeSignUp, data, u.Email, "Welcome to "+config.Config.APP.Name)
 }
 
-func SendOtpUserMail(o model.Otp) error {
+func SendOtpUserMail(ctx context.Context, o model.Otp) error {
 	data := SendOTPMailOptions{
 		USER_EMAIL:    o.Key,
 		SUPPORT_EMAIL: SUPPORT_EMAIL,
@@ -167,15 +298,37 @@ func SendOtpUserMail(o model.Otp) error {
 	}
 
 	subject := fmt.Sprintf("Your OTP to %s for %s", o.Action, config.Config.APP.Name)
-	return sendMail(MailTypeSendOTP, data, o.Key, subject)
+	return sendMail(ctx, MailTypeSendOTP, data, o.Key, subject)
 }
 
-func SendShortUrlUserMail(u model.Url) error {
-	user, userErr := model.GetUserById(u.UserID)
-	if userErr != nil {
-		return userErr
+func SendMagicLinkMail(ctx context.Context, o model.Otp) error {
+	data := MagicLinkMailOptions{
+		USER_EMAIL:     o.Key,
+		MAGIC_LINK:     utils.GetShortUrl("user/magic/" + o.MagicLinkToken()),
+		EXPIRY_MINUTES: config.Config.OTP.MagicLinkExpiryMinutes,
+		SUPPORT_EMAIL:  SUPPORT_EMAIL,
+		IMG_BASE_URL:   template.URL(logoBase64()),
+		AppConfigOptions: AppConfigOptions{
+			APP_NAME: config.Config.APP.Name,
+		},
 	}
 
+	sendMailErr := sendMail(ctx, MailTypeMagicLink, data, o.Key, "Your sign-in link for "+config.Config.APP.Name)
+	if sendMailErr != nil {
+		utils.Log.Error("Error sending magic link email: ", sendMailErr)
+	}
+
+	return sendMailErr
+}
+
+// OtpSender delivers email OTPs, see model.OtpSender
+type OtpSender struct{}
+
+func (OtpSender) Send(ctx context.Context, o model.Otp) error {
+	return SendOtpUserMail(ctx, o)
+}
+
+func SendShortUrlUserMail(ctx context.Context, user model.User, u model.Url) error {
 	data := URLRegisteredMailOptions{
 		USER_EMAIL:    user.Email,
 		SHORT_URL:     utils.GetShortUrl(u.Code),
@@ -187,10 +340,117 @@ func SendShortUrlUserMail(u model.Url) error {
 		},
 	}
 
-	sendMailErr := sendMail(MailTypeURLRegistered, data, user.Email, "Your shortened URL is ready")
+	sendMailErr := sendMail(ctx, MailTypeURLRegistered, data, user.Email, "Your shortened URL is ready")
 	if sendMailErr != nil {
 		utils.Log.Error("Error sending URL registered email: ", sendMailErr)
 	}
 
 	return sendMailErr
 }
+
+func SendSuspiciousLoginMail(ctx context.Context, user model.User, failure model.LoginFailure, ip string, userAgent string) error {
+	data := SuspiciousLoginMailOptions{
+		USER_EMAIL:    user.Email,
+		ATTEMPTS:      failure.Attempts,
+		IP_ADDRESS:    ip,
+		USER_AGENT:    userAgent,
+		LOCKED_UNTIL:  time.Now().UTC().Add(failure.Delay).Format(config.TIME_FORMAT),
+		SUPPORT_EMAIL: SUPPORT_EMAIL,
+		IMG_BASE_URL:  template.URL(logoBase64()),
+		AppConfigOptions: AppConfigOptions{
+			APP_NAME: config.Config.APP.Name,
+		},
+	}
+
+	sendMailErr := sendMail(ctx, MailTypeSuspicious, data, user.Email, "Suspicious login attempts on your "+config.Config.APP.Name+" account")
+	if sendMailErr != nil {
+		utils.Log.Error("Error sending suspicious login email: ", sendMailErr)
+	}
+
+	return sendMailErr
+}
+
+// SendEmailChangedMail tells the previous address that the account moved
+func SendEmailChangedMail(ctx context.Context, oldEmail string, user model.User) error {
+	data := EmailChangedMailOptions{
+		OLD_EMAIL:     oldEmail,
+		NEW_EMAIL:     user.Email,
+		CHANGED_AT:    time.Now().UTC().Format(config.TIME_FORMAT),
+		SUPPORT_EMAIL: SUPPORT_EMAIL,
+		IMG_BASE_URL:  template.URL(logoBase64()),
+		AppConfigOptions: AppConfigOptions{
+			APP_NAME: config.Config.APP.Name,
+		},
+	}
+
+	sendMailErr := sendMail(ctx, MailTypeEmailChanged, data, oldEmail, "Your "+config.Config.APP.Name+" email address was changed")
+	if sendMailErr != nil {
+		utils.Log.Error("Error sending email changed email: ", sendMailErr)
+	}
+
+	return sendMailErr
+}
+
+// SendDataExportMail delivers the download link of a finished export
+func SendDataExportMail(ctx context.Context, user model.User, token string) error {
+	data := DataExportMailOptions{
+		USER_EMAIL:    user.Email,
+		DOWNLOAD_URL:  utils.GetShortUrl("user/export/" + token),
+		EXPIRES_AT:    time.Now().UTC().Add(config.Config.PRIVACY.ExportExpiry).Format(config.TIME_FORMAT),
+		SUPPORT_EMAIL: SUPPORT_EMAIL,
+		IMG_BASE_URL:  template.URL(logoBase64()),
+		AppConfigOptions: AppConfigOptions{
+			APP_NAME: config.Config.APP.Name,
+		},
+	}
+
+	sendMailErr := sendMail(ctx, MailTypeDataExport, data, user.Email, "Your "+config.Config.APP.Name+" data export is ready")
+	if sendMailErr != nil {
+		utils.Log.Error("Error sending data export email: ", sendMailErr)
+	}
+
+	return sendMailErr
+}
+
+// SendAccountDeletedMail confirms the deletion to the former address
+func SendAccountDeletedMail(ctx context.Context, user model.User) error {
+	data := AccountDeletedMailOptions{
+		USER_EMAIL:    user.Email,
+		DELETED_AT:    time.Now().UTC().Format(config.TIME_FORMAT),
+		SUPPORT_EMAIL: SUPPORT_EMAIL,
+		IMG_BASE_URL:  template.URL(logoBase64()),
+		AppConfigOptions: AppConfigOptions{
+			APP_NAME: config.Config.APP.Name,
+		},
+	}
+
+	sendMailErr := sendMail(ctx, MailTypeDeleted, data, user.Email, "Your "+config.Config.APP.Name+" account was deleted")
+	if sendMailErr != nil {
+		utils.Log.Error("Error sending account deleted email: ", sendMailErr)
+	}
+
+	return sendMailErr
+}
+
+// SendLinkBlockedMail tells the owner that their link was blocked after
+// abuse reports. reason completes "was disabled ...".
+func SendLinkBlockedMail(ctx context.Context, user model.User, u model.Url, reason string) error {
+	data := LinkBlockedMailOptions{
+		USER_EMAIL:    user.Email,
+		SHORT_URL:     utils.GetShortUrl(u.Code),
+		ORIGINAL_URL:  u.Url,
+		REASON:        reason,
+		SUPPORT_EMAIL: SUPPORT_EMAIL,
+		IMG_BASE_URL:  template.URL(logoBase64()),
+		AppConfigOptions: AppConfigOptions{
+			APP_NAME: config.Config.APP.Name,
+		},
+	}
+
+	sendMailErr := sendMail(ctx, MailTypeLinkBlocked, data, user.Email, "Your "+config.Config.APP.Name+" link was disabled")
+	if sendMailErr != nil {
+		utils.Log.Error("Error sending link blocked email: ", sendMailErr)
+	}
+
+	return sendMailErr
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"kgoel085.com/url-shortner/config"
 	"kgoel085.com/url-shortner/db"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
 	"kgoel085.com/url-shortner/proto"
 	"kgoel085.com/url-shortner/routes"
+	"kgoel085.com/url-shortner/safety"
 	"kgoel085.com/url-shortner/utils"
 	"kgoel085.com/url-shortner/validator"
 )
@@ -19,13 +24,36 @@ import (
 func main() {
 	server := gin.Default()
 
-	utils.InitLogger()             // Initialize logger
-	config.LoadConfig()            // Load ENV variables
-	db.InitRedis()                 // Initialize Redis client
-	db.InitDB()                    // Initialize Postgres client
-	validator.LoadCustomBindings() // Load custom validators
-	proto.InitClients()            // Initialize gRPC clients
-	routes.SetUpRouter(server)     // Setup all routes
+	utils.InitLogger()   // Initialize logger
+	config.LoadConfig()  // Load ENV variables
+	utils.InitJwtKeys()  // Load JWT signing keys and start rotation
+	store := initStore() // Initialize storage backends
+
+	// One-off commands, e.g. `url-shortner anonymize`, run instead of the server
+	if len(os.Args) > 1 {
+		if err := runCommand(store, os.Args[1:]); err != nil {
+			utils.Log.Fatal("Command failed: ", err)
+		}
+		return
+	}
+
+	validator.LoadCustomBindings()    // Load custom validators
+	proto.InitClients()               // Initialize gRPC clients
+	routes.SetUpRouter(server, store) // Setup all routes
+	model.StartExportCleanup()        // Remove expired data exports
+
+	// Purge audit events past AUDIT_RETENTION
+	model.StartAuditPurge(store.Audits)
+
+	// Check links again as the safety lists change
+	model.StartUrlRescan(store.Urls, store.Audits, safety.Init())
+
+	// Roll up clicks and purge the ones past retention
+	model.StartAnalyticsRollup(store.Analytics, store.Rollups)
+	model.StartAnalyticsPurge(store.Analytics, store.Rollups)
+
+	// Make sure the users in ADMIN_EMAILS can manage roles
+	model.PromoteAdmins(context.Background(), store.Users, config.Config.APP.AdminEmails)
 
 	appUrl := fmt.Sprintf("%s:%s", config.Config.APP.Host, config.Config.APP.Port)
 	trustedProxies := strings.Split(config.Config.APP.TrustedProxies, ",")
@@ -43,3 +71,14 @@ func main() {
 	}
 	utils.Log.Info("Server started at ", appUrl)
 }
+
+func initStore() *model.Store {
+	if config.Config.APP.StorageDriver == "memory" {
+		utils.Log.Warn("Using in-memory storage, data will be lost on restart")
+		return memory.NewStore()
+	}
+
+	redisClient := db.InitRedis() // Initialize Redis client
+	conn := db.InitDB()           // Initialize Postgres client
+	return db.NewStore(conn, redisClient)
+}
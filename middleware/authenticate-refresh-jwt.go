@@ -1,9 +1,6 @@
 package middleware
 
 import (
-	"errors"
-	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,53 +9,47 @@ import (
 	"kgoel085.com/url-shortner/utils"
 )
 
-func AuthenticateRefreshToken(context *gin.Context) {
+func AuthenticateRefreshToken(tokens model.RefreshTokenStore) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		authenticateRefreshToken(context, tokens)
+	}
+}
+
+func authenticateRefreshToken(context *gin.Context, tokens model.RefreshTokenStore) {
 	token := context.Request.Header.Get("Authorization")
 	if token == "" {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "Unauthorized !",
-		})
+		utils.HandleError(context, utils.Unauthorized("unauthorized", "Unauthorized !"))
 		return
 	}
 
 	decryptedToken, decryptErr := utils.Decrypt(token)
 	if decryptErr != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": fmt.Sprintf("Unauthorized - %s", decryptErr.Error()),
-		})
+		utils.HandleError(context, errTokenInvalid.Wrap(decryptErr))
 		return
 	}
 
-	tokenUserId, tokenErr := utils.ValidateJWT(decryptedToken, utils.RefreshJwtType)
+	tokenClaims, tokenErr := utils.ValidateJWT(decryptedToken, utils.RefreshJwtType)
 	if tokenErr != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": fmt.Sprintf("Unauthorized - %s", tokenErr.Error()),
-		})
+		utils.HandleError(context, errTokenInvalid.Wrap(tokenErr))
 		return
 	}
 
-	userRefreshToken, userRefreshTokenErr := model.GetRefreshTokenByToken(token)
+	userRefreshToken, userRefreshTokenErr := tokens.GetByToken(context.Request.Context(), token)
 	if userRefreshTokenErr != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": fmt.Sprintf("Unauthorized - %s", userRefreshTokenErr.Error()),
-		})
+		utils.HandleError(context, userRefreshTokenErr)
 		return
 	}
 
 	if time.Now().After(userRefreshToken.ExpiresAt) {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": fmt.Sprintf("Unauthorized - %s", errors.New("Refresh token has expired").Error()),
-		})
+		utils.HandleError(context, utils.Unauthorized("refresh_token_expired", "Unauthorized - Refresh token has expired"))
 		return
 	}
 
 	if userRefreshToken.IsUsed {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": fmt.Sprintf("Unauthorized - %s", errors.New("Refresh token has been used").Error()),
-		})
+		utils.HandleError(context, utils.Unauthorized("refresh_token_used", "Unauthorized - Refresh token has been used"))
 		return
 	}
 
-	context.Set(config.JWT_LOGGED_IN_USER, tokenUserId) // Set it to be available for further requests
+	context.Set(config.JWT_LOGGED_IN_USER, tokenClaims.UserID) // Set it to be available for further requests
 	context.Next()
 }
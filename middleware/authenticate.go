@@ -1,31 +1,126 @@
 package middleware
 
 import (
-	"fmt"
-	"net/http"
+	"errors"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
 	"kgoel085.com/url-shortner/utils"
 )
 
-func Authenticate(context *gin.Context) {
+// maxCachedStatuses bounds the user statuses Authenticate remembers
+const maxCachedStatuses = 10000
+
+// errTokenInvalid is all clients learn about a refused token, the reason is
+// attached with Wrap and only logged
+var errTokenInvalid = utils.Unauthorized("token_invalid", "Unauthorized - invalid or expired token")
+
+// Authenticate accepts valid access tokens of active users. The stored
+// status of the user is checked too, so suspending an account locks it out
+// within JWT_USER_STATUS_CACHE_TTL instead of when its tokens expire.
+func Authenticate(users model.UserStore) gin.HandlerFunc {
+	statuses := &userStatusCache{entries: map[int64]userStatusEntry{}}
+	return func(context *gin.Context) {
+		authenticate(context, users, statuses)
+	}
+}
+
+// AuthenticateOptional lets requests without a token through anonymously.
+// Requests with one are authenticated like Authenticate does, a bad token
+// is refused rather than ignored.
+func AuthenticateOptional(users model.UserStore) gin.HandlerFunc {
+	statuses := &userStatusCache{entries: map[int64]userStatusEntry{}}
+	return func(context *gin.Context) {
+		if context.Request.Header.Get("Authorization") == "" {
+			context.Next()
+			return
+		}
+		authenticate(context, users, statuses)
+	}
+}
+
+func authenticate(context *gin.Context, users model.UserStore, statuses *userStatusCache) {
 	token := context.Request.Header.Get("Authorization")
 	if token == "" {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "Unauthorized !",
-		})
+		utils.HandleError(context, utils.Unauthorized("unauthorized", "Unauthorized !"))
 		return
 	}
 
-	tokenUserId, tokenErr := utils.ValidateJWT(token, utils.LoginJwtType)
+	tokenClaims, tokenErr := utils.ValidateJWT(token, utils.LoginJwtType)
 	if tokenErr != nil {
-		context.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": fmt.Sprintf("Unauthorized - %s", tokenErr.Error()),
-		})
+		utils.HandleError(context, errTokenInvalid.Wrap(tokenErr))
+		return
+	}
+
+	status, statusErr := statuses.get(context, users, tokenClaims.UserID)
+	if errors.Is(statusErr, model.ErrUserNotFound) {
+		utils.HandleError(context, errTokenInvalid.Wrap(statusErr))
+		return
+	}
+	if statusErr != nil {
+		utils.HandleError(context, statusErr)
+		return
+	}
+	if status == model.UserStatusSuspended {
+		utils.HandleError(context, model.ErrUserSuspended)
 		return
 	}
 
-	context.Set(config.JWT_LOGGED_IN_USER, tokenUserId) // Set it to be available for further requests
+	context.Set(config.JWT_LOGGED_IN_USER, tokenClaims.UserID) // Set it to be available for further requests
+	context.Set(config.JWT_LOGGED_IN_USER_PLAN, tokenClaims.Plan)
+	context.Set(config.JWT_LOGGED_IN_USER_ROLE, tokenClaims.Role)
 	context.Next()
 }
+
+type userStatusEntry struct {
+	status    model.UserStatus
+	expiresAt time.Time
+}
+
+// userStatusCache remembers stored user statuses for
+// JWT_USER_STATUS_CACHE_TTL, so most requests don't read the user
+type userStatusCache struct {
+	mu      sync.Mutex
+	entries map[int64]userStatusEntry
+}
+
+func (c *userStatusCache) get(context *gin.Context, users model.UserStore, userID int64) (model.UserStatus, error) {
+	ttl := config.Config.JWT.UserStatusCacheTTL
+	now := time.Now()
+
+	if ttl > 0 {
+		c.mu.Lock()
+		entry, ok := c.entries[userID]
+		c.mu.Unlock()
+		if ok && now.Before(entry.expiresAt) {
+			return entry.status, nil
+		}
+	}
+
+	user, userErr := users.GetByID(context.Request.Context(), userID)
+	if userErr != nil {
+		return "", userErr
+	}
+	if ttl <= 0 {
+		return user.Status, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCachedStatuses {
+		for id, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, id)
+			}
+		}
+	}
+	if len(c.entries) >= maxCachedStatuses {
+		clear(c.entries)
+	}
+	c.entries[userID] = userStatusEntry{status: user.Status, expiresAt: now.Add(ttl)}
+	return user.Status, nil
+}
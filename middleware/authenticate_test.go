@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/db/memory"
+)
+
+func TestRefusedTokensDontExplainWhy(t *testing.T) {
+	engine := gin.New()
+	engine.GET("/access", Authenticate(memory.NewUserStore()), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	engine.GET("/refresh", AuthenticateRefreshToken(memory.NewRefreshTokenStore()), func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+
+	for _, path := range []string{"/access", "/refresh"} {
+		for _, token := range []string{"not-a-jwt", "eyJhbGciOiJub25lIn0.eyJ1c2VyX2lkIjoxfQ."} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", token)
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+
+			var out map[string]any
+			_ = json.Unmarshal(w.Body.Bytes(), &out)
+			if w.Code != http.StatusUnauthorized || out["code"] != "token_invalid" || out["message"] != errTokenInvalid.Message {
+				t.Errorf("%s with %q: %d %v", path, token, w.Code, out)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger()
+}
+
+func TestAuthorize(t *testing.T) {
+	users := memory.NewUserStore()
+	stored := map[string]*model.User{
+		"user":              {Email: "user@example.com", Role: model.UserRoleUser, Status: model.UserStatusActive},
+		"support":           {Email: "support@example.com", Role: model.UserRoleSupport, Status: model.UserStatusActive},
+		"admin":             {Email: "admin@example.com", Role: model.UserRoleAdmin, Status: model.UserStatusActive},
+		"suspended support": {Email: "suspended@example.com", Role: model.UserRoleSupport, Status: model.UserStatusSuspended},
+	}
+	for _, user := range stored {
+		user.Password = "Passw0rd!"
+		if err := user.Save(context.Background(), users); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.UpdateRole(context.Background(), user.ID, user.Role); err != nil {
+			t.Fatal(err)
+		}
+		if err := users.UpdateStatus(context.Background(), user.ID, user.Status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		user      string
+		claimRole model.UserRole // Role claim of the access token
+		required  model.UserRole
+		status    int
+	}{
+		{"support route as user", "user", model.UserRoleUser, model.UserRoleSupport, http.StatusForbidden},
+		{"forged role claim", "user", model.UserRoleAdmin, model.UserRoleSupport, http.StatusForbidden},
+		{"support route as support", "support", model.UserRoleSupport, model.UserRoleSupport, http.StatusOK},
+		{"admin route as support", "support", model.UserRoleSupport, model.UserRoleAdmin, http.StatusForbidden},
+		{"support route as admin", "admin", model.UserRoleAdmin, model.UserRoleSupport, http.StatusOK},
+		{"admin route as admin", "admin", model.UserRoleAdmin, model.UserRoleAdmin, http.StatusOK},
+		{"suspended staff", "suspended support", model.UserRoleSupport, model.UserRoleSupport, http.StatusForbidden},
+		{"deleted user", "", model.UserRoleAdmin, model.UserRoleSupport, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var userID int64 = 999
+			if user, ok := stored[test.user]; ok {
+				userID = user.ID
+			}
+
+			engine := gin.New()
+			engine.GET("/", func(ctx *gin.Context) {
+				ctx.Set(config.JWT_LOGGED_IN_USER, userID)
+				ctx.Set(config.JWT_LOGGED_IN_USER_ROLE, string(test.claimRole))
+			}, Authorize(users, test.required), func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+		})
+	}
+}
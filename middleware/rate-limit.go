@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// RateLimitKey identifies who a request is counted against
+type RateLimitKey func(context *gin.Context) string
+
+// RateLimitPolicy is a named limit applied to a group of routes
+type RateLimitPolicy struct {
+	Name  string
+	Limit model.RateLimit
+	Key   RateLimitKey
+	// Scale the limit with the plan of the authenticated user
+	PlanTiers bool
+	// Reject requests when the limiter backend is unavailable instead of letting them through
+	FailClosed bool
+}
+
+// RateLimitPolicies are all the policies the router applies
+type RateLimitPolicies struct {
+	Default    RateLimitPolicy
+	Redirect   RateLimitPolicy
+	OtpSend    RateLimitPolicy
+	Login      RateLimitPolicy
+	LinkCreate RateLimitPolicy
+	Analytics  RateLimitPolicy
+	Report     RateLimitPolicy
+}
+
+// LoadRateLimitPolicies builds the policies from the RATE_LIMIT_* config
+func LoadRateLimitPolicies() RateLimitPolicies {
+	rules := config.Config.RATELIMIT
+
+	return RateLimitPolicies{
+		Default:    RateLimitPolicy{Name: "default", Limit: toRateLimit(rules.Default), Key: KeyByUser},
+		Redirect:   RateLimitPolicy{Name: "redirect", Limit: toRateLimit(rules.Redirect), Key: KeyByIP},
+		OtpSend:    RateLimitPolicy{Name: "otp_send", Limit: toRateLimit(rules.OtpSend), Key: KeyByIP, FailClosed: true},
+		Login:      RateLimitPolicy{Name: "login", Limit: toRateLimit(rules.Login), Key: KeyByIP, FailClosed: true},
+		LinkCreate: RateLimitPolicy{Name: "link_create", Limit: toRateLimit(rules.LinkCreate), Key: KeyByUser, PlanTiers: true},
+		Analytics:  RateLimitPolicy{Name: "analytics", Limit: toRateLimit(rules.Analytics), Key: KeyByUser, PlanTiers: true},
+		Report:     RateLimitPolicy{Name: "report", Limit: toRateLimit(rules.Report), Key: KeyByIP, FailClosed: true},
+	}
+}
+
+func toRateLimit(rule config.RateLimitRule) model.RateLimit {
+	return model.RateLimit{Rate: rule.Rate, Burst: rule.Burst, Period: rule.Period}
+}
+
+// KeyByIP counts requests per client IP
+func KeyByIP(context *gin.Context) string {
+	return "ip:" + context.ClientIP()
+}
+
+// KeyByUser counts requests per authenticated user, falling back to the
+// client IP. Must run after Authenticate.
+func KeyByUser(context *gin.Context) string {
+	if userID := getUserIDFromContext(context); userID != 0 {
+		return "user:" + strconv.FormatInt(userID, 10)
+	}
+	return KeyByIP(context)
+}
+
+// RateLimit enforces the policy and advertises it through the
+// RateLimit-* and Retry-After headers
+func RateLimit(limiter model.RateLimiter, policy RateLimitPolicy) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if err := AllowN(context, limiter, policy, 1); err != nil {
+			utils.HandleError(context, err)
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// AllowN counts n requests against the policy at once, for handlers whose
+// cost is only known from the body, e.g. the links of a bulk upload. It sets
+// the RateLimit-* headers and returns the error to answer with when the
+// requests aren't allowed.
+func AllowN(context *gin.Context, limiter model.RateLimiter, policy RateLimitPolicy, n int) error {
+	key := "ratelimit:" + policy.Name + ":" + policy.Key(context)
+	res, err := limiter.AllowN(context.Request.Context(), key, policy.LimitFor(context), n)
+	if err != nil {
+		if policy.FailClosed {
+			return utils.Unavailable("rate_limiter_unavailable", "Service temporarily unavailable, please try again").Wrap(err)
+		}
+
+		utils.Log.Error("Rate limiter unavailable, letting request through: ", err)
+		return nil
+	}
+
+	setRateLimitHeaders(context, res)
+
+	if !res.Allowed {
+		return utils.RateLimited("rate_limited", "Too Many Requests").WithRetryAfter(res.RetryAfter)
+	}
+	return nil
+}
+
+// LimitFor is the limit of the policy for the request, scaled for the plan
+// of the authenticated user
+func (p RateLimitPolicy) LimitFor(context *gin.Context) model.RateLimit {
+	if p.PlanTiers {
+		return scaleForPlan(p.Limit, context.GetString(config.JWT_LOGGED_IN_USER_PLAN))
+	}
+	return p.Limit
+}
+
+func scaleForPlan(limit model.RateLimit, plan string) model.RateLimit {
+	multiplier, ok := config.Config.RATELIMIT.PlanMultipliers[plan]
+	if !ok || multiplier <= 1 {
+		return limit
+	}
+
+	limit.Rate *= multiplier
+	limit.Burst *= multiplier
+	return limit
+}
+
+// setRateLimitHeaders advertises the rate per period as the limit, the
+// burst only shows in RateLimit-Policy
+func setRateLimitHeaders(context *gin.Context, res model.RateLimitResult) {
+	context.Header("RateLimit-Limit", strconv.Itoa(res.Limit.Rate))
+	context.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	context.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+	context.Header("RateLimit-Policy", strconv.Itoa(res.Limit.Rate)+";w="+strconv.Itoa(ceilSeconds(res.Limit.Period))+";burst="+strconv.Itoa(res.Limit.Burst))
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+func getUserIDFromContext(context *gin.Context) int64 {
+	// Set by Authenticate, 0 when the request is anonymous
+	if userID, exists := context.Get(config.JWT_LOGGED_IN_USER); exists {
+		if id, ok := userID.(int64); ok {
+			return id
+		}
+	}
+	return 0
+}
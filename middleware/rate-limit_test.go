@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+// rateLimitedEngine serves the policy on /, the X-User header stands in for
+// Authenticate
+func rateLimitedEngine(policy RateLimitPolicy) *gin.Engine {
+	engine := gin.New()
+	engine.Use(func(context *gin.Context) {
+		if id, err := strconv.ParseInt(context.GetHeader("X-User"), 10, 64); err == nil {
+			context.Set(config.JWT_LOGGED_IN_USER, id)
+		}
+	})
+	engine.GET("/", RateLimit(memory.NewRateLimiter(), policy), func(context *gin.Context) {
+		context.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	policy := RateLimitPolicy{Name: "test", Limit: model.RateLimit{Rate: 20, Burst: 5, Period: time.Minute}, Key: KeyByIP}
+	engine := rateLimitedEngine(policy)
+
+	recorder := httptest.NewRecorder()
+	engine.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := map[string]string{
+		"RateLimit-Limit":     "20",
+		"RateLimit-Remaining": "4",
+		"RateLimit-Policy":    "20;w=60;burst=5",
+	}
+	for header, value := range want {
+		if got := recorder.Header().Get(header); got != value {
+			t.Errorf("%s: %q, want %q", header, got, value)
+		}
+	}
+}
+
+func TestDefaultPolicyCountsPerUser(t *testing.T) {
+	old := config.Config.RATELIMIT.Default
+	t.Cleanup(func() { config.Config.RATELIMIT.Default = old })
+	config.Config.RATELIMIT.Default = config.RateLimitRule{Rate: 2, Burst: 2, Period: time.Minute}
+	engine := rateLimitedEngine(LoadRateLimitPolicies().Default)
+
+	status := func(user string) int {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if user != "" {
+			request.Header.Set("X-User", user)
+		}
+		recorder := httptest.NewRecorder()
+		engine.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	// Users behind one address, e.g. an office NAT, have their own limits
+	for _, user := range []string{"1", "1", "2", "2", ""} {
+		if got := status(user); got != http.StatusOK {
+			t.Fatalf("request of user %q: %d", user, got)
+		}
+	}
+	if got := status("1"); got != http.StatusTooManyRequests {
+		t.Errorf("third request of user 1: %d, want 429", got)
+	}
+	// Anonymous requests are counted per address
+	if got := status(""); got != http.StatusOK {
+		t.Errorf("second anonymous request: %d", got)
+	}
+	if got := status(""); got != http.StatusTooManyRequests {
+		t.Errorf("third anonymous request: %d, want 429", got)
+	}
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+type ChangePassword struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,strongpwd"`
+}
+
+// ChangeEmail takes an OTP sent to the new address with action change_email
+type ChangeEmail struct {
+	NewEmail string `json:"new_email" binding:"required,email" example:"new@example.com"`
+	Password string `json:"password" binding:"required"`
+	UserOtp
+}
+
+var ErrCurrentPasswordInvalid = utils.Unauthorized("invalid_credentials", "Current password is incorrect")
+
+// ChangePassword replaces the password after checking the current one
+func (u *User) ChangePassword(ctx context.Context, users UserStore, request ChangePassword) error {
+	if !utils.CheckHashPwd(request.CurrentPassword, u.Password) {
+		return ErrCurrentPasswordInvalid
+	}
+	if request.NewPassword == request.CurrentPassword {
+		return utils.BadRequest("password_unchanged", "The new password must differ from the current one")
+	}
+
+	hashedPwd, hashPwdErr := utils.HashPwd(request.NewPassword)
+	if hashPwdErr != nil {
+		return utils.Internal(fmt.Errorf("Error while trying to hash - %w !", hashPwdErr))
+	}
+
+	updateErr := users.UpdatePassword(ctx, u.ID, hashedPwd)
+	if updateErr != nil {
+		return updateErr
+	}
+
+	u.Password = hashedPwd
+	return nil
+}
+
+// ChangeEmail moves the account to a new address proven by an OTP sent
+// there. It returns the previous address so it can be notified.
+func (u *User) ChangeEmail(ctx context.Context, users UserStore, otps OtpStore, request ChangeEmail) (string, error) {
+	if !utils.CheckHashPwd(request.Password, u.Password) {
+		return "", ErrCurrentPasswordInvalid
+	}
+	if strings.EqualFold(request.NewEmail, u.Email) {
+		return "", utils.BadRequest("email_unchanged", "This already is your email address")
+	}
+	if reuseErr := checkEmailReusable(ctx, users, request.NewEmail); reuseErr != nil {
+		return "", reuseErr
+	}
+
+	otpVerify := VerifyOtp{
+		Token:  request.OtpToken,
+		Otp:    request.OtpCode,
+		Action: string(OtpActionTypeChangeEmail),
+		Key:    request.NewEmail,
+	}
+	otpErr := otpVerify.VerifyWithUpdate(ctx, otps) // Use the OTP up before the change, so it works once
+	if otpErr != nil {
+		return "", otpErr
+	}
+
+	updateErr := users.UpdateEmail(ctx, u.ID, request.NewEmail)
+	if updateErr != nil {
+		return "", updateErr
+	}
+
+	oldEmail := u.Email
+	u.Email = request.NewEmail
+	return oldEmail, nil
+}
+
+// UserProfileResponse is what GET /user/me returns
+type UserProfileResponse struct {
+	User
+	TwoFactorEnabled bool `json:"two_factor_enabled"`
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	adminSearchDefaultLimit = 50
+	adminSearchMaxLimit     = 200
+)
+
+// Pagination of admin search results
+type Pagination struct {
+	Limit  int `form:"limit" json:"limit" binding:"omitempty,min=1,max=200"`
+	Offset int `form:"offset" json:"offset" binding:"omitempty,min=0"`
+}
+
+func (p *Pagination) normalize() {
+	if p.Limit <= 0 {
+		p.Limit = adminSearchDefaultLimit
+	}
+	p.Limit = min(p.Limit, adminSearchMaxLimit)
+	p.Offset = max(p.Offset, 0)
+}
+
+type UserSearchFilter struct {
+	Query  string     `form:"q" json:"q"` // Part of the email
+	Role   UserRole   `form:"role" json:"role" binding:"omitempty,oneof=user support admin"`
+	Status UserStatus `form:"status" json:"status" binding:"omitempty,oneof=active suspended"`
+	Pagination
+}
+
+type UrlSearchFilter struct {
+	Query  string    `form:"q" json:"q"` // Part of the code or destination URL
+	UserID int64     `form:"user_id" json:"user_id" binding:"omitempty,min=1"`
+	Status UrlStatus `form:"status" json:"status" binding:"omitempty,oneof=active inactive deleted expired disabled quarantined blocked"`
+	Pagination
+}
+
+// SystemStats are system wide counters for the admin dashboard
+type SystemStats struct {
+	Users         int64                `json:"users"`
+	UsersByRole   map[UserRole]int64   `json:"users_by_role"`
+	UsersByStatus map[UserStatus]int64 `json:"users_by_status"`
+	NewUsers24h   int64                `json:"new_users_24h"`
+	Urls          int64                `json:"urls"`
+	UrlsByStatus  map[UrlStatus]int64  `json:"urls_by_status"`
+	NewUrls24h    int64                `json:"new_urls_24h"`
+	Clicks        int64                `json:"clicks"`     // Of people, bots are counted separately
+	Clicks24h     int64                `json:"clicks_24h"` // Of people
+	BotClicks     int64                `json:"bot_clicks"`
+	BotClicks24h  int64                `json:"bot_clicks_24h"`
+	GeneratedAt   time.Time            `json:"generated_at"`
+}
+
+type AdminUserResponse struct {
+	User User               `json:"user"`
+	Urls []UrlWithShortCode `json:"urls"`
+}
+
+type AdminUsersResponse struct {
+	Users []User `json:"users"`
+}
+
+type AdminUrlsResponse struct {
+	Urls []UrlWithShortCode `json:"urls"`
+}
+
+type UpdateUserRole struct {
+	Role UserRole `json:"role" binding:"required,oneof=user support admin"`
+}
+
+func SearchUsers(ctx context.Context, users UserStore, filter UserSearchFilter) ([]User, error) {
+	filter.normalize()
+	return users.Search(ctx, filter)
+}
+
+func SearchUrls(ctx context.Context, urls UrlStore, filter UrlSearchFilter) ([]UrlWithShortCode, error) {
+	filter.normalize()
+
+	found, err := urls.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]UrlWithShortCode, 0, len(found))
+	for _, url := range found {
+		result = append(result, UrlWithShortCode{Url: url, ShortUrl: utils.GetShortUrl(url.Code)})
+	}
+	return result, nil
+}
+
+// Suspend blocks the user from logging in and revokes their refresh tokens.
+// Access tokens already issued are refused once Authenticate reads the new
+// status, within JWT_USER_STATUS_CACHE_TTL.
+func (u *User) Suspend(ctx context.Context, users UserStore, tokens RefreshTokenStore) error {
+	if u.Role.Includes(UserRoleSupport) {
+		return utils.Forbidden("user_is_staff", "Staff accounts can't be suspended, change their role first")
+	}
+
+	if err := users.UpdateStatus(ctx, u.ID, UserStatusSuspended); err != nil {
+		return err
+	}
+	u.Status = UserStatusSuspended
+
+	return tokens.MarkAllUsed(ctx, u.ID)
+}
+
+func (u *User) Unsuspend(ctx context.Context, users UserStore) error {
+	if err := users.UpdateStatus(ctx, u.ID, UserStatusActive); err != nil {
+		return err
+	}
+	u.Status = UserStatusActive
+	return nil
+}
+
+func (u *User) UpdateRole(ctx context.Context, users UserStore, role UserRole) error {
+	if !role.IsValid() {
+		return utils.BadRequest("user_role_invalid", "Invalid role")
+	}
+
+	if err := users.UpdateRole(ctx, u.ID, role); err != nil {
+		return err
+	}
+	u.Role = role
+	return nil
+}
+
+// PromoteAdmins gives the admin role to the users with the given emails, so
+// a fresh installation has someone who can manage roles
+func PromoteAdmins(ctx context.Context, users UserStore, emails []string) {
+	for _, email := range emails {
+		if email == "" {
+			continue
+		}
+
+		user, err := users.GetByEmail(ctx, email)
+		if err != nil {
+			utils.Log.Warn("Can't promote ", email, " to admin: ", err)
+			continue
+		}
+		if user.Role == UserRoleAdmin {
+			continue
+		}
+
+		if err := user.UpdateRole(ctx, users, UserRoleAdmin); err != nil {
+			utils.Log.Error("Failed to promote ", email, " to admin: ", err)
+			continue
+		}
+		utils.Log.Info("Promoted ", email, " to admin")
+	}
+}
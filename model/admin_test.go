@@ -0,0 +1,34 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestPromoteAdmins(t *testing.T) {
+	ctx := context.Background()
+	users := memory.NewUserStore()
+	for _, email := range []string{"alice@example.com", "Bob@Example.com"} {
+		user := model.User{Email: email, Password: "Passw0rd!"}
+		if err := user.Save(ctx, users); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Wildcards of LIKE patterns must not match other accounts
+	model.PromoteAdmins(ctx, users, []string{"", "a_ice@example.com", "%@example.com", "bob@example.com", "nobody@example.com"})
+
+	want := map[string]model.UserRole{"alice@example.com": model.UserRoleUser, "Bob@Example.com": model.UserRoleAdmin}
+	for email, role := range want {
+		user, err := users.GetByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Role != role {
+			t.Errorf("%s has role %s, want %s", email, user.Role, role)
+		}
+	}
+}
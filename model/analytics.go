@@ -1,43 +1,347 @@
 package model
 
 import (
+	"context"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
-	"kgoel085.com/url-shortner/db"
+	"kgoel085.com/url-shortner/config"
 	"kgoel085.com/url-shortner/utils"
 )
 
+const (
+	defaultAnalyticsDays    = 30
+	maxAnalyticsDays        = 366
+	rawAnalyticsDays        = 7 // Longer ranges are read from the rollups
+	analyticsBreakdownLimit = 10
+)
+
 type Analytics struct {
 	ID         int64  `json:"id"`
 	UrlID      int64  `json:"url_id" binding:"required"`
 	ClickCount int64  `json:"click_count" binding:"required"`
-	IPAddress  string `json:"ip_address" binding:"required,ip"`
-	UserAgent  string `json:"user_agent" binding:"required"`
+	IPAddress  string `json:"ip_address"` // As the ClickPrivacy of the link owner allows
+	UserAgent  string `json:"user_agent"`
 	Referrer   string `json:"referrer"`
+	Bot        bool   `json:"bot"`
+	BotRule    string `json:"bot_rule,omitempty"` // Why the click was taken for a bot
 	CreatedAt  string `json:"created_at"`
+	// Dimensions of the rollups, set by RecordClick
+	ReferrerDomain string `json:"referrer_domain,omitempty"`
+	Country        string `json:"country,omitempty"` // ISO code from ANALYTICS_COUNTRY_HEADER
+	Device         string `json:"device"`
+	VisitorHash    string `json:"-"` // Salted hash of the day, empty for bots
+}
+
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceOther   = "other"
+)
+
+// RecordClick stores the click on the link with its rollup dimensions,
+// counts its visitor and streams it to live viewers, bots aren't visitors.
+// The IP address and user agent are kept as privacy, the link owner's mode,
+// allows. Visitors asking not to be tracked are recorded with
+// ClickPrivacyNone and aren't counted as visitors.
+func RecordClick(ctx context.Context, store *Store, url Url, click *Analytics, privacy ClickPrivacy) error {
+	click.ReferrerDomain = referrerDomain(click.Referrer)
+	click.Device = deviceType(click.UserAgent, click.Bot)
+
+	day := VisitorDay(time.Now())
+	var totalVisitor string
+	if !click.Bot && privacy != ClickPrivacyNone {
+		hash, hashErr := VisitorHash(ctx, store.Visitors, day, click.IPAddress, click.UserAgent)
+		if hashErr == nil {
+			totalVisitor, hashErr = TotalVisitorHash(ctx, store.Visitors, click.IPAddress, click.UserAgent)
+		}
+		if hashErr != nil {
+			utils.Log.Error("Failed to hash visitor: ", hashErr)
+			hash = ""
+		}
+		click.VisitorHash = hash
+	}
+	click.IPAddress, click.UserAgent = privacy.Anonymize(click.IPAddress, click.UserAgent)
+
+	if err := store.Analytics.Save(ctx, click); err != nil {
+		return err
+	}
+	publishClick(ctx, store.Live, url.Code, click)
+
+	if click.VisitorHash == "" {
+		return nil
+	}
+	return store.Visitors.Add(ctx, click.UrlID, day, click.VisitorHash, totalVisitor)
+}
+
+// CountryCode returns the country of a click as sent by a CDN or proxy in
+// the header, empty unless it is a two letter code
+func CountryCode(header string) string {
+	code := strings.ToUpper(strings.TrimSpace(header))
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return ""
+	}
+	return code
+}
+
+// referrerDomain returns the host of the referrer without "www."
+func referrerDomain(referrer string) string {
+	parsed, err := url.Parse(referrer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// deviceType tells the kind of device from the user agent, roughly
+func deviceType(userAgent string, bot bool) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case bot:
+		return DeviceBot
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return DeviceMobile
+	case strings.HasPrefix(ua, "mozilla/"):
+		return DeviceDesktop
+	}
+	return DeviceOther
+}
+
+// DailyClicks counts the clicks on a link on one day
+type DailyClicks struct {
+	Day       string
+	Clicks    int64
+	BotClicks int64
+}
+
+// UrlAnalyticsFilter picks the days of the analytics, the last 30 days by
+// default. Hours are only available for short ranges.
+type UrlAnalyticsFilter struct {
+	From        string         `form:"from" json:"from" binding:"omitempty,datetime=2006-01-02"`
+	To          string         `form:"to" json:"to" binding:"omitempty,datetime=2006-01-02"`
+	IncludeBots bool           `form:"include_bots" json:"include_bots"`
+	Interval    RollupInterval `form:"interval" json:"interval" binding:"omitempty,oneof=day hour"`
+}
+
+type DailyAnalytics struct {
+	Day            string `json:"day"`
+	Clicks         int64  `json:"clicks"`
+	BotClicks      int64  `json:"bot_clicks,omitempty"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+type HourlyAnalytics struct {
+	Hour           time.Time `json:"hour"`
+	Clicks         int64     `json:"clicks"`
+	BotClicks      int64     `json:"bot_clicks,omitempty"`
+	UniqueVisitors int64     `json:"unique_visitors"`
+}
+
+// AnalyticsBreakdown counts the clicks with one referrer domain, country or
+// device. Empty values are direct clicks and unknown countries.
+type AnalyticsBreakdown struct {
+	Value     string `json:"value"`
+	Clicks    int64  `json:"clicks"`
+	BotClicks int64  `json:"bot_clicks,omitempty"`
+}
+
+// UrlAnalytics are the all-time counts of a link, its counts per day or
+// hour and its top referrers, countries and devices
+type UrlAnalytics struct {
+	Code           string               `json:"code"`
+	ShortUrl       string               `json:"short_url"`
+	Clicks         int64                `json:"clicks"`
+	BotClicks      int64                `json:"bot_clicks,omitempty"`
+	UniqueVisitors int64                `json:"unique_visitors"`
+	From           string               `json:"from"`
+	To             string               `json:"to"`
+	Source         string               `json:"source"` // Where days were read from, raw clicks or rollups
+	Days           []DailyAnalytics     `json:"days"`
+	Hours          []HourlyAnalytics    `json:"hours,omitempty"`
+	Referrers      []AnalyticsBreakdown `json:"referrers"`
+	Countries      []AnalyticsBreakdown `json:"countries"`
+	Devices        []AnalyticsBreakdown `json:"devices"`
+}
+
+const (
+	AnalyticsSourceRaw    = "raw"
+	AnalyticsSourceRollup = "rollup"
+)
+
+// GetUrlAnalytics counts the clicks and unique visitors of the link per day
+// from From to To, both included. Short recent ranges are counted from the
+// raw clicks, longer ones and those reaching past ANALYTICS_RAW_RETENTION are
+// read from the daily rollups. Bot clicks are left out unless asked for.
+func GetUrlAnalytics(ctx context.Context, store *Store, url Url, filter UrlAnalyticsFilter) (UrlAnalytics, error) {
+	from, to, rangeErr := filter.days()
+	if rangeErr != nil {
+		return UrlAnalytics{}, rangeErr
+	}
+	end := to.AddDate(0, 0, 1)
+	if filter.Interval == RollupHour && end.Sub(from) > rawAnalyticsDays*24*time.Hour {
+		return UrlAnalytics{}, utils.BadRequest("analytics_range_invalid", fmt.Sprintf("Hours are available for up to %d days at once", rawAnalyticsDays))
+	}
+
+	result := UrlAnalytics{
+		Code:     url.Code,
+		ShortUrl: utils.GetShortUrl(url.Code),
+		Clicks:   url.ClickCount,
+		From:     VisitorDay(from),
+		To:       VisitorDay(to),
+	}
+	if filter.IncludeBots {
+		result.Clicks += url.BotClickCount
+		result.BotClicks = url.BotClickCount
+	}
+
+	total, totalErr := store.Visitors.CountTotal(ctx, []int64{url.ID})
+	if totalErr != nil {
+		return UrlAnalytics{}, totalErr
+	}
+	result.UniqueVisitors = total[url.ID]
+
+	// Raw days take their unique visitors from the daily HyperLogLogs, which
+	// expire after ANALYTICS_VISITOR_RETENTION
+	retention := config.Config.ANALYTICS.RawRetention
+	if visitorRetention := config.Config.ANALYTICS.VisitorRetention; visitorRetention > 0 && (retention <= 0 || visitorRetention < retention) {
+		retention = visitorRetention
+	}
+	var daysErr error
+	if end.Sub(from) > rawAnalyticsDays*24*time.Hour || (retention > 0 && from.Before(time.Now().Add(-retention))) {
+		result.Source = AnalyticsSourceRollup
+		result.Days, daysErr = rollupDays(ctx, store.Rollups, url.ID, from, end, filter.IncludeBots)
+	} else {
+		result.Source = AnalyticsSourceRaw
+		result.Days, daysErr = rawDays(ctx, store, url.ID, from, end, filter.IncludeBots)
+	}
+	if daysErr != nil {
+		return UrlAnalytics{}, daysErr
+	}
+
+	if filter.Interval == RollupHour {
+		hours, hoursErr := store.Rollups.Series(ctx, url.ID, RollupHour, from, end)
+		if hoursErr != nil {
+			return UrlAnalytics{}, hoursErr
+		}
+		result.Hours = []HourlyAnalytics{}
+		for _, bucket := range hours {
+			hourly := HourlyAnalytics{Hour: bucket.Bucket, Clicks: bucket.Clicks, UniqueVisitors: bucket.UniqueVisitors}
+			if filter.IncludeBots {
+				hourly.Clicks += bucket.BotClicks
+				hourly.BotClicks = bucket.BotClicks
+			}
+			result.Hours = append(result.Hours, hourly)
+		}
+	}
+
+	breakdowns := map[string]*[]AnalyticsBreakdown{
+		DimensionReferrer: &result.Referrers,
+		DimensionCountry:  &result.Countries,
+		DimensionDevice:   &result.Devices,
+	}
+	for dimension, breakdown := range breakdowns {
+		buckets, breakdownErr := store.Rollups.Breakdown(ctx, url.ID, dimension, from, end, analyticsBreakdownLimit)
+		if breakdownErr != nil {
+			return UrlAnalytics{}, breakdownErr
+		}
+		*breakdown = []AnalyticsBreakdown{}
+		for _, bucket := range buckets {
+			item := AnalyticsBreakdown{Value: bucket.Value, Clicks: bucket.Clicks}
+			if filter.IncludeBots {
+				item.Clicks += bucket.BotClicks
+				item.BotClicks = bucket.BotClicks
+			} else if item.Clicks == 0 {
+				continue // Only bots
+			}
+			*breakdown = append(*breakdown, item)
+		}
+	}
+
+	return result, nil
 }
 
-func (a *Analytics) Save() error {
-	query := `INSERT INTO analytics (url_id, ip_address, user_agent, referrer, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+// rawDays counts the clicks per day from the raw clicks and the unique
+// visitors from the HyperLogLogs
+func rawDays(ctx context.Context, store *Store, urlID int64, from time.Time, end time.Time, includeBots bool) ([]DailyAnalytics, error) {
+	clicks, clicksErr := store.Analytics.CountDaily(ctx, urlID, from, end)
+	if clicksErr != nil {
+		return nil, clicksErr
+	}
+	clicksByDay := make(map[string]DailyClicks, len(clicks))
+	for _, day := range clicks {
+		clicksByDay[day.Day] = day
+	}
 
-	logStr := fmt.Sprintf("Save analytics in DB : %s, URL ID: %d, Timestamp: %s", query, a.UrlID, time.Now().UTC())
-	utils.Log.Info(logStr)
+	var days []string
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, VisitorDay(day))
+	}
+	visitors, visitorsErr := store.Visitors.CountDays(ctx, urlID, days)
+	if visitorsErr != nil {
+		return nil, visitorsErr
+	}
 
-	rowErr := db.DB.QueryRow(query, a.UrlID, a.IPAddress, a.UserAgent, a.Referrer, time.Now().UTC()).Scan(&a.ID, &a.CreatedAt)
+	result := make([]DailyAnalytics, 0, len(days))
+	for _, name := range days {
+		daily := DailyAnalytics{Day: name, Clicks: clicksByDay[name].Clicks, UniqueVisitors: visitors[name]}
+		if includeBots {
+			daily.Clicks += clicksByDay[name].BotClicks
+			daily.BotClicks = clicksByDay[name].BotClicks
+		}
+		result = append(result, daily)
+	}
+	return result, nil
+}
 
-	if rowErr != nil {
-		errStr := fmt.Sprintf("Error while trying to save analytics - %s !", rowErr.Error())
-		return fmt.Errorf("%s", errStr)
+// rollupDays reads the days from the daily rollups
+func rollupDays(ctx context.Context, rollups RollupStore, urlID int64, from time.Time, end time.Time, includeBots bool) ([]DailyAnalytics, error) {
+	buckets, err := rollups.Series(ctx, urlID, RollupDay, from, end)
+	if err != nil {
+		return nil, err
 	}
+	byDay := make(map[string]AnalyticsBucket, len(buckets))
+	for _, bucket := range buckets {
+		byDay[VisitorDay(bucket.Bucket)] = bucket
+	}
+
+	var result []DailyAnalytics
+	for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+		bucket := byDay[VisitorDay(day)]
+		daily := DailyAnalytics{Day: VisitorDay(day), Clicks: bucket.Clicks, UniqueVisitors: bucket.UniqueVisitors}
+		if includeBots {
+			daily.Clicks += bucket.BotClicks
+			daily.BotClicks = bucket.BotClicks
+		}
+		result = append(result, daily)
+	}
+	return result, nil
+}
 
-	// Increment click count in url table
-	updateQuery := `UPDATE url SET click_count = click_count + 1 WHERE id = $1`
-	_, updateErr := db.DB.Exec(updateQuery, a.UrlID)
-	if updateErr != nil {
-		errStr := fmt.Sprintf("Error while trying to update click count - %s !", updateErr.Error())
-		return fmt.Errorf("%s", errStr)
+// days returns the first and last day of the filter, UTC midnights
+func (f UrlAnalyticsFilter) days() (time.Time, time.Time, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	to := today
+	if f.To != "" {
+		to, _ = time.Parse(VisitorDayFormat, f.To) // Validated by binding
+	}
+	from := to.AddDate(0, 0, -(defaultAnalyticsDays - 1))
+	if f.From != "" {
+		from, _ = time.Parse(VisitorDayFormat, f.From)
 	}
 
-	return nil
+	if from.After(to) {
+		return from, to, utils.BadRequest("analytics_range_invalid", "from must not be after to")
+	}
+	if to.Sub(from) >= maxAnalyticsDays*24*time.Hour {
+		return from, to, utils.BadRequest("analytics_range_invalid", fmt.Sprintf("At most %d days can be requested at once", maxAnalyticsDays))
+	}
+	return from, to, nil
 }
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type AuditAction string
+
+const (
+	AuditActionSignUp             AuditAction = "sign_up"
+	AuditActionLogin              AuditAction = "login"
+	AuditActionLoginFailed        AuditAction = "login_failed"
+	AuditActionOtpSent            AuditAction = "otp_sent"
+	AuditActionTokenRefresh       AuditAction = "token_refresh"
+	AuditActionPasswordChange     AuditAction = "password_change"
+	AuditActionEmailChange        AuditAction = "email_change"
+	AuditActionTotpEnable         AuditAction = "totp_enable"
+	AuditActionTotpDisable        AuditAction = "totp_disable"
+	AuditActionDataExport         AuditAction = "data_export"
+	AuditActionAccountDelete      AuditAction = "account_delete"
+	AuditActionClickPrivacyChange AuditAction = "click_privacy_change"
+	AuditActionUserSuspend        AuditAction = "user_suspend"
+	AuditActionUserUnsuspend      AuditAction = "user_unsuspend"
+	AuditActionUserRoleChange     AuditAction = "user_role_change"
+	AuditActionLinkCreate         AuditAction = "link_create"
+	AuditActionLinkStatusChange   AuditAction = "link_status_change"
+	AuditActionLinkReport         AuditAction = "link_report"
+	AuditActionReportDismiss      AuditAction = "report_dismiss"
+)
+
+type AuditTargetType string
+
+const (
+	AuditTargetUser AuditTargetType = "user"
+	AuditTargetUrl  AuditTargetType = "url"
+	AuditTargetOtp  AuditTargetType = "otp"
+)
+
+// AuditChange is the value of a field before and after an event
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEvent is an entry of the append-only audit log. UserID is the
+// account the event concerns, ActorID who caused it; they differ when staff
+// act on a user and ActorID is 0 for anonymous callers and the system.
+type AuditEvent struct {
+	ID         int64                  `json:"id"`
+	UserID     int64                  `json:"user_id,omitempty"`
+	ActorID    int64                  `json:"actor_id,omitempty"`
+	Action     AuditAction            `json:"action"`
+	TargetType AuditTargetType        `json:"target_type,omitempty"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	IPAddress  string                 `json:"ip_address"`
+	UserAgent  string                 `json:"user_agent"`
+	Changes    map[string]AuditChange `json:"changes,omitempty"`
+	Details    map[string]string      `json:"details,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// AuditFilter narrows down audit events, newest first
+type AuditFilter struct {
+	UserID     int64           `form:"user_id" json:"user_id" binding:"omitempty,min=1"`
+	ActorID    int64           `form:"actor_id" json:"actor_id" binding:"omitempty,min=1"`
+	Action     AuditAction     `form:"action" json:"action"`
+	TargetType AuditTargetType `form:"target_type" json:"target_type" binding:"omitempty,oneof=user url otp"`
+	TargetID   string          `form:"target_id" json:"target_id"`
+	IPAddress  string          `form:"ip" json:"ip" binding:"omitempty,ip"`
+	Since      time.Time       `form:"since" json:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until      time.Time       `form:"until" json:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Pagination
+}
+
+// UserAuditFilter is what users can filter their own events by
+type UserAuditFilter struct {
+	Action AuditAction `form:"action" json:"action"`
+	Since  time.Time   `form:"since" json:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until  time.Time   `form:"until" json:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	Pagination
+}
+
+type AuditEventsResponse struct {
+	Events []AuditEvent `json:"events"`
+}
+
+// AuditEvent starts an event concerning the user's account
+func (u *User) AuditEvent(action AuditAction) AuditEvent {
+	return AuditEvent{UserID: u.ID, Action: action, TargetType: AuditTargetUser, TargetID: strconv.FormatInt(u.ID, 10)}
+}
+
+// AuditEvent starts an event concerning the link, on behalf of its owner
+func (u *Url) AuditEvent(action AuditAction) AuditEvent {
+	return AuditEvent{UserID: u.UserID, Action: action, TargetType: AuditTargetUrl, TargetID: u.Code}
+}
+
+// RecordAudit appends the event to the audit log. Failures are logged, they
+// never fail the request that caused the event.
+func RecordAudit(ctx context.Context, audits AuditStore, event AuditEvent) {
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+
+	if err := audits.Save(ctx, &event); err != nil {
+		utils.Log.Error("Error saving audit event ", event.Action, ": ", err)
+	}
+}
+
+func SearchAuditEvents(ctx context.Context, audits AuditStore, filter AuditFilter) ([]AuditEvent, error) {
+	filter.normalize()
+
+	events, err := audits.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return nonNil(events), nil
+}
+
+// UserAuditEvents lists the events concerning the user
+func UserAuditEvents(ctx context.Context, audits AuditStore, userID int64, filter UserAuditFilter) ([]AuditEvent, error) {
+	return SearchAuditEvents(ctx, audits, AuditFilter{
+		UserID:     userID,
+		Action:     filter.Action,
+		Since:      filter.Since,
+		Until:      filter.Until,
+		Pagination: filter.Pagination,
+	})
+}
+
+// StartAuditPurge deletes audit events older than AUDIT_RETENTION every hour
+func StartAuditPurge(audits AuditStore) {
+	if config.Config.PRIVACY.AuditRetention <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			if err := PurgeAuditEvents(context.Background(), audits, time.Now().UTC()); err != nil {
+				utils.Log.Error("Error purging audit events: ", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeAuditEvents deletes audit events older than AUDIT_RETENTION,
+// ANALYTICS_PURGE_BATCH rows at a time
+func PurgeAuditEvents(ctx context.Context, audits AuditStore, now time.Time) error {
+	retention := config.Config.PRIVACY.AuditRetention
+	if retention <= 0 {
+		return nil
+	}
+
+	before := now.Add(-retention)
+	purged, err := purgeInBatches(func(limit int) (int64, error) {
+		return audits.Purge(ctx, before, limit)
+	})
+	if purged > 0 {
+		utils.Log.Info("Purged ", purged, " audit events before ", before.Format(time.DateOnly))
+	}
+	return err
+}
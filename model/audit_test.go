@@ -0,0 +1,66 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestPurgeAuditEvents(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	retention, batch := config.Config.PRIVACY.AuditRetention, config.Config.ANALYTICS.PurgeBatch
+	t.Cleanup(func() { config.Config.PRIVACY.AuditRetention, config.Config.ANALYTICS.PurgeBatch = retention, batch })
+	config.Config.PRIVACY.AuditRetention = 30 * 24 * time.Hour
+	config.Config.ANALYTICS.PurgeBatch = 2
+	audits := memory.NewAuditStore()
+
+	ages := []time.Duration{90 * 24 * time.Hour, 31 * 24 * time.Hour, 31 * 24 * time.Hour, 30*24*time.Hour + time.Second, 29 * 24 * time.Hour, time.Hour}
+	for _, age := range ages {
+		model.RecordAudit(ctx, audits, model.AuditEvent{Action: model.AuditActionLogin, IPAddress: "203.0.113.1", CreatedAt: now.Add(-age)})
+	}
+
+	// Several batches are needed
+	if err := model.PurgeAuditEvents(ctx, audits, now); err != nil {
+		t.Fatal(err)
+	}
+	events, err := audits.Search(ctx, model.AuditFilter{Pagination: model.Pagination{Limit: 100}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d events kept, want the 2 within retention", len(events))
+	}
+	for _, event := range events {
+		if now.Sub(event.CreatedAt) > config.Config.PRIVACY.AuditRetention {
+			t.Errorf("event from %s kept", event.CreatedAt)
+		}
+	}
+
+	// IDs aren't reused after a purge
+	model.RecordAudit(ctx, audits, model.AuditEvent{Action: model.AuditActionLogin, CreatedAt: now})
+	events, _ = audits.Search(ctx, model.AuditFilter{Pagination: model.Pagination{Limit: 1}})
+	if events[0].ID != int64(len(ages)+1) {
+		t.Errorf("new event got ID %d, want %d", events[0].ID, len(ages)+1)
+	}
+}
+
+func TestPurgeAuditEventsKeepsThemWithoutRetention(t *testing.T) {
+	ctx := context.Background()
+	retention := config.Config.PRIVACY.AuditRetention
+	t.Cleanup(func() { config.Config.PRIVACY.AuditRetention = retention })
+	config.Config.PRIVACY.AuditRetention = 0
+	audits := memory.NewAuditStore()
+
+	model.RecordAudit(ctx, audits, model.AuditEvent{Action: model.AuditActionLogin, CreatedAt: time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)})
+	if err := model.PurgeAuditEvents(ctx, audits, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := audits.Search(ctx, model.AuditFilter{Pagination: model.Pagination{Limit: 10}}); len(events) != 1 {
+		t.Errorf("%d events kept, want all", len(events))
+	}
+}
@@ -0,0 +1,18 @@
+package model
+
+import "net/http"
+
+// BotVerdict is the outcome of classifying a click. Rule names the rule or
+// heuristic that matched.
+type BotVerdict struct {
+	Bot  bool
+	Rule string
+}
+
+// BotClassifier tells clicks of people from those of crawlers, scanners
+// and health checkers. Unfurl crawlers are the bots building link cards
+// for chat apps and social networks.
+type BotClassifier interface {
+	Classify(ip string, header http.Header) BotVerdict
+	IsUnfurlCrawler(userAgent string) bool
+}
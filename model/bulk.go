@@ -0,0 +1,421 @@
+package model
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+type BulkMode string
+
+const (
+	BulkModeBestEffort  BulkMode = "best_effort" // Creates the valid rows and reports the others
+	BulkModeTransaction BulkMode = "transaction" // Creates all rows or none
+)
+
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatJSON ExportFormat = "json"
+)
+
+var (
+	errBulkRowSkipped = utils.Conflict("bulk_row_skipped", "Not created because other rows failed")
+	errBulkCsvInvalid = utils.BadRequest("bulk_csv_invalid", "CSV file could not be read")
+)
+
+// csvColumns maps the header names of our own export and of Bitly and
+// TinyURL exports to fields. Headers are matched case insensitively with
+// spaces and dashes read as underscores.
+var csvColumns = map[string][]string{
+	"url":         {"url", "long_url", "destination", "original_url"},
+	"code":        {"code", "alias", "back_half", "bitlink", "link", "tiny_url", "tinyurl", "short_url"},
+	"expires_at":  {"expires_at", "expiry", "expiration", "expiration_date", "expires"},
+	"description": {"description", "title"},
+}
+
+const bulkSafetyWorkers = 8
+
+var csvTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// UrlExportHeader is the first row of CSV exports, which can be imported again
+var UrlExportHeader = []string{"code", "short_url", "url", "status", "click_count", "created_at", "expires_at", "description"}
+
+type BulkCreateQuery struct {
+	Mode BulkMode `form:"mode" json:"mode" binding:"omitempty,oneof=best_effort transaction"`
+}
+
+type ExportUrlsQuery struct {
+	Format ExportFormat `form:"format" json:"format" binding:"omitempty,oneof=csv json"`
+}
+
+// BulkUrlRow is one link of a bulk request. Err is set when the row could
+// not be read or validated.
+type BulkUrlRow struct {
+	Link CreateShortUrl
+	Err  error
+}
+
+type BulkUrlResult struct {
+	Row      int                  `json:"row"` // Starting at 1, CSV headers not counted
+	Url      string               `json:"url"`
+	Code     string               `json:"code,omitempty"`
+	ShortUrl string               `json:"short_url,omitempty"`
+	Status   UrlStatus            `json:"status,omitempty"` // Quarantined when the safety checks flagged the link
+	Error    *utils.ErrorResponse `json:"error,omitempty"`
+}
+
+type BulkCreateUrlsResponse struct {
+	Created int             `json:"created"`
+	Failed  int             `json:"failed"`
+	Results []BulkUrlResult `json:"results"`
+}
+
+// UrlSaveError tells which URL of a batch could not be saved
+type UrlSaveError struct {
+	Index int
+	Err   error
+}
+
+func (e *UrlSaveError) Error() string {
+	return fmt.Sprintf("URL %d: %v", e.Index, e.Err)
+}
+
+func (e *UrlSaveError) Unwrap() error {
+	return e.Err
+}
+
+// ParseUrlsCSV reads links from a CSV file with a header row. Besides our
+// own export it accepts the exports of Bitly and TinyURL, whose short links
+// are imported with the same code.
+func ParseUrlsCSV(r io.Reader) ([]BulkUrlRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, headerErr := reader.Read()
+	if headerErr == io.EOF {
+		return nil, nil
+	}
+	if headerErr != nil {
+		return nil, errBulkCsvInvalid.Wrap(headerErr)
+	}
+
+	columns := csvColumnIndexes(header)
+	if _, ok := columns["url"]; !ok {
+		return nil, utils.BadRequest("bulk_csv_no_url", "CSV file needs a url or long_url column")
+	}
+
+	var rows []BulkUrlRow
+	for {
+		record, readErr := reader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, errBulkCsvInvalid.Wrap(readErr)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := BulkUrlRow{Link: CreateShortUrl{Url: field("url"), Code: shortLinkCode(field("code")), Description: field("description")}}
+		if expiry := field("expires_at"); expiry != "" {
+			row.Link.ExpiryAt, row.Err = parseCsvTime(expiry)
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func csvColumnIndexes(header []string) map[string]int {
+	indexes := make(map[string]int)
+	for field, names := range csvColumns {
+		for _, name := range names {
+			for i, column := range header {
+				if normalizeCsvHeader(column) == name {
+					indexes[field] = i
+					break
+				}
+			}
+			if _, ok := indexes[field]; ok {
+				break
+			}
+		}
+	}
+	return indexes
+}
+
+func normalizeCsvHeader(column string) string {
+	column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(column)
+}
+
+// shortLinkCode turns short links like bit.ly/abc or https://tinyurl.com/abc
+// into their code
+func shortLinkCode(value string) string {
+	value = strings.TrimRight(value, "/")
+	if i := strings.LastIndex(value, "/"); i >= 0 {
+		return value[i+1:]
+	}
+	return value
+}
+
+func parseCsvTime(value string) (time.Time, error) {
+	for _, layout := range csvTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, utils.BadRequest("bulk_expiry_invalid", "Expiry must be a date like 2006-01-02 or an RFC 3339 time")
+}
+
+// CreateUrls creates the links of a bulk request for the user. In
+// transaction mode nothing is created unless every row is valid and saved.
+func CreateUrls(ctx context.Context, urls UrlStore, checker UrlChecker, userID int64, rows []BulkUrlRow, mode BulkMode) (BulkCreateUrlsResponse, []Url, error) {
+	results := make([]BulkUrlResult, len(rows))
+	pending := make([]*Url, len(rows))
+	codes := make(map[string]bool)
+	failed := false
+
+	for i, row := range rows {
+		results[i] = BulkUrlResult{Row: i + 1, Url: row.Link.Url}
+
+		rowErr := row.Err
+		if rowErr == nil {
+			row.Link.UserID = userID
+			url, validateErr := row.Link.Validate(ctx, urls)
+			if validateErr == nil && codes[url.Code] {
+				validateErr = ErrUrlCodeExists
+			}
+			if validateErr == nil {
+				codes[url.Code] = true
+				pending[i] = &url
+			}
+			rowErr = validateErr
+		}
+
+		if rowErr != nil {
+			if !isRowError(rowErr) {
+				return BulkCreateUrlsResponse{}, nil, rowErr
+			}
+			results[i].Error = bulkRowError(rowErr)
+			failed = true
+		}
+	}
+
+	checkBulkSafety(ctx, checker, pending)
+
+	if mode == BulkModeTransaction {
+		if !failed {
+			batch := make([]*Url, 0, len(pending))
+			for _, url := range pending {
+				batch = append(batch, url)
+			}
+
+			saveErr := urls.SaveAll(ctx, batch)
+			var urlSaveErr *UrlSaveError
+			if errors.As(saveErr, &urlSaveErr) && isRowError(urlSaveErr.Err) {
+				results[urlSaveErr.Index].Error = bulkRowError(urlSaveErr.Err)
+				pending[urlSaveErr.Index] = nil
+				failed = true
+			} else if saveErr != nil {
+				return BulkCreateUrlsResponse{}, nil, saveErr
+			}
+		}
+
+		if failed {
+			for i, url := range pending {
+				if url != nil {
+					results[i].Error = bulkRowError(errBulkRowSkipped)
+				}
+			}
+			return summarizeBulk(results), nil, nil
+		}
+	} else {
+		for i, url := range pending {
+			if url == nil {
+				continue
+			}
+
+			saveErr := urls.Save(ctx, url)
+			if saveErr != nil {
+				if !isRowError(saveErr) {
+					return BulkCreateUrlsResponse{}, nil, saveErr
+				}
+				results[i].Error = bulkRowError(saveErr)
+				pending[i] = nil
+			}
+		}
+	}
+
+	var created []Url
+	for i, url := range pending {
+		if url == nil {
+			continue
+		}
+		results[i].Code = url.Code
+		results[i].ShortUrl = utils.GetShortUrl(url.Code)
+		results[i].Status = url.Status
+		created = append(created, *url)
+	}
+
+	return summarizeBulk(results), created, nil
+}
+
+// checkBulkSafety runs the safety checks of the links in parallel, they may
+// have to follow redirects
+func checkBulkSafety(ctx context.Context, checker UrlChecker, pending []*Url) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, bulkSafetyWorkers)
+
+	for _, url := range pending {
+		if url == nil {
+			continue
+		}
+
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			url.CheckSafety(ctx, checker)
+		}()
+	}
+	wg.Wait()
+}
+
+// isRowError tells row level failures, reported in the results, from
+// failures of the whole request
+func isRowError(err error) bool {
+	if _, ok := utils.ValidationDetails(err); ok {
+		return true
+	}
+	kind := utils.AsAppError(err).Kind
+	return kind == utils.ErrorKindBadRequest || kind == utils.ErrorKindConflict
+}
+
+func bulkRowError(err error) *utils.ErrorResponse {
+	if details, ok := utils.ValidationDetails(err); ok {
+		return &utils.ErrorResponse{Code: "validation_failed", Message: "Invalid row", Errors: details}
+	}
+
+	appErr := utils.AsAppError(err)
+	return &utils.ErrorResponse{Code: appErr.Code, Message: appErr.Message}
+}
+
+func summarizeBulk(results []BulkUrlResult) BulkCreateUrlsResponse {
+	response := BulkCreateUrlsResponse{Results: results}
+	for _, result := range results {
+		if result.Error != nil {
+			response.Failed++
+		} else {
+			response.Created++
+		}
+	}
+	return response
+}
+
+// ExportUrls writes all links of the user with their click counts to w,
+// oldest first. Rows are flushed as they are written, so large exports
+// stream instead of being built in memory.
+func ExportUrls(ctx context.Context, urls UrlStore, userID int64, format ExportFormat, w io.Writer) error {
+	flusher, _ := w.(http.Flusher)
+	flush := func() {
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	written := 0
+	if format == ExportFormatJSON {
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+
+		eachErr := urls.EachByUser(ctx, userID, func(url Url) error {
+			item, marshalErr := json.Marshal(UrlWithShortCode{Url: url, ShortUrl: utils.GetShortUrl(url.Code)})
+			if marshalErr != nil {
+				return marshalErr
+			}
+			if written > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if _, err := w.Write(item); err != nil {
+				return err
+			}
+
+			written++
+			if written%100 == 0 {
+				flush()
+			}
+			return nil
+		})
+		if eachErr != nil {
+			return eachErr
+		}
+
+		_, err := io.WriteString(w, "]\n")
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(UrlExportHeader); err != nil {
+		return err
+	}
+
+	eachErr := urls.EachByUser(ctx, userID, func(url Url) error {
+		if err := writer.Write(url.exportRecord()); err != nil {
+			return err
+		}
+
+		written++
+		if written%100 == 0 {
+			writer.Flush()
+			flush()
+		}
+		return writer.Error()
+	})
+	if eachErr != nil {
+		return eachErr
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (u *Url) exportRecord() []string {
+	expiresAt := ""
+	if !u.ExpiryAt.IsZero() {
+		expiresAt = u.ExpiryAt.UTC().Format(time.RFC3339)
+	}
+
+	return []string{
+		u.Code,
+		utils.GetShortUrl(u.Code),
+		u.Url,
+		string(u.Status),
+		strconv.FormatInt(u.ClickCount, 10),
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		expiresAt,
+		u.Description,
+	}
+}
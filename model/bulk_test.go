@@ -0,0 +1,181 @@
+package model_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func TestParseUrlsCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []model.CreateShortUrl
+	}{
+		{
+			name: "own export",
+			csv: "\ufeffcode,short_url,url,status,click_count,created_at,expires_at,description\n" +
+				"hello,http://localhost:8000/hello,https://example.com/a,active,3,2026-01-01T00:00:00Z,2027-01-02,Landing page\n",
+			want: []model.CreateShortUrl{{Url: "https://example.com/a", Code: "hello", Description: "Landing page", ExpiryAt: time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC)}},
+		},
+		{
+			name: "Bitly",
+			csv: "Bitlink,Long URL,Title,Created\n" +
+				"bit.ly/3AbCd,https://example.com/b,Spring sale,2025-03-01\n" +
+				"https://bit.ly/custom/,https://example.com/c,,2025-03-02\n",
+			want: []model.CreateShortUrl{
+				{Url: "https://example.com/b", Code: "3AbCd", Description: "Spring sale"},
+				{Url: "https://example.com/c", Code: "custom"},
+			},
+		},
+		{
+			name: "TinyURL",
+			csv:  "Tiny URL,Long URL,Created\n\"https://tinyurl.com/y2k\",  https://example.com/d ,2025-03-01\n",
+			want: []model.CreateShortUrl{{Url: "https://example.com/d", Code: "y2k"}},
+		},
+		{
+			name: "short rows",
+			csv:  "url,code,description\nhttps://example.com/e\n",
+			want: []model.CreateShortUrl{{Url: "https://example.com/e"}},
+		},
+		{
+			name: "header only",
+			csv:  "url\n",
+		},
+		{
+			name: "empty file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := model.ParseUrlsCSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) != len(tt.want) {
+				t.Fatalf("%d rows, want %d", len(rows), len(tt.want))
+			}
+			for i, row := range rows {
+				if row.Err != nil {
+					t.Errorf("row %d: %v", i+1, row.Err)
+				}
+				if row.Link.Url != tt.want[i].Url || row.Link.Code != tt.want[i].Code || row.Link.Description != tt.want[i].Description || !row.Link.ExpiryAt.Equal(tt.want[i].ExpiryAt) {
+					t.Errorf("row %d = %+v, want %+v", i+1, row.Link, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseUrlsCSVErrors(t *testing.T) {
+	if _, err := model.ParseUrlsCSV(strings.NewReader("code,title\nabc,Missing destination\n")); utils.AsAppError(err).Code != "bulk_csv_no_url" {
+		t.Errorf("without url column: %v", err)
+	}
+	if _, err := model.ParseUrlsCSV(strings.NewReader("url\n\"https://example.com/unterminated\n")); utils.AsAppError(err).Code != "bulk_csv_invalid" {
+		t.Errorf("malformed CSV: %v", err)
+	}
+
+	// A bad expiry only fails its row
+	rows, err := model.ParseUrlsCSV(strings.NewReader("url,expires_at\nhttps://example.com/a,next week\nhttps://example.com/b,2027-01-02 15:04:05\n"))
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("%d rows, %v", len(rows), err)
+	}
+	if utils.AsAppError(rows[0].Err).Code != "bulk_expiry_invalid" || rows[1].Err != nil {
+		t.Errorf("row errors %v and %v", rows[0].Err, rows[1].Err)
+	}
+}
+
+func bulkRows(links ...model.CreateShortUrl) []model.BulkUrlRow {
+	rows := make([]model.BulkUrlRow, len(links))
+	for i, link := range links {
+		rows[i] = model.BulkUrlRow{Link: link}
+	}
+	return rows
+}
+
+func TestCreateUrlsTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	if err := store.Urls.Save(ctx, &model.Url{UserID: 2, Code: "taken", Url: "https://example.com/", Status: model.UrlStatusActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	// One failing row, nothing is created and the others are skipped
+	rows := bulkRows(
+		model.CreateShortUrl{Url: "https://example.com/a", Code: "first"},
+		model.CreateShortUrl{Url: "https://example.com/b", Code: "taken"},
+		model.CreateShortUrl{Url: "https://example.com/c", Code: "third"},
+	)
+	response, created, err := model.CreateUrls(ctx, store.Urls, fakeChecker{}, 1, rows, model.BulkModeTransaction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Created != 0 || response.Failed != 3 || len(created) != 0 {
+		t.Fatalf("%d created, %d failed, %d returned", response.Created, response.Failed, len(created))
+	}
+	for i, code := range []string{"bulk_row_skipped", "url_code_exists", "bulk_row_skipped"} {
+		if got := response.Results[i].Error; got == nil || got.Code != code {
+			t.Errorf("row %d error = %+v, want %s", i+1, got, code)
+		}
+	}
+	for _, code := range []string{"first", "third"} {
+		if _, err := store.Urls.GetByCode(ctx, code); !errors.Is(err, model.ErrUrlNotFound) {
+			t.Errorf("%s was created: %v", code, err)
+		}
+	}
+
+	// Codes repeated within the upload fail the same way
+	rows = bulkRows(
+		model.CreateShortUrl{Url: "https://example.com/a", Code: "twice"},
+		model.CreateShortUrl{Url: "https://example.com/b", Code: "twice"},
+	)
+	if response, _, _ := model.CreateUrls(ctx, store.Urls, fakeChecker{}, 1, rows, model.BulkModeTransaction); response.Created != 0 {
+		t.Errorf("%d created with a repeated code", response.Created)
+	}
+
+	rows = bulkRows(
+		model.CreateShortUrl{Url: "https://example.com/a", Code: "first"},
+		model.CreateShortUrl{Url: "https://bad.example/"},
+	)
+	response, created, err = model.CreateUrls(ctx, store.Urls, fakeChecker{flagged: map[string]bool{"https://bad.example/": true}}, 1, rows, model.BulkModeTransaction)
+	if err != nil || response.Created != 2 || len(created) != 2 {
+		t.Fatalf("%d created, %d returned, %v", response.Created, len(created), err)
+	}
+	// Flagged links are created quarantined
+	if response.Results[1].Status != model.UrlStatusQuarantined || created[1].UserID != 1 {
+		t.Errorf("flagged row = %+v", response.Results[1])
+	}
+}
+
+func TestCreateUrlsBestEffort(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+
+	rows := bulkRows(
+		model.CreateShortUrl{Url: "https://example.com/a", Code: "kept"},
+		model.CreateShortUrl{Url: "https://example.com/b", Code: "kept"},
+		model.CreateShortUrl{Url: "https://example.com/c"},
+	)
+	rows = append(rows, model.BulkUrlRow{Link: model.CreateShortUrl{Url: "https://example.com/d"}, Err: utils.BadRequest("bulk_expiry_invalid", "bad expiry")})
+
+	response, created, err := model.CreateUrls(ctx, store.Urls, fakeChecker{}, 1, rows, model.BulkModeBestEffort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Created != 2 || response.Failed != 2 || len(created) != 2 {
+		t.Fatalf("%d created, %d failed, %d returned", response.Created, response.Failed, len(created))
+	}
+	if response.Results[0].Code != "kept" || response.Results[2].Code == "" || response.Results[2].ShortUrl == "" {
+		t.Errorf("created rows %+v and %+v", response.Results[0], response.Results[2])
+	}
+	if response.Results[1].Error == nil || response.Results[3].Error.Code != "bulk_expiry_invalid" {
+		t.Errorf("failed rows %+v and %+v", response.Results[1], response.Results[3])
+	}
+}
@@ -0,0 +1,295 @@
+package model
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// ClickPrivacy decides how much of the visitor's IP address and user agent
+// is stored with a click
+type ClickPrivacy string
+
+const (
+	ClickPrivacyFull      ClickPrivacy = "full"      // IP address and user agent as sent
+	ClickPrivacyTruncated ClickPrivacy = "truncated" // IPv4 to /24, IPv6 to /48
+	ClickPrivacyHashed    ClickPrivacy = "hashed"    // Keyed hash of the IP address, pseudonymous rather than anonymous
+	ClickPrivacyNone      ClickPrivacy = "none"      // Neither IP address nor user agent
+)
+
+// anonymizeBatch is how many clicks AnonymizeClicks rewrites at a time
+const anonymizeBatch = 1000
+
+// maxCachedClickPrivacies bounds the modes a ClickPrivacyCache remembers
+const maxCachedClickPrivacies = 10000
+
+// hashedIPPrefix marks a hashed address
+const hashedIPPrefix = "hmac-sha256:"
+
+func (p ClickPrivacy) IsValid() bool {
+	switch p {
+	case ClickPrivacyFull, ClickPrivacyTruncated, ClickPrivacyHashed, ClickPrivacyNone:
+		return true
+	}
+	return false
+}
+
+// UpdateClickPrivacy sets the privacy mode of the user's links, empty
+// follows CLICK_PRIVACY
+type UpdateClickPrivacy struct {
+	ClickPrivacy ClickPrivacy `json:"click_privacy" binding:"omitempty,oneof=full truncated hashed none" example:"truncated"`
+}
+
+// ClickPrivacySettings are the user's own mode and the one applied
+type ClickPrivacySettings struct {
+	ClickPrivacy ClickPrivacy `json:"click_privacy"`
+	Effective    ClickPrivacy `json:"effective"`
+}
+
+// ClickClient is a stored IP address and user agent, of a click with the
+// owner of its link, an audit event with its user or an abuse report with
+// its reporter
+type ClickClient struct {
+	ID        int64
+	UserID    int64
+	IPAddress string
+	UserAgent string
+}
+
+// DefaultClickPrivacy is CLICK_PRIVACY. Unknown values store nothing
+// rather than too much.
+func DefaultClickPrivacy() ClickPrivacy {
+	privacy := ClickPrivacy(config.Config.PRIVACY.ClickPrivacy)
+	if !privacy.IsValid() {
+		return ClickPrivacyNone
+	}
+	return privacy
+}
+
+// EffectiveClickPrivacy is the user's own mode or else CLICK_PRIVACY
+func (u *User) EffectiveClickPrivacy() ClickPrivacy {
+	if u.ClickPrivacy.IsValid() {
+		return u.ClickPrivacy
+	}
+	return DefaultClickPrivacy()
+}
+
+// SetClickPrivacy changes the mode new clicks on the user's links are
+// stored with
+func (u *User) SetClickPrivacy(ctx context.Context, users UserStore, privacy ClickPrivacy) error {
+	if err := users.UpdateClickPrivacy(ctx, u.ID, privacy); err != nil {
+		return err
+	}
+	u.ClickPrivacy = privacy
+	return nil
+}
+
+// Anonymize returns the IP address and user agent as stored in the mode.
+// Addresses already truncated or hashed stay as they are, so clicks can be
+// anonymized again.
+func (p ClickPrivacy) Anonymize(ip string, userAgent string) (string, string) {
+	switch p {
+	case ClickPrivacyFull:
+		return ip, userAgent
+	case ClickPrivacyTruncated, ClickPrivacyHashed:
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return ip, userAgent // Hashed already, or empty
+		}
+		if p == ClickPrivacyHashed {
+			return hashIP(addr.Unmap(), time.Now()), userAgent
+		}
+		return truncateIP(addr), userAgent
+	}
+	return "", ""
+}
+
+// truncateIP keeps the network of the address, /24 of IPv4 and /48 of IPv6
+func truncateIP(addr netip.Addr) string {
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.Addr().String()
+}
+
+// hashIP hashes the address with the key of the CLICK_IP_HASH_ROTATION
+// period at. Equal addresses only hash the same within a period, so hashes
+// can't be linked across periods. Anyone with the key can still test a
+// guessed address against a hash, hashed addresses are pseudonymous.
+func hashIP(addr netip.Addr, at time.Time) string {
+	period := int64(0)
+	if rotation := int64(config.Config.PRIVACY.ClickIPHashRotation / time.Second); rotation > 0 {
+		period = at.Unix() / rotation
+	}
+
+	mac := hmac.New(sha256.New, clickIPHashKey(period))
+	mac.Write([]byte(addr.String()))
+	return hashedIPPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// clickIPHashKey is the key of a rotation period, derived from
+// CLICK_IP_HASH_KEY or else ENCRYPTION_KEY, never the key of OTPs
+func clickIPHashKey(period int64) []byte {
+	base := []byte(config.Config.PRIVACY.ClickIPHashKey)
+	if len(base) == 0 {
+		derive := hmac.New(sha256.New, []byte(config.Config.APP.EncryptionKey))
+		derive.Write([]byte("click-ip-hash"))
+		base = derive.Sum(nil)
+	}
+
+	mac := hmac.New(sha256.New, base)
+	mac.Write([]byte("period|" + strconv.FormatInt(period, 10)))
+	return mac.Sum(nil)
+}
+
+type clickPrivacyEntry struct {
+	privacy   ClickPrivacy
+	expiresAt time.Time
+}
+
+// ClickPrivacyCache remembers the modes of link owners for
+// CLICK_PRIVACY_CACHE_TTL, so recording a click doesn't read the owner of
+// the link every time. Changes made through another instance apply once the
+// entry expires.
+type ClickPrivacyCache struct {
+	users UserStore
+
+	mu      sync.Mutex
+	entries map[int64]clickPrivacyEntry
+}
+
+func NewClickPrivacyCache(users UserStore) *ClickPrivacyCache {
+	return &ClickPrivacyCache{users: users, entries: map[int64]clickPrivacyEntry{}}
+}
+
+// Get returns the mode of the user's links
+func (c *ClickPrivacyCache) Get(ctx context.Context, userID int64) ClickPrivacy {
+	ttl := config.Config.PRIVACY.ClickPrivacyCacheTTL
+	now := time.Now()
+
+	if ttl > 0 {
+		c.mu.Lock()
+		entry, ok := c.entries[userID]
+		c.mu.Unlock()
+		if ok && now.Before(entry.expiresAt) {
+			return entry.privacy
+		}
+	}
+
+	privacy := clickPrivacyOf(ctx, c.users, userID)
+	if ttl <= 0 {
+		return privacy
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCachedClickPrivacies {
+		clear(c.entries)
+	}
+	c.entries[userID] = clickPrivacyEntry{privacy: privacy, expiresAt: now.Add(ttl)}
+	return privacy
+}
+
+// Forget drops the cached mode of the user, after they changed it
+func (c *ClickPrivacyCache) Forget(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, userID)
+}
+
+// clickPrivacyOf is the mode of the user's links. Owners that can't be
+// read get CLICK_PRIVACY.
+func clickPrivacyOf(ctx context.Context, users UserStore, userID int64) ClickPrivacy {
+	user, err := users.GetByID(ctx, userID)
+	if err != nil {
+		utils.Log.Error("Failed to read click privacy of user ", userID, ": ", err)
+		return DefaultClickPrivacy()
+	}
+	return user.EffectiveClickPrivacy()
+}
+
+// AnonymizeClicks applies the privacy modes of their owners, or privacy
+// for every click when it is set, to the clicks stored before before. It
+// returns how many clicks changed.
+func AnonymizeClicks(ctx context.Context, store *Store, privacy ClickPrivacy, before time.Time) (int64, error) {
+	owners := map[int64]ClickPrivacy{}
+
+	return anonymizeClients(ctx, store.Analytics, before, func(client ClickClient) ClickPrivacy {
+		if privacy != "" {
+			return privacy
+		}
+		if _, ok := owners[client.UserID]; !ok {
+			owners[client.UserID] = clickPrivacyOf(ctx, store.Users, client.UserID)
+		}
+		return owners[client.UserID]
+	})
+}
+
+// AnonymizeAuditAndReports applies privacy, or CLICK_PRIVACY when it is
+// empty, to the addresses of audit events and resolved abuse reports stored
+// before before. Open reports keep theirs, it is how one address is kept
+// from reporting a link twice. It returns how many events and reports
+// changed.
+func AnonymizeAuditAndReports(ctx context.Context, store *Store, privacy ClickPrivacy, before time.Time) (int64, error) {
+	if privacy == "" {
+		privacy = DefaultClickPrivacy()
+	}
+	modeOf := func(ClickClient) ClickPrivacy { return privacy }
+
+	changed, auditErr := anonymizeClients(ctx, store.Audits, before, modeOf)
+	if auditErr != nil {
+		return changed, auditErr
+	}
+	reports, reportErr := anonymizeClients(ctx, store.AbuseReports, before, modeOf)
+	return changed + reports, reportErr
+}
+
+// clientStore lists and rewrites stored IP addresses and user agents
+type clientStore interface {
+	ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]ClickClient, error)
+	UpdateClients(ctx context.Context, clients []ClickClient) error
+}
+
+// anonymizeClients rewrites the clients stored before before as modeOf says,
+// in batches, and returns how many changed
+func anonymizeClients(ctx context.Context, clients clientStore, before time.Time, modeOf func(ClickClient) ClickPrivacy) (int64, error) {
+	var afterID, changed int64
+	for {
+		batch, listErr := clients.ListClients(ctx, afterID, before, anonymizeBatch)
+		if listErr != nil {
+			return changed, listErr
+		}
+		if len(batch) == 0 {
+			return changed, nil
+		}
+
+		var updates []ClickClient
+		for _, client := range batch {
+			ip, userAgent := modeOf(client).Anonymize(client.IPAddress, client.UserAgent)
+			if ip != client.IPAddress || userAgent != client.UserAgent {
+				updates = append(updates, ClickClient{ID: client.ID, UserID: client.UserID, IPAddress: ip, UserAgent: userAgent})
+			}
+		}
+
+		if len(updates) > 0 {
+			if err := clients.UpdateClients(ctx, updates); err != nil {
+				return changed, err
+			}
+			changed += int64(len(updates))
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
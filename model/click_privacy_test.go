@@ -0,0 +1,175 @@
+package model_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func setClickPrivacy(t *testing.T, privacy model.ClickPrivacy) {
+	t.Helper()
+
+	old := config.Config.PRIVACY
+	t.Cleanup(func() { config.Config.PRIVACY = old })
+	config.Config.PRIVACY.ClickPrivacy = string(privacy)
+	config.Config.PRIVACY.ClickIPHashKey = "click-ip-key"
+	config.Config.PRIVACY.ClickIPHashRotation = 0
+	config.Config.PRIVACY.ClickPrivacyCacheTTL = time.Minute
+}
+
+func TestClickPrivacyAnonymize(t *testing.T) {
+	setClickPrivacy(t, model.ClickPrivacyFull)
+	const ua = "Mozilla/5.0"
+
+	tests := []struct {
+		privacy model.ClickPrivacy
+		ip      string
+		wantIP  string
+		wantUA  string
+	}{
+		{model.ClickPrivacyFull, "203.0.113.77", "203.0.113.77", ua},
+		{model.ClickPrivacyTruncated, "203.0.113.77", "203.0.113.0", ua},
+		{model.ClickPrivacyTruncated, "::ffff:203.0.113.77", "203.0.113.0", ua},
+		{model.ClickPrivacyTruncated, "2001:db8:1234:5678:9abc::1", "2001:db8:1234::", ua},
+		{model.ClickPrivacyTruncated, "203.0.113.0", "203.0.113.0", ua}, // Truncated already
+		{model.ClickPrivacyTruncated, "", "", ua},
+		{model.ClickPrivacyNone, "203.0.113.77", "", ""},
+		{model.ClickPrivacy("unknown"), "203.0.113.77", "", ""},
+	}
+	for _, tt := range tests {
+		ip, userAgent := tt.privacy.Anonymize(tt.ip, ua)
+		if ip != tt.wantIP || userAgent != tt.wantUA {
+			t.Errorf("%s of %q = %q %q, want %q %q", tt.privacy, tt.ip, ip, userAgent, tt.wantIP, tt.wantUA)
+		}
+	}
+}
+
+func TestHashedClickAddresses(t *testing.T) {
+	setClickPrivacy(t, model.ClickPrivacyFull)
+
+	hashed, userAgent := model.ClickPrivacyHashed.Anonymize("203.0.113.77", "Mozilla/5.0")
+	if !strings.HasPrefix(hashed, "hmac-sha256:") || userAgent != "Mozilla/5.0" {
+		t.Fatalf("hashed = %q %q", hashed, userAgent)
+	}
+	if again, _ := model.ClickPrivacyHashed.Anonymize("::ffff:203.0.113.77", ""); again != hashed {
+		t.Fatalf("same address hashed to %q and %q", hashed, again)
+	}
+	if other, _ := model.ClickPrivacyHashed.Anonymize("203.0.113.78", ""); other == hashed {
+		t.Fatal("different addresses hashed the same")
+	}
+	if rehashed, _ := model.ClickPrivacyHashed.Anonymize(hashed, ""); rehashed != hashed {
+		t.Fatalf("hash was hashed again: %q", rehashed)
+	}
+
+	// The key is neither the OTP key nor the same after CLICK_IP_HASH_KEY changes
+	if hashed == utils.HashOtp("click-ip|203.0.113.77") {
+		t.Fatal("addresses are hashed with the OTP key")
+	}
+	config.Config.PRIVACY.ClickIPHashKey = "rotated-key"
+	if rotated, _ := model.ClickPrivacyHashed.Anonymize("203.0.113.77", ""); rotated == hashed {
+		t.Fatal("changing CLICK_IP_HASH_KEY kept the hash")
+	}
+}
+
+func TestAnonymizeClicksIsIdempotent(t *testing.T) {
+	setClickPrivacy(t, model.ClickPrivacyFull)
+	ctx := context.Background()
+	store, _, url := newClickedUrl(t)
+
+	for _, ip := range []string{"203.0.113.77", "203.0.113.78", "2001:db8::1"} {
+		click := model.Analytics{UrlID: url.ID, IPAddress: ip, UserAgent: "Mozilla/5.0"}
+		if err := store.Analytics.Save(ctx, &click); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := time.Now().Add(time.Minute)
+
+	steps := []struct {
+		privacy model.ClickPrivacy
+		changed int64
+	}{
+		{model.ClickPrivacyFull, 0},
+		{model.ClickPrivacyTruncated, 3},
+		{model.ClickPrivacyTruncated, 0},
+		{model.ClickPrivacyHashed, 3},
+		{model.ClickPrivacyHashed, 0},
+		{model.ClickPrivacyTruncated, 0}, // Hashes can't be truncated
+		{model.ClickPrivacyNone, 3},
+		{model.ClickPrivacyNone, 0},
+	}
+	for _, step := range steps {
+		changed, err := model.AnonymizeClicks(ctx, store, step.privacy, later)
+		if err != nil || changed != step.changed {
+			t.Fatalf("anonymize %s: %d %v, want %d", step.privacy, changed, err, step.changed)
+		}
+	}
+}
+
+func TestAnonymizeAuditAndReports(t *testing.T) {
+	setClickPrivacy(t, model.ClickPrivacyTruncated)
+	ctx := context.Background()
+	store, user, url := newClickedUrl(t)
+
+	event := model.AuditEvent{UserID: user.ID, Action: model.AuditActionLogin, IPAddress: "203.0.113.77", UserAgent: "Mozilla/5.0", CreatedAt: time.Now().UTC()}
+	if err := store.Audits.Save(ctx, &event); err != nil {
+		t.Fatal(err)
+	}
+	resolved := model.AbuseReport{UrlID: url.ID, Reason: model.AbuseReasonSpam, ReporterIP: "198.51.100.7", Status: model.AbuseReportStatusOpen, CreatedAt: time.Now().UTC()}
+	if err := store.AbuseReports.Save(ctx, &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AbuseReports.Resolve(ctx, url.ID, model.AbuseReportStatusDismissed, user.ID, time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+	open := model.AbuseReport{UrlID: url.ID, Reason: model.AbuseReasonSpam, ReporterIP: "198.51.100.8", Status: model.AbuseReportStatusOpen, CreatedAt: time.Now().UTC()}
+	if err := store.AbuseReports.Save(ctx, &open); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+
+	// Without a mode CLICK_PRIVACY applies, open reports keep their address
+	changed, err := model.AnonymizeAuditAndReports(ctx, store, "", later)
+	if err != nil || changed != 2 {
+		t.Fatalf("anonymize: %d %v", changed, err)
+	}
+	if changed, err := model.AnonymizeAuditAndReports(ctx, store, "", later); err != nil || changed != 0 {
+		t.Fatalf("anonymize again: %d %v", changed, err)
+	}
+
+	events, err := store.Audits.Search(ctx, model.AuditFilter{UserID: user.ID, Pagination: model.Pagination{Limit: 10}})
+	if err != nil || len(events) != 1 || events[0].IPAddress != "203.0.113.0" || events[0].UserAgent != "Mozilla/5.0" {
+		t.Fatalf("audit events: %+v %v", events, err)
+	}
+	for id, want := range map[int64]string{resolved.ID: "198.51.100.0", open.ID: "198.51.100.8"} {
+		report, err := store.AbuseReports.GetByID(ctx, id)
+		if err != nil || report.ReporterIP != want {
+			t.Errorf("report %d: %q %v, want %q", id, report.ReporterIP, err, want)
+		}
+	}
+}
+
+func TestClickPrivacyCache(t *testing.T) {
+	setClickPrivacy(t, model.ClickPrivacyFull)
+	ctx := context.Background()
+	store, user, _ := newClickedUrl(t)
+	cache := model.NewClickPrivacyCache(store.Users)
+
+	if privacy := cache.Get(ctx, user.ID); privacy != model.ClickPrivacyFull {
+		t.Fatalf("default mode = %s", privacy)
+	}
+	if err := user.SetClickPrivacy(ctx, store.Users, model.ClickPrivacyNone); err != nil {
+		t.Fatal(err)
+	}
+	if privacy := cache.Get(ctx, user.ID); privacy != model.ClickPrivacyFull {
+		t.Fatalf("cached mode = %s, the user wasn't read again", privacy)
+	}
+	cache.Forget(user.ID)
+	if privacy := cache.Get(ctx, user.ID); privacy != model.ClickPrivacyNone {
+		t.Fatalf("mode after Forget = %s", privacy)
+	}
+}
@@ -0,0 +1,89 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+// UserIdentity links a user to the subject of an external identity provider
+type UserIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExternalIdentity is what an identity provider told us about the user
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// LoginWithIdentity returns the user linked to the identity. Unknown
+// identities are linked to the user with the same verified email, or a new
+// user is provisioned for them.
+func LoginWithIdentity(ctx context.Context, users UserStore, identities IdentityStore, external ExternalIdentity) (User, error) {
+	identity, identityErr := identities.GetByProviderSubject(ctx, external.Provider, external.Subject)
+	if identityErr == nil {
+		return users.GetByID(ctx, identity.UserID)
+	}
+	if !errors.Is(identityErr, ErrIdentityNotFound) {
+		return User{}, identityErr
+	}
+
+	// Only a verified email proves the identity belongs to the account owner
+	if external.Email == "" || !external.EmailVerified {
+		return User{}, utils.Forbidden("sso_email_unverified", "Your identity provider did not confirm a verified email address")
+	}
+
+	user, userErr := users.GetByEmail(ctx, external.Email)
+	if errors.Is(userErr, ErrUserNotFound) {
+		user, userErr = provisionUser(ctx, users, external.Email)
+	}
+	if userErr != nil {
+		return User{}, userErr
+	}
+
+	saveErr := identities.Save(ctx, &UserIdentity{
+		UserID:    user.ID,
+		Provider:  external.Provider,
+		Subject:   external.Subject,
+		Email:     strings.ToLower(external.Email),
+		CreatedAt: time.Now().UTC(),
+	})
+	if saveErr != nil {
+		return User{}, saveErr
+	}
+
+	utils.Log.Info("Linked ", external.Provider, " identity to user ", user.ID)
+	return user, nil
+}
+
+// provisionUser creates a user for an SSO login. The random password is never
+// shown, the user can set one through the password reset flow.
+func provisionUser(ctx context.Context, users UserStore, email string) (User, error) {
+	password, pwdErr := utils.GenerateOtpCode(32, magicLinkAlphabet)
+	if pwdErr != nil {
+		return User{}, utils.Internal(pwdErr)
+	}
+
+	user := User{Email: email, Password: password}
+	saveErr := user.Save(ctx, users)
+	if errors.Is(saveErr, ErrUserExists) { // Created by a concurrent login
+		return users.GetByEmail(ctx, email)
+	}
+	if saveErr != nil {
+		return User{}, saveErr
+	}
+
+	utils.Log.Info("Provisioned user ", user.ID, " from SSO login")
+	return user, nil
+}
@@ -0,0 +1,82 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func TestLoginWithIdentity(t *testing.T) {
+	tests := []struct {
+		name      string
+		external  model.ExternalIdentity
+		wantCode  string // Error code, empty when the login succeeds
+		wantEmail string
+	}{
+		{
+			name:      "linked identity",
+			external:  model.ExternalIdentity{Provider: "stub", Subject: "linked", Email: "other@example.com"},
+			wantEmail: "henry@example.com",
+		},
+		{
+			name:      "verified email of a user",
+			external:  model.ExternalIdentity{Provider: "stub", Subject: "new", Email: "henry@example.com", EmailVerified: true},
+			wantEmail: "henry@example.com",
+		},
+		{
+			name:      "verified email of nobody",
+			external:  model.ExternalIdentity{Provider: "stub", Subject: "new", Email: "ivy@example.com", EmailVerified: true},
+			wantEmail: "ivy@example.com",
+		},
+		{
+			name:     "unverified email of a user",
+			external: model.ExternalIdentity{Provider: "stub", Subject: "new", Email: "henry@example.com", EmailVerified: false},
+			wantCode: "sso_email_unverified",
+		},
+		{
+			name:     "no email",
+			external: model.ExternalIdentity{Provider: "stub", Subject: "new", EmailVerified: true},
+			wantCode: "sso_email_unverified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			users, identities := memory.NewUserStore(), memory.NewIdentityStore()
+
+			henry := model.User{Email: "henry@example.com", Password: "Passw0rd!"}
+			if err := henry.Save(ctx, users); err != nil {
+				t.Fatal(err)
+			}
+			if err := identities.Save(ctx, &model.UserIdentity{UserID: henry.ID, Provider: "stub", Subject: "linked"}); err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := model.LoginWithIdentity(ctx, users, identities, tt.external)
+			if tt.wantCode != "" {
+				if code := utils.AsAppError(err).Code; err == nil || code != tt.wantCode {
+					t.Fatalf("LoginWithIdentity() error = %v, want %s", err, tt.wantCode)
+				}
+				if _, identityErr := identities.GetByProviderSubject(ctx, tt.external.Provider, tt.external.Subject); identityErr == nil {
+					t.Fatal("refused identity was linked")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("LoginWithIdentity() error = %v", err)
+			}
+			if user.Email != tt.wantEmail {
+				t.Fatalf("logged in as %s, want %s", user.Email, tt.wantEmail)
+			}
+			identity, identityErr := identities.GetByProviderSubject(ctx, tt.external.Provider, tt.external.Subject)
+			if identityErr != nil || identity.UserID != user.ID {
+				t.Fatalf("identity linked to %d (%v), want %d", identity.UserID, identityErr, user.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package model
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// LiveClick is a click as streamed to the owner of the link. It carries
+// the rollup dimensions only, nothing that identifies the visitor.
+type LiveClick struct {
+	UrlID          int64     `json:"-"`
+	Code           string    `json:"code"`
+	At             time.Time `json:"at"`
+	Bot            bool      `json:"bot"`
+	BotRule        string    `json:"bot_rule,omitempty"`
+	ReferrerDomain string    `json:"referrer_domain,omitempty"`
+	Country        string    `json:"country,omitempty"`
+	Device         string    `json:"device"`
+}
+
+// ClickStream fans clicks out to live viewers. Any replica can publish a
+// click and every replica with a viewer of the link receives it.
+type ClickStream interface {
+	Publish(ctx context.Context, click LiveClick) error
+	// Subscribe calls deliver with every click on the link published from
+	// now on until unsubscribe is called. deliver must not block.
+	Subscribe(ctx context.Context, urlID int64, deliver func(LiveClick)) (unsubscribe func() error, err error)
+}
+
+// ErrLiveStreamsExceeded is returned when a user opens more than
+// LIVE_MAX_STREAMS streams at once
+var ErrLiveStreamsExceeded = utils.RateLimited("live_streams_exceeded", "Too many live streams open at once, close one first")
+
+// LiveViewers counts the open streams of each user on this instance
+type LiveViewers struct {
+	mu   sync.Mutex
+	open map[int64]int
+}
+
+func NewLiveViewers() *LiveViewers {
+	return &LiveViewers{open: map[int64]int{}}
+}
+
+// Acquire takes one of the user's LIVE_MAX_STREAMS streams, Release gives
+// it back
+func (v *LiveViewers) Acquire(userID int64) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if limit := config.Config.LIVE.MaxStreams; limit > 0 && v.open[userID] >= limit {
+		return ErrLiveStreamsExceeded
+	}
+	v.open[userID]++
+	return nil
+}
+
+func (v *LiveViewers) Release(userID int64) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.open[userID]--; v.open[userID] <= 0 {
+		delete(v.open, userID)
+	}
+}
+
+// WatchableUrl returns the link with the code if the user may watch its
+// clicks: they own it and their account isn't suspended. Links of other
+// users don't exist as far as the caller is concerned.
+func WatchableUrl(ctx context.Context, store *Store, code string, userID int64) (Url, error) {
+	url, urlErr := store.Urls.GetByCode(ctx, code)
+	if urlErr != nil {
+		return Url{}, urlErr
+	}
+	if url.UserID != userID {
+		return Url{}, ErrUrlNotFound
+	}
+
+	owner, ownerErr := store.Users.GetByID(ctx, userID)
+	if ownerErr != nil {
+		return Url{}, ownerErr
+	}
+	if err := owner.CheckActive(); err != nil {
+		return Url{}, err
+	}
+	return url, nil
+}
+
+// LiveSubscription buffers the clicks of one viewer. A viewer reading too
+// slowly loses clicks instead of holding up the stream, Dropped tells how
+// many.
+type LiveSubscription struct {
+	events      chan LiveClick
+	dropped     atomic.Int64
+	unsubscribe func() error
+}
+
+// WatchClicks subscribes to the clicks on the link, with a buffer of
+// LIVE_BUFFER clicks
+func WatchClicks(ctx context.Context, stream ClickStream, urlID int64) (*LiveSubscription, error) {
+	sub := &LiveSubscription{events: make(chan LiveClick, max(config.Config.LIVE.Buffer, 1))}
+
+	unsubscribe, err := stream.Subscribe(ctx, urlID, sub.deliver)
+	if err != nil {
+		return nil, err
+	}
+	sub.unsubscribe = unsubscribe
+	return sub, nil
+}
+
+func (s *LiveSubscription) deliver(click LiveClick) {
+	select {
+	case s.events <- click:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *LiveSubscription) Events() <-chan LiveClick {
+	return s.events
+}
+
+// Dropped returns how many clicks were dropped since it was last called
+func (s *LiveSubscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *LiveSubscription) Close() error {
+	return s.unsubscribe()
+}
+
+// publishClick tells live viewers of the link about the click. Viewers are
+// best effort, failures are only logged.
+func publishClick(ctx context.Context, stream ClickStream, code string, click *Analytics) {
+	live := LiveClick{
+		UrlID:          click.UrlID,
+		Code:           code,
+		At:             time.Now().UTC(),
+		Bot:            click.Bot,
+		BotRule:        click.BotRule,
+		ReferrerDomain: click.ReferrerDomain,
+		Country:        click.Country,
+		Device:         click.Device,
+	}
+	if err := stream.Publish(ctx, live); err != nil {
+		utils.Log.Error("Failed to publish live click: ", err)
+	}
+}
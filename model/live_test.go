@@ -0,0 +1,116 @@
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestClickStreamDeliversToViewersOfTheLink(t *testing.T) {
+	ctx := context.Background()
+	stream := memory.NewClickStream()
+
+	received := map[string]int{}
+	subscribe := func(name string, urlID int64) func() error {
+		t.Helper()
+		unsubscribe, err := stream.Subscribe(ctx, urlID, func(model.LiveClick) { received[name]++ })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return unsubscribe
+	}
+	first, second := subscribe("first", 1), subscribe("second", 1)
+	defer second()
+	defer subscribe("other link", 2)()
+
+	_ = stream.Publish(ctx, model.LiveClick{UrlID: 1})
+	if err := first(); err != nil {
+		t.Fatal(err)
+	}
+	_ = stream.Publish(ctx, model.LiveClick{UrlID: 1})
+
+	want := map[string]int{"first": 1, "second": 2}
+	for name, count := range want {
+		if received[name] != count {
+			t.Errorf("%s viewer got %d clicks, want %d", name, received[name], count)
+		}
+	}
+	if received["other link"] != 0 {
+		t.Errorf("viewer of another link got %d clicks", received["other link"])
+	}
+}
+
+func TestLiveSubscriptionDropsClicksOfSlowViewers(t *testing.T) {
+	ctx := context.Background()
+	old := config.Config.LIVE.Buffer
+	t.Cleanup(func() { config.Config.LIVE.Buffer = old })
+	config.Config.LIVE.Buffer = 2
+
+	stream := memory.NewClickStream()
+	sub, err := model.WatchClicks(ctx, stream, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	for i := 0; i < 5; i++ {
+		_ = stream.Publish(ctx, model.LiveClick{UrlID: 1, Code: "slow"})
+	}
+	if len(sub.Events()) != 2 {
+		t.Errorf("%d clicks buffered, want 2", len(sub.Events()))
+	}
+	if dropped := sub.Dropped(); dropped != 3 {
+		t.Errorf("%d clicks dropped, want 3", dropped)
+	}
+	// Dropped counts from the last call
+	if dropped := sub.Dropped(); dropped != 0 {
+		t.Errorf("%d clicks dropped again", dropped)
+	}
+}
+
+func TestLiveViewersPerUser(t *testing.T) {
+	old := config.Config.LIVE.MaxStreams
+	t.Cleanup(func() { config.Config.LIVE.MaxStreams = old })
+	config.Config.LIVE.MaxStreams = 2
+
+	viewers := model.NewLiveViewers()
+	for i := 0; i < 2; i++ {
+		if err := viewers.Acquire(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := viewers.Acquire(1); !errors.Is(err, model.ErrLiveStreamsExceeded) {
+		t.Fatalf("third stream: %v", err)
+	}
+	if err := viewers.Acquire(2); err != nil {
+		t.Fatalf("other user: %v", err)
+	}
+
+	viewers.Release(1)
+	if err := viewers.Acquire(1); err != nil {
+		t.Fatalf("stream after one was closed: %v", err)
+	}
+}
+
+func TestWatchableUrl(t *testing.T) {
+	ctx := context.Background()
+	store, owner, url := newClickedUrl(t)
+
+	if _, err := model.WatchableUrl(ctx, store, url.Code, owner.ID); err != nil {
+		t.Fatalf("owner: %v", err)
+	}
+	if _, err := model.WatchableUrl(ctx, store, url.Code, owner.ID+1); !errors.Is(err, model.ErrUrlNotFound) {
+		t.Fatalf("other user: %v, want not found", err)
+	}
+
+	if err := store.Users.UpdateStatus(ctx, owner.ID, model.UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.WatchableUrl(ctx, store, url.Code, owner.ID); !errors.Is(err, model.ErrUserSuspended) {
+		t.Fatalf("suspended owner: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	magicLinkCodeLength = 32
+	magicLinkAlphabet   = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var errMagicLinkInvalid = utils.BadRequest("magic_link_invalid", "Sign-in link is invalid or was already used")
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// DeviceFingerprint identifies the browser a magic link was requested from.
+// It is keyed so the stored value reveals nothing about the device.
+func DeviceFingerprint(userAgent, acceptLanguage string) string {
+	return utils.HashOtp("device|" + userAgent + "|" + acceptLanguage)
+}
+
+// MagicLinkToken is the path segment of the emailed link, "<otp token>.<code>"
+func (otp *Otp) MagicLinkToken() string {
+	return otp.Token + "." + otp.OtpCode
+}
+
+// LoginWithMagicLink exchanges a magic link for its user. The link must be
+// followed on the device that requested it, before it expires, and only once.
+// Locked out accounts and IPs are refused before the link is used up.
+func LoginWithMagicLink(ctx context.Context, otps OtpStore, users UserStore, guard *LoginGuard, ip string, linkToken string, fingerprint string) (User, error) {
+	token, code, ok := strings.Cut(linkToken, ".")
+	if !ok || !uuidRegex.MatchString(token) {
+		return User{}, errMagicLinkInvalid
+	}
+
+	otp, otpErr := otps.GetPendingByToken(ctx, token, string(OtpActionTypeMagicLogin))
+	if otpErr != nil {
+		if errors.Is(otpErr, ErrOtpNotFound) {
+			return User{}, errMagicLinkInvalid
+		}
+		return User{}, otpErr
+	}
+
+	if !utils.CompareOtp(otp.OtpHash, code) {
+		return User{}, errMagicLinkInvalid
+	}
+
+	if time.Since(otp.CreatedAt) > time.Minute*time.Duration(config.Config.OTP.MagicLinkExpiryMinutes) {
+		updateErr := otp.UpdateStatus(ctx, otps, OtpStatusExpire)
+		if updateErr != nil {
+			utils.Log.Error("Error expiring magic link: ", updateErr)
+		}
+		return User{}, utils.Gone("magic_link_expired", "Sign-in link has expired. Please request a new one.")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(otp.Fingerprint), []byte(fingerprint)) != 1 {
+		return User{}, utils.Forbidden("magic_link_device_mismatch", "Open the sign-in link on the device you requested it from")
+	}
+
+	guardErr := guard.Check(ctx, otp.Key, ip)
+	if guardErr != nil {
+		return User{}, guardErr
+	}
+
+	consumed, consumeErr := otps.Consume(ctx, otp.ID)
+	if consumeErr != nil {
+		return User{}, consumeErr
+	}
+	if !consumed {
+		return User{}, errMagicLinkInvalid
+	}
+
+	return users.GetByEmail(ctx, otp.Key)
+}
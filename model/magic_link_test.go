@@ -0,0 +1,47 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func TestLoginWithMagicLinkChecksLockoutFirst(t *testing.T) {
+	ctx := context.Background()
+	config.Config.OTP.MagicLinkExpiryMinutes = 10
+	config.Config.LOGIN.IPMaxAttempts = 1
+	config.Config.LOGIN.MaxAttempts = 100
+	config.Config.LOGIN.Window = time.Minute
+	config.Config.LOGIN.Lockout = time.Minute
+
+	users, otps := memory.NewUserStore(), memory.NewOtpStore()
+	guard := model.NewLoginGuard(memory.NewCounterStore())
+	if err := users.Save(ctx, &model.User{Email: "dave@example.com", Status: model.UserStatusActive}); err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint := model.DeviceFingerprint("Mozilla/5.0", "en")
+	otp := model.Otp{Key: "dave@example.com", Type: model.OtpTypeEmail, Action: model.OtpActionTypeMagicLogin, Fingerprint: fingerprint}
+	if err := otp.Generate(ctx, otps, users); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := guard.RecordFailure(ctx, "someone@example.com", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	_, loginErr := model.LoginWithMagicLink(ctx, otps, users, guard, "10.0.0.1", otp.MagicLinkToken(), fingerprint)
+	if code := utils.AsAppError(loginErr).Code; code != "login_locked" {
+		t.Fatalf("login from a locked IP: %v", loginErr)
+	}
+
+	// The refused login didn't use the link up
+	user, loginErr := model.LoginWithMagicLink(ctx, otps, users, guard, "10.0.0.2", otp.MagicLinkToken(), fingerprint)
+	if loginErr != nil || user.Email != "dave@example.com" {
+		t.Fatalf("login from another IP: %v %v", user.Email, loginErr)
+	}
+}
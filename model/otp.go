@@ -1,14 +1,15 @@
 package model
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"strings"
 	"time"
 
 	"kgoel085.com/url-shortner/config"
-	"kgoel085.com/url-shortner/db"
 	"kgoel085.com/url-shortner/utils"
+	"kgoel085.com/url-shortner/validator"
 )
 
 type OtpType string
@@ -25,6 +26,9 @@ const (
 	OtpActionTypeLogin         OtpActionType = "login"
 	OtpActionTypeSignUp        OtpActionType = "signup"
 	OtpActionTypeResetPassword OtpActionType = "reset_password"
+	OtpActionTypeMagicLogin    OtpActionType = "magic_login"
+	OtpActionTypeChangeEmail   OtpActionType = "change_email"
+	OtpActionTypeDeleteAccount OtpActionType = "delete_account"
 )
 
 const (
@@ -37,22 +41,31 @@ type Otp struct {
 	Key       string        `json:"key" binding:"required"`
 	Type      OtpType       `json:"type" binding:"required"`
 	Action    OtpActionType `json:"action" binding:"required"`
-	OtpCode   string        `json:"otp"`
+	OtpCode   string        `json:"otp"` // Plain code, only set right after generation
+	OtpHash   string        `json:"-"`   // What gets stored, see utils.HashOtp
 	Token     string        `json:"token"`
 	CreatedAt time.Time     `json:"created_at"`
 	Status    OtpStatus     `json:"status"`
+	Attempts  int           `json:"-"`
+	// Fingerprint binds magic links to the device that requested them, see DeviceFingerprint
+	Fingerprint string `json:"-"`
+
+	undeliverable bool // Sent to an email without account, see Generate
 }
 
+var errOtpInvalid = utils.BadRequest("otp_invalid", "Invalid OTP token")
+
 type SendOtp struct {
 	Type   OtpType       `json:"type" binding:"required"`
 	Action OtpActionType `json:"action" binding:"required"`
-	Key    string        `json:"key" binding:"required,email"`
+	Key    string        `json:"key" binding:"required" example:"user@example.com"` // Email, or E.164 phone number for phone OTPs
 }
 
 type VerifyOtp struct {
 	Token  string `json:"token" binding:"required"`
 	Otp    string `json:"otp" binding:"required"`
 	Action string `json:"action" binding:"required"`
+	Key    string `json:"-"` // When set, the OTP must have been sent to this key
 }
 
 type SendOTPResponse struct {
@@ -60,57 +73,118 @@ type SendOTPResponse struct {
 	Token string `json:"token"`
 }
 
-func (otpVerify *VerifyOtp) Verify() error {
-	return otpVerify.verifyInternal(false)
+// OtpSender delivers a freshly generated OTP to its key
+type OtpSender interface {
+	Send(ctx context.Context, otp Otp) error
 }
 
-func (otpVerify *VerifyOtp) VerifyWithUpdate() error {
-	return otpVerify.verifyInternal(true)
+// OtpSenders picks the sender by OTP type
+type OtpSenders map[OtpType]OtpSender
+
+// Supports reports whether OTPs of the type can be delivered
+func (s OtpSenders) Supports(otpType OtpType) bool {
+	_, ok := s[otpType]
+	return ok
 }
 
-func (otpVerify *VerifyOtp) verifyInternal(performUpdate bool) error {
-	var otp Otp
-	row := db.DB.QueryRow("SELECT id, otp, status, created_at FROM otp WHERE token = $1 AND action = $2 AND status = $3", otpVerify.Token, otpVerify.Action, OtpStatusPending)
+func (s OtpSenders) Send(ctx context.Context, otp Otp) error {
+	sender, ok := s[otp.Type]
+	if !ok {
+		return fmt.Errorf("no OTP sender for type %s", otp.Type)
+	}
+	return sender.Send(ctx, otp)
+}
 
-	scanErr := row.Scan(&otp.ID, &otp.OtpCode, &otp.Status, &otp.CreatedAt)
-	if scanErr != nil {
-		if scanErr == sql.ErrNoRows {
-			return fmt.Errorf("Invalid OTP details !")
+// ValidateKey checks the key matches the OTP type, normalising phone numbers to E.164
+func (otpRequest *SendOtp) ValidateKey() error {
+	switch otpRequest.Type {
+	case OtpTypeEmail:
+		if !validator.IsEmail(otpRequest.Key) {
+			return utils.BadRequest("otp_key_invalid", "Invalid email address")
 		}
-		return scanErr
+	case OtpTypePhone:
+		phone := validator.NormalizePhone(otpRequest.Key)
+		if !validator.IsE164(phone) {
+			return utils.BadRequest("otp_key_invalid", "Invalid phone number, expected E.164 format e.g. +14155550123")
+		}
+		otpRequest.Key = phone
+	}
+	return nil
+}
+
+func (otpVerify *VerifyOtp) Verify(ctx context.Context, otps OtpStore) error {
+	return otpVerify.verifyInternal(ctx, otps, false)
+}
+
+func (otpVerify *VerifyOtp) VerifyWithUpdate(ctx context.Context, otps OtpStore) error {
+	return otpVerify.verifyInternal(ctx, otps, true)
+}
+
+func (otpVerify *VerifyOtp) verifyInternal(ctx context.Context, otps OtpStore, performUpdate bool) error {
+	otp, otpErr := otps.GetPendingByToken(ctx, otpVerify.Token, otpVerify.Action)
+	if otpErr != nil {
+		return otpErr
+	}
+
+	if otp.ID == 0 || (otpVerify.Key != "" && !strings.EqualFold(otp.Key, otpVerify.Key)) {
+		return errOtpInvalid
 	}
 
-	if otp.ID == 0 {
-		return fmt.Errorf("Invalid OTP token")
+	// Every check takes an attempt before the code is compared, so parallel
+	// requests can't try more codes than OTP_MAX_ATTEMPTS
+	maxAttempts := config.Config.OTP.MaxAttempts
+	attempts, attemptsErr := otps.ReserveAttempt(ctx, otp.ID, maxAttempts)
+	if attemptsErr != nil {
+		return attemptsErr
+	}
+	if attempts == 0 {
+		if otp.Attempts >= maxAttempts {
+			return otp.invalidate(ctx, otps)
+		}
+		return errOtpInvalid // Used or expired since it was read
 	}
 
-	if otp.OtpCode != otpVerify.Otp {
-		return fmt.Errorf("Invalid OTP code")
+	if !utils.CompareOtp(otp.OtpHash, otpVerify.Otp) {
+		if attempts >= maxAttempts {
+			return otp.invalidate(ctx, otps)
+		}
+		return utils.BadRequest("otp_code_invalid", "Invalid OTP code")
 	}
 
 	if time.Since(otp.CreatedAt) > time.Minute*time.Duration(config.Config.OTP.ExpiryMinutes) {
 		// Expire the OTP
-		_, updateErr := db.DB.Exec("UPDATE otp SET status=$1 WHERE id=$2", OtpStatusExpire, otp.ID)
+		updateErr := otp.UpdateStatus(ctx, otps, OtpStatusExpire)
 		if updateErr != nil {
 			utils.Log.Error("Error expiring OTP: ", updateErr)
 		}
-		return fmt.Errorf("OTP has expired. Please request a new one.")
+		return utils.Gone("otp_expired", "OTP has expired. Please request a new one.")
 	}
 
-	if performUpdate { // Mark OTP as success only if performUpdate is true
-		updateErr := otp.UpdateStatus(OtpStatusSuccess)
-		if updateErr != nil {
-			return updateErr
+	if performUpdate { // Use the OTP up only if performUpdate is true, it works once
+		consumed, consumeErr := otps.Consume(ctx, otp.ID)
+		if consumeErr != nil {
+			return consumeErr
 		}
+		if !consumed {
+			return errOtpInvalid
+		}
+		otp.Status = OtpStatusSuccess
 	}
 
 	return nil
 }
 
-func (otp *Otp) UpdateStatus(status OtpStatus) error {
-	// Mark OTP as success
-	utils.Log.Info("Updating OTP status to ", status, "UPDATE otp SET status=$1 WHERE id=$2")
-	_, updateErr := db.DB.Exec("UPDATE otp SET status=$1 WHERE id=$2", status, otp.ID)
+// invalidate expires an OTP that has seen too many wrong codes
+func (otp *Otp) invalidate(ctx context.Context, otps OtpStore) error {
+	updateErr := otp.UpdateStatus(ctx, otps, OtpStatusExpire)
+	if updateErr != nil {
+		utils.Log.Error("Error expiring OTP: ", updateErr)
+	}
+	return utils.Gone("otp_attempts_exceeded", "Too many wrong codes. Please request a new OTP.")
+}
+
+func (otp *Otp) UpdateStatus(ctx context.Context, otps OtpStore, status OtpStatus) error {
+	updateErr := otps.UpdateStatus(ctx, otp.ID, status)
 	if updateErr != nil {
 		return updateErr
 	}
@@ -119,81 +193,80 @@ func (otp *Otp) UpdateStatus(status OtpStatus) error {
 	return nil
 }
 
-func (otp *Otp) Generate() error {
+// Deliverable reports whether the generated OTP should be sent to its key
+func (otp *Otp) Deliverable() bool {
+	return !otp.undeliverable
+}
+
+func (otp *Otp) Generate(ctx context.Context, otps OtpStore, users UserStore) error {
 	// OTP Type checks
 	switch {
-	case otp.Action == OtpActionTypeLogin && otp.Type == OtpTypeEmail:
+	case (otp.Action == OtpActionTypeLogin || otp.Action == OtpActionTypeMagicLogin) && otp.Type == OtpTypePhone:
+		return utils.BadRequest("otp_type_unsupported", "Phone OTPs can't be used to log in")
+	case (otp.Action == OtpActionTypeChangeEmail || otp.Action == OtpActionTypeDeleteAccount) && otp.Type != OtpTypeEmail:
+		return utils.BadRequest("otp_type_unsupported", "Account changes are verified with an email OTP")
+	case (otp.Action == OtpActionTypeLogin || otp.Action == OtpActionTypeMagicLogin || otp.Action == OtpActionTypeDeleteAccount) && otp.Type == OtpTypeEmail:
 		{
-			{
-				userEmail, userEmailErr := GetUserByEmail(otp.Key)
-				if userEmailErr != nil {
-					return userEmailErr
-				}
-				if userEmail.ID == 0 {
-					return fmt.Errorf("No user found with email %s", otp.Key)
-				}
+			// OTPs for unknown emails are answered like any other but never
+			// delivered, so requesting one doesn't tell which accounts exist
+			userEmail, userEmailErr := users.GetByEmail(ctx, otp.Key)
+			if userEmailErr != nil && !errors.Is(userEmailErr, ErrUserNotFound) {
+				return userEmailErr
 			}
+			otp.undeliverable = userEmail.ID == 0
 		}
 	}
 
-	otp.generateOtp()
+	generateErr := otp.generateOtp()
+	if generateErr != nil {
+		return utils.Internal(generateErr)
+	}
 
 	// Check if any other OTP exists with same action and type recently
-	checkErr := otp.checkExistingOtp()
+	checkErr := otp.checkExistingOtp(ctx, otps)
 	if checkErr != nil {
 		return checkErr
 	}
 
-	insertQuery := `INSERT INTO otp (key, type, action, otp, created_at, status) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, token`
-	logStr := fmt.Sprintf("Insert OTP in DB : %s, Key: %s, Type: %s, Action: %s, Timestamp: %s", insertQuery, otp.Key, otp.Type, otp.Action, time.Now().UTC())
-	utils.Log.Info(logStr)
+	otp.Status = OtpStatusPending
+	return otps.Save(ctx, otp)
+}
 
-	rowErr := db.DB.QueryRow(insertQuery, otp.Key, otp.Type, otp.Action, otp.OtpCode, otp.CreatedAt, OtpStatusPending).Scan(&otp.ID, &otp.Token)
-	if rowErr != nil {
-		return rowErr
+func (otp *Otp) checkExistingOtp(ctx context.Context, otps OtpStore) error {
+	existingOtp, existingErr := otps.GetLatestPending(ctx, otp.Key, otp.Type, otp.Action)
+	if existingErr != nil {
+		if errors.Is(existingErr, ErrOtpNotFound) {
+			return nil
+		}
+		return existingErr
 	}
 
-	return nil
+	// If OTP was sent within last specified minute, do not send another one
+	if time.Since(existingOtp.CreatedAt) < time.Minute*time.Duration(config.Config.OTP.ExpiryMinutes) {
+		errStr := fmt.Sprintf("OTP already sent recently at %s. Please wait before requesting a new one.", existingOtp.CreatedAt.Format(config.TIME_FORMAT))
+		return utils.RateLimited("otp_recently_sent", errStr)
+	}
 
+	// Expire the previous OTP
+	return existingOtp.UpdateStatus(ctx, otps, OtpStatusExpire)
 }
 
-func (otp *Otp) checkExistingOtp() error {
-	row, rowErr := db.DB.Query("SELECT id, otp, created_at FROM otp WHERE key = $1 AND type=$2 AND action=$3 AND status = $4 ORDER BY created_at DESC", otp.Key, otp.Type, otp.Action, OtpStatusPending)
-	if rowErr != nil {
-		return rowErr
+func (otp *Otp) generateOtp() error {
+	length, alphabet := config.Config.OTP.Length, config.Config.OTP.Alphabet
+	if otp.Action == OtpActionTypeMagicLogin { // Links are never typed, so they can be long
+		length, alphabet = magicLinkCodeLength, magicLinkAlphabet
 	}
-	defer row.Close()
 
-	if row.Next() {
-		existingOtp := Otp{}
-		scanErr := row.Scan(&existingOtp.ID, &existingOtp.OtpCode, &existingOtp.CreatedAt)
-		if scanErr != nil {
-			return scanErr
-		}
-		// If OTP was sent within last specified minute, do not send another one
-		if time.Since(existingOtp.CreatedAt) < time.Minute*time.Duration(config.Config.OTP.ExpiryMinutes) {
-			errStr := fmt.Sprintf("OTP already sent recently at %s. Please wait before requesting a new one.", existingOtp.CreatedAt.Format(config.TIME_FORMAT))
-			return fmt.Errorf("%s", errStr)
-		} else {
-			// Expire the previous OTP
-			_, updateErr := db.DB.Exec("UPDATE otp SET status=$1 WHERE id=$2", OtpStatusExpire, existingOtp.ID)
-			if updateErr != nil {
-				return updateErr
-			}
-		}
+	newOtp, err := utils.GenerateOtpCode(length, alphabet)
+	if err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func (otp *Otp) generateOtp() string {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	newOtp := fmt.Sprintf("%06d", r.Intn(1000000))
-
 	otp.OtpCode = newOtp
+	otp.OtpHash = utils.HashOtp(newOtp)
 	otp.CreatedAt = time.Now().UTC()
 
-	return newOtp
+	return nil
 }
 
 func (ot OtpType) IsValid() bool {
@@ -206,7 +279,7 @@ func (ot OtpType) IsValid() bool {
 
 func (ot OtpActionType) IsValid() bool {
 	switch ot {
-	case OtpActionTypeLogin, OtpActionTypeSignUp, OtpActionTypeResetPassword:
+	case OtpActionTypeLogin, OtpActionTypeSignUp, OtpActionTypeResetPassword, OtpActionTypeMagicLogin, OtpActionTypeChangeEmail, OtpActionTypeDeleteAccount:
 		return true
 	}
 	return false
@@ -0,0 +1,104 @@
+package model_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func init() {
+	utils.InitLogger()
+	config.Config.OTP.Secret = "test-secret"
+	config.Config.OTP.MaxAttempts = 3
+	config.Config.OTP.ExpiryMinutes = 5
+}
+
+func saveOtp(t *testing.T, otps model.OtpStore, code string) model.Otp {
+	t.Helper()
+
+	otp := model.Otp{
+		Key:       "user@example.com",
+		Type:      model.OtpTypeEmail,
+		Action:    model.OtpActionTypeLogin,
+		OtpHash:   utils.HashOtp(code),
+		CreatedAt: time.Now().UTC(),
+		Status:    model.OtpStatusPending,
+	}
+	if err := otps.Save(context.Background(), &otp); err != nil {
+		t.Fatal(err)
+	}
+	return otp
+}
+
+// verifyInParallel checks every code at once and returns the error codes
+func verifyInParallel(otps model.OtpStore, token string, codes []string, update bool) []string {
+	results := make([]string, len(codes))
+
+	var wg sync.WaitGroup
+	for i, code := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			verify := model.VerifyOtp{Token: token, Otp: code, Action: string(model.OtpActionTypeLogin)}
+			verifyFn := verify.Verify
+			if update {
+				verifyFn = verify.VerifyWithUpdate
+			}
+			if err := verifyFn(context.Background(), otps); err != nil {
+				results[i] = utils.AsAppError(err).Code
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func TestVerifyOtpReservesAttempts(t *testing.T) {
+	otps := memory.NewOtpStore()
+	otp := saveOtp(t, otps, "123456")
+
+	codes := make([]string, 20)
+	for i := range codes {
+		codes[i] = "000000"
+	}
+
+	var compared int
+	for _, code := range verifyInParallel(otps, otp.Token, codes, false) {
+		if code == "otp_code_invalid" {
+			compared++
+		}
+	}
+	// The last attempt invalidates the OTP rather than answering otp_code_invalid
+	if compared != config.Config.OTP.MaxAttempts-1 {
+		t.Fatalf("%d wrong codes compared, want %d", compared, config.Config.OTP.MaxAttempts-1)
+	}
+
+	// Not even the right code works afterwards
+	verify := model.VerifyOtp{Token: otp.Token, Otp: "123456", Action: string(model.OtpActionTypeLogin)}
+	if err := verify.VerifyWithUpdate(context.Background(), otps); err == nil {
+		t.Fatal("right code accepted after the attempts were used up")
+	}
+}
+
+func TestVerifyOtpWithUpdateWorksOnce(t *testing.T) {
+	otps := memory.NewOtpStore()
+	otp := saveOtp(t, otps, "123456")
+
+	results := verifyInParallel(otps, otp.Token, []string{"123456", "123456", "123456"}, true)
+
+	var accepted int
+	for _, code := range results {
+		if code == "" {
+			accepted++
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("OTP accepted %d times, want once: %v", accepted, results)
+	}
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+// PageMetadata describes a destination page, read from its HTML head
+type PageMetadata struct {
+	Title       string
+	Description string
+	Favicon     string
+}
+
+// MetadataFetcher reads the metadata of destination pages for previews
+type MetadataFetcher interface {
+	Fetch(ctx context.Context, rawUrl string) (PageMetadata, error)
+}
+
+// UrlPreview tells visitors where a short link goes before they follow it
+type UrlPreview struct {
+	Code            string    `json:"code"`
+	ShortUrl        string    `json:"short_url"`
+	Url             string    `json:"url"`
+	Title           string    `json:"title,omitempty"`
+	Description     string    `json:"description,omitempty"`      // Chosen by the owner
+	PageDescription string    `json:"page_description,omitempty"` // From the destination page
+	Favicon         string    `json:"favicon,omitempty"`
+	Status          UrlStatus `json:"status"`
+	SafetyReason    string    `json:"safety_reason,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Preview describes the link with the metadata of its destination.
+// Quarantined destinations aren't requested. Failing to fetch metadata
+// still returns a preview, just without it.
+func (u *Url) Preview(ctx context.Context, fetcher MetadataFetcher) UrlPreview {
+	preview := UrlPreview{
+		Code:         u.Code,
+		ShortUrl:     utils.GetShortUrl(u.Code),
+		Url:          u.Url,
+		Description:  u.Description,
+		Status:       u.Status,
+		SafetyReason: u.SafetyReason,
+		CreatedAt:    u.CreatedAt,
+	}
+	if u.Status == UrlStatusQuarantined {
+		return preview
+	}
+
+	metadata, err := fetcher.Fetch(ctx, u.Url)
+	if err != nil {
+		utils.Log.Warn("Failed to fetch preview metadata of ", u.Code, ": ", err)
+		return preview
+	}
+
+	preview.Title = metadata.Title
+	preview.PageDescription = metadata.Description
+	preview.Favicon = metadata.Favicon
+	return preview
+}
+
+// SocialCard is the Open Graph card of a link, served to chat apps and
+// social networks unfurling it
+type SocialCard struct {
+	ShortUrl    string
+	Title       string
+	Description string
+	Image       string
+}
+
+// SocialCard uses the owner's Open Graph tags and fills the missing title
+// and description from the destination page. Quarantined links get a
+// warning instead, whatever the owner chose.
+func (u *Url) SocialCard(ctx context.Context, fetcher MetadataFetcher) SocialCard {
+	card := SocialCard{
+		ShortUrl:    utils.GetShortUrl(u.Code),
+		Title:       u.OgTitle,
+		Description: utils.FirstNonEmpty(u.OgDescription, u.Description),
+		Image:       u.OgImage,
+	}
+	if u.Status == UrlStatusQuarantined {
+		return SocialCard{
+			ShortUrl:    card.ShortUrl,
+			Title:       "Warning: this link may be unsafe",
+			Description: "The destination of this short link was flagged by our safety checks.",
+		}
+	}
+	if card.Title != "" && card.Description != "" {
+		return card
+	}
+
+	preview := u.Preview(ctx, fetcher)
+	card.Title = utils.FirstNonEmpty(card.Title, preview.Title, card.ShortUrl)
+	card.Description = utils.FirstNonEmpty(card.Description, preview.PageDescription)
+	return card
+}
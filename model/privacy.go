@@ -0,0 +1,329 @@
+package model
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const exportTokenLength = 32
+
+var exportTokenRegex = regexp.MustCompile(`^[A-Za-z0-9]{32}$`)
+
+var errExportNotFound = utils.NotFound("export_not_found", "Download link is invalid")
+
+// UserTombstone remembers the email of a deleted user, as a keyed hash, so
+// it can't sign up again right away
+type UserTombstone struct {
+	EmailHash string
+	DeletedAt time.Time
+}
+
+// DeleteAccount takes an OTP sent to the account email with action delete_account
+type DeleteAccount struct {
+	UserOtp
+}
+
+// UserExportProfile is profile.json in the export archive
+type UserExportProfile struct {
+	User
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	ExportedAt       time.Time `json:"exported_at"`
+}
+
+// ExportClick is a click in analytics.json of the export archive. Like the
+// rollups it leaves out what identifies the visitor.
+type ExportClick struct {
+	UrlID          int64  `json:"url_id"`
+	CreatedAt      string `json:"created_at"`
+	ReferrerDomain string `json:"referrer_domain,omitempty"`
+	Country        string `json:"country,omitempty"`
+	Device         string `json:"device"`
+	Bot            bool   `json:"bot"`
+}
+
+// TombstoneHash is the keyed hash deleted emails are remembered by
+func TombstoneHash(email string) string {
+	return utils.HashOtp("tombstone|" + strings.ToLower(strings.TrimSpace(email)))
+}
+
+// checkEmailReusable rejects emails of accounts deleted within DELETED_EMAIL_COOLDOWN
+func checkEmailReusable(ctx context.Context, users UserStore, email string) error {
+	tombstone, err := users.GetTombstone(ctx, TombstoneHash(email))
+	if errors.Is(err, ErrTombstoneNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if time.Since(tombstone.DeletedAt) < config.Config.PRIVACY.EmailReuseCooldown {
+		return utils.Conflict("email_recently_deleted", "An account with this email was deleted recently. Please try again later.")
+	}
+	return nil
+}
+
+// DeleteAccount erases the user and everything they own after checking an
+// OTP sent to their email
+func (u *User) DeleteAccount(ctx context.Context, store *Store, request DeleteAccount) error {
+	otpVerify := VerifyOtp{
+		Token:  request.OtpToken,
+		Otp:    request.OtpCode,
+		Action: string(OtpActionTypeDeleteAccount),
+		Key:    u.Email,
+	}
+	// Consume the OTP first, deleting the user removes it as well
+	otpErr := otpVerify.VerifyWithUpdate(ctx, store.Otps)
+	if otpErr != nil {
+		return otpErr
+	}
+
+	// The visitor counts live outside the database, the links are gone
+	// once the user is deleted
+	urls, urlsErr := store.Urls.ListByUser(ctx, u.ID, GetUrlByUserFilter{})
+	if urlsErr != nil {
+		return urlsErr
+	}
+
+	tombstone := UserTombstone{EmailHash: TombstoneHash(u.Email), DeletedAt: time.Now().UTC()}
+	if deleteErr := store.Users.Delete(ctx, *u, tombstone); deleteErr != nil {
+		return deleteErr
+	}
+
+	forgetVisitors(ctx, store.Visitors, urls)
+	removeExports(u.ID)
+	return nil
+}
+
+// RequestExport allows one export per user every EXPORT_COOLDOWN
+func (u *User) RequestExport(ctx context.Context, counters CounterStore) error {
+	key := "export:" + strconv.FormatInt(u.ID, 10)
+	count, incrErr := counters.Incr(ctx, key, config.Config.PRIVACY.ExportCooldown)
+	if incrErr != nil {
+		return incrErr
+	}
+	if count == 1 {
+		return nil
+	}
+
+	ttl, ttlErr := counters.TTL(ctx, key)
+	if ttlErr != nil {
+		return ttlErr
+	}
+	return utils.RateLimited("export_recently_requested", "An export was requested recently. Please check your email.").WithRetryAfter(ttl)
+}
+
+// Export writes the user's profile, links and clicks to a ZIP archive in
+// EXPORT_DIR and returns the token to download it with
+func (u *User) Export(ctx context.Context, store *Store) (string, error) {
+	hasTotp, totpErr := u.HasTotp(ctx, store.Totps)
+	if totpErr != nil {
+		return "", totpErr
+	}
+
+	urls, urlsErr := store.Urls.ListByUser(ctx, u.ID, GetUrlByUserFilter{})
+	if urlsErr != nil {
+		return "", urlsErr
+	}
+
+	clicks, clicksErr := store.Analytics.ListByUser(ctx, u.ID)
+	if clicksErr != nil {
+		return "", clicksErr
+	}
+
+	exportClicks := make([]ExportClick, 0, len(clicks))
+	for _, click := range clicks {
+		exportClicks = append(exportClicks, ExportClick{
+			UrlID:          click.UrlID,
+			CreatedAt:      click.CreatedAt,
+			ReferrerDomain: click.ReferrerDomain,
+			Country:        click.Country,
+			Device:         click.Device,
+			Bot:            click.Bot,
+		})
+	}
+
+	files := map[string]any{
+		"profile.json":   UserExportProfile{User: *u, TwoFactorEnabled: hasTotp, ExportedAt: time.Now().UTC()},
+		"links.json":     nonNil(urls),
+		"analytics.json": exportClicks,
+	}
+
+	token, tokenErr := utils.GenerateOtpCode(exportTokenLength, magicLinkAlphabet)
+	if tokenErr != nil {
+		return "", utils.Internal(tokenErr)
+	}
+
+	writeErr := writeExportArchive(exportPath(u.ID, token), files)
+	if writeErr != nil {
+		return "", utils.Internal(fmt.Errorf("Error while trying to write export - %w !", writeErr))
+	}
+
+	// The account may have been deleted while the archive was written
+	if _, userErr := store.Users.GetByID(ctx, u.ID); errors.Is(userErr, ErrUserNotFound) {
+		removeExports(u.ID)
+		return "", userErr
+	}
+
+	return token, nil
+}
+
+// OpenExport opens the archive of a download link that has not expired
+func OpenExport(token string) (*os.File, error) {
+	if !exportTokenRegex.MatchString(token) {
+		return nil, errExportNotFound
+	}
+
+	paths, globErr := filepath.Glob(filepath.Join(config.Config.PRIVACY.ExportDir, "*-"+exportTokenHash(token)+".zip"))
+	if globErr != nil {
+		return nil, utils.Internal(globErr)
+	}
+	if len(paths) != 1 {
+		return nil, errExportNotFound
+	}
+
+	path := paths[0]
+	info, statErr := os.Stat(path)
+	if os.IsNotExist(statErr) {
+		return nil, errExportNotFound
+	}
+	if statErr != nil {
+		return nil, utils.Internal(statErr)
+	}
+
+	if time.Since(info.ModTime()) > config.Config.PRIVACY.ExportExpiry {
+		if removeErr := os.Remove(path); removeErr != nil {
+			utils.Log.Error("Error removing expired export: ", removeErr)
+		}
+		return nil, utils.Gone("export_expired", "Download link has expired. Please request a new export.")
+	}
+
+	file, openErr := os.Open(path)
+	if openErr != nil {
+		return nil, utils.Internal(openErr)
+	}
+	return file, nil
+}
+
+// StartExportCleanup removes expired archives from EXPORT_DIR every hour
+func StartExportCleanup() {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			purgeExpiredExports()
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeExpiredExports() {
+	entries, err := os.ReadDir(config.Config.PRIVACY.ExportDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			utils.Log.Error("Error listing exports: ", err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		info, infoErr := entry.Info()
+		if infoErr != nil || entry.IsDir() || time.Since(info.ModTime()) <= config.Config.PRIVACY.ExportExpiry {
+			continue
+		}
+
+		if removeErr := os.Remove(filepath.Join(config.Config.PRIVACY.ExportDir, entry.Name())); removeErr != nil {
+			utils.Log.Error("Error removing expired export: ", removeErr)
+		}
+	}
+}
+
+// exportPath names archives "<owner>-<token>" by the hashes of the user and
+// the download token, so the directory listing reveals neither the user nor
+// download links but the archives of a user can be found
+func exportPath(userID int64, token string) string {
+	return filepath.Join(config.Config.PRIVACY.ExportDir, exportOwner(userID)+"-"+exportTokenHash(token)+".zip")
+}
+
+func exportOwner(userID int64) string {
+	return strings.TrimPrefix(utils.HashOtp("export-user|"+strconv.FormatInt(userID, 10)), utils.OtpHashPrefix)
+}
+
+func exportTokenHash(token string) string {
+	return strings.TrimPrefix(utils.HashOtp("export|"+token), utils.OtpHashPrefix)
+}
+
+// removeExports deletes the archives of the user that were not downloaded
+// or have not expired yet
+func removeExports(userID int64) {
+	paths, err := filepath.Glob(filepath.Join(config.Config.PRIVACY.ExportDir, exportOwner(userID)+"-*.zip"))
+	if err != nil {
+		utils.Log.Error("Error listing exports of user ", userID, ": ", err)
+		return
+	}
+
+	for _, path := range paths {
+		if removeErr := os.Remove(path); removeErr != nil && !os.IsNotExist(removeErr) {
+			utils.Log.Error("Error removing export of user ", userID, ": ", removeErr)
+		}
+	}
+}
+
+func writeExportArchive(path string, files map[string]any) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a download never sees a partial archive
+	tmp, err := os.CreateTemp(dir, ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	archive := zip.NewWriter(tmp)
+	for name, content := range files {
+		entry, createErr := archive.Create(name)
+		if createErr != nil {
+			tmp.Close()
+			return createErr
+		}
+
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(content); encodeErr != nil {
+			tmp.Close()
+			return encodeErr
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// nonNil makes empty lists encode as [] instead of null
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
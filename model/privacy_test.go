@@ -0,0 +1,195 @@
+package model_test
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestExportAndDeleteAccount(t *testing.T) {
+	ctx := context.Background()
+	config.Config.PRIVACY.ExportDir = t.TempDir()
+	config.Config.PRIVACY.ExportExpiry = time.Hour
+	config.Config.OTP.Length, config.Config.OTP.Alphabet = 6, "0123456789"
+
+	store := memory.NewStore()
+	user := model.User{Email: "kim@example.com", Password: "Passw0rd!"}
+	if err := user.Save(ctx, store.Users); err != nil {
+		t.Fatal(err)
+	}
+	url := model.Url{UserID: user.ID, Code: "kim1", Url: "https://example.com"}
+	if err := store.Urls.Save(ctx, &url); err != nil {
+		t.Fatal(err)
+	}
+	click := model.Analytics{
+		UrlID:          url.ID,
+		IPAddress:      "203.0.113.7",
+		UserAgent:      "Mozilla/5.0 (X11; Linux x86_64)",
+		Referrer:       "https://news.example.org/item?id=1",
+		ReferrerDomain: "news.example.org",
+		Country:        "DE",
+		Device:         model.DeviceDesktop,
+		CreatedAt:      time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if err := store.Analytics.Save(ctx, &click); err != nil {
+		t.Fatal(err)
+	}
+
+	token, exportErr := user.Export(ctx, store)
+	if exportErr != nil {
+		t.Fatal(exportErr)
+	}
+
+	analytics := readExportFile(t, token, "analytics.json")
+	for _, leaked := range []string{click.IPAddress, click.UserAgent, "item?id=1", "ip_address", "user_agent"} {
+		if strings.Contains(analytics, leaked) {
+			t.Errorf("analytics.json contains %q: %s", leaked, analytics)
+		}
+	}
+	for _, kept := range []string{`"referrer_domain": "news.example.org"`, `"country": "DE"`, `"device": "desktop"`, `"bot": false`} {
+		if !strings.Contains(analytics, kept) {
+			t.Errorf("analytics.json lacks %s: %s", kept, analytics)
+		}
+	}
+
+	today := model.VisitorDay(time.Now())
+	if err := store.Visitors.Add(ctx, url.ID, today, "day-visitor", "visitor"); err != nil {
+		t.Fatal(err)
+	}
+
+	otp := model.Otp{Key: user.Email, Type: model.OtpTypeEmail, Action: model.OtpActionTypeDeleteAccount}
+	if err := otp.Generate(ctx, store.Otps, store.Users); err != nil {
+		t.Fatal(err)
+	}
+	deleteErr := user.DeleteAccount(ctx, store, model.DeleteAccount{UserOtp: model.UserOtp{OtpToken: otp.Token, OtpCode: otp.OtpCode}})
+	if deleteErr != nil {
+		t.Fatal(deleteErr)
+	}
+
+	if _, err := model.OpenExport(token); err == nil {
+		t.Fatal("export of the deleted account can still be downloaded")
+	}
+	if entries, _ := os.ReadDir(config.Config.PRIVACY.ExportDir); len(entries) != 0 {
+		t.Fatalf("EXPORT_DIR still holds %d files", len(entries))
+	}
+	total, _ := store.Visitors.CountTotal(ctx, []int64{url.ID})
+	daily, _ := store.Visitors.CountDay(ctx, []int64{url.ID}, today)
+	if total[url.ID] != 0 || daily[url.ID] != 0 {
+		t.Fatalf("unique visitors of the deleted account's link kept: %d all-time, %d today", total[url.ID], daily[url.ID])
+	}
+}
+
+func readExportFile(t *testing.T, token string, name string) string {
+	t.Helper()
+
+	file, openErr := model.OpenExport(token)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	defer file.Close()
+
+	info, _ := file.Stat()
+	archive, zipErr := zip.NewReader(file, info.Size())
+	if zipErr != nil {
+		t.Fatal(zipErr)
+	}
+
+	entry, entryErr := archive.Open(name)
+	if entryErr != nil {
+		t.Fatalf("%s: %v (%s)", name, entryErr, filepath.Base(file.Name()))
+	}
+	defer entry.Close()
+
+	content, readErr := io.ReadAll(entry)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	return string(content)
+}
+
+func TestDeleteAccountAnonymizesAuditEventsAndReports(t *testing.T) {
+	ctx := context.Background()
+	config.Config.OTP.Length, config.Config.OTP.Alphabet = 6, "0123456789"
+
+	store := memory.NewStore()
+	user := model.User{Email: "lee@example.com", Password: "Passw0rd!"}
+	other := model.User{Email: "max@example.com", Password: "Passw0rd!"}
+	for _, u := range []*model.User{&user, &other} {
+		if err := u.Save(ctx, store.Users); err != nil {
+			t.Fatal(err)
+		}
+	}
+	url := model.Url{UserID: other.ID, Code: "max1", Url: "https://example.com"}
+	if err := store.Urls.Save(ctx, &url); err != nil {
+		t.Fatal(err)
+	}
+
+	emailChange := user.AuditEvent(model.AuditActionEmailChange)
+	emailChange.ActorID, emailChange.IPAddress, emailChange.UserAgent = user.ID, "203.0.113.7", "Firefox"
+	emailChange.Changes = map[string]model.AuditChange{"email": {Before: "old@example.com", After: user.Email}}
+	staffAction := other.AuditEvent(model.AuditActionUserSuspend)
+	staffAction.ActorID, staffAction.IPAddress = user.ID, "203.0.113.7"
+	otherLogin := other.AuditEvent(model.AuditActionLogin)
+	otherLogin.ActorID, otherLogin.IPAddress = other.ID, "198.51.100.9"
+	for _, event := range []model.AuditEvent{emailChange, staffAction, otherLogin} {
+		model.RecordAudit(ctx, store.Audits, event)
+	}
+
+	reports := []model.AbuseReport{
+		{UrlID: url.ID, Reason: model.AbuseReasonSpam, ReporterIP: "203.0.113.7", ReporterID: user.ID, Status: model.AbuseReportStatusOpen},
+		{UrlID: url.ID, Reason: model.AbuseReasonSpam, ReporterIP: "203.0.113.8", ReporterEmail: "LEE@example.com", Status: model.AbuseReportStatusOpen},
+		{UrlID: url.ID, Reason: model.AbuseReasonSpam, ReporterIP: "198.51.100.9", ReporterEmail: "max@example.com", Status: model.AbuseReportStatusOpen},
+	}
+	for i := range reports {
+		if err := store.AbuseReports.Save(ctx, &reports[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	otp := model.Otp{Key: user.Email, Type: model.OtpTypeEmail, Action: model.OtpActionTypeDeleteAccount}
+	if err := otp.Generate(ctx, store.Otps, store.Users); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.DeleteAccount(ctx, store, model.DeleteAccount{UserOtp: model.UserOtp{OtpToken: otp.Token, OtpCode: otp.OtpCode}}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, _ := store.Audits.Search(ctx, model.AuditFilter{Pagination: model.Pagination{Limit: 10}})
+	if len(events) != 3 {
+		t.Fatalf("%d audit events kept, want all 3", len(events))
+	}
+	for _, event := range events {
+		ofUser := event.UserID == user.ID || event.ActorID == user.ID
+		if anonymized := event.IPAddress == "" && event.UserAgent == "" && event.Changes["email"] == (model.AuditChange{}); anonymized != ofUser {
+			t.Errorf("%s event: anonymized %v, want %v (%+v)", event.Action, anonymized, ofUser, event)
+		}
+	}
+
+	// Reports filed by the account or with its email lose the reporter
+	for i, want := range []model.AbuseReport{
+		{ID: reports[0].ID},
+		{ID: reports[1].ID},
+		{ID: reports[2].ID, ReporterIP: "198.51.100.9", ReporterEmail: "max@example.com"},
+	} {
+		report, err := store.AbuseReports.GetByID(ctx, want.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.ReporterIP != want.ReporterIP || report.ReporterEmail != want.ReporterEmail || report.ReporterID != 0 {
+			t.Errorf("report %d: reporter %q %q %d", i, report.ReporterIP, report.ReporterEmail, report.ReporterID)
+		}
+	}
+	// Open reports without an address don't hold the address of a new one
+	if err := store.AbuseReports.Save(ctx, &model.AbuseReport{UrlID: url.ID, Reason: model.AbuseReasonSpam, ReporterIP: "203.0.113.7", Status: model.AbuseReportStatusOpen}); err != nil {
+		t.Errorf("report from the address of the deleted account: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package model
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type AbuseReason string
+
+const (
+	AbuseReasonPhishing AbuseReason = "phishing"
+	AbuseReasonMalware  AbuseReason = "malware"
+	AbuseReasonSpam     AbuseReason = "spam"
+	AbuseReasonOther    AbuseReason = "other"
+)
+
+type AbuseReportStatus string
+
+const (
+	AbuseReportStatusOpen      AbuseReportStatus = "open"
+	AbuseReportStatusDismissed AbuseReportStatus = "dismissed"
+	AbuseReportStatusActioned  AbuseReportStatus = "actioned" // The link was blocked
+)
+
+// Safety reasons of blocked links, dismissing reports only reactivates
+// links that were blocked automatically
+const (
+	abuseAutoBlockReason      = "Blocked automatically after reports of abuse"
+	abuseModeratorBlockReason = "Blocked by a moderator after reports of abuse"
+)
+
+// AbuseReport is a report of a malicious link by a visitor. Anonymous
+// reporters are told apart by IP address, IPv6 addresses by their /64
+// network. Only reports of signed-in users count towards blocking a link
+// automatically. The email is optional and unverified.
+type AbuseReport struct {
+	ID            int64             `json:"id"`
+	UrlID         int64             `json:"url_id"`
+	Code          string            `json:"code"`
+	Reason        AbuseReason       `json:"reason"`
+	Details       string            `json:"details,omitempty"`
+	ReporterID    int64             `json:"reporter_id,omitempty"` // Signed-in reporter
+	ReporterEmail string            `json:"reporter_email,omitempty"`
+	ReporterIP    string            `json:"reporter_ip"`
+	Status        AbuseReportStatus `json:"status"`
+	CreatedAt     time.Time         `json:"created_at"`
+	ResolvedAt    time.Time         `json:"resolved_at"`
+	ResolvedBy    int64             `json:"resolved_by,omitempty"`
+}
+
+type CreateAbuseReport struct {
+	Reason  AbuseReason `json:"reason" binding:"required,oneof=phishing malware spam other"`
+	Details string      `json:"details" binding:"max=1000"`
+	Email   string      `json:"email" binding:"omitempty,email"`
+}
+
+// AbuseReportFilter narrows down the moderation queue, newest first
+type AbuseReportFilter struct {
+	Status AbuseReportStatus `form:"status" json:"status" binding:"omitempty,oneof=open dismissed actioned"`
+	Code   string            `form:"code" json:"code"`
+	Pagination
+}
+
+type AbuseReportsResponse struct {
+	Reports []AbuseReport `json:"reports"`
+}
+
+// AbuseReportResponse is a report with the link and every report on it
+type AbuseReportResponse struct {
+	Report  AbuseReport      `json:"report"`
+	Url     UrlWithShortCode `json:"url"`
+	Reports []AbuseReport    `json:"reports"`
+}
+
+// AbuseReportResolution is the outcome of a moderation action
+type AbuseReportResolution struct {
+	Url      Url   `json:"url"`
+	Resolved int64 `json:"resolved"` // Open reports closed by the action
+}
+
+// ReportUrl records the report and blocks the link once ABUSE_AUTO_BLOCK_REPORTS
+// signed-in users have open reports on it. reporterID is 0 for anonymous
+// reports. It returns whether the link was blocked by this report.
+func ReportUrl(ctx context.Context, urls UrlStore, reports AbuseReportStore, url *Url, create CreateAbuseReport, ip string, reporterID int64) (AbuseReport, bool, error) {
+	report := AbuseReport{
+		UrlID:         url.ID,
+		Code:          url.Code,
+		Reason:        create.Reason,
+		Details:       create.Details,
+		ReporterID:    reporterID,
+		ReporterEmail: create.Email,
+		ReporterIP:    reporterNetwork(ip),
+		Status:        AbuseReportStatusOpen,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := reports.Save(ctx, &report); err != nil {
+		return report, false, err
+	}
+
+	threshold := config.Config.ABUSE.AutoBlockReports
+	if threshold <= 0 || reporterID == 0 || (url.Status != UrlStatusActive && url.Status != UrlStatusQuarantined) {
+		return report, false, nil
+	}
+
+	reporters, countErr := reports.CountVerifiedReporters(ctx, url.ID)
+	if countErr != nil {
+		return report, false, countErr
+	}
+	if reporters < threshold {
+		return report, false, nil
+	}
+
+	// Concurrent reports crossing the threshold block the link only once
+	blocked, blockErr := urls.ReplaceStatus(ctx, url.ID, []UrlStatus{UrlStatusActive, UrlStatusQuarantined}, UrlStatusBlocked, abuseAutoBlockReason)
+	if blockErr != nil || !blocked {
+		return report, false, blockErr
+	}
+	url.Status, url.SafetyReason = UrlStatusBlocked, abuseAutoBlockReason
+
+	utils.Log.Warn("URL ", url.Code, " blocked after reports of ", reporters, " users")
+	return report, true, nil
+}
+
+// reporterNetwork is the address reporters are told apart by. IPv6 users
+// usually get a whole /64, so it counts as one reporter.
+func reporterNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, _ := addr.WithZone("").Prefix(64)
+	return prefix.String()
+}
+
+func SearchAbuseReports(ctx context.Context, reports AbuseReportStore, filter AbuseReportFilter) ([]AbuseReport, error) {
+	filter.normalize()
+
+	found, err := reports.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return nonNil(found), nil
+}
+
+// GetAbuseReport loads the report with its link and the other reports on it
+func GetAbuseReport(ctx context.Context, urls UrlStore, reports AbuseReportStore, id int64) (AbuseReportResponse, error) {
+	report, reportErr := reports.GetByID(ctx, id)
+	if reportErr != nil {
+		return AbuseReportResponse{}, reportErr
+	}
+
+	url, urlErr := urls.GetByCode(ctx, report.Code)
+	if urlErr != nil {
+		return AbuseReportResponse{}, urlErr
+	}
+
+	all, allErr := SearchAbuseReports(ctx, reports, AbuseReportFilter{Code: report.Code, Pagination: Pagination{Limit: adminSearchMaxLimit}})
+	if allErr != nil {
+		return AbuseReportResponse{}, allErr
+	}
+
+	return AbuseReportResponse{
+		Report:  report,
+		Url:     UrlWithShortCode{Url: url, ShortUrl: utils.GetShortUrl(url.Code)},
+		Reports: all,
+	}, nil
+}
+
+// DismissAbuseReports closes the open reports on the link as unfounded. A
+// link blocked automatically is active again, one blocked by a moderator
+// stays blocked.
+func DismissAbuseReports(ctx context.Context, urls UrlStore, reports AbuseReportStore, url *Url, moderatorID int64) (int64, error) {
+	resolved, err := reports.Resolve(ctx, url.ID, AbuseReportStatusDismissed, moderatorID, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	if url.Status != UrlStatusBlocked || url.SafetyReason != abuseAutoBlockReason {
+		return resolved, nil
+	}
+
+	reactivated, reactivateErr := urls.ReplaceStatus(ctx, url.ID, []UrlStatus{UrlStatusBlocked}, UrlStatusActive, "")
+	if reactivateErr != nil {
+		return resolved, reactivateErr
+	}
+	if reactivated {
+		url.Status, url.SafetyReason = UrlStatusActive, ""
+	}
+	return resolved, nil
+}
+
+// BlockReportedUrl blocks the link and closes its open reports as actioned.
+// Links blocked automatically before count as blocked by the moderator from
+// now on.
+func BlockReportedUrl(ctx context.Context, urls UrlStore, reports AbuseReportStore, url *Url, moderatorID int64) (int64, error) {
+	from := []UrlStatus{UrlStatusActive, UrlStatusInactive, UrlStatusExpired, UrlStatusDisabled, UrlStatusQuarantined, UrlStatusBlocked}
+	blocked, blockErr := urls.ReplaceStatus(ctx, url.ID, from, UrlStatusBlocked, abuseModeratorBlockReason)
+	if blockErr != nil {
+		return 0, blockErr
+	}
+	if blocked {
+		url.Status, url.SafetyReason = UrlStatusBlocked, abuseModeratorBlockReason
+	}
+
+	return reports.Resolve(ctx, url.ID, AbuseReportStatusActioned, moderatorID, time.Now().UTC())
+}
@@ -0,0 +1,196 @@
+package model_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+var phishing = model.CreateAbuseReport{Reason: model.AbuseReasonPhishing}
+
+func newReportedUrl(t *testing.T, store *model.Store) *model.Url {
+	t.Helper()
+
+	url := &model.Url{UserID: 1, Code: "reported", Url: "https://reported.example/", Status: model.UrlStatusActive}
+	if err := store.Urls.Save(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+	return url
+}
+
+func storedStatus(t *testing.T, store *model.Store, code string) model.UrlStatus {
+	t.Helper()
+
+	url, err := store.Urls.GetByCode(context.Background(), code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url.Status
+}
+
+func TestReportUrlBlocksAfterVerifiedReporters(t *testing.T) {
+	ctx := context.Background()
+	config.Config.ABUSE.AutoBlockReports = 3
+	store := memory.NewStore()
+	url := newReportedUrl(t, store)
+
+	// Anonymous reports from any number of addresses only reach the queue
+	for i := range 10 {
+		if _, blocked, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, fmt.Sprintf("198.51.100.%d", i), 0); err != nil || blocked {
+			t.Fatalf("anonymous report %d: blocked %v, %v", i, blocked, err)
+		}
+	}
+
+	for i, userID := range []int64{11, 12, 13} {
+		_, blocked, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, fmt.Sprintf("203.0.113.%d", i), userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wantBlocked := i == 2; blocked != wantBlocked {
+			t.Fatalf("report of user %d: blocked %v, want %v", userID, blocked, wantBlocked)
+		}
+	}
+	if status := storedStatus(t, store, url.Code); status != model.UrlStatusBlocked {
+		t.Fatalf("link is %s after 3 verified reporters", status)
+	}
+
+	// Further reports don't block it again
+	if _, blocked, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, "203.0.113.50", 14); err != nil || blocked {
+		t.Fatalf("report on a blocked link: blocked %v, %v", blocked, err)
+	}
+}
+
+func TestReportUrlOncePerReporter(t *testing.T) {
+	ctx := context.Background()
+	config.Config.ABUSE.AutoBlockReports = 0
+	store := memory.NewStore()
+	url := newReportedUrl(t, store)
+
+	first := []struct {
+		ip     string
+		userID int64
+	}{
+		{"198.51.100.1", 0},
+		{"2001:db8:1:2::1", 0},
+		{"203.0.113.1", 11},
+	}
+	for _, reporter := range first {
+		if _, _, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, reporter.ip, reporter.userID); err != nil {
+			t.Fatalf("first report of %s: %v", reporter.ip, err)
+		}
+	}
+
+	again := map[string]struct {
+		ip     string
+		userID int64
+	}{
+		"same address":             {"198.51.100.1", 0},
+		"same address, mapped":     {"::ffff:198.51.100.1", 0},
+		"same IPv6 /64":            {"2001:db8:1:2:ffff:ffff:ffff:ffff", 0},
+		"same user, other address": {"203.0.113.99", 11},
+	}
+	for name, reporter := range again {
+		if _, _, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, reporter.ip, reporter.userID); !errors.Is(err, model.ErrAbuseReportExists) {
+			t.Errorf("%s: error = %v, want %v", name, err, model.ErrAbuseReportExists)
+		}
+	}
+
+	// Another /64 is another reporter
+	if _, _, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, "2001:db8:1:3::1", 0); err != nil {
+		t.Errorf("report of another /64: %v", err)
+	}
+}
+
+func TestConcurrentReportsBlockOnce(t *testing.T) {
+	ctx := context.Background()
+	config.Config.ABUSE.AutoBlockReports = 2
+	store := memory.NewStore()
+	url := newReportedUrl(t, store)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	blocks := 0
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reported := *url // Every request loaded the link on its own
+			_, blocked, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, &reported, phishing, fmt.Sprintf("203.0.113.%d", i), int64(100+i))
+			if err != nil {
+				t.Error(err)
+			}
+			if blocked {
+				mu.Lock()
+				blocks++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if blocks != 1 {
+		t.Errorf("link blocked by %d reports, want 1", blocks)
+	}
+}
+
+func TestDismissAbuseReports(t *testing.T) {
+	ctx := context.Background()
+	config.Config.ABUSE.AutoBlockReports = 1
+	store := memory.NewStore()
+	url := newReportedUrl(t, store)
+
+	report := func(ip string, userID int64) {
+		t.Helper()
+		if _, _, err := model.ReportUrl(ctx, store.Urls, store.AbuseReports, url, phishing, ip, userID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reload := func() {
+		t.Helper()
+		stored, err := store.Urls.GetByCode(ctx, url.Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*url = stored
+	}
+
+	// Blocked automatically, dismissing reactivates it
+	report("203.0.113.1", 11)
+	reload()
+	resolved, err := model.DismissAbuseReports(ctx, store.Urls, store.AbuseReports, url, 1)
+	if err != nil || resolved != 1 || url.Status != model.UrlStatusActive || storedStatus(t, store, url.Code) != model.UrlStatusActive {
+		t.Fatalf("dismiss automatic block: %d resolved, link %s, %v", resolved, url.Status, err)
+	}
+
+	// Blocked by a moderator, dismissing later reports keeps it blocked
+	report("203.0.113.2", 12)
+	reload()
+	if _, err := model.BlockReportedUrl(ctx, store.Urls, store.AbuseReports, url, 1); err != nil {
+		t.Fatal(err)
+	}
+	report("203.0.113.3", 13)
+	reload()
+	if _, err := model.DismissAbuseReports(ctx, store.Urls, store.AbuseReports, url, 1); err != nil || storedStatus(t, store, url.Code) != model.UrlStatusBlocked {
+		t.Fatalf("dismiss after moderator block: link %s, %v", storedStatus(t, store, url.Code), err)
+	}
+
+	// Reactivated by staff and blocked automatically again, the actioned
+	// reports of the earlier block don't keep it blocked
+	if err := url.UpdateStatus(ctx, store.Urls, model.UrlStatusActive); err != nil {
+		t.Fatal(err)
+	}
+	report("203.0.113.4", 14)
+	reload()
+	if url.Status != model.UrlStatusBlocked {
+		t.Fatalf("link is %s, want blocked again", url.Status)
+	}
+	if _, err := model.DismissAbuseReports(ctx, store.Urls, store.AbuseReports, url, 1); err != nil || storedStatus(t, store, url.Code) != model.UrlStatusActive {
+		t.Fatalf("dismiss after a new automatic block: link %s, %v", storedStatus(t, store, url.Code), err)
+	}
+}
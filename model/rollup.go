@@ -0,0 +1,213 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	// Clicks are saved in the background and can arrive late, the hour
+	// before the watermark is rolled up again
+	rollupLateness = time.Hour
+	// Backfills roll up this much at a time
+	rollupChunk = 24 * time.Hour
+)
+
+type RollupInterval string
+
+const (
+	RollupHour RollupInterval = "hour"
+	RollupDay  RollupInterval = "day"
+)
+
+// Dimensions of the rollups. Every click counts once for each of them,
+// DimensionTotal has a single empty value.
+const (
+	DimensionTotal    = "total"
+	DimensionReferrer = "referrer"
+	DimensionCountry  = "country"
+	DimensionDevice   = "device"
+)
+
+// AnalyticsBucket counts the clicks on a link with one value of a
+// dimension in an hour or a day
+type AnalyticsBucket struct {
+	UrlID          int64
+	Bucket         time.Time
+	Dimension      string
+	Value          string
+	Clicks         int64
+	BotClicks      int64
+	UniqueVisitors int64
+}
+
+// RollupStore keeps hourly and daily rollups of the clicks, which stay
+// after raw clicks are purged
+type RollupStore interface {
+	// Rollup computes the buckets of the interval from the raw clicks from
+	// from up to before to, both aligned to the interval. Buckets already
+	// rolled up are replaced.
+	Rollup(ctx context.Context, interval RollupInterval, from time.Time, to time.Time) error
+	// Watermark is the time clicks are rolled up until, zero before the
+	// first rollup
+	Watermark(ctx context.Context) (time.Time, error)
+	SetWatermark(ctx context.Context, watermark time.Time) error
+	// Series returns the DimensionTotal buckets of the link from from up to
+	// before to, oldest first. Buckets without clicks are left out.
+	Series(ctx context.Context, urlID int64, interval RollupInterval, from time.Time, to time.Time) ([]AnalyticsBucket, error)
+	// Breakdown sums the daily buckets of the dimension from from up to
+	// before to per value, most clicks first
+	Breakdown(ctx context.Context, urlID int64, dimension string, from time.Time, to time.Time, limit int) ([]AnalyticsBucket, error)
+	// Purge deletes up to limit buckets of the interval older than before
+	// and returns how many it deleted
+	Purge(ctx context.Context, interval RollupInterval, before time.Time, limit int) (int64, error)
+}
+
+// StartAnalyticsRollup rolls up clicks every ANALYTICS_ROLLUP_INTERVAL
+func StartAnalyticsRollup(analytics AnalyticsStore, rollups RollupStore) {
+	if config.Config.ANALYTICS.RollupInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Config.ANALYTICS.RollupInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := RollupAnalytics(context.Background(), analytics, rollups, time.Now().UTC()); err != nil {
+				utils.Log.Error("Error rolling up analytics: ", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// StartAnalyticsPurge purges old clicks and hourly rollups every
+// ANALYTICS_PURGE_INTERVAL, also on instances that don't roll up
+func StartAnalyticsPurge(analytics AnalyticsStore, rollups RollupStore) {
+	if config.Config.ANALYTICS.PurgeInterval <= 0 {
+		return
+	}
+	if config.Config.ANALYTICS.RollupInterval <= 0 && config.Config.ANALYTICS.RawRetention > 0 {
+		utils.Log.Warn("ANALYTICS_ROLLUP_INTERVAL is 0, raw clicks are only purged once another instance rolled them up")
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Config.ANALYTICS.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := PurgeAnalytics(context.Background(), analytics, rollups, time.Now().UTC()); err != nil {
+				utils.Log.Error("Error purging analytics: ", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RollupAnalytics rolls up the clicks since the watermark until now. The
+// first run starts at the oldest click, a day at a time.
+func RollupAnalytics(ctx context.Context, analytics AnalyticsStore, rollups RollupStore, now time.Time) error {
+	watermark, watermarkErr := rollups.Watermark(ctx)
+	if watermarkErr != nil {
+		return watermarkErr
+	}
+	if watermark.IsZero() {
+		oldest, oldestErr := analytics.Oldest(ctx)
+		if oldestErr != nil {
+			return oldestErr
+		}
+		if oldest.IsZero() {
+			return rollups.SetWatermark(ctx, now)
+		}
+		watermark = oldest
+	}
+
+	from := watermark.Add(-rollupLateness).Truncate(time.Hour)
+	end := now.Truncate(time.Hour).Add(time.Hour) // The current hour so far
+	for from.Before(end) {
+		to := earliest(from.Add(rollupChunk), end)
+
+		if err := rollups.Rollup(ctx, RollupHour, from, to); err != nil {
+			return err
+		}
+		// Unique visitors of a day can't be added up from its hours, days
+		// are rolled up from the raw clicks as a whole
+		dayFrom := from.Truncate(24 * time.Hour)
+		dayTo := to.Add(-time.Nanosecond).Truncate(24*time.Hour).AddDate(0, 0, 1)
+		if err := rollups.Rollup(ctx, RollupDay, dayFrom, dayTo); err != nil {
+			return err
+		}
+
+		if err := rollups.SetWatermark(ctx, earliest(to, now)); err != nil {
+			return err
+		}
+		from = to
+	}
+
+	return nil
+}
+
+// PurgeAnalytics deletes raw clicks older than ANALYTICS_RAW_RETENTION and
+// hourly rollups older than ANALYTICS_HOURLY_RETENTION, ANALYTICS_PURGE_BATCH
+// rows at a time. Clicks that weren't rolled up yet are kept.
+func PurgeAnalytics(ctx context.Context, analytics AnalyticsStore, rollups RollupStore, now time.Time) error {
+	if retention := config.Config.ANALYTICS.RawRetention; retention > 0 {
+		watermark, watermarkErr := rollups.Watermark(ctx)
+		if watermarkErr != nil {
+			return watermarkErr
+		}
+
+		// Whole days only, days are rolled up from all their clicks
+		before := earliest(now.Add(-retention), watermark.Add(-rollupLateness)).Truncate(24 * time.Hour)
+		purged, err := purgeInBatches(func(limit int) (int64, error) {
+			return analytics.Purge(ctx, before, limit)
+		})
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			utils.Log.Info("Purged ", purged, " clicks before ", before.Format(time.DateOnly))
+		}
+	}
+
+	if retention := config.Config.ANALYTICS.HourlyRetention; retention > 0 {
+		before := now.Add(-retention).Truncate(time.Hour)
+		purged, err := purgeInBatches(func(limit int) (int64, error) {
+			return rollups.Purge(ctx, RollupHour, before, limit)
+		})
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			utils.Log.Info("Purged ", purged, " hourly rollups before ", before.Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// purgeInBatches calls purge until it deletes less than a batch, so no
+// statement holds locks on lots of rows
+func purgeInBatches(purge func(limit int) (int64, error)) (int64, error) {
+	batch := max(config.Config.ANALYTICS.PurgeBatch, 1)
+
+	var total int64
+	for {
+		purged, err := purge(batch)
+		total += purged
+		if err != nil || purged < int64(batch) {
+			return total, err
+		}
+	}
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
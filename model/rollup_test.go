@@ -0,0 +1,237 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+// newClickedUrl saves a user with a link, clicks are then saved at the
+// current time
+func newClickedUrl(t *testing.T) (*model.Store, model.User, model.Url) {
+	t.Helper()
+
+	ctx := context.Background()
+	store := memory.NewStore()
+	user := model.User{Email: "clicks@example.com", Password: "Passw0rd!"}
+	if err := user.Save(ctx, store.Users); err != nil {
+		t.Fatal(err)
+	}
+	url := model.Url{UserID: user.ID, Code: "clicked", Url: "https://example.com/", Status: model.UrlStatusActive}
+	if err := store.Urls.Save(ctx, &url); err != nil {
+		t.Fatal(err)
+	}
+	return store, user, url
+}
+
+func saveClick(t *testing.T, store *model.Store, url model.Url, bot bool) {
+	t.Helper()
+
+	click := model.Analytics{UrlID: url.ID, Bot: bot, Device: model.DeviceDesktop}
+	if err := store.Analytics.Save(context.Background(), &click); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dayBucket(t *testing.T, store *model.Store, url model.Url, day time.Time) model.AnalyticsBucket {
+	t.Helper()
+
+	series, err := store.Rollups.Series(context.Background(), url.ID, model.RollupDay, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 1 {
+		t.Fatalf("%d daily buckets, want 1", len(series))
+	}
+	return series[0]
+}
+
+func setAnalyticsRetention(t *testing.T, raw time.Duration, hourly time.Duration) {
+	t.Helper()
+
+	oldRaw, oldHourly := config.Config.ANALYTICS.RawRetention, config.Config.ANALYTICS.HourlyRetention
+	t.Cleanup(func() {
+		config.Config.ANALYTICS.RawRetention, config.Config.ANALYTICS.HourlyRetention = oldRaw, oldHourly
+	})
+	config.Config.ANALYTICS.RawRetention, config.Config.ANALYTICS.HourlyRetention = raw, hourly
+}
+
+func TestRollupAnalyticsResumesFromWatermark(t *testing.T) {
+	ctx := context.Background()
+	store, _, url := newClickedUrl(t)
+	saveClick(t, store, url, false)
+	saveClick(t, store, url, true)
+
+	start := time.Now().UTC()
+	day := start.Truncate(24 * time.Hour)
+	if err := model.RollupAnalytics(ctx, store.Analytics, store.Rollups, start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if watermark, _ := store.Rollups.Watermark(ctx); !watermark.Equal(start.Add(time.Minute)) {
+		t.Fatalf("watermark %s, want %s", watermark, start.Add(time.Minute))
+	}
+	if bucket := dayBucket(t, store, url, day); bucket.Clicks != 1 || bucket.BotClicks != 1 {
+		t.Fatalf("day after the first rollup: %+v", bucket)
+	}
+
+	// Saved late, before the watermark, and picked up by the next rollup
+	// without counting the earlier clicks twice
+	saveClick(t, store, url, false)
+	if err := model.RollupAnalytics(ctx, store.Analytics, store.Rollups, start.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if bucket := dayBucket(t, store, url, day); bucket.Clicks != 2 || bucket.BotClicks != 1 {
+		t.Fatalf("day after the late click: %+v", bucket)
+	}
+
+	// Resuming after days backfills up to now
+	later := start.Add(72 * time.Hour)
+	if err := model.RollupAnalytics(ctx, store.Analytics, store.Rollups, later); err != nil {
+		t.Fatal(err)
+	}
+	if watermark, _ := store.Rollups.Watermark(ctx); !watermark.Equal(later) {
+		t.Fatalf("watermark %s after resuming, want %s", watermark, later)
+	}
+	if bucket := dayBucket(t, store, url, day); bucket.Clicks != 2 {
+		t.Fatalf("day after resuming: %+v", bucket)
+	}
+}
+
+func TestPurgeAnalyticsKeepsClicksNotRolledUp(t *testing.T) {
+	ctx := context.Background()
+	setAnalyticsRetention(t, time.Hour, 0)
+	store, user, url := newClickedUrl(t)
+	saveClick(t, store, url, false)
+
+	start := time.Now().UTC()
+	later := start.Add(72 * time.Hour)
+	clicks := func() int {
+		t.Helper()
+		events, err := store.Analytics.ListByUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(events)
+	}
+
+	// Past retention but never rolled up
+	if err := model.PurgeAnalytics(ctx, store.Analytics, store.Rollups, later); err != nil {
+		t.Fatal(err)
+	}
+	if clicks() != 1 {
+		t.Fatal("click purged before any rollup")
+	}
+
+	// Rolled up, but its day isn't complete yet
+	if err := model.RollupAnalytics(ctx, store.Analytics, store.Rollups, start.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.PurgeAnalytics(ctx, store.Analytics, store.Rollups, later); err != nil {
+		t.Fatal(err)
+	}
+	if clicks() != 1 {
+		t.Fatal("click purged before its day was rolled up")
+	}
+
+	if err := model.RollupAnalytics(ctx, store.Analytics, store.Rollups, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.PurgeAnalytics(ctx, store.Analytics, store.Rollups, later); err != nil {
+		t.Fatal(err)
+	}
+	if clicks() != 0 {
+		t.Fatal("rolled up click past retention kept")
+	}
+	if bucket := dayBucket(t, store, url, start.Truncate(24*time.Hour)); bucket.Clicks != 1 {
+		t.Fatalf("daily bucket after purging: %+v", bucket)
+	}
+}
+
+func TestUrlAnalyticsSource(t *testing.T) {
+	ctx := context.Background()
+	store, _, url := newClickedUrl(t)
+	saveClick(t, store, url, false)
+	if err := model.RollupAnalytics(ctx, store.Analytics, store.Rollups, time.Now().UTC().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	url, _ = store.Urls.GetByCode(ctx, url.Code)
+
+	today := time.Now().UTC()
+	daysAgo := func(n int) string { return today.AddDate(0, 0, -n).Format(model.VisitorDayFormat) }
+	oldVisitorRetention := config.Config.ANALYTICS.VisitorRetention
+	t.Cleanup(func() { config.Config.ANALYTICS.VisitorRetention = oldVisitorRetention })
+
+	tests := []struct {
+		name             string
+		rawRetention     time.Duration
+		visitorRetention time.Duration
+		from             string
+		wantSource       string
+	}{
+		{"week", 90 * 24 * time.Hour, 90 * 24 * time.Hour, daysAgo(6), model.AnalyticsSourceRaw},
+		{"longer than a week", 90 * 24 * time.Hour, 90 * 24 * time.Hour, daysAgo(7), model.AnalyticsSourceRollup},
+		{"past raw retention", 48 * time.Hour, 90 * 24 * time.Hour, daysAgo(3), model.AnalyticsSourceRollup},
+		{"raw kept forever", 0, 90 * 24 * time.Hour, daysAgo(6), model.AnalyticsSourceRaw},
+		// Daily unique visitors would read as 0 once their HyperLogLog expired
+		{"past visitor retention", 0, 48 * time.Hour, daysAgo(3), model.AnalyticsSourceRollup},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setAnalyticsRetention(t, tt.rawRetention, 0)
+			config.Config.ANALYTICS.VisitorRetention = tt.visitorRetention
+
+			analytics, err := model.GetUrlAnalytics(ctx, store, url, model.UrlAnalyticsFilter{From: tt.from, To: daysAgo(0)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if analytics.Source != tt.wantSource {
+				t.Errorf("source %s, want %s", analytics.Source, tt.wantSource)
+			}
+			// Both sources count the click
+			if last := analytics.Days[len(analytics.Days)-1]; last.Day != daysAgo(0) || last.Clicks != 1 {
+				t.Errorf("today %+v", last)
+			}
+		})
+	}
+}
+
+func TestRecordClickDimensions(t *testing.T) {
+	ctx := context.Background()
+	store, user, url := newClickedUrl(t)
+
+	tests := []struct {
+		userAgent    string
+		referrer     string
+		bot          bool
+		wantDevice   string
+		wantReferrer string
+	}{
+		{"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)", "https://www.News.example.org/item?id=1", false, model.DeviceTablet, "news.example.org"},
+		{"Mozilla/5.0 (Linux; Android 14; SM-X700)", "", false, model.DeviceTablet, ""},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) Mobile Safari/537.36", "android-app://com.slack/", false, model.DeviceMobile, "com.slack"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)", "%zz", false, model.DeviceMobile, ""},
+		{"Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0", "https://wwwexample.com/", false, model.DeviceDesktop, "wwwexample.com"},
+		{"curl/8.5.0", "", false, model.DeviceOther, ""},
+		{"Mozilla/5.0 (iPhone) Slackbot", "", true, model.DeviceBot, ""},
+	}
+	for _, tt := range tests {
+		click := model.Analytics{UrlID: url.ID, UserAgent: tt.userAgent, Referrer: tt.referrer, Bot: tt.bot}
+		if err := model.RecordClick(ctx, store, url, &click, model.ClickPrivacyFull); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clicks, err := store.Analytics.ListByUser(ctx, user.ID)
+	if err != nil || len(clicks) != len(tests) {
+		t.Fatalf("%d clicks stored, %v", len(clicks), err)
+	}
+	for i, tt := range tests {
+		if clicks[i].Device != tt.wantDevice || clicks[i].ReferrerDomain != tt.wantReferrer {
+			t.Errorf("%q from %q: device %q, referrer %q; want %q, %q", tt.userAgent, tt.referrer, clicks[i].Device, clicks[i].ReferrerDomain, tt.wantDevice, tt.wantReferrer)
+		}
+	}
+}
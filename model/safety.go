@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	urlRescanTick  = time.Hour
+	urlRescanBatch = 200
+)
+
+// UrlVerdict is the outcome of a safety check. Check names the check that
+// flagged the URL.
+type UrlVerdict struct {
+	Flagged bool
+	Check   string
+	Reason  string
+}
+
+// UrlChecker decides whether a destination is safe to redirect to
+type UrlChecker interface {
+	Check(ctx context.Context, rawUrl string) (UrlVerdict, error)
+}
+
+// CheckSafety runs the safety checks on a link that is about to be created
+// and quarantines it if it is flagged. Links that could not be checked are
+// created as they are and picked up by the next rescan.
+func (u *Url) CheckSafety(ctx context.Context, checker UrlChecker) {
+	u.checkOgImage(ctx, checker)
+
+	verdict, err := checker.Check(ctx, u.Url)
+	if err != nil {
+		utils.Log.Error("Error checking URL safety of ", u.Code, ": ", err)
+		return
+	}
+
+	u.ScannedAt = time.Now().UTC()
+	if verdict.Flagged {
+		u.Status = UrlStatusQuarantined
+		u.SafetyReason = verdict.Reason
+		utils.Log.Warn("URL ", u.Code, " quarantined by ", verdict.Check, ": ", verdict.Reason)
+	}
+}
+
+// checkOgImage drops an Open Graph image that is flagged or could not be
+// checked, crawlers fetch it for everyone seeing the card and the rescan
+// only covers destinations
+func (u *Url) checkOgImage(ctx context.Context, checker UrlChecker) {
+	if u.OgImage == "" {
+		return
+	}
+
+	verdict, err := checker.Check(ctx, u.OgImage)
+	if err != nil {
+		utils.Log.Error("Error checking Open Graph image safety of ", u.Code, ", dropping it: ", err)
+		u.OgImage = ""
+		return
+	}
+	if verdict.Flagged {
+		utils.Log.Warn("Open Graph image of ", u.Code, " dropped by ", verdict.Check, ": ", verdict.Reason)
+		u.OgImage = ""
+	}
+}
+
+// StartUrlRescan checks links again every SAFETY_RESCAN_INTERVAL, so links
+// turning unsafe after creation are quarantined and links cleared by list
+// updates are released
+func StartUrlRescan(urls UrlStore, audits AuditStore, checker UrlChecker) {
+	if config.Config.SAFETY.RescanInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(min(urlRescanTick, config.Config.SAFETY.RescanInterval))
+		defer ticker.Stop()
+
+		for {
+			if err := RescanUrls(context.Background(), urls, audits, checker); err != nil {
+				utils.Log.Error("Error rescanning URLs: ", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// RescanUrls checks every link whose last check is older than
+// SAFETY_RESCAN_INTERVAL
+func RescanUrls(ctx context.Context, urls UrlStore, audits AuditStore, checker UrlChecker) error {
+	scannedBefore := time.Now().UTC().Add(-config.Config.SAFETY.RescanInterval)
+
+	for {
+		batch, err := urls.ListForScan(ctx, scannedBefore, urlRescanBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, url := range batch {
+			if err := rescanUrl(ctx, urls, audits, checker, url); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < urlRescanBatch {
+			return nil
+		}
+	}
+}
+
+func rescanUrl(ctx context.Context, urls UrlStore, audits AuditStore, checker UrlChecker, url Url) error {
+	verdict, checkErr := checker.Check(ctx, url.Url)
+	if checkErr != nil {
+		utils.Log.Error("Error checking URL safety of ", url.Code, ": ", checkErr)
+		// Still mark it as scanned so one bad URL doesn't stall the rescan
+		_, err := urls.UpdateSafety(ctx, url.ID, url.Status, url.SafetyReason, time.Now().UTC())
+		return err
+	}
+
+	status, reason := UrlStatusActive, ""
+	if verdict.Flagged {
+		status, reason = UrlStatusQuarantined, verdict.Reason
+	}
+
+	updated, updateErr := urls.UpdateSafety(ctx, url.ID, status, reason, time.Now().UTC())
+	if updateErr != nil {
+		return updateErr
+	}
+
+	// Not updated when the link was disabled, blocked or expired meanwhile
+	if updated && status != url.Status {
+		utils.Log.Info("URL ", url.Code, " set to ", status, " by rescan")
+
+		event := url.AuditEvent(AuditActionLinkStatusChange)
+		event.Changes = map[string]AuditChange{"status": {Before: url.Status, After: status}}
+		if verdict.Flagged {
+			event.Details = map[string]string{"check": verdict.Check, "reason": verdict.Reason}
+		}
+		RecordAudit(ctx, audits, event)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+// fakeChecker flags the URLs in flagged. onCheck, if set, runs while a URL
+// is checked.
+type fakeChecker struct {
+	flagged map[string]bool
+	err     error
+	onCheck func(rawUrl string)
+}
+
+func (c fakeChecker) Check(ctx context.Context, rawUrl string) (model.UrlVerdict, error) {
+	if c.onCheck != nil {
+		c.onCheck(rawUrl)
+	}
+	if c.err != nil {
+		return model.UrlVerdict{}, c.err
+	}
+	if c.flagged[rawUrl] {
+		return model.UrlVerdict{Flagged: true, Check: "blocklist", Reason: "Domain is on the blocklist"}, nil
+	}
+	return model.UrlVerdict{}, nil
+}
+
+func TestRescanUrls(t *testing.T) {
+	ctx := context.Background()
+	config.Config.SAFETY.RescanInterval = time.Hour
+
+	store := memory.NewStore()
+	urls := map[string]*model.Url{
+		"turned-bad": {UserID: 1, Code: "bad", Url: "https://bad.example/", Status: model.UrlStatusActive},
+		"cleared":    {UserID: 1, Code: "cleared", Url: "https://cleared.example/", Status: model.UrlStatusQuarantined, SafetyReason: "Domain is on the blocklist"},
+		"disabled":   {UserID: 1, Code: "disabled", Url: "https://disabled.example/", Status: model.UrlStatusDisabled},
+		"blocked":    {UserID: 1, Code: "blocked", Url: "https://blocked.example/", Status: model.UrlStatusQuarantined},
+	}
+	for _, url := range urls {
+		if err := store.Urls.Save(ctx, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checker := fakeChecker{
+		flagged: map[string]bool{"https://bad.example/": true},
+		// A moderator blocks the link while it is checked
+		onCheck: func(rawUrl string) {
+			if rawUrl == urls["blocked"].Url {
+				_ = store.Urls.UpdateStatus(ctx, urls["blocked"].ID, model.UrlStatusBlocked)
+			}
+		},
+	}
+	if err := model.RescanUrls(ctx, store.Urls, store.Audits, checker); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]model.UrlStatus{
+		"turned-bad": model.UrlStatusQuarantined,
+		"cleared":    model.UrlStatusActive,
+		"disabled":   model.UrlStatusDisabled,
+		"blocked":    model.UrlStatusBlocked,
+	}
+	for name, status := range want {
+		url, err := store.Urls.GetByCode(ctx, urls[name].Code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url.Status != status {
+			t.Errorf("%s link is %s, want %s", name, url.Status, status)
+		}
+		if name == "cleared" && url.SafetyReason != "" {
+			t.Errorf("cleared link keeps safety reason %q", url.SafetyReason)
+		}
+	}
+
+	events, err := store.Audits.Search(ctx, model.AuditFilter{Action: model.AuditActionLinkStatusChange, Pagination: model.Pagination{Limit: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := map[string]bool{}
+	for _, event := range events {
+		changed[event.TargetID] = true
+	}
+	if len(events) != 2 || !changed[urls["turned-bad"].Code] || !changed[urls["cleared"].Code] {
+		t.Errorf("audited status changes of %v, want the turned-bad and cleared links only", changed)
+	}
+
+	// Links aren't checked again before SAFETY_RESCAN_INTERVAL passed
+	if err := model.RescanUrls(ctx, store.Urls, store.Audits, fakeChecker{err: errors.New("not again")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRescanKeepsStatusWhenCheckFails(t *testing.T) {
+	ctx := context.Background()
+	config.Config.SAFETY.RescanInterval = time.Hour
+
+	store := memory.NewStore()
+	url := model.Url{UserID: 1, Code: "flagged", Url: "https://flagged.example/", Status: model.UrlStatusQuarantined, SafetyReason: "Domain is on the blocklist"}
+	if err := store.Urls.Save(ctx, &url); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := model.RescanUrls(ctx, store.Urls, store.Audits, fakeChecker{err: errors.New("list unavailable")}); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := store.Urls.GetByCode(ctx, url.Code)
+	if stored.Status != model.UrlStatusQuarantined || stored.SafetyReason != url.SafetyReason {
+		t.Errorf("link is %s (%q) after a failed check, want it unchanged", stored.Status, stored.SafetyReason)
+	}
+	if stored.ScannedAt.IsZero() {
+		t.Error("link not marked as scanned, it would be checked again right away")
+	}
+}
+
+func TestCheckSafetyDropsUnsafeOgImages(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		checker fakeChecker
+		image   string
+	}{
+		{"safe image", fakeChecker{}, "https://cdn.example/card.png"},
+		{"flagged image", fakeChecker{flagged: map[string]bool{"https://cdn.example/card.png": true}}, ""},
+		{"unchecked image", fakeChecker{err: errors.New("list unavailable")}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url := model.Url{Code: "card", Url: "https://example.com/", Status: model.UrlStatusActive, OgImage: "https://cdn.example/card.png"}
+			url.CheckSafety(ctx, test.checker)
+
+			if url.OgImage != test.image {
+				t.Errorf("og_image is %q, want %q", url.OgImage, test.image)
+			}
+			if url.Status != model.UrlStatusActive {
+				t.Errorf("link is %s, the image alone shouldn't quarantine it", url.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package model
+
+import (
+	"context"
+	"time"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+// Sentinel errors returned by every store implementation so callers can
+// tell "not found" apart from storage failures
+var (
+	ErrUrlNotFound          = utils.NotFound("url_not_found", "no URL found for the provided code")
+	ErrUserNotFound         = utils.NotFound("user_not_found", "User not found")
+	ErrOtpNotFound          = utils.BadRequest("otp_invalid", "Invalid OTP details !")
+	ErrRefreshTokenNotFound = utils.Unauthorized("refresh_token_invalid", "Refresh token not found")
+	ErrUserExists           = utils.Conflict("user_exists", "user already exists !")
+	ErrUrlCodeExists        = utils.Conflict("url_code_exists", "URL code already exists !")
+	ErrTotpNotFound         = utils.NotFound("totp_not_enrolled", "Two-factor authentication is not set up")
+	ErrIdentityNotFound     = utils.NotFound("identity_not_found", "No user linked to this identity")
+	ErrTombstoneNotFound    = utils.NotFound("tombstone_not_found", "No deleted user with this email")
+	ErrAbuseReportNotFound  = utils.NotFound("report_not_found", "Report not found")
+	ErrAbuseReportExists    = utils.Conflict("report_exists", "You already reported this link")
+)
+
+type UrlStore interface {
+	// GetByCode returns the URL with the code whatever its status
+	GetByCode(ctx context.Context, code string) (Url, error)
+	ListByUser(ctx context.Context, userID int64, filter GetUrlByUserFilter) ([]Url, error)
+	// Save inserts the URL, ErrUrlCodeExists if any URL has the code
+	Save(ctx context.Context, url *Url) error
+	// SaveAll inserts all URLs or none. The URL that failed is reported as
+	// a *UrlSaveError.
+	SaveAll(ctx context.Context, urls []*Url) error
+	// EachByUser calls fn with every URL of the user, oldest first, without
+	// loading them all at once
+	EachByUser(ctx context.Context, userID int64, fn func(Url) error) error
+	UpdateStatus(ctx context.Context, id int64, status UrlStatus) error
+	// UpdateSafety stores the outcome of a safety check. Only active and
+	// quarantined URLs are updated, updated is false for the others.
+	UpdateSafety(ctx context.Context, id int64, status UrlStatus, reason string, scannedAt time.Time) (updated bool, err error)
+	// ReplaceStatus sets the status and safety reason of the URL if its
+	// status is one of from. replaced is false otherwise, so concurrent
+	// changes only apply once.
+	ReplaceStatus(ctx context.Context, id int64, from []UrlStatus, status UrlStatus, reason string) (replaced bool, err error)
+	// ListForScan returns up to limit active or quarantined URLs that were
+	// not checked since scannedBefore, least recently checked first
+	ListForScan(ctx context.Context, scannedBefore time.Time, limit int) ([]Url, error)
+	// Search lists URLs of all users, newest first
+	Search(ctx context.Context, filter UrlSearchFilter) ([]Url, error)
+}
+
+type UserStore interface {
+	GetByEmail(ctx context.Context, email string) (User, error)
+	GetByID(ctx context.Context, id int64) (User, error)
+	// Save inserts the user; Password must already be hashed
+	Save(ctx context.Context, user *User) error
+	// Search lists users, newest first
+	Search(ctx context.Context, filter UserSearchFilter) ([]User, error)
+	UpdateRole(ctx context.Context, id int64, role UserRole) error
+	UpdateStatus(ctx context.Context, id int64, status UserStatus) error
+	// UpdatePassword stores a new password hash
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	// UpdateEmail returns ErrUserExists when the email is taken
+	UpdateEmail(ctx context.Context, id int64, email string) error
+	// UpdateClickPrivacy sets the user's privacy mode, empty for CLICK_PRIVACY
+	UpdateClickPrivacy(ctx context.Context, id int64, privacy ClickPrivacy) error
+	// Delete removes the user with their links, clicks, tokens, OTPs and
+	// identities, and records the tombstone, all or nothing
+	Delete(ctx context.Context, user User, tombstone UserTombstone) error
+	GetTombstone(ctx context.Context, emailHash string) (UserTombstone, error)
+}
+
+// StatsStore aggregates system wide counters
+type StatsStore interface {
+	System(ctx context.Context, since time.Time) (SystemStats, error)
+}
+
+type OtpStore interface {
+	GetPendingByToken(ctx context.Context, token string, action string) (Otp, error)
+	// ReserveAttempt counts a verification attempt on a pending OTP with
+	// fewer than maxAttempts and returns the new count, 0 when the OTP is no
+	// longer pending or has no attempts left
+	ReserveAttempt(ctx context.Context, id int64, maxAttempts int) (int, error)
+	// GetLatestPending returns the most recent pending OTP for key/type/action
+	GetLatestPending(ctx context.Context, key string, otpType OtpType, action OtpActionType) (Otp, error)
+	Save(ctx context.Context, otp *Otp) error
+	UpdateStatus(ctx context.Context, id int64, status OtpStatus) error
+	// Consume marks a pending OTP as used, false if it was no longer pending
+	Consume(ctx context.Context, id int64) (bool, error)
+}
+
+type AnalyticsStore interface {
+	// Save records the click and increments the URL click count
+	Save(ctx context.Context, analytics *Analytics) error
+	// ListByUser returns the clicks on all links of the user, oldest first
+	ListByUser(ctx context.Context, userID int64) ([]Analytics, error)
+	// CountDaily counts the clicks on the link per UTC day from from up to
+	// before to. Days without clicks are left out.
+	CountDaily(ctx context.Context, urlID int64, from time.Time, to time.Time) ([]DailyClicks, error)
+	// Oldest returns the time of the oldest click, zero without clicks
+	Oldest(ctx context.Context) (time.Time, error)
+	// Purge deletes up to limit clicks older than before and returns how
+	// many it deleted
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+	// ListClients returns up to limit clicks older than before with IDs
+	// above afterID, by ID
+	ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]ClickClient, error)
+	// UpdateClients replaces the IP addresses and user agents of the clicks
+	UpdateClients(ctx context.Context, clients []ClickClient) error
+}
+
+type RefreshTokenStore interface {
+	GetByToken(ctx context.Context, token string) (UserRefreshToken, error)
+	MarkAllUsed(ctx context.Context, userID int64) error
+	Save(ctx context.Context, token *UserRefreshToken) error
+}
+
+type TotpStore interface {
+	Get(ctx context.Context, userID int64) (UserTotp, error)
+	// SavePending stores an encrypted secret awaiting confirmation, an
+	// already enabled secret stays in use until then
+	SavePending(ctx context.Context, userID int64, pendingSecret string) error
+	// Activate promotes the pending secret, remembers step as used and
+	// replaces the recovery codes
+	Activate(ctx context.Context, userID int64, step int64, recoveryCodeHashes []string) error
+	// Delete removes the secret and all recovery codes
+	Delete(ctx context.Context, userID int64) error
+	// UseStep records the time step a code was accepted for, false when that
+	// step (or a later one) was already used
+	UseStep(ctx context.Context, userID int64, step int64) (bool, error)
+	// UseRecoveryCode consumes an unused recovery code, false if there is none
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID int64) (int, error)
+}
+
+// IdentityStore links users to accounts at external identity providers
+type IdentityStore interface {
+	GetByProviderSubject(ctx context.Context, provider string, subject string) (UserIdentity, error)
+	Save(ctx context.Context, identity *UserIdentity) error
+}
+
+// AuditStore is append-only, events can't be changed and are only removed
+// once they are past AUDIT_RETENTION
+type AuditStore interface {
+	Save(ctx context.Context, event *AuditEvent) error
+	// Search lists events, newest first
+	Search(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+	// Purge deletes up to limit events older than before and returns how
+	// many it deleted
+	Purge(ctx context.Context, before time.Time, limit int) (int64, error)
+	// ListClients lists the addresses of up to limit events after afterID
+	// created before before, by ID with the user of each event
+	ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]ClickClient, error)
+	UpdateClients(ctx context.Context, clients []ClickClient) error
+}
+
+// AbuseReportStore holds reports of malicious links
+type AbuseReportStore interface {
+	// Save returns ErrAbuseReportExists when the reporter already has an open
+	// report on the link
+	Save(ctx context.Context, report *AbuseReport) error
+	GetByID(ctx context.Context, id int64) (AbuseReport, error)
+	// Search lists reports, newest first
+	Search(ctx context.Context, filter AbuseReportFilter) ([]AbuseReport, error)
+	// CountVerifiedReporters returns the number of users with an open report
+	// on the URL, anonymous reports aren't counted
+	CountVerifiedReporters(ctx context.Context, urlID int64) (int, error)
+	// Resolve closes all open reports on the URL and returns how many it closed
+	Resolve(ctx context.Context, urlID int64, status AbuseReportStatus, resolvedBy int64, resolvedAt time.Time) (int64, error)
+	// ListClients lists the reporter addresses of up to limit resolved
+	// reports after afterID created before before, by ID with the reporter
+	ListClients(ctx context.Context, afterID int64, before time.Time, limit int) ([]ClickClient, error)
+	UpdateClients(ctx context.Context, clients []ClickClient) error
+}
+
+// CounterStore keeps short lived counters, e.g. failed login attempts
+type CounterStore interface {
+	// Incr increments the counter, starting its ttl when it is created
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// TTL returns how long the key lives, 0 if it does not exist
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Set(ctx context.Context, key string, value int64, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// RateLimit allows Rate requests per Period with up to Burst requests at once
+type RateLimit struct {
+	Rate   int
+	Burst  int
+	Period time.Duration
+}
+
+type RateLimitResult struct {
+	Limit      RateLimit
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration // How long to wait before the next request is allowed
+	ResetAfter time.Duration // How long until the limit is fully replenished
+}
+
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+	// AllowN counts n requests at once, either all of them are allowed or
+	// none is
+	AllowN(ctx context.Context, key string, limit RateLimit, n int) (RateLimitResult, error)
+}
+
+// Store groups every storage backend the handlers depend on
+type Store struct {
+	Urls          UrlStore
+	Users         UserStore
+	Otps          OtpStore
+	Analytics     AnalyticsStore
+	RefreshTokens RefreshTokenStore
+	RateLimiter   RateLimiter
+	Counters      CounterStore
+	Totps         TotpStore
+	Identities    IdentityStore
+	Stats         StatsStore
+	Audits        AuditStore
+	AbuseReports  AbuseReportStore
+	Visitors      VisitorStore
+	Rollups       RollupStore
+	Live          ClickStream
+}
@@ -0,0 +1,321 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// recoveryCodeAlphabet leaves out characters that are easy to mix up
+const recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// totpChallengeTTL is how long a sign-in waits for the second factor
+const totpChallengeTTL = 5 * time.Minute
+
+var errTotpChallengeInvalid = utils.Unauthorized("totp_challenge_invalid", "Sign-in has expired, please sign in again")
+
+type UserTotp struct {
+	UserID        int64     `json:"user_id"`
+	Secret        string    `json:"-"` // Encrypted with utils.Encrypt
+	PendingSecret string    `json:"-"` // Encrypted, awaiting confirmation
+	Enabled       bool      `json:"enabled"`
+	LastUsedStep  int64     `json:"-"`
+	CreatedAt     time.Time `json:"created_at"`
+	EnabledAt     time.Time `json:"enabled_at"`
+}
+
+// SecondFactor is either a code from the authenticator app or a recovery code
+type SecondFactor struct {
+	TotpCode     string `json:"totp_code" binding:"required_without=RecoveryCode" example:"123456"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=TotpCode" example:"ABCDE-23456"`
+}
+
+// TotpChallenge is sealed into the token handed out instead of a token pair
+// when a sign-in that doesn't ask for the second factor itself (magic link,
+// SSO) reaches an account with TOTP enabled
+type TotpChallenge struct {
+	UserID    int64     `json:"user_id"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type TotpChallengeResponse struct {
+	TotpRequired bool   `json:"totp_required" example:"true"`
+	Challenge    string `json:"challenge" example:"b64-encrypted-challenge"`
+}
+
+// CompleteTotpLogin exchanges a challenge and the second factor for a token pair
+type CompleteTotpLogin struct {
+	Challenge string `json:"challenge" binding:"required" example:"b64-encrypted-challenge"`
+	SecondFactor
+}
+
+type ConfirmTotp struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type TotpSetupResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	URI    string `json:"uri" example:"otpauth://totp/App:user@example.com?secret=JBSWY3DPEHPK3PXP"`
+	QRCode string `json:"qr_code" example:"data:image/png;base64,..."`
+}
+
+type TotpEnabledResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" example:"ABCDE-23456"`
+}
+
+type TotpStatusResponse struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+func totpIssuer() string {
+	if config.Config.TOTP.Issuer != "" {
+		return config.Config.TOTP.Issuer
+	}
+	return config.Config.APP.Name
+}
+
+// TotpStatus reports whether TOTP is enabled and how many recovery codes are left
+func (u *User) TotpStatus(ctx context.Context, totps TotpStore) (TotpStatusResponse, error) {
+	var status TotpStatusResponse
+
+	totp, totpErr := totps.Get(ctx, u.ID)
+	if errors.Is(totpErr, ErrTotpNotFound) {
+		return status, nil
+	}
+	if totpErr != nil {
+		return status, totpErr
+	}
+
+	status.Enabled = totp.Enabled
+	if totp.Enabled {
+		left, countErr := totps.CountRecoveryCodes(ctx, u.ID)
+		if countErr != nil {
+			return status, countErr
+		}
+		status.RecoveryCodesLeft = left
+	}
+
+	return status, nil
+}
+
+// BeginTotpEnrollment creates a new secret to be confirmed with ConfirmTotpEnrollment.
+// Users with TOTP already enabled have to re-enroll instead.
+func (u *User) BeginTotpEnrollment(ctx context.Context, totps TotpStore) (TotpSetupResponse, error) {
+	totp, totpErr := totps.Get(ctx, u.ID)
+	if totpErr != nil && !errors.Is(totpErr, ErrTotpNotFound) {
+		return TotpSetupResponse{}, totpErr
+	}
+	if totp.Enabled {
+		return TotpSetupResponse{}, utils.Conflict("totp_already_enabled", "Two-factor authentication is already enabled, re-enroll to change the authenticator")
+	}
+
+	return u.newPendingTotp(ctx, totps)
+}
+
+// ReenrollTotp checks the current second factor and creates a replacement
+// secret. The old one keeps working until the new one is confirmed.
+func (u *User) ReenrollTotp(ctx context.Context, totps TotpStore, factor SecondFactor) (TotpSetupResponse, error) {
+	verifyErr := u.VerifySecondFactor(ctx, totps, factor)
+	if verifyErr != nil {
+		return TotpSetupResponse{}, verifyErr
+	}
+
+	return u.newPendingTotp(ctx, totps)
+}
+
+func (u *User) newPendingTotp(ctx context.Context, totps TotpStore) (TotpSetupResponse, error) {
+	var setup TotpSetupResponse
+
+	secret, secretErr := utils.GenerateTotpSecret()
+	if secretErr != nil {
+		return setup, utils.Internal(secretErr)
+	}
+
+	encryptedSecret, encErr := utils.Encrypt(secret)
+	if encErr != nil {
+		return setup, utils.Internal(encErr)
+	}
+
+	saveErr := totps.SavePending(ctx, u.ID, encryptedSecret)
+	if saveErr != nil {
+		return setup, saveErr
+	}
+
+	setup.Secret = secret
+	setup.URI = utils.TotpURI(totpIssuer(), u.Email, secret)
+
+	qrCode, qrErr := utils.QRCodeDataURL(setup.URI)
+	if qrErr != nil {
+		utils.Log.Error("Error rendering TOTP QR code: ", qrErr) // The URI and secret are enough to enroll
+	}
+	setup.QRCode = qrCode
+
+	return setup, nil
+}
+
+// ConfirmTotpEnrollment enables the pending secret once the user proves their
+// app produces valid codes, and returns a fresh set of recovery codes
+func (u *User) ConfirmTotpEnrollment(ctx context.Context, totps TotpStore, code string) ([]string, error) {
+	totp, totpErr := totps.Get(ctx, u.ID)
+	if totpErr != nil {
+		return nil, totpErr
+	}
+	if totp.PendingSecret == "" {
+		return nil, utils.BadRequest("totp_not_pending", "No authenticator enrollment in progress")
+	}
+
+	secret, decErr := utils.Decrypt(totp.PendingSecret)
+	if decErr != nil {
+		return nil, utils.Internal(decErr)
+	}
+
+	step, ok := utils.ValidateTotp(secret, strings.TrimSpace(code), time.Now(), config.Config.TOTP.Skew)
+	if !ok {
+		return nil, utils.BadRequest("totp_code_invalid", "Invalid authenticator code")
+	}
+
+	recoveryCodes, hashes, codesErr := generateRecoveryCodes(config.Config.TOTP.RecoveryCodes)
+	if codesErr != nil {
+		return nil, utils.Internal(codesErr)
+	}
+
+	activateErr := totps.Activate(ctx, u.ID, step, hashes)
+	if activateErr != nil {
+		return nil, activateErr
+	}
+
+	return recoveryCodes, nil
+}
+
+// DisableTotp checks the current second factor and removes TOTP and recovery codes
+func (u *User) DisableTotp(ctx context.Context, totps TotpStore, factor SecondFactor) error {
+	verifyErr := u.VerifySecondFactor(ctx, totps, factor)
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	return totps.Delete(ctx, u.ID)
+}
+
+// HasTotp reports whether the user has TOTP enabled
+func (u *User) HasTotp(ctx context.Context, totps TotpStore) (bool, error) {
+	totp, totpErr := totps.Get(ctx, u.ID)
+	if errors.Is(totpErr, ErrTotpNotFound) {
+		return false, nil
+	}
+	return totp.Enabled, totpErr
+}
+
+// NewTotpChallenge seals a challenge for a sign-in by method that still needs
+// the second factor
+func (u *User) NewTotpChallenge(method string) (string, error) {
+	challenge, jsonErr := json.Marshal(TotpChallenge{UserID: u.ID, Method: method, ExpiresAt: time.Now().Add(totpChallengeTTL)})
+	if jsonErr != nil {
+		return "", utils.Internal(jsonErr)
+	}
+
+	sealed, encErr := utils.Encrypt(string(challenge))
+	if encErr != nil {
+		return "", utils.Internal(encErr)
+	}
+	return sealed, nil
+}
+
+// OpenTotpChallenge checks a challenge from NewTotpChallenge and loads the user it was issued for
+func OpenTotpChallenge(ctx context.Context, users UserStore, sealed string) (User, TotpChallenge, error) {
+	var challenge TotpChallenge
+
+	plain, decErr := utils.Decrypt(sealed)
+	if decErr != nil {
+		return User{}, challenge, errTotpChallengeInvalid.Wrap(decErr)
+	}
+	if jsonErr := json.Unmarshal([]byte(plain), &challenge); jsonErr != nil {
+		return User{}, challenge, errTotpChallengeInvalid.Wrap(jsonErr)
+	}
+	if challenge.UserID == 0 || time.Now().After(challenge.ExpiresAt) {
+		return User{}, challenge, errTotpChallengeInvalid
+	}
+
+	user, userErr := users.GetByID(ctx, challenge.UserID)
+	if errors.Is(userErr, ErrUserNotFound) {
+		return User{}, challenge, errTotpChallengeInvalid
+	}
+	return user, challenge, userErr
+}
+
+// VerifySecondFactor accepts a current TOTP code or an unused recovery code.
+// Each TOTP code and recovery code works only once.
+func (u *User) VerifySecondFactor(ctx context.Context, totps TotpStore, factor SecondFactor) error {
+	totp, totpErr := totps.Get(ctx, u.ID)
+	if totpErr != nil && !errors.Is(totpErr, ErrTotpNotFound) {
+		return totpErr
+	}
+	if !totp.Enabled {
+		return utils.BadRequest("totp_not_enabled", "Two-factor authentication is not enabled for this account")
+	}
+
+	if factor.TotpCode == "" {
+		code := normalizeRecoveryCode(factor.RecoveryCode)
+		used, useErr := totps.UseRecoveryCode(ctx, u.ID, utils.HashRecoveryCode(code))
+		if useErr == nil && !used {
+			// Codes generated by older releases were hashed with the OTP key
+			used, useErr = totps.UseRecoveryCode(ctx, u.ID, utils.HashOtp(code))
+		}
+		if useErr != nil {
+			return useErr
+		}
+		if !used {
+			return utils.Unauthorized("recovery_code_invalid", "Invalid or already used recovery code")
+		}
+		return nil
+	}
+
+	secret, decErr := utils.Decrypt(totp.Secret)
+	if decErr != nil {
+		return utils.Internal(decErr)
+	}
+
+	step, ok := utils.ValidateTotp(secret, strings.TrimSpace(factor.TotpCode), time.Now(), config.Config.TOTP.Skew)
+	if !ok {
+		return utils.Unauthorized("totp_code_invalid", "Invalid authenticator code")
+	}
+
+	fresh, stepErr := totps.UseStep(ctx, u.ID, step)
+	if stepErr != nil {
+		return stepErr
+	}
+	if !fresh {
+		return utils.Unauthorized("totp_code_used", "Authenticator code already used, please wait for the next one")
+	}
+
+	return nil
+}
+
+// generateRecoveryCodes returns codes formatted for the user and their hashes for storage
+func generateRecoveryCodes(count int) ([]string, []string, error) {
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		code, err := utils.GenerateOtpCode(10, recoveryCodeAlphabet)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, fmt.Sprintf("%s-%s", code[:5], code[5:]))
+		hashes = append(hashes, utils.HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
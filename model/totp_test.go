@@ -0,0 +1,155 @@
+package model_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// enrollTotp turns TOTP on for user and returns the secret and recovery codes
+func enrollTotp(t *testing.T, totps model.TotpStore, user *model.User) (string, []string) {
+	t.Helper()
+
+	app, tOTP := config.Config.APP, config.Config.TOTP
+	t.Cleanup(func() { config.Config.APP, config.Config.TOTP = app, tOTP })
+	config.Config.APP.EncryptionKey = "0123456789abcdef0123456789abcdef"
+	config.Config.TOTP.Skew = 1
+	config.Config.TOTP.RecoveryCodes = 3
+
+	setup, err := user.BeginTotpEnrollment(context.Background(), totps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := utils.TotpCode(setup.Secret, utils.TotpStep(time.Now()))
+	recoveryCodes, err := user.ConfirmTotpEnrollment(context.Background(), totps, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return setup.Secret, recoveryCodes
+}
+
+func TestTotpCodesWorkOnce(t *testing.T) {
+	ctx := context.Background()
+	totps := memory.NewTotpStore()
+	user := model.User{ID: 1, Email: "ivy@example.com"}
+	secret, _ := enrollTotp(t, totps, &user)
+
+	// The code that confirmed the enrollment is already used up
+	current, _ := utils.TotpCode(secret, utils.TotpStep(time.Now()))
+	err := user.VerifySecondFactor(ctx, totps, model.SecondFactor{TotpCode: current})
+	if code := utils.AsAppError(err).Code; code != "totp_code_used" {
+		t.Fatalf("replayed code: %v", err)
+	}
+
+	// The next one works, once
+	next, _ := utils.TotpCode(secret, utils.TotpStep(time.Now())+1)
+	if err := user.VerifySecondFactor(ctx, totps, model.SecondFactor{TotpCode: next}); err != nil {
+		t.Fatalf("next code: %v", err)
+	}
+	err = user.VerifySecondFactor(ctx, totps, model.SecondFactor{TotpCode: next})
+	if code := utils.AsAppError(err).Code; code != "totp_code_used" {
+		t.Fatalf("next code again: %v", err)
+	}
+
+	// Nor is an earlier code accepted once a later one was used
+	err = user.VerifySecondFactor(ctx, totps, model.SecondFactor{TotpCode: current})
+	if code := utils.AsAppError(err).Code; code != "totp_code_used" {
+		t.Fatalf("earlier code: %v", err)
+	}
+
+	err = user.VerifySecondFactor(ctx, totps, model.SecondFactor{TotpCode: "000000"})
+	if code := utils.AsAppError(err).Code; code != "totp_code_invalid" {
+		t.Fatalf("wrong code: %v", err)
+	}
+}
+
+func TestRecoveryCodesWorkOnce(t *testing.T) {
+	ctx := context.Background()
+	totps := memory.NewTotpStore()
+	user := model.User{ID: 1, Email: "ivy@example.com"}
+	_, recoveryCodes := enrollTotp(t, totps, &user)
+
+	// Codes are accepted without the dash and in lower case
+	typed := strings.ToLower(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+	if err := user.VerifySecondFactor(ctx, totps, model.SecondFactor{RecoveryCode: typed}); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	err := user.VerifySecondFactor(ctx, totps, model.SecondFactor{RecoveryCode: recoveryCodes[0]})
+	if code := utils.AsAppError(err).Code; code != "recovery_code_invalid" {
+		t.Fatalf("recovery code again: %v", err)
+	}
+
+	status, err := user.TotpStatus(ctx, totps)
+	if err != nil || status.RecoveryCodesLeft != len(recoveryCodes)-1 {
+		t.Fatalf("status after using a code: %+v %v", status, err)
+	}
+}
+
+func TestRecoveryCodesHaveTheirOwnKey(t *testing.T) {
+	ctx := context.Background()
+	otp := config.Config.OTP
+	t.Cleanup(func() { config.Config.OTP = otp })
+	config.Config.OTP.Secret = "otp-secret"
+
+	totps := memory.NewTotpStore()
+	user := model.User{ID: 1, Email: "ivy@example.com"}
+	_, recoveryCodes := enrollTotp(t, totps, &user)
+
+	// Changing the OTP key leaves recovery codes alone
+	config.Config.OTP.Secret = "rotated-otp-secret"
+	if err := user.VerifySecondFactor(ctx, totps, model.SecondFactor{RecoveryCode: recoveryCodes[0]}); err != nil {
+		t.Fatalf("recovery code after rotating OTP_SECRET: %v", err)
+	}
+
+	// Codes hashed with the OTP key by older releases still work, once
+	legacyUser := model.User{ID: 2, Email: "jack@example.com"}
+	legacy := "ABCDE23456"
+	if err := totps.SavePending(ctx, legacyUser.ID, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := totps.Activate(ctx, legacyUser.ID, 0, []string{utils.HashOtp(legacy)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := legacyUser.VerifySecondFactor(ctx, totps, model.SecondFactor{RecoveryCode: "abcde-23456"}); err != nil {
+		t.Fatalf("legacy recovery code: %v", err)
+	}
+	err := legacyUser.VerifySecondFactor(ctx, totps, model.SecondFactor{RecoveryCode: legacy})
+	if code := utils.AsAppError(err).Code; code != "recovery_code_invalid" {
+		t.Fatalf("legacy recovery code again: %v", err)
+	}
+}
+
+func TestTotpChallenge(t *testing.T) {
+	ctx := context.Background()
+	app := config.Config.APP
+	t.Cleanup(func() { config.Config.APP = app })
+	config.Config.APP.EncryptionKey = "0123456789abcdef0123456789abcdef"
+
+	users := memory.NewUserStore()
+	user := model.User{Email: "ivy@example.com", Status: model.UserStatusActive}
+	if err := users.Save(ctx, &user); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := user.NewTotpChallenge("magic_link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, opened, err := model.OpenTotpChallenge(ctx, users, challenge)
+	if err != nil || loaded.ID != user.ID || opened.Method != "magic_link" {
+		t.Fatalf("open challenge: %+v %+v %v", loaded, opened, err)
+	}
+
+	for name, sealed := range map[string]string{"empty": "", "tampered": "A" + challenge, "garbage": "not-a-challenge"} {
+		_, _, err := model.OpenTotpChallenge(ctx, users, sealed)
+		if code := utils.AsAppError(err).Code; code != "totp_challenge_invalid" {
+			t.Errorf("%s challenge: %v", name, err)
+		}
+	}
+}
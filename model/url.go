@@ -1,27 +1,28 @@
 package model
 
 import (
-	"database/sql"
-	"fmt"
-	"strings"
+	"context"
+	"errors"
 	"time"
 
-	"kgoel085.com/url-shortner/db"
 	"kgoel085.com/url-shortner/utils"
 )
 
 type UrlStatus string
 
 const (
-	UrlStatusActive   UrlStatus = "active"
-	UrlStatusInactive UrlStatus = "inactive"
-	UrlStatusDeleted  UrlStatus = "deleted"
-	UrlStatusExpired  UrlStatus = "expired"
+	UrlStatusActive      UrlStatus = "active"
+	UrlStatusInactive    UrlStatus = "inactive"
+	UrlStatusDeleted     UrlStatus = "deleted"
+	UrlStatusExpired     UrlStatus = "expired"
+	UrlStatusDisabled    UrlStatus = "disabled"    // Deactivated by staff
+	UrlStatusQuarantined UrlStatus = "quarantined" // Flagged by the safety checks, visitors see a warning first
+	UrlStatusBlocked     UrlStatus = "blocked"     // Disabled after abuse reports, visitors see a warning page
 )
 
 func (us UrlStatus) IsValid() bool {
 	switch us {
-	case UrlStatusActive, UrlStatusInactive, UrlStatusDeleted, UrlStatusExpired:
+	case UrlStatusActive, UrlStatusInactive, UrlStatusDeleted, UrlStatusExpired, UrlStatusDisabled, UrlStatusQuarantined, UrlStatusBlocked:
 		return true
 	}
 	return false
@@ -34,24 +35,45 @@ type Url struct {
 	Code       string    `json:"code" binding:"required,alphanum"`
 	Status     UrlStatus `json:"status" binding:"required"`
 	CreatedAt  time.Time `json:"created_at" binding:"required"`
-	ClickCount int64     `json:"click_count"`
+	ClickCount int64     `json:"click_count"` // Clicks of people, see BotClickCount
 	ExpiryAt   time.Time `json:"expires_at"`
+	// BotClickCount counts clicks of crawlers, scanners and health checkers
+	BotClickCount int64 `json:"bot_click_count,omitempty"`
+	// Description is chosen by the owner and shown on the preview page
+	Description string `json:"description,omitempty"`
+	// Open Graph tags served to chat apps and social networks unfurling the
+	// link, defaults are taken from the destination page
+	OgTitle       string `json:"og_title,omitempty"`
+	OgDescription string `json:"og_description,omitempty"`
+	OgImage       string `json:"og_image,omitempty"`
+	// SafetyReason says why the safety checks quarantined the link
+	SafetyReason string    `json:"safety_reason,omitempty"`
+	ScannedAt    time.Time `json:"-"` // Zero until the safety checks ran
 }
 
 type CreateShortUrl struct {
 	Url      string    `json:"url" binding:"required,http_url"`
 	ExpiryAt time.Time `json:"expires_at" binding:"omitempty"`
 	Code     string    `json:"code" binding:"omitempty,alphanum"`
-	UserID   int64     `json:"user_id"`
+	// Description is shown on the preview page of the link
+	Description string `json:"description" binding:"max=300"`
+	// Shown when the link is pasted into chat apps and social networks
+	OgTitle       string `json:"og_title" binding:"max=200"`
+	OgDescription string `json:"og_description" binding:"max=300"`
+	OgImage       string `json:"og_image" binding:"omitempty,http_url,max=2048"`
+	UserID        int64  `json:"user_id"`
 }
 
 type GetUrlByUserFilter struct {
-	Status UrlStatus `json:"status" binding:"omitempty,oneof=active inactive deleted expired"`
+	Status UrlStatus `json:"status" binding:"omitempty,oneof=active inactive deleted expired disabled quarantined blocked"`
+	// IncludeBots adds bot clicks to click_count, they are left out by default
+	IncludeBots bool `json:"include_bots"`
 }
 
 type UrlWithShortCode struct {
 	Url
-	ShortUrl string `json:"short_url"`
+	ShortUrl       string          `json:"short_url"`
+	UniqueVisitors *UniqueVisitors `json:"unique_visitors,omitempty"`
 }
 
 type GetUrlsByUserResponse struct {
@@ -59,149 +81,70 @@ type GetUrlsByUserResponse struct {
 }
 
 type CreateShortUrlResponse struct {
-	ShortUrl string `json:"short_url"`
+	ShortUrl     string    `json:"short_url"`
+	Status       UrlStatus `json:"status"`
+	SafetyReason string    `json:"safety_reason,omitempty"`
 }
 
-func GetUrlByCode(code string) (Url, error) {
-	var url Url
-	query := `SELECT id, user_id, url, code, status, created_at, expiry_at FROM url WHERE code=$1`
-
-	logStr := fmt.Sprintf("Get URL by Code from DB : %s, Code: %s, Timestamp: %s", query, code, time.Now().UTC())
-	utils.Log.Info(logStr)
-
-	rowErr := db.DB.QueryRow(query, code).Scan(&url.ID, &url.UserID, &url.Url, &url.Code, &url.Status, &url.CreatedAt, &url.ExpiryAt)
-	if rowErr != nil {
-		if rowErr == sql.ErrNoRows {
-			return url, fmt.Errorf("no URL found for the provided code")
-		}
-		errStr := fmt.Sprintf("Error while trying to get URL by code - %s !", rowErr.Error())
-		return url, fmt.Errorf("%s", errStr)
-	}
-
-	return url, nil
-}
-
-func (u *Url) UpdateStatus(status UrlStatus) error {
+func (u *Url) UpdateStatus(ctx context.Context, urls UrlStore, status UrlStatus) error {
 	if !status.IsValid() {
-		return fmt.Errorf("invalid URL status")
+		return utils.BadRequest("url_status_invalid", "invalid URL status")
 	}
-	query := `UPDATE url SET status=$1 WHERE id=$2`
 
-	logStr := fmt.Sprintf("Update URL status in DB : %s, ID: %d, New Status: %s, Timestamp: %s", query, u.ID, status, time.Now().UTC())
-	utils.Log.Info(logStr)
-
-	_, execErr := db.DB.Exec(query, u.Status, u.ID)
-	if execErr != nil {
-		errStr := fmt.Sprintf("Error while trying to update URL status - %s !", execErr.Error())
-		return fmt.Errorf("%s", errStr)
+	updateErr := urls.UpdateStatus(ctx, u.ID, status)
+	if updateErr != nil {
+		return updateErr
 	}
 
 	u.Status = status
 	return nil
 }
 
-func GetUrlsByUser(userID int64, filter GetUrlByUserFilter) ([]UrlWithShortCode, error) {
-	var urls []UrlWithShortCode
-
-	var args []interface{}
-	var conditions []string
-
-	// Base query
-	query := `SELECT id, user_id, url, code, status, created_at, expiry_at FROM url WHERE user_id=$1`
-	args = append(args, userID)
-
-	// Add status filter if provided
-	if filter.Status.IsValid() {
-		conditions = append(conditions, fmt.Sprintf("status=$%d", len(args)+1))
-		args = append(args, filter.Status)
-	}
-
-	// Append additional conditions
-	if len(conditions) > 0 {
-		query += " AND " + strings.Join(conditions, " AND ")
-	}
-
-	query += ` ORDER BY created_at DESC`
-
-	rows, err := db.DB.Query(query, args...)
+func GetUrlsByUser(ctx context.Context, urls UrlStore, visitors VisitorStore, userID int64, filter GetUrlByUserFilter) ([]UrlWithShortCode, error) {
+	userUrls, err := urls.ListByUser(ctx, userID, filter)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get URLs by user: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var url Url
-		var expiryAt sql.NullTime
-
-		if err := rows.Scan(&url.ID, &url.UserID, &url.Url, &url.Code, &url.Status, &url.CreatedAt, &expiryAt); err != nil {
-			return nil, fmt.Errorf("failed to scan URL: %w", err)
-		}
 
-		if expiryAt.Valid {
-			url.ExpiryAt = expiryAt.Time
+	var result []UrlWithShortCode
+	for _, url := range userUrls {
+		if filter.IncludeBots {
+			url.ClickCount += url.BotClickCount
 		} else {
-			url.ExpiryAt = time.Time{}
+			url.BotClickCount = 0
 		}
-
-		urls = append(urls, UrlWithShortCode{Url: url, ShortUrl: utils.GetShortUrl(url.Code)})
+		result = append(result, UrlWithShortCode{Url: url, ShortUrl: utils.GetShortUrl(url.Code)})
 	}
 
-	logStr := fmt.Sprintf("Get URLs by user from DB : %s, UserID: %d, Timestamp: %s", query, userID, time.Now().UTC())
-	utils.Log.Info(logStr)
-
-	return urls, nil
+	withUniqueVisitors(ctx, visitors, result)
+	return result, nil
 }
 
-func (u *CreateShortUrl) Validate() (Url, error) {
+// Validate builds the link to save. Codes stay taken whatever the status of
+// their link, so a short link that was deleted or expired never starts
+// leading somewhere else. Saving enforces the same for concurrent requests.
+func (u *CreateShortUrl) Validate(ctx context.Context, urls UrlStore) (Url, error) {
 	var url Url
 	u.Code = utils.GenerateSlug(u.Code, 20)
 
-	urlByCode, urlByCodeErr := getUrlByCode(u.Code)
-	if urlByCode.Code == u.Code || urlByCodeErr == nil {
-		return url, fmt.Errorf("URL code already exists !")
+	_, urlByCodeErr := urls.GetByCode(ctx, u.Code)
+	if urlByCodeErr == nil {
+		return url, ErrUrlCodeExists
+	}
+	if !errors.Is(urlByCodeErr, ErrUrlNotFound) {
+		return url, urlByCodeErr
 	}
 
 	return Url{
-		UserID:    u.UserID,
-		Url:       u.Url,
-		Code:      u.Code,
-		Status:    UrlStatusActive,
-		CreatedAt: time.Now(),
-		ExpiryAt:  u.ExpiryAt,
+		UserID:        u.UserID,
+		Url:           u.Url,
+		Code:          u.Code,
+		Status:        UrlStatusActive,
+		CreatedAt:     time.Now(),
+		ExpiryAt:      u.ExpiryAt,
+		Description:   u.Description,
+		OgTitle:       u.OgTitle,
+		OgDescription: u.OgDescription,
+		OgImage:       u.OgImage,
 	}, nil
 }
-
-func (u *Url) Save() error {
-
-	query := `INSERT INTO url (user_id, url, code, status, created_at, expiry_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
-
-	logStr := fmt.Sprintf("Save URL in DB : %s, Code: %s, Timestamp: %s", query, u.Code, time.Now().UTC())
-	utils.Log.Info(logStr)
-
-	rowErr := db.DB.QueryRow(query, u.UserID, u.Url, u.Code, u.Status, u.CreatedAt, u.ExpiryAt).Scan(&u.ID, &u.CreatedAt)
-	if rowErr != nil {
-		errStr := fmt.Sprintf("Error while trying to save URL - %s !", rowErr.Error())
-		return fmt.Errorf("%s", errStr)
-	}
-
-	return nil
-}
-
-func getUrlByCode(code string) (Url, error) {
-	var url Url
-	query := `SELECT id, user_id, url, code, status, created_at, expiry_at FROM url WHERE code=$1 AND status=$2`
-
-	logStr := fmt.Sprintf("Get URL by code from DB : %s, Code: %s, Timestamp: %s", query, code, time.Now().UTC())
-	utils.Log.Info(logStr)
-
-	rowErr := db.DB.QueryRow(query, code, UrlStatusActive).Scan(&url.ID, &url.UserID, &url.Url, &url.Code, &url.Status, &url.CreatedAt, &url.ExpiryAt)
-	if rowErr != nil {
-		if rowErr == sql.ErrNoRows {
-			return url, fmt.Errorf("no active URL found for the provided code")
-		}
-		errStr := fmt.Sprintf("Error while trying to get URL by code - %s !", rowErr.Error())
-		return url, fmt.Errorf("%s", errStr)
-	}
-
-	return url, nil
-}
@@ -0,0 +1,42 @@
+package model_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestCreateShortUrlValidateKeepsCodesTaken(t *testing.T) {
+	ctx := context.Background()
+
+	for _, status := range []model.UrlStatus{
+		model.UrlStatusActive,
+		model.UrlStatusInactive,
+		model.UrlStatusDeleted,
+		model.UrlStatusExpired,
+		model.UrlStatusDisabled,
+		model.UrlStatusQuarantined,
+		model.UrlStatusBlocked,
+	} {
+		t.Run(string(status), func(t *testing.T) {
+			urls := memory.NewUrlStore()
+			if err := urls.Save(ctx, &model.Url{UserID: 1, Code: "taken", Url: "https://old.example/", Status: status}); err != nil {
+				t.Fatal(err)
+			}
+
+			link := model.CreateShortUrl{UserID: 2, Url: "https://new.example/", Code: "taken"}
+			if _, err := link.Validate(ctx, urls); !errors.Is(err, model.ErrUrlCodeExists) {
+				t.Errorf("code of a %s link: %v, want %v", status, err, model.ErrUrlCodeExists)
+			}
+		})
+	}
+
+	link := model.CreateShortUrl{UserID: 2, Url: "https://new.example/", Code: "free"}
+	url, err := link.Validate(ctx, memory.NewUrlStore())
+	if err != nil || url.Code != "free" || url.Status != model.UrlStatusActive || url.UserID != 2 {
+		t.Errorf("free code: %+v %v", url, err)
+	}
+}
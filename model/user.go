@@ -1,20 +1,75 @@
 package model
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"kgoel085.com/url-shortner/config"
-	"kgoel085.com/url-shortner/db"
 	"kgoel085.com/url-shortner/utils"
 )
 
+type UserPlan string
+
+const (
+	UserPlanFree     UserPlan = "free"
+	UserPlanPro      UserPlan = "pro"
+	UserPlanBusiness UserPlan = "business"
+)
+
+// UserRole grants access to staff routes, every role includes the ones before it
+type UserRole string
+
+const (
+	UserRoleUser    UserRole = "user"
+	UserRoleSupport UserRole = "support"
+	UserRoleAdmin   UserRole = "admin"
+)
+
+var userRoleRanks = map[UserRole]int{
+	UserRoleUser:    1,
+	UserRoleSupport: 2,
+	UserRoleAdmin:   3,
+}
+
+func (r UserRole) IsValid() bool {
+	_, ok := userRoleRanks[r]
+	return ok
+}
+
+// Includes reports whether r grants at least the permissions of role
+func (r UserRole) Includes(role UserRole) bool {
+	return r.IsValid() && userRoleRanks[r] >= userRoleRanks[role]
+}
+
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+)
+
+func (us UserStatus) IsValid() bool {
+	switch us {
+	case UserStatusActive, UserStatusSuspended:
+		return true
+	}
+	return false
+}
+
+var ErrUserSuspended = utils.Forbidden("user_suspended", "This account has been suspended")
+
 type User struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email" binding:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        int64      `json:"id"`
+	Email     string     `json:"email" binding:"email"`
+	Password  string     `json:"-"`
+	Plan      UserPlan   `json:"plan"`
+	Role      UserRole   `json:"role"`
+	Status    UserStatus `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	// How clicks on the user's links are stored, empty follows CLICK_PRIVACY
+	ClickPrivacy ClickPrivacy `json:"click_privacy,omitempty"`
 }
 
 type UserRefreshToken struct {
@@ -41,9 +96,14 @@ type SignUpUser struct {
 	UserOtp
 }
 
+// LoginUser takes the emailed OTP, or instead a TOTP or recovery code when
+// the user has two-factor authentication enabled
 type LoginUser struct {
 	UserCredentials
-	UserOtp
+	OtpToken     string `json:"otp_token" binding:"required_without_all=TotpCode RecoveryCode"`
+	OtpCode      string `json:"otp_code" binding:"required_without_all=TotpCode RecoveryCode"`
+	TotpCode     string `json:"totp_code" example:"123456"`
+	RecoveryCode string `json:"recovery_code" example:"ABCDE-23456"`
 }
 
 type LoginUserResponse struct {
@@ -51,33 +111,63 @@ type LoginUserResponse struct {
 	RefreshToken string `json:"refresh_token" example:"JWT Refresh Token"`
 }
 
-func (u *User) Save() error {
-	userByEmail, userByEmailErr := GetUserByEmail(u.Email)
+func (u *User) Save(ctx context.Context, users UserStore) error {
+	userByEmail, userByEmailErr := users.GetByEmail(ctx, u.Email)
 	if userByEmail.Email == u.Email || userByEmailErr == nil {
-		return fmt.Errorf("user already exists !")
+		return ErrUserExists
+	}
+	if !errors.Is(userByEmailErr, ErrUserNotFound) {
+		return userByEmailErr
+	}
+	if reuseErr := checkEmailReusable(ctx, users, u.Email); reuseErr != nil {
+		return reuseErr
 	}
 
 	hashedPwd, hashPwdErr := utils.HashPwd(u.Password)
 	if hashPwdErr != nil {
-		errStr := fmt.Sprintf("Error while trying to hash - %s !", hashPwdErr.Error())
-		return fmt.Errorf("%s", errStr)
+		return utils.Internal(fmt.Errorf("Error while trying to hash - %w !", hashPwdErr))
 	}
 
-	query := `INSERT INTO users (email, password, created_at) VALUES ($1, $2, $3) RETURNING id, created_at`
-
-	logStr := fmt.Sprintf("Save user in DB : %s, Email: %s, Timestamp: %s", query, u.Email, time.Now().UTC())
-	utils.Log.Info(logStr)
+	toSave := *u
+	toSave.Password = hashedPwd
+	if toSave.Plan == "" {
+		toSave.Plan = UserPlanFree
+	}
+	if toSave.Role == "" {
+		toSave.Role = UserRoleUser
+	}
+	if toSave.Status == "" {
+		toSave.Status = UserStatusActive
+	}
+	saveErr := users.Save(ctx, &toSave)
+	if saveErr != nil {
+		return saveErr
+	}
 
-	rowErr := db.DB.QueryRow(query, u.Email, hashedPwd, time.Now().UTC()).Scan(&u.ID, &u.CreatedAt)
+	u.ID = toSave.ID
+	u.Plan = toSave.Plan
+	u.Role = toSave.Role
+	u.Status = toSave.Status
+	u.CreatedAt = toSave.CreatedAt
+	return nil
+}
 
-	return rowErr
+// CheckActive rejects suspended users
+func (u *User) CheckActive() error {
+	if u.Status == UserStatusSuspended {
+		return ErrUserSuspended
+	}
+	return nil
 }
 
 func (u *User) GenerateJWT() (string, error) {
-	return utils.GenerateLoginJWT(u.ID)
+	if err := u.CheckActive(); err != nil {
+		return "", err
+	}
+	return utils.GenerateLoginJWT(u.ID, string(u.Plan), string(u.Role))
 }
 
-func (u *User) GenerateRefreshJWT() (string, error) {
+func (u *User) GenerateRefreshJWT(ctx context.Context, tokens RefreshTokenStore) (string, error) {
 	token, tokenErr := utils.GenerateRefreshJWT(u.ID)
 	if tokenErr != nil {
 		return "", tokenErr
@@ -92,37 +182,50 @@ func (u *User) GenerateRefreshJWT() (string, error) {
 	token = encryptedToken
 
 	// Mark all records as used for this user
-	markUsedQuery := `UPDATE refresh_tokens SET is_used = TRUE WHERE user_id = $1`
-	_, markUsedErr := db.DB.Exec(markUsedQuery, u.ID)
+	markUsedErr := tokens.MarkAllUsed(ctx, u.ID)
 	if markUsedErr != nil {
-		errStr := fmt.Sprintf("Error marking old refresh tokens as used - %s !", markUsedErr.Error())
-		return "", fmt.Errorf("%s", errStr)
+		return "", markUsedErr
 	}
 
-	// Save refresh token in DB
+	// Save refresh token
 	expiryInMin := config.Config.JWT.RefreshExpiryMinutes
-	expiresAt := time.Now().Add(time.Duration(expiryInMin) * time.Minute)
-
-	query := `INSERT INTO refresh_tokens (user_id, token, expires_at, created_at) VALUES ($1, $2, $3, $4)`
-
-	logStr := fmt.Sprintf("Save refresh token in DB : %s, UserID: %d, ExpiresAt: %s", query, u.ID, expiresAt)
-	utils.Log.Info(logStr)
+	refreshToken := UserRefreshToken{
+		UserID:    u.ID,
+		Token:     token,
+		ExpiresAt: time.Now().Add(time.Duration(expiryInMin) * time.Minute),
+		CreatedAt: time.Now().UTC(),
+	}
 
-	_, rowErr := db.DB.Exec(query, u.ID, token, expiresAt, time.Now().UTC())
-	if rowErr != nil {
-		return "", rowErr
+	saveErr := tokens.Save(ctx, &refreshToken)
+	if saveErr != nil {
+		return "", saveErr
 	}
 
 	return token, nil
 }
 
-func (u *User) ValidateCredentials() error {
-	userByEmail, userByEmailErr := GetUserByEmail(u.Email)
+// errInvalidCredentials is returned for unknown emails and wrong passwords
+// alike, so logins don't tell which accounts exist
+var errInvalidCredentials = utils.Unauthorized("invalid_credentials", "Invalid email or password !")
+
+// unknownUserPwdHash is checked against for unknown emails, so they take as
+// long to refuse as wrong passwords
+const unknownUserPwdHash = "$2a$10$ZTedmg3XJE1JdbwR.QfSq.croU/PAhTxucxOj28Kwh6APoEVAfys2"
+
+func (u *User) ValidateCredentials(ctx context.Context, users UserStore) error {
+	userByEmail, userByEmailErr := users.GetByEmail(ctx, u.Email)
+	if errors.Is(userByEmailErr, ErrUserNotFound) {
+		utils.CheckHashPwd(u.Password, unknownUserPwdHash)
+		return errInvalidCredentials
+	}
 	if userByEmailErr != nil {
 		return userByEmailErr
 	}
 
 	u.ID = userByEmail.ID
+	u.Plan = userByEmail.Plan
+	u.Role = userByEmail.Role
+	u.Status = userByEmail.Status
 
 	userPwdHash := userByEmail.Password
 	userPwd := u.Password
@@ -130,68 +233,8 @@ func (u *User) ValidateCredentials() error {
 	// Check pwd
 	isValidPwd := utils.CheckHashPwd(userPwd, userPwdHash)
 	if !isValidPwd {
-		return fmt.Errorf("Invalid password !")
-	}
-
-	return nil
-}
-
-func GetRefreshTokenByToken(token string) (UserRefreshToken, error) {
-	var refreshToken UserRefreshToken
-
-	query := `SELECT id, token, expires_at, user_id, created_at, is_used FROM refresh_tokens WHERE token=$1`
-
-	logStr := fmt.Sprintf("Get refresh token from DB : %s, Timestamp: %s", query, time.Now().UTC())
-	utils.Log.Info(logStr)
-
-	rowErr := db.DB.QueryRow(query, token).Scan(&refreshToken.ID, &refreshToken.Token, &refreshToken.ExpiresAt, &refreshToken.UserID, &refreshToken.CreatedAt, &refreshToken.IsUsed)
-	if rowErr != nil {
-		if rowErr == sql.ErrNoRows {
-			return refreshToken, fmt.Errorf("Refresh token not found")
-		}
-		errStr := fmt.Sprintf("Error while trying to get refresh token - %s !", rowErr.Error())
-		return refreshToken, fmt.Errorf("%s", errStr)
-	}
-
-	return refreshToken, nil
-}
-
-func GetUserByEmail(email string) (User, error) {
-	var user User
-
-	query := `SELECT id, email, password, created_at FROM users WHERE email ILIKE $1`
-	row := db.DB.QueryRow(query, email)
-
-	logStr := fmt.Sprintf("Check User via EMAIL: %s, %s", query, email)
-	utils.Log.Info(logStr)
-	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return user, fmt.Errorf("User not found")
-		}
-		return user, err
-	}
-
-	return user, nil
-}
-
-func GetUserById(id int64) (User, error) {
-	var user User
-
-	query := `SELECT id, email, password, created_at FROM users WHERE id = $1`
-	row := db.DB.QueryRow(query, id)
-
-	logStr := fmt.Sprintf("Check User via ID: %s, %d", query, id)
-	utils.Log.Info(logStr)
-	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.CreatedAt)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return user, fmt.Errorf("User not found")
-		}
-		return user, err
+		return errInvalidCredentials
 	}
 
-	return user, nil
+	return u.CheckActive()
 }
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+// VisitorDayFormat names days in visitor counts and daily analytics
+const VisitorDayFormat = "2006-01-02"
+
+// VisitorStore counts unique visitors of links with HyperLogLogs, per day
+// and all-time. Visitors are hashes, never IP addresses or user agents.
+type VisitorStore interface {
+	// DailySalt returns the random salt of the day, created on first use.
+	// Salts are dropped once their day is over.
+	DailySalt(ctx context.Context, day string) ([]byte, error)
+	// TotalSalt returns the random salt of all-time counts, created on
+	// first use and kept, so returning visitors are counted once
+	TotalSalt(ctx context.Context) ([]byte, error)
+	// Add counts the visitor for the link on the day by its hash with the
+	// salt of the day, and all-time by its hash with the all-time salt
+	Add(ctx context.Context, urlID int64, day string, dayVisitor string, totalVisitor string) error
+	// CountTotal returns the all-time unique visitors of each link
+	CountTotal(ctx context.Context, urlIDs []int64) (map[int64]int64, error)
+	// CountDay returns the unique visitors of each link on the day
+	CountDay(ctx context.Context, urlIDs []int64, day string) (map[int64]int64, error)
+	// CountDays returns the unique visitors of the link on each of the days
+	CountDays(ctx context.Context, urlID int64, days []string) (map[string]int64, error)
+	// Delete drops the daily and all-time counts of the links
+	Delete(ctx context.Context, urlIDs []int64) error
+}
+
+// UniqueVisitors of a link
+type UniqueVisitors struct {
+	Today int64 `json:"today"`
+	Total int64 `json:"total"`
+}
+
+// VisitorDay returns the day of t in visitor counts, days are UTC
+func VisitorDay(t time.Time) string {
+	return t.UTC().Format(VisitorDayFormat)
+}
+
+// VisitorHash identifies a visitor on the day by a hash of IP address and
+// user agent with the salt of the day
+func VisitorHash(ctx context.Context, visitors VisitorStore, day string, ip string, userAgent string) (string, error) {
+	salt, saltErr := visitors.DailySalt(ctx, day)
+	if saltErr != nil {
+		return "", saltErr
+	}
+	return hashVisitor(salt, ip, userAgent), nil
+}
+
+// TotalVisitorHash identifies a visitor across days for the all-time
+// counts. It is only added to HyperLogLogs, which don't keep it, and never
+// stored with the click.
+func TotalVisitorHash(ctx context.Context, visitors VisitorStore, ip string, userAgent string) (string, error) {
+	salt, saltErr := visitors.TotalSalt(ctx)
+	if saltErr != nil {
+		return "", saltErr
+	}
+	return hashVisitor(salt, ip, userAgent), nil
+}
+
+func hashVisitor(salt []byte, ip string, userAgent string) string {
+	hash := sha256.New()
+	hash.Write(salt)
+	hash.Write([]byte(ip))
+	hash.Write([]byte{0})
+	hash.Write([]byte(userAgent))
+
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// forgetVisitors drops the unique visitor counts of the user's links. The
+// counts expire on their own when it fails.
+func forgetVisitors(ctx context.Context, visitors VisitorStore, urls []Url) {
+	if len(urls) == 0 {
+		return
+	}
+
+	ids := make([]int64, len(urls))
+	for i, url := range urls {
+		ids[i] = url.ID
+	}
+	if err := visitors.Delete(ctx, ids); err != nil {
+		utils.Log.Error("Failed to delete unique visitor counts: ", err)
+	}
+}
+
+// withUniqueVisitors fills the unique visitors of the links. Counts are
+// left out when they can't be read, the links are still listed.
+func withUniqueVisitors(ctx context.Context, visitors VisitorStore, urls []UrlWithShortCode) {
+	if len(urls) == 0 {
+		return
+	}
+
+	ids := make([]int64, len(urls))
+	for i, url := range urls {
+		ids[i] = url.ID
+	}
+
+	total, totalErr := visitors.CountTotal(ctx, ids)
+	if totalErr != nil {
+		utils.Log.Error("Failed to count unique visitors: ", totalErr)
+		return
+	}
+	today, todayErr := visitors.CountDay(ctx, ids, VisitorDay(time.Now()))
+	if todayErr != nil {
+		utils.Log.Error("Failed to count unique visitors of today: ", todayErr)
+		return
+	}
+
+	for i := range urls {
+		urls[i].UniqueVisitors = &UniqueVisitors{Today: today[urls[i].ID], Total: total[urls[i].ID]}
+	}
+}
@@ -0,0 +1,81 @@
+package model_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestAllTimeVisitorsCountReturningVisitorsOnce(t *testing.T) {
+	ctx := context.Background()
+	store, _, url := newClickedUrl(t)
+
+	const ip, userAgent = "203.0.113.7", "Mozilla/5.0 (X11; Linux x86_64)"
+
+	// The visitor came yesterday, with yesterday's salt
+	yesterday := model.VisitorDay(time.Now().AddDate(0, 0, -1))
+	dayHash, err := model.VisitorHash(ctx, store.Visitors, yesterday, ip, userAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	totalHash, err := model.TotalVisitorHash(ctx, store.Visitors, ip, userAgent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Visitors.Add(ctx, url.ID, yesterday, dayHash, totalHash); err != nil {
+		t.Fatal(err)
+	}
+
+	// and comes back today twice, with somebody else
+	clicks := []model.Analytics{
+		{UrlID: url.ID, IPAddress: ip, UserAgent: userAgent},
+		{UrlID: url.ID, IPAddress: ip, UserAgent: userAgent},
+		{UrlID: url.ID, IPAddress: "198.51.100.2", UserAgent: userAgent},
+	}
+	for i := range clicks {
+		if err := model.RecordClick(ctx, store, url, &clicks[i], model.ClickPrivacyFull); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if clicks[0].VisitorHash == dayHash {
+		t.Fatal("visitor hash of the click links it to the day before")
+	}
+
+	today := model.VisitorDay(time.Now())
+	days, _ := store.Visitors.CountDays(ctx, url.ID, []string{yesterday, today})
+	if days[yesterday] != 1 || days[today] != 2 {
+		t.Errorf("daily unique visitors %v, want 1 yesterday and 2 today", days)
+	}
+	total, _ := store.Visitors.CountTotal(ctx, []int64{url.ID})
+	if total[url.ID] != 2 {
+		t.Errorf("%d all-time unique visitors, want 2", total[url.ID])
+	}
+}
+
+func TestVisitorsNotCountedWithoutTracking(t *testing.T) {
+	ctx := context.Background()
+	store, _, url := newClickedUrl(t)
+
+	clicks := []struct {
+		click   model.Analytics
+		privacy model.ClickPrivacy
+	}{
+		{model.Analytics{UrlID: url.ID, IPAddress: "203.0.113.7", UserAgent: "Mozilla/5.0"}, model.ClickPrivacyNone},
+		{model.Analytics{UrlID: url.ID, IPAddress: "203.0.113.8", UserAgent: "curl/8.4.0", Bot: true}, model.ClickPrivacyFull},
+	}
+	for _, tt := range clicks {
+		if err := model.RecordClick(ctx, store, url, &tt.click, tt.privacy); err != nil {
+			t.Fatal(err)
+		}
+		if tt.click.VisitorHash != "" {
+			t.Errorf("click %+v has a visitor hash", tt.click)
+		}
+	}
+
+	total, _ := store.Visitors.CountTotal(ctx, []int64{url.ID})
+	if total[url.ID] != 0 {
+		t.Errorf("%d all-time unique visitors, want none", total[url.ID])
+	}
+}
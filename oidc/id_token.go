@@ -0,0 +1,78 @@
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Asymmetric algorithms only, an ID token signed with the client secret (HS*)
+// or not at all is rejected
+var idTokenAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+func (p *Provider) validateIDToken(ctx context.Context, rawToken string, issuer string, nonce string) (Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+
+	p.mu.Lock()
+	keys := p.keys
+	p.mu.Unlock()
+
+	token, err := parser.Parse(rawToken, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return keys.get(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return Claims{}, fmt.Errorf("invalid id token claims")
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return Claims{}, fmt.Errorf("id token nonce mismatch")
+	}
+
+	// With several audiences the token must say it was issued to us
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return Claims{}, fmt.Errorf("id token issued to %q", azp)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return Claims{}, fmt.Errorf("id token has no subject")
+	}
+
+	email, _ := claims["email"].(string)
+	name, _ := claims["name"].(string)
+
+	return Claims{
+		Subject:       subject,
+		Email:         email,
+		EmailVerified: isTrue(claims["email_verified"]),
+		Name:          name,
+	}, nil
+}
+
+// isTrue accepts booleans and the "true" strings some providers send
+func isTrue(value any) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+// Package oidc is a small OpenID Connect relying party: discovery, the
+// authorization code flow with PKCE and ID token validation against the
+// provider's JWKS.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+)
+
+// discoveryTTL is how long discovery documents are cached
+const discoveryTTL = time.Hour
+
+// Discovery is the subset of the provider metadata we rely on
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// Claims are the ID token claims used to find or create the user
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// AuthRequest holds what has to survive the redirect to the provider
+type AuthRequest struct {
+	Provider     string    `json:"provider"`
+	State        string    `json:"state"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+type Provider struct {
+	config config.OIDCProvider
+	client *http.Client
+
+	mu           sync.Mutex
+	discovery    *Discovery
+	discoveredAt time.Time
+	keys         *keySet
+}
+
+func NewProvider(cfg config.OIDCProvider, client *http.Client) *Provider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Provider{config: cfg, client: client}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// Discover fetches and caches <issuer>/.well-known/openid-configuration
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.discoveredAt) < discoveryTTL {
+		return p.discovery, nil
+	}
+
+	var discovery Discovery
+	if err := p.getJSON(ctx, p.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery for %s: %w", p.config.Name, err)
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("oidc discovery for %s: issuer mismatch %q", p.config.Name, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, fmt.Errorf("oidc discovery for %s: incomplete provider metadata", p.config.Name)
+	}
+
+	if p.keys == nil || p.keys.uri != discovery.JwksURI {
+		p.keys = newKeySet(discovery.JwksURI, p.getJSON)
+	}
+	p.discovery = &discovery
+	p.discoveredAt = time.Now()
+	return p.discovery, nil
+}
+
+// AuthCodeURL starts the authorization code flow. The returned AuthRequest
+// must be kept (e.g. in an encrypted cookie) until the callback.
+func (p *Provider) AuthCodeURL(ctx context.Context, ttl time.Duration) (string, AuthRequest, error) {
+	var req AuthRequest
+
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", req, err
+	}
+
+	req = AuthRequest{
+		Provider:     p.config.Name,
+		State:        randomString(),
+		Nonce:        randomString(),
+		CodeVerifier: randomString(),
+		ExpiresAt:    time.Now().Add(ttl),
+	}
+
+	challenge := sha256.Sum256([]byte(req.CodeVerifier))
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.redirectURL())
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", req.State)
+	params.Set("nonce", req.Nonce)
+	params.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	params.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), req, nil
+}
+
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems the authorization code and returns the validated ID token claims
+func (p *Provider) Exchange(ctx context.Context, code string, req AuthRequest) (Claims, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.redirectURL())
+	form.Set("code_verifier", req.CodeVerifier)
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID) // Public client
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	httpReq.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		httpReq.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return Claims{}, err
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&token); err != nil {
+		return Claims{}, fmt.Errorf("oidc token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return Claims{}, fmt.Errorf("oidc token exchange failed (%d): %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return Claims{}, fmt.Errorf("oidc token response has no id_token")
+	}
+
+	return p.validateIDToken(ctx, token.IDToken, discovery.Issuer, req.Nonce)
+}
+
+func (p *Provider) redirectURL() string {
+	if p.config.RedirectURL != "" {
+		return p.config.RedirectURL
+	}
+
+	scheme := "http"
+	if config.Config.APP.EnableHTTPS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s:%s/auth/oidc/%s/callback", scheme, config.Config.APP.Host, config.Config.APP.Port, p.config.Name)
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s responded %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
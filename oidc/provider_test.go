@@ -0,0 +1,156 @@
+package oidc_test
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/oidc"
+	"kgoel085.com/url-shortner/oidc/oidctest"
+)
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.NewServer(t, "client-1")
+	const nonce = "nonce-1"
+
+	tests := []struct {
+		name    string
+		idToken func() string
+		wantErr string
+	}{
+		{
+			name:    "valid",
+			idToken: func() string { return idp.Sign(idp.Claims(nonce, "sub-1", "erin@example.com")) },
+		},
+		{
+			name:    "wrong nonce",
+			idToken: func() string { return idp.Sign(idp.Claims("other-nonce", "sub-1", "erin@example.com")) },
+			wantErr: "nonce mismatch",
+		},
+		{
+			name: "wrong issuer",
+			idToken: func() string {
+				claims := idp.Claims(nonce, "sub-1", "erin@example.com")
+				claims["iss"] = "https://evil.example.com"
+				return idp.Sign(claims)
+			},
+			wantErr: "invalid issuer",
+		},
+		{
+			name: "wrong audience",
+			idToken: func() string {
+				claims := idp.Claims(nonce, "sub-1", "erin@example.com")
+				claims["aud"] = "client-2"
+				return idp.Sign(claims)
+			},
+			wantErr: "invalid audience",
+		},
+		{
+			name: "issued to another client",
+			idToken: func() string {
+				claims := idp.Claims(nonce, "sub-1", "erin@example.com")
+				claims["aud"] = []string{"client-1", "client-2"}
+				claims["azp"] = "client-2"
+				return idp.Sign(claims)
+			},
+			wantErr: "issued to",
+		},
+		{
+			name: "expired",
+			idToken: func() string {
+				claims := idp.Claims(nonce, "sub-1", "erin@example.com")
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return idp.Sign(claims)
+			},
+			wantErr: "expired",
+		},
+		{
+			name: "signed with the client secret",
+			idToken: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.Claims(nonce, "sub-1", "erin@example.com"))
+				signed, _ := token.SignedString([]byte("client-secret"))
+				return signed
+			},
+			wantErr: "signing method HS256 is invalid",
+		},
+		{
+			name: "unsigned",
+			idToken: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodNone, idp.Claims(nonce, "sub-1", "erin@example.com"))
+				signed, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return signed
+			},
+			wantErr: "signing method none is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := oidc.NewProvider(config.OIDCProvider{Name: "stub", Issuer: idp.Issuer(), ClientID: "client-1", Scopes: []string{"openid"}}, idp.Client())
+
+			authURL, authRequest, authErr := provider.AuthCodeURL(context.Background(), time.Minute)
+			if authErr != nil {
+				t.Fatal(authErr)
+			}
+			authRequest.Nonce = nonce
+			parsed, _ := url.Parse(authURL)
+
+			claims, err := provider.Exchange(context.Background(), idp.IssueCode(tt.idToken(), parsed.Query().Get("code_challenge")), authRequest)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Exchange() error = %v", err)
+				}
+				if claims.Subject != "sub-1" || claims.Email != "erin@example.com" || !claims.EmailVerified {
+					t.Fatalf("Exchange() claims = %+v", claims)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Exchange() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExchangeUnknownCode(t *testing.T) {
+	idp := oidctest.NewServer(t, "client-1")
+	provider := oidc.NewProvider(config.OIDCProvider{Name: "stub", Issuer: idp.Issuer(), ClientID: "client-1"}, idp.Client())
+
+	_, authRequest, authErr := provider.AuthCodeURL(context.Background(), time.Minute)
+	if authErr != nil {
+		t.Fatal(authErr)
+	}
+	if _, err := provider.Exchange(context.Background(), "made-up", authRequest); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("Exchange() error = %v, want invalid_grant", err)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := oidctest.NewServer(t, "client-1")
+	provider := oidc.NewProvider(config.OIDCProvider{Name: "stub", Issuer: idp.Issuer(), ClientID: "client-1", RedirectURL: "https://short.example.com/cb", Scopes: []string{"openid", "email"}}, idp.Client())
+
+	authURL, authRequest, err := provider.AuthCodeURL(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		idp.URL + "/authorize?",
+		"client_id=client-1",
+		"code_challenge_method=S256",
+		"scope=openid+email",
+		"state=" + authRequest.State,
+		"nonce=" + authRequest.Nonce,
+	} {
+		if !strings.Contains(authURL, want) {
+			t.Errorf("auth URL %s lacks %s", authURL, want)
+		}
+	}
+	if authRequest.CodeVerifier == "" || authRequest.State == authRequest.Nonce {
+		t.Fatalf("auth request = %+v", authRequest)
+	}
+}
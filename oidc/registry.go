@@ -0,0 +1,42 @@
+package oidc
+
+import (
+	"net/http"
+	"sort"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// Registry holds the configured providers by name
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry builds a provider for every entry in OIDC_PROVIDERS
+func NewRegistry(client *http.Client) *Registry {
+	registry := &Registry{providers: map[string]*Provider{}}
+	providers, skipped := config.OIDCProviders()
+	for _, skipErr := range skipped {
+		utils.Log.Warn(skipErr)
+	}
+	for _, cfg := range providers {
+		registry.providers[cfg.Name] = NewProvider(cfg, client)
+	}
+	return registry
+}
+
+func (r *Registry) Get(name string) (*Provider, bool) {
+	provider, ok := r.providers[name]
+	return provider, ok
+}
+
+// Names lists the configured providers
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,108 @@
+package preview
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const maxTextLength = 300
+
+// parseMetadata reads the head of the page. Open Graph tags win over the
+// title and description meta tags, since sites tailor them for previews.
+// metadata holds the defaults.
+func parseMetadata(body io.Reader, contentType string, base *url.URL, metadata model.PageMetadata) (model.PageMetadata, error) {
+	reader, charsetErr := charset.NewReader(body, contentType)
+	if charsetErr != nil {
+		return metadata, charsetErr
+	}
+
+	var title, ogTitle, description, ogDescription string
+	tokenizer := html.NewTokenizer(reader)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if tokenizer.Err() != io.EOF {
+				return metadata, tokenizer.Err()
+			}
+			return finishMetadata(metadata, title, ogTitle, description, ogDescription), nil
+
+		case html.TextToken:
+			if inTitle {
+				title += string(tokenizer.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return finishMetadata(metadata, title, ogTitle, description, ogDescription), nil
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			attrs := map[string]string{}
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				attrs[string(key)] = string(value)
+			}
+
+			switch string(name) {
+			case "title":
+				inTitle = title == ""
+			case "body":
+				return finishMetadata(metadata, title, ogTitle, description, ogDescription), nil
+			case "meta":
+				switch strings.ToLower(attrs["property"] + attrs["name"]) {
+				case "og:title":
+					ogTitle = attrs["content"]
+				case "og:description":
+					ogDescription = attrs["content"]
+				case "description":
+					description = attrs["content"]
+				}
+			case "link":
+				if isIconRel(attrs["rel"]) && attrs["href"] != "" {
+					if icon, err := base.Parse(attrs["href"]); err == nil && (icon.Scheme == "http" || icon.Scheme == "https") {
+						metadata.Favicon = icon.String()
+					}
+				}
+			}
+		}
+	}
+}
+
+func finishMetadata(metadata model.PageMetadata, title, ogTitle, description, ogDescription string) model.PageMetadata {
+	metadata.Title = cleanText(utils.FirstNonEmpty(ogTitle, title))
+	metadata.Description = cleanText(utils.FirstNonEmpty(ogDescription, description))
+	return metadata
+}
+
+// isIconRel matches "icon", "shortcut icon" and "apple-touch-icon"
+func isIconRel(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		if value == "icon" || value == "apple-touch-icon" {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanText collapses whitespace and shortens the text
+func cleanText(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxTextLength {
+		text = strings.TrimSpace(string(runes[:maxTextLength-1])) + "…"
+	}
+	return text
+}
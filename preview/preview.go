@@ -0,0 +1,159 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+)
+
+const maxCached = 1000
+
+type cacheEntry struct {
+	metadata  model.PageMetadata
+	expiresAt time.Time
+}
+
+// Fetcher reads titles, descriptions and favicons of destination pages.
+// Results, also failures, are cached for PREVIEW_CACHE_TTL so previews don't
+// hit destinations on every view.
+type Fetcher struct {
+	client *http.Client // Must not follow redirects, the fetcher does
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewFetcher creates a fetcher requesting pages with client, e.g.
+// safety.NewHTTPClient, which keeps previews away from private addresses
+func NewFetcher(client *http.Client) *Fetcher {
+	return &Fetcher{client: client, cache: map[string]cacheEntry{}}
+}
+
+var _ model.MetadataFetcher = (*Fetcher)(nil)
+
+func (f *Fetcher) Fetch(ctx context.Context, rawUrl string) (model.PageMetadata, error) {
+	if metadata, ok := f.cached(rawUrl); ok {
+		return metadata, nil
+	}
+
+	metadata, err := f.fetch(ctx, rawUrl)
+	f.store(rawUrl, metadata)
+	return metadata, err
+}
+
+func (f *Fetcher) fetch(ctx context.Context, rawUrl string) (model.PageMetadata, error) {
+	target, parseErr := url.Parse(rawUrl)
+	if parseErr != nil {
+		return model.PageMetadata{}, parseErr
+	}
+
+	// Redirects are followed as far as the safety checks follow them
+	maxRedirects := config.Config.SAFETY.MaxRedirects
+	for range maxRedirects + 1 {
+		resp, err := f.get(ctx, target)
+		if err != nil {
+			return model.PageMetadata{}, err
+		}
+
+		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+			location, locationErr := resp.Location()
+			resp.Body.Close()
+			if locationErr != nil {
+				return model.PageMetadata{}, locationErr
+			}
+			target = location
+			continue
+		}
+
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return model.PageMetadata{}, fmt.Errorf("destination answered %s", resp.Status)
+		}
+
+		metadata := model.PageMetadata{Favicon: target.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()}
+		if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+			return metadata, nil
+		}
+
+		body := io.LimitReader(resp.Body, config.Config.PREVIEW.MaxBytes)
+		return parseMetadata(body, resp.Header.Get("Content-Type"), target, metadata)
+	}
+
+	return model.PageMetadata{}, fmt.Errorf("more than %d redirects", maxRedirects)
+}
+
+func (f *Fetcher) get(ctx context.Context, target *url.URL) (*http.Response, error) {
+	fetchCtx, cancel := context.WithTimeout(ctx, config.Config.PREVIEW.FetchTimeout)
+	req, err := http.NewRequestWithContext(fetchCtx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("User-Agent", config.Config.APP.Name+" link preview")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (f *Fetcher) cached(rawUrl string) (model.PageMetadata, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, ok := f.cache[rawUrl]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return model.PageMetadata{}, false
+	}
+	return entry.metadata, true
+}
+
+func (f *Fetcher) store(rawUrl string, metadata model.PageMetadata) {
+	if config.Config.PREVIEW.CacheTTL <= 0 {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	if len(f.cache) >= maxCached {
+		for key, entry := range f.cache {
+			if now.After(entry.expiresAt) {
+				delete(f.cache, key)
+			}
+		}
+	}
+	// Still full of fresh entries, make room for one
+	for key := range f.cache {
+		if len(f.cache) < maxCached {
+			break
+		}
+		delete(f.cache, key)
+	}
+
+	f.cache[rawUrl] = cacheEntry{metadata: metadata, expiresAt: now.Add(config.Config.PREVIEW.CacheTTL)}
+}
+
+// cancelOnClose ends the request timeout once the body was read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,150 @@
+package preview
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+)
+
+func init() {
+	config.Config.PREVIEW.FetchTimeout = 5 * time.Second
+	config.Config.PREVIEW.CacheTTL = time.Hour
+	config.Config.PREVIEW.MaxBytes = 1 << 20
+}
+
+// newTestFetcher fetches with a client that, like safety.NewHTTPClient,
+// doesn't follow redirects but may connect to the loopback test server
+func newTestFetcher() *Fetcher {
+	return NewFetcher(&http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	})
+}
+
+func TestFetchMetadata(t *testing.T) {
+	pages := map[string]string{
+		"/plain": `<html><head><title>  Plain
+			page </title><meta name="description" content="About the page"></head><body><title>Not this</title></body></html>`,
+		"/og": `<html><head><title>Title</title><meta name="description" content="Description">
+			<meta property="og:title" content="OG title"><meta property="og:description" content="OG description">
+			<link rel="shortcut icon" href="/static/icon.png"></head></html>`,
+		"/script-icon": `<html><head><link rel="icon" href="javascript:alert(1)"><title>Icon</title></head></html>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image" {
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "<title>Not HTML</title>")
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		want model.PageMetadata
+	}{
+		{"/plain", model.PageMetadata{Title: "Plain page", Description: "About the page", Favicon: server.URL + "/favicon.ico"}},
+		{"/og", model.PageMetadata{Title: "OG title", Description: "OG description", Favicon: server.URL + "/static/icon.png"}},
+		{"/script-icon", model.PageMetadata{Title: "Icon", Favicon: server.URL + "/favicon.ico"}},
+		{"/image", model.PageMetadata{Favicon: server.URL + "/favicon.ico"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			metadata, err := newTestFetcher().Fetch(context.Background(), server.URL+tt.path)
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if metadata != tt.want {
+				t.Fatalf("Fetch() = %+v, want %+v", metadata, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	oldMax := config.Config.SAFETY.MaxRedirects
+	t.Cleanup(func() { config.Config.SAFETY.MaxRedirects = oldMax })
+	const maxRedirects = 3
+	config.Config.SAFETY.MaxRedirects = maxRedirects
+
+	// /hop/<n> redirects n more times before the page
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hops int
+		if _, err := fmt.Sscanf(r.URL.Path, "/hop/%d", &hops); err == nil && hops > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", hops-1), http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<title>Landed</title>")
+	}))
+	defer server.Close()
+
+	metadata, err := newTestFetcher().Fetch(context.Background(), fmt.Sprintf("%s/hop/%d", server.URL, maxRedirects))
+	if err != nil || metadata.Title != "Landed" {
+		t.Fatalf("%d redirects: %+v %v", maxRedirects, metadata, err)
+	}
+
+	_, err = newTestFetcher().Fetch(context.Background(), fmt.Sprintf("%s/hop/%d", server.URL, maxRedirects+1))
+	if err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Fatalf("%d redirects: error = %v", maxRedirects+1, err)
+	}
+}
+
+func TestFetchReadsAtMostMaxBytes(t *testing.T) {
+	maxBytes := config.Config.PREVIEW.MaxBytes
+	config.Config.PREVIEW.MaxBytes = 1024
+	t.Cleanup(func() { config.Config.PREVIEW.MaxBytes = maxBytes })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><!-- %s --><title>Too far</title></head></html>", strings.Repeat("x", 4096))
+	}))
+	defer server.Close()
+
+	metadata, err := newTestFetcher().Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if metadata.Title != "" {
+		t.Fatalf("title after PREVIEW_MAX_BYTES was read: %q", metadata.Title)
+	}
+}
+
+func TestFetchCaches(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<title>Cached</title>")
+	}))
+	defer server.Close()
+
+	fetcher := newTestFetcher()
+	var failures int
+	for range 3 {
+		if metadata, err := fetcher.Fetch(context.Background(), server.URL); err != nil || metadata.Title != "Cached" {
+			t.Fatalf("Fetch() = %+v, %v", metadata, err)
+		}
+		// Failures are cached as empty metadata, only the first fetch errors
+		if _, err := fetcher.Fetch(context.Background(), server.URL+"/missing"); err != nil {
+			failures++
+		}
+	}
+	if got := requests.Load(); got != 2 || failures != 1 {
+		t.Fatalf("destination requested %d times with %d failures, want 2 and 1", got, failures)
+	}
+}
@@ -11,6 +11,7 @@ type GRPCClientType string
 
 const (
 	EmailServiceClientType GRPCClientType = "email"
+	SmsServiceClientType   GRPCClientType = "sms"
 )
 
 func InitClients() {
@@ -25,4 +26,14 @@ func InitClients() {
 			utils.Log.Info("GRPC:: connected to email service")
 		}
 	}
+
+	if config.Config.GRPC.SmsServiceAddr != "" { // SMS GRPC service
+		utils.Log.Info("GRPC:: connecting to sms service at ", config.Config.GRPC.SmsServiceAddr)
+		_, err := ClientManager.Connect(string(SmsServiceClientType), config.Config.GRPC.SmsServiceAddr)
+		if err != nil {
+			utils.Log.Errorf("GRPC:: failed to connect to sms service: %v", err)
+		} else {
+			utils.Log.Info("GRPC:: connected to sms service")
+		}
+	}
 }
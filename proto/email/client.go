@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"kgoel085.com/url-shortner/config"
 	email "kgoel085.com/url-shortner/grpc/email" // Generated via 'protoc --go_out=grpc/email --go-grpc_out=grpc/email  proto/email/email.proto'
 	"kgoel085.com/url-shortner/proto"
 	"kgoel085.com/url-shortner/utils"
@@ -16,7 +17,9 @@ type GrpcSendEmailRequest struct {
 	ProjectId string
 }
 
-func SendEmailViaGRPC(req GrpcSendEmailRequest) error {
+// SendEmailViaGRPC sends the email, giving up once ctx is done or the
+// configured mail timeout elapses
+func SendEmailViaGRPC(ctx context.Context, req GrpcSendEmailRequest) error {
 	client, _ := proto.ClientManager.Get(string(proto.EmailServiceClientType))
 	if client == nil {
 		return errors.New("email service client not available")
@@ -26,7 +29,10 @@ func SendEmailViaGRPC(req GrpcSendEmailRequest) error {
 	emailClient := email.NewEmailServiceClient(client)
 
 	utils.Log.Info("Sending email via gRPC to ", emailClient)
-	resp, err := emailClient.SendEmail(context.Background(), &email.SendEmailRequest{
+	sendCtx, cancel := context.WithTimeout(ctx, config.Config.TIMEOUT.Mail)
+	defer cancel()
+
+	resp, err := emailClient.SendEmail(sendCtx, &email.SendEmailRequest{
 		ToEmail:   req.ToEmail,
 		Subject:   req.Subject,
 		Content:   req.Content,
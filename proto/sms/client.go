@@ -0,0 +1,46 @@
+package sms
+
+import (
+	"context"
+	"errors"
+
+	"kgoel085.com/url-shortner/config"
+	sms "kgoel085.com/url-shortner/grpc/sms" // Generated via 'protoc --go_out=grpc/sms --go-grpc_out=grpc/sms  proto/sms/sms.proto'
+	"kgoel085.com/url-shortner/proto"
+	"kgoel085.com/url-shortner/utils"
+)
+
+type GrpcSendSmsRequest struct {
+	ToPhone   string
+	Content   string
+	ProjectId string
+}
+
+// SendSmsViaGRPC sends the text message, giving up once ctx is done or the
+// configured mail timeout elapses
+func SendSmsViaGRPC(ctx context.Context, req GrpcSendSmsRequest) error {
+	client, _ := proto.ClientManager.Get(string(proto.SmsServiceClientType))
+	if client == nil {
+		return errors.New("sms service client not available")
+	}
+
+	utils.Log.Info("Using gRPC client to send sms to ", req.ToPhone)
+	smsClient := sms.NewSmsServiceClient(client)
+
+	sendCtx, cancel := context.WithTimeout(ctx, config.Config.TIMEOUT.Mail)
+	defer cancel()
+
+	resp, err := smsClient.SendSms(sendCtx, &sms.SendSmsRequest{
+		ToPhone:   req.ToPhone,
+		Content:   req.Content,
+		ProjectId: req.ProjectId,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Id == "" {
+		return errors.New("failed to send sms")
+	}
+
+	return nil
+}
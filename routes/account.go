@@ -0,0 +1,337 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/mail"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func (h *Handler) AccountRoutes(router *gin.RouterGroup) {
+	router.Use(h.Authenticate)
+
+	router.GET("/me", h.rateLimit(h.RateLimits.Default), h.handleGetMe)
+	router.POST("/change-password", h.rateLimit(h.RateLimits.Login), h.handleChangePassword)
+	router.POST("/change-email", h.rateLimit(h.RateLimits.Login), h.handleChangeEmail)
+	router.GET("/export", h.rateLimit(h.RateLimits.Default), h.handleRequestExport)
+	router.PUT("/privacy", h.rateLimit(h.RateLimits.Default), h.handleUpdateClickPrivacy)
+	router.DELETE("/me", h.rateLimit(h.RateLimits.Login), h.handleDeleteAccount)
+	router.GET("/audit", h.rateLimit(h.RateLimits.Default), h.handleUserAudit)
+}
+
+// @Summary      Current User
+// @Description  Returns the profile of the logged in user.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  model.APIResponse{data=model.UserProfileResponse} "Success"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Router       /user/me [get]
+func (h *Handler) handleGetMe(ctx *gin.Context) {
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	hasTotp, totpErr := user.HasTotp(ctx.Request.Context(), h.Store.Totps)
+	if totpErr != nil {
+		utils.HandleError(ctx, totpErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "User fetched successfully",
+		Data:    model.UserProfileResponse{User: user, TwoFactorEnabled: hasTotp},
+	})
+}
+
+// @Summary      Change Password
+// @Description  Replaces the password after checking the current one. Signs out all other sessions and returns a new token pair.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        changePassword  body  model.ChangePassword  true  "Current and new password"
+// @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"code\": \"password_unchanged\", \"message\": \"The new password must differ from the current one\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Wrong password" "Example: {\"code\": \"invalid_credentials\", \"message\": \"Current password is incorrect\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited or locked out" "Example: {\"code\": \"login_locked\", \"message\": \"Too many failed login attempts, please try again later\"}"
+// @Router       /user/change-password [post]
+func (h *Handler) handleChangePassword(ctx *gin.Context) {
+	var changePassword model.ChangePassword
+	payloadErr := ctx.ShouldBindJSON(&changePassword)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	guardErr := h.LoginGuard.Check(ctx.Request.Context(), user.Email, ctx.ClientIP())
+	if guardErr != nil {
+		utils.HandleError(ctx, guardErr)
+		return
+	}
+
+	changeErr := user.ChangePassword(ctx.Request.Context(), h.Store.Users, changePassword)
+	if changeErr != nil {
+		h.recordPasswordFailure(ctx, user, changeErr)
+		utils.HandleError(ctx, changeErr)
+		return
+	}
+	h.recordPasswordSuccess(ctx, user.Email)
+
+	utils.Log.Info("Password changed for user ", user.ID)
+	h.audit(ctx, user.AuditEvent(model.AuditActionPasswordChange))
+	h.respondWithNewSession(ctx, user, "Password changed successfully")
+}
+
+// @Summary      Change Email
+// @Description  Moves the account to a new email address. Request an OTP for the new address first via `/otp/send` with action `change_email`. The previous address is notified. Signs out all other sessions and returns a new token pair.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        changeEmail  body  model.ChangeEmail  true  "New email, password and OTP"
+// @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid OTP" "Example: {\"code\": \"otp_invalid\", \"message\": \"Invalid OTP token\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Wrong password" "Example: {\"code\": \"invalid_credentials\", \"message\": \"Current password is incorrect\"}"
+// @Failure      409  {object}  utils.ErrorResponse "Email taken" "Example: {\"code\": \"user_exists\", \"message\": \"user already exists !\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited or locked out" "Example: {\"code\": \"login_locked\", \"message\": \"Too many failed login attempts, please try again later\"}"
+// @Router       /user/change-email [post]
+func (h *Handler) handleChangeEmail(ctx *gin.Context) {
+	var changeEmail model.ChangeEmail
+	payloadErr := ctx.ShouldBindJSON(&changeEmail)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	guardErr := h.LoginGuard.Check(ctx.Request.Context(), user.Email, ctx.ClientIP())
+	if guardErr != nil {
+		utils.HandleError(ctx, guardErr)
+		return
+	}
+
+	oldEmail, changeErr := user.ChangeEmail(ctx.Request.Context(), h.Store.Users, h.Store.Otps, changeEmail)
+	if changeErr != nil {
+		h.recordPasswordFailure(ctx, user, changeErr)
+		utils.HandleError(ctx, changeErr)
+		return
+	}
+	h.recordPasswordSuccess(ctx, oldEmail)
+
+	utils.Log.Info("Email changed for user ", user.ID)
+	emailChange := user.AuditEvent(model.AuditActionEmailChange)
+	emailChange.Changes = map[string]model.AuditChange{"email": {Before: oldEmail, After: user.Email}}
+	h.audit(ctx, emailChange)
+	go mail.SendEmailChangedMail(context.WithoutCancel(ctx.Request.Context()), oldEmail, user)
+	h.respondWithNewSession(ctx, user, "Email changed successfully")
+}
+
+// recordPasswordFailure counts a wrong current password as a failed login, so
+// a stolen access token can't be used to guess the password
+func (h *Handler) recordPasswordFailure(ctx *gin.Context, user model.User, err error) {
+	if errors.Is(err, model.ErrCurrentPasswordInvalid) {
+		h.recordLoginFailure(ctx, user, err)
+	}
+}
+
+func (h *Handler) recordPasswordSuccess(ctx *gin.Context, email string) {
+	if successErr := h.LoginGuard.RecordSuccess(ctx.Request.Context(), email); successErr != nil {
+		utils.Log.Error("Error clearing failed logins: ", successErr)
+	}
+}
+
+// respondWithNewSession issues a fresh token pair. Issuing the refresh token
+// revokes every other one, signing out the user's other sessions.
+func (h *Handler) respondWithNewSession(ctx *gin.Context, user model.User, message string) {
+	token, tokenErr := user.GenerateJWT()
+	if tokenErr != nil {
+		utils.HandleError(ctx, tokenErr)
+		return
+	}
+
+	refreshToken, refreshTokenErr := user.GenerateRefreshJWT(ctx.Request.Context(), h.Store.RefreshTokens)
+	if refreshTokenErr != nil {
+		utils.HandleError(ctx, refreshTokenErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: message,
+		Data:    model.LoginUserResponse{Token: token, RefreshToken: refreshToken},
+	})
+}
+
+// @Summary      Click Privacy
+// @Description  Sets how much of the IP address and user agent of visitors is stored with new clicks on your links: `full`, `truncated` (IPv4 /24, IPv6 /48), `hashed` (keyed hash of the IP address) or `none`. Empty follows the server default `CLICK_PRIVACY`. Clicks of visitors sending `DNT: 1` or `Sec-GPC: 1` are always stored as `none`.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        clickPrivacy  body  model.UpdateClickPrivacy  true  "Privacy mode"
+// @Success      200  {object}  model.APIResponse{data=model.ClickPrivacySettings} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"code\": \"validation_failed\", \"message\": \"Request failed\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Router       /user/privacy [put]
+func (h *Handler) handleUpdateClickPrivacy(ctx *gin.Context) {
+	var update model.UpdateClickPrivacy
+	payloadErr := ctx.ShouldBindJSON(&update)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	oldPrivacy := user.ClickPrivacy
+	updateErr := user.SetClickPrivacy(ctx.Request.Context(), h.Store.Users, update.ClickPrivacy)
+	if updateErr != nil {
+		utils.HandleError(ctx, updateErr)
+		return
+	}
+	h.ClickPrivacy.Forget(user.ID)
+
+	privacyChange := user.AuditEvent(model.AuditActionClickPrivacyChange)
+	privacyChange.Changes = map[string]model.AuditChange{"click_privacy": {Before: oldPrivacy, After: user.ClickPrivacy}}
+	h.audit(ctx, privacyChange)
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Click privacy updated successfully",
+		Data:    model.ClickPrivacySettings{ClickPrivacy: user.ClickPrivacy, Effective: user.EffectiveClickPrivacy()},
+	})
+}
+
+// @Summary      Export Personal Data
+// @Description  Starts collecting the profile, links and click analytics of the user into a ZIP archive. A download link is emailed once it is ready. Clicks are exported without IP addresses or user agents. One export per `EXPORT_COOLDOWN`.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Produce      json
+// @Success      202  {object}  model.APIResponse "Export started"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Too many exports" "Example: {\"code\": \"export_recently_requested\", \"message\": \"An export was requested recently. Please check your email.\"}"
+// @Router       /user/export [get]
+func (h *Handler) handleRequestExport(ctx *gin.Context) {
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	requestErr := user.RequestExport(ctx.Request.Context(), h.Store.Counters)
+	if requestErr != nil {
+		utils.HandleError(ctx, requestErr)
+		return
+	}
+
+	h.audit(ctx, user.AuditEvent(model.AuditActionDataExport))
+	go h.runExport(context.WithoutCancel(ctx.Request.Context()), user)
+
+	ctx.JSON(http.StatusAccepted, model.APIResponse{
+		Message: "Export started. We will email you a download link once it is ready.",
+	})
+}
+
+func (h *Handler) runExport(ctx context.Context, user model.User) {
+	token, exportErr := user.Export(ctx, h.Store)
+	if exportErr != nil {
+		utils.Log.Error("Error exporting data of user ", user.ID, ": ", exportErr)
+		return
+	}
+
+	utils.Log.Info("Exported data of user ", user.ID)
+	mail.SendDataExportMail(ctx, user, token)
+}
+
+// @Summary      Download Personal Data
+// @Description  Downloads an export archive using the token from the emailed link. Links expire after `EXPORT_EXPIRY`.
+// @Tags         Auth
+// @Produce      application/zip
+// @Param        token  path  string  true  "Download token"
+// @Success      200  {file}    file "ZIP archive with profile.json, links.json and analytics.json"
+// @Failure      404  {object}  utils.ErrorResponse "Unknown link" "Example: {\"code\": \"export_not_found\", \"message\": \"Download link is invalid\"}"
+// @Failure      410  {object}  utils.ErrorResponse "Expired link" "Example: {\"code\": \"export_expired\", \"message\": \"Download link has expired. Please request a new export.\"}"
+// @Router       /user/export/{token} [get]
+func (h *Handler) handleDownloadExport(ctx *gin.Context) {
+	file, openErr := model.OpenExport(ctx.Param("token"))
+	if openErr != nil {
+		utils.HandleError(ctx, openErr)
+		return
+	}
+	defer file.Close()
+
+	info, statErr := file.Stat()
+	if statErr != nil {
+		utils.HandleError(ctx, utils.Internal(statErr))
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.DataFromReader(http.StatusOK, info.Size(), "application/zip", file, map[string]string{
+		"Content-Disposition": `attachment; filename="export-` + strconv.FormatInt(info.ModTime().Unix(), 10) + `.zip"`,
+	})
+}
+
+// @Summary      Delete Account
+// @Description  Permanently deletes the user with their links, click analytics, sessions, linked identities and pending export archives. Their audit events and abuse reports are kept without IP addresses, user agents and emails. Request an OTP for the account email first via `/otp/send` with action `delete_account`. The email can't sign up again for `DELETED_EMAIL_COOLDOWN`.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        deleteAccount  body  model.DeleteAccount  true  "OTP confirming the deletion"
+// @Success      200  {object}  model.APIResponse "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid OTP" "Example: {\"code\": \"otp_invalid\", \"message\": \"Invalid OTP token\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Router       /user/me [delete]
+func (h *Handler) handleDeleteAccount(ctx *gin.Context) {
+	var deleteAccount model.DeleteAccount
+	payloadErr := ctx.ShouldBindJSON(&deleteAccount)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	deleteErr := user.DeleteAccount(ctx.Request.Context(), h.Store, deleteAccount)
+	if deleteErr != nil {
+		utils.HandleError(ctx, deleteErr)
+		return
+	}
+
+	utils.Log.Info("Deleted user ", user.ID)
+	// Like the earlier events of the account, without the address and user agent
+	deleted := user.AuditEvent(model.AuditActionAccountDelete)
+	deleted.ActorID = user.ID
+	model.RecordAudit(ctx.Request.Context(), h.Store.Audits, deleted)
+	go mail.SendAccountDeletedMail(context.WithoutCancel(ctx.Request.Context()), user)
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Account deleted successfully",
+	})
+}
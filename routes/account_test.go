@@ -0,0 +1,126 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+
+	"kgoel085.com/url-shortner/config"
+)
+
+func TestChangePassword(t *testing.T) {
+	s := newTestServer(t)
+	auth := map[string]string{"Authorization": s.signUp("pat@example.com", "Passw0rd!")}
+
+	for name, tc := range map[string]struct {
+		current, new string
+		status       int
+		code         string
+	}{
+		"wrong password": {"Wrong0rd!", "N3wPassw0rd!", http.StatusUnauthorized, "invalid_credentials"},
+		"unchanged":      {"Passw0rd!", "Passw0rd!", http.StatusBadRequest, "password_unchanged"},
+		"weak password":  {"Passw0rd!", "short", http.StatusBadRequest, "validation_failed"},
+	} {
+		resp, out := s.do(http.MethodPost, "/user/change-password", map[string]any{"current_password": tc.current, "new_password": tc.new}, auth)
+		if resp.StatusCode != tc.status || out["code"] != tc.code {
+			t.Errorf("%s: %d %v", name, resp.StatusCode, out)
+		}
+	}
+
+	resp, out := s.do(http.MethodPost, "/user/change-password", map[string]any{"current_password": "Passw0rd!", "new_password": "N3wPassw0rd!"}, auth)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("change password: %d %v", resp.StatusCode, out)
+	}
+	if data, _ := out["data"].(map[string]any); data["token"] == "" || data["refresh_token"] == "" {
+		t.Errorf("change password returned no new session: %v", out)
+	}
+
+	token := s.sendOtp("pat@example.com", "login")
+	resp, out = s.do(http.MethodPost, "/user/login", map[string]any{"email": "pat@example.com", "password": "Passw0rd!", "otp_token": token, "otp_code": testOtpCode}, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("login with the old password: %d %v", resp.StatusCode, out)
+	}
+	resp, out = s.do(http.MethodPost, "/user/login", map[string]any{"email": "pat@example.com", "password": "N3wPassw0rd!", "otp_token": token, "otp_code": testOtpCode}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("login with the new password: %d %v", resp.StatusCode, out)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	s := newTestServer(t)
+	auth := map[string]string{"Authorization": s.signUp("quinn@example.com", "Passw0rd!")}
+	s.signUp("taken@example.com", "Passw0rd!")
+
+	changeEmail := func(newEmail, password, otpToken, otpCode string) (*http.Response, map[string]any) {
+		return s.do(http.MethodPost, "/user/change-email", map[string]any{"new_email": newEmail, "password": password, "otp_token": otpToken, "otp_code": otpCode}, auth)
+	}
+
+	token := s.sendOtp("new@example.com", "change_email")
+	if resp, out := changeEmail("new@example.com", "Wrong0rd!", token, testOtpCode); resp.StatusCode != http.StatusUnauthorized || out["code"] != "invalid_credentials" {
+		t.Errorf("wrong password: %d %v", resp.StatusCode, out)
+	}
+	if resp, out := changeEmail("quinn@example.com", "Passw0rd!", token, testOtpCode); resp.StatusCode != http.StatusBadRequest || out["code"] != "email_unchanged" {
+		t.Errorf("same address: %d %v", resp.StatusCode, out)
+	}
+	if resp, out := changeEmail("taken@example.com", "Passw0rd!", s.sendOtp("taken@example.com", "change_email"), testOtpCode); resp.StatusCode != http.StatusConflict || out["code"] != "user_exists" {
+		t.Errorf("address of another account: %d %v", resp.StatusCode, out)
+	}
+	// The OTP proves the new address, one sent to another address doesn't
+	if resp, out := changeEmail("other@example.com", "Passw0rd!", token, testOtpCode); resp.StatusCode == http.StatusOK {
+		t.Errorf("OTP of another address accepted: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out := changeEmail("new@example.com", "Passw0rd!", token, testOtpCode)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("change email: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out = s.do(http.MethodGet, "/user/me", nil, auth)
+	if data, _ := out["data"].(map[string]any); resp.StatusCode != http.StatusOK || data["email"] != "new@example.com" {
+		t.Errorf("profile after change: %d %v", resp.StatusCode, out)
+	}
+	s.login("new@example.com", "Passw0rd!")
+
+	// The OTP is used up
+	if resp, out := changeEmail("new@example.com", "Passw0rd!", token, testOtpCode); resp.StatusCode == http.StatusOK {
+		t.Errorf("OTP reused: %d %v", resp.StatusCode, out)
+	}
+}
+
+func TestWrongCurrentPasswordsLockTheAccount(t *testing.T) {
+	s := newTestServer(t)
+	auth := map[string]string{"Authorization": s.signUp("rene@example.com", "Passw0rd!")}
+
+	login := config.Config.LOGIN
+	t.Cleanup(func() { config.Config.LOGIN = login })
+	config.Config.LOGIN.FreeAttempts = 2
+	config.Config.LOGIN.MaxAttempts = 2
+
+	// Rejections that don't involve a wrong password aren't counted
+	for i := 0; i < 3; i++ {
+		resp, out := s.do(http.MethodPost, "/user/change-password", map[string]any{"current_password": "Passw0rd!", "new_password": "Passw0rd!"}, auth)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("unchanged password: %d %v", resp.StatusCode, out)
+		}
+	}
+
+	resp, out := s.do(http.MethodPost, "/user/change-password", map[string]any{"current_password": "Wrong0rd!", "new_password": "N3wPassw0rd!"}, auth)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("first wrong password: %d %v", resp.StatusCode, out)
+	}
+	token := s.sendOtp("new@example.com", "change_email")
+	resp, out = s.do(http.MethodPost, "/user/change-email", map[string]any{"new_email": "new@example.com", "password": "Wrong0rd!", "otp_token": token, "otp_code": testOtpCode}, auth)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("second wrong password: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out = s.do(http.MethodPost, "/user/change-password", map[string]any{"current_password": "Passw0rd!", "new_password": "N3wPassw0rd!"}, auth)
+	if resp.StatusCode != http.StatusTooManyRequests || out["code"] != "login_locked" {
+		t.Fatalf("change password of a locked account: %d %v", resp.StatusCode, out)
+	}
+
+	otpToken := s.sendOtp("rene@example.com", "login")
+	resp, out = s.do(http.MethodPost, "/user/login", map[string]any{"email": "rene@example.com", "password": "Passw0rd!", "otp_token": otpToken, "otp_code": testOtpCode}, nil)
+	if resp.StatusCode != http.StatusTooManyRequests || out["code"] != "login_locked" {
+		t.Fatalf("login to a locked account: %d %v", resp.StatusCode, out)
+	}
+}
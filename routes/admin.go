@@ -0,0 +1,339 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/middleware"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func (h *Handler) AdminRoutes(router *gin.RouterGroup) {
+	router.Use(h.Authenticate) // Before rate limiting so limits apply per user
+	router.Use(h.rateLimit(h.RateLimits.Default))
+
+	support := router.Group("")
+	support.Use(middleware.Authorize(h.Store.Users, model.UserRoleSupport))
+	support.GET("/users", h.handleAdminSearchUsers)
+	support.GET("/users/:id", h.handleAdminGetUser)
+	support.POST("/users/:id/suspend", h.handleAdminSuspendUser)
+	support.POST("/users/:id/unsuspend", h.handleAdminUnsuspendUser)
+	support.GET("/urls", h.handleAdminSearchUrls)
+	support.POST("/urls/:code/deactivate", h.handleAdminDeactivateUrl)
+	support.POST("/urls/:code/reactivate", h.handleAdminReactivateUrl)
+	support.GET("/audit", h.handleAdminSearchAudit)
+	support.GET("/reports", h.handleAdminSearchReports)
+	support.GET("/reports/:id", h.handleAdminGetReport)
+	support.POST("/reports/:id/dismiss", h.handleAdminDismissReport)
+	support.POST("/reports/:id/disable", h.handleAdminDisableReportedUrl)
+
+	admin := router.Group("")
+	admin.Use(middleware.Authorize(h.Store.Users, model.UserRoleAdmin))
+	admin.PUT("/users/:id/role", h.handleAdminUpdateUserRole)
+	admin.GET("/stats", h.handleAdminStats)
+}
+
+// @Summary      Search Users
+// @Description  Lists users, newest first. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        q       query  string  false  "Part of the email"
+// @Param        role    query  string  false  "Role"  Enums(user, support, admin)
+// @Param        status  query  string  false  "Status"  Enums(active, suspended)
+// @Param        limit   query  int     false  "Page size, up to 200"  default(50)
+// @Param        offset  query  int     false  "Results to skip"
+// @Success      200  {object}  model.APIResponse{data=model.AdminUsersResponse} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Forbidden" "Example: {\"code\": \"forbidden\", \"message\": \"You don't have permission to access this resource\"}"
+// @Router       /admin/users [get]
+func (h *Handler) handleAdminSearchUsers(ctx *gin.Context) {
+	var filter model.UserSearchFilter
+	bindErr := ctx.ShouldBindQuery(&filter)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+
+	users, usersErr := model.SearchUsers(ctx.Request.Context(), h.Store.Users, filter)
+	if usersErr != nil {
+		utils.HandleError(ctx, usersErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Users fetched successfully",
+		Data:    model.AdminUsersResponse{Users: users},
+	})
+}
+
+// @Summary      Get User
+// @Description  Returns a user with all their links. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  model.APIResponse{data=model.AdminUserResponse} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"user_not_found\", \"message\": \"User not found\"}"
+// @Router       /admin/users/{id} [get]
+func (h *Handler) handleAdminGetUser(ctx *gin.Context) {
+	user, userErr := h.adminTargetUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	urls, urlsErr := model.GetUrlsByUser(ctx.Request.Context(), h.Store.Urls, h.Store.Visitors, user.ID, model.GetUrlByUserFilter{})
+	if urlsErr != nil {
+		utils.HandleError(ctx, urlsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "User fetched successfully",
+		Data:    model.AdminUserResponse{User: user, Urls: urls},
+	})
+}
+
+// @Summary      Suspend User
+// @Description  Blocks the user from logging in and revokes their refresh tokens. Issued access tokens are refused within JWT_USER_STATUS_CACHE_TTL. Staff accounts can't be suspended. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  model.APIResponse{data=model.User} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Staff account" "Example: {\"code\": \"user_is_staff\", \"message\": \"Staff accounts can't be suspended, change their role first\"}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"user_not_found\", \"message\": \"User not found\"}"
+// @Router       /admin/users/{id}/suspend [post]
+func (h *Handler) handleAdminSuspendUser(ctx *gin.Context) {
+	user, userErr := h.adminTargetUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	oldStatus := user.Status
+	suspendErr := user.Suspend(ctx.Request.Context(), h.Store.Users, h.Store.RefreshTokens)
+	if suspendErr != nil {
+		utils.HandleError(ctx, suspendErr)
+		return
+	}
+
+	event := user.AuditEvent(model.AuditActionUserSuspend)
+	event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: user.Status}}
+	h.audit(ctx, event)
+
+	utils.Log.Info("User ", user.ID, " suspended by ", ctx.GetInt64(config.JWT_LOGGED_IN_USER))
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "User suspended",
+		Data:    user,
+	})
+}
+
+// @Summary      Unsuspend User
+// @Description  Lets a suspended user log in again. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        id  path  int  true  "User ID"
+// @Success      200  {object}  model.APIResponse{data=model.User} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"user_not_found\", \"message\": \"User not found\"}"
+// @Router       /admin/users/{id}/unsuspend [post]
+func (h *Handler) handleAdminUnsuspendUser(ctx *gin.Context) {
+	user, userErr := h.adminTargetUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	oldStatus := user.Status
+	unsuspendErr := user.Unsuspend(ctx.Request.Context(), h.Store.Users)
+	if unsuspendErr != nil {
+		utils.HandleError(ctx, unsuspendErr)
+		return
+	}
+
+	event := user.AuditEvent(model.AuditActionUserUnsuspend)
+	event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: user.Status}}
+	h.audit(ctx, event)
+
+	utils.Log.Info("User ", user.ID, " unsuspended by ", ctx.GetInt64(config.JWT_LOGGED_IN_USER))
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "User unsuspended",
+		Data:    user,
+	})
+}
+
+// @Summary      Change User Role
+// @Description  Grants or revokes a staff role. Admins can't change their own role. Needs the admin role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id    path  int                   true  "User ID"
+// @Param        role  body  model.UpdateUserRole  true  "New role"
+// @Success      200  {object}  model.APIResponse{data=model.User} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Own role" "Example: {\"code\": \"own_role\", \"message\": \"You can't change your own role\"}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"user_not_found\", \"message\": \"User not found\"}"
+// @Router       /admin/users/{id}/role [put]
+func (h *Handler) handleAdminUpdateUserRole(ctx *gin.Context) {
+	var updateRole model.UpdateUserRole
+	payloadErr := ctx.ShouldBindJSON(&updateRole)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.adminTargetUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	// Keeps the last admin from locking everybody out
+	if user.ID == ctx.GetInt64(config.JWT_LOGGED_IN_USER) {
+		utils.HandleError(ctx, utils.Forbidden("own_role", "You can't change your own role"))
+		return
+	}
+
+	oldRole := user.Role
+	roleErr := user.UpdateRole(ctx.Request.Context(), h.Store.Users, updateRole.Role)
+	if roleErr != nil {
+		utils.HandleError(ctx, roleErr)
+		return
+	}
+
+	event := user.AuditEvent(model.AuditActionUserRoleChange)
+	event.Changes = map[string]model.AuditChange{"role": {Before: oldRole, After: user.Role}}
+	h.audit(ctx, event)
+
+	utils.Log.Info("User ", user.ID, " role changed to ", user.Role, " by ", ctx.GetInt64(config.JWT_LOGGED_IN_USER))
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "User role updated",
+		Data:    user,
+	})
+}
+
+// @Summary      Search Links
+// @Description  Lists links of all users, newest first. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        q        query  string  false  "Part of the code or destination URL"
+// @Param        user_id  query  int     false  "Owner"
+// @Param        status   query  string  false  "Status"  Enums(active, inactive, deleted, expired, disabled, quarantined, blocked)
+// @Param        limit    query  int     false  "Page size, up to 200"  default(50)
+// @Param        offset   query  int     false  "Results to skip"
+// @Success      200  {object}  model.APIResponse{data=model.AdminUrlsResponse} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Forbidden" "Example: {\"code\": \"forbidden\", \"message\": \"You don't have permission to access this resource\"}"
+// @Router       /admin/urls [get]
+func (h *Handler) handleAdminSearchUrls(ctx *gin.Context) {
+	var filter model.UrlSearchFilter
+	bindErr := ctx.ShouldBindQuery(&filter)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+
+	urls, urlsErr := model.SearchUrls(ctx.Request.Context(), h.Store.Urls, filter)
+	if urlsErr != nil {
+		utils.HandleError(ctx, urlsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "URLs fetched successfully",
+		Data:    model.AdminUrlsResponse{Urls: urls},
+	})
+}
+
+// @Summary      Deactivate Link
+// @Description  Disables a link so it stops redirecting. Owners can't reactivate it. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        code  path  string  true  "Short code"
+// @Success      200  {object}  model.APIResponse{data=model.Url} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Router       /admin/urls/{code}/deactivate [post]
+func (h *Handler) handleAdminDeactivateUrl(ctx *gin.Context) {
+	h.adminUpdateUrlStatus(ctx, model.UrlStatusDisabled)
+}
+
+// @Summary      Reactivate Link
+// @Description  Re-enables a link disabled by staff or blocked after abuse reports. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        code  path  string  true  "Short code"
+// @Success      200  {object}  model.APIResponse{data=model.Url} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Failure      409  {object}  utils.ErrorResponse "Not disabled" "Example: {\"code\": \"url_not_disabled\", \"message\": \"Only disabled or blocked links can be reactivated\"}"
+// @Router       /admin/urls/{code}/reactivate [post]
+func (h *Handler) handleAdminReactivateUrl(ctx *gin.Context) {
+	h.adminUpdateUrlStatus(ctx, model.UrlStatusActive)
+}
+
+func (h *Handler) adminUpdateUrlStatus(ctx *gin.Context, status model.UrlStatus) {
+	url, urlErr := h.Store.Urls.GetByCode(ctx.Request.Context(), ctx.Param("code"))
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return
+	}
+
+	if status == model.UrlStatusActive && url.Status != model.UrlStatusDisabled && url.Status != model.UrlStatusBlocked {
+		utils.HandleError(ctx, utils.Conflict("url_not_disabled", "Only disabled or blocked links can be reactivated"))
+		return
+	}
+
+	oldStatus := url.Status
+	updateErr := url.UpdateStatus(ctx.Request.Context(), h.Store.Urls, status)
+	if updateErr != nil {
+		utils.HandleError(ctx, updateErr)
+		return
+	}
+
+	event := url.AuditEvent(model.AuditActionLinkStatusChange)
+	event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: url.Status}}
+	h.audit(ctx, event)
+
+	utils.Log.Info("URL ", url.Code, " set to ", status, " by ", ctx.GetInt64(config.JWT_LOGGED_IN_USER))
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "URL status updated",
+		Data:    url,
+	})
+}
+
+// @Summary      System Stats
+// @Description  System wide counts of users, links and clicks. Needs the admin role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Success      200  {object}  model.APIResponse{data=model.SystemStats} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Forbidden" "Example: {\"code\": \"forbidden\", \"message\": \"You don't have permission to access this resource\"}"
+// @Router       /admin/stats [get]
+func (h *Handler) handleAdminStats(ctx *gin.Context) {
+	stats, statsErr := h.Store.Stats.System(ctx.Request.Context(), time.Now().Add(-24*time.Hour))
+	if statsErr != nil {
+		utils.HandleError(ctx, statsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Stats fetched successfully",
+		Data:    stats,
+	})
+}
+
+// adminTargetUser loads the user from the :id path parameter
+func (h *Handler) adminTargetUser(ctx *gin.Context) (model.User, error) {
+	id, parseErr := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if parseErr != nil || id <= 0 {
+		return model.User{}, utils.BadRequest("user_id_invalid", "Invalid user ID")
+	}
+
+	return h.Store.Users.GetByID(ctx.Request.Context(), id)
+}
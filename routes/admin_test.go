@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+)
+
+// staffSession signs up a user with the role and returns its ID with the
+// access token of a login after the role was given
+func (s *testServer) staffSession(email string, role model.UserRole) (int64, map[string]string) {
+	s.t.Helper()
+
+	s.signUp(email, "Passw0rd!")
+	user, err := s.store.Users.GetByEmail(context.Background(), email)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := s.store.Users.UpdateRole(context.Background(), user.ID, role); err != nil {
+		s.t.Fatal(err)
+	}
+	return user.ID, map[string]string{"Authorization": s.login(email, "Passw0rd!")}
+}
+
+func TestAdminRoutesNeedStaffRoles(t *testing.T) {
+	s := newTestServer(t)
+	userID, user := s.staffSession("erin@example.com", model.UserRoleUser)
+	supportID, support := s.staffSession("sam@example.com", model.UserRoleSupport)
+	adminID, admin := s.staffSession("ada@example.com", model.UserRoleAdmin)
+
+	tests := []struct {
+		name    string
+		auth    map[string]string
+		method  string
+		path    string
+		body    any
+		status  int
+		errCode string
+	}{
+		{"user searches users", user, http.MethodGet, "/admin/users", nil, http.StatusForbidden, "forbidden"},
+		{"user makes themselves admin", user, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", userID), map[string]any{"role": "admin"}, http.StatusForbidden, "forbidden"},
+		{"support searches users", support, http.MethodGet, "/admin/users", nil, http.StatusOK, ""},
+		{"support reads stats", support, http.MethodGet, "/admin/stats", nil, http.StatusForbidden, "forbidden"},
+		{"support makes themselves admin", support, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", supportID), map[string]any{"role": "admin"}, http.StatusForbidden, "forbidden"},
+		{"support suspends admin", support, http.MethodPost, fmt.Sprintf("/admin/users/%d/suspend", adminID), nil, http.StatusForbidden, "user_is_staff"},
+		{"admin changes own role", admin, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", adminID), map[string]any{"role": "user"}, http.StatusForbidden, "own_role"},
+		{"admin reads stats", admin, http.MethodGet, "/admin/stats", nil, http.StatusOK, ""},
+		{"admin gives invalid role", admin, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", userID), map[string]any{"role": "root"}, http.StatusBadRequest, ""},
+		{"admin promotes user", admin, http.MethodPut, fmt.Sprintf("/admin/users/%d/role", userID), map[string]any{"role": "support"}, http.StatusOK, ""},
+		{"anonymous", nil, http.MethodGet, "/admin/users", nil, http.StatusUnauthorized, ""},
+	}
+	for _, test := range tests {
+		resp, out := s.do(test.method, test.path, test.body, test.auth)
+		if resp.StatusCode != test.status || (test.errCode != "" && out["code"] != test.errCode) {
+			t.Errorf("%s: %d %v, want %d %s", test.name, resp.StatusCode, out, test.status, test.errCode)
+		}
+	}
+
+	promoted, _ := s.store.Users.GetByID(context.Background(), userID)
+	if promoted.Role != model.UserRoleSupport {
+		t.Errorf("promoted user has role %s", promoted.Role)
+	}
+}
+
+func TestAdminRoutesCheckTheStoredUser(t *testing.T) {
+	s := newTestServer(t)
+	ttl := config.Config.JWT.UserStatusCacheTTL
+	config.Config.JWT.UserStatusCacheTTL = 0
+	t.Cleanup(func() { config.Config.JWT.UserStatusCacheTTL = ttl })
+
+	adminID, admin := s.staffSession("ada@example.com", model.UserRoleAdmin)
+	supportID, support := s.staffSession("sam@example.com", model.UserRoleSupport)
+
+	// The token still claims admin, the stored role decides
+	if err := s.store.Users.UpdateRole(context.Background(), adminID, model.UserRoleSupport); err != nil {
+		t.Fatal(err)
+	}
+	resp, out := s.do(http.MethodGet, "/admin/stats", nil, admin)
+	if resp.StatusCode != http.StatusForbidden || out["code"] != "forbidden" {
+		t.Fatalf("demoted admin: %d %v", resp.StatusCode, out)
+	}
+
+	if err := s.store.Users.UpdateStatus(context.Background(), supportID, model.UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	resp, out = s.do(http.MethodGet, "/admin/users", nil, support)
+	if resp.StatusCode != http.StatusForbidden || out["code"] != "user_suspended" {
+		t.Fatalf("suspended support: %d %v", resp.StatusCode, out)
+	}
+}
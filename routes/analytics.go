@@ -0,0 +1,56 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// @Summary      Link Analytics
+// @Description  Clicks and unique visitors of one of your links per day, the last 30 days unless from and to are given (up to 366 days). Clicks of bots are left out unless include_bots=true. Unique visitors are estimated from a hash of IP address and user agent with a salt that changes daily, so all-time counts a visitor once per day they came. Ranges over 7 days or past the raw click retention are read from daily rollups, which lag up to ANALYTICS_ROLLUP_INTERVAL behind. interval=hour adds clicks per hour for ranges of up to 7 days. Top referrer domains, countries and devices always come from the rollups.
+// @Security     BearerAuth
+// @Tags         URL
+// @Produce      json
+// @Param        code          path   string  true   "Short URL code"
+// @Param        from          query  string  false  "First day, YYYY-MM-DD (UTC)"
+// @Param        to            query  string  false  "Last day, YYYY-MM-DD (UTC), defaults to today"
+// @Param        include_bots  query  bool    false  "Count clicks of bots too"
+// @Param        interval      query  string  false  "Add clicks per hour" Enums(day, hour)
+// @Success      200  {object}  model.APIResponse{data=model.UrlAnalytics} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid range" "Example: {\"code\": \"analytics_range_invalid\", \"message\": \"from must not be after to\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Router       /url/{code}/analytics [get]
+func (h *Handler) handleUrlAnalytics(ctx *gin.Context) {
+	var filter model.UrlAnalyticsFilter
+	bindErr := ctx.ShouldBindQuery(&filter)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+
+	url, urlErr := h.Store.Urls.GetByCode(ctx.Request.Context(), ctx.Param("code"))
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return
+	}
+	// Links of other users don't exist as far as the caller is concerned
+	if url.UserID != ctx.GetInt64(config.JWT_LOGGED_IN_USER) {
+		utils.HandleError(ctx, model.ErrUrlNotFound)
+		return
+	}
+
+	analytics, analyticsErr := model.GetUrlAnalytics(ctx.Request.Context(), h.Store, url, filter)
+	if analyticsErr != nil {
+		utils.HandleError(ctx, analyticsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Analytics fetched successfully",
+		Data:    analytics,
+	})
+}
@@ -7,8 +7,10 @@ import (
 	"kgoel085.com/url-shortner/model"
 )
 
-func AppRoutes(router *gin.RouterGroup) {
-	router.GET("/ping", handlePing)
+func (h *Handler) AppRoutes(router *gin.RouterGroup) {
+	router.Use(h.rateLimit(h.RateLimits.Default))
+
+	router.GET("/ping", h.handlePing)
 }
 
 // @Summary      Ping
@@ -18,7 +20,7 @@ func AppRoutes(router *gin.RouterGroup) {
 // @Produce      json
 // @Success      200  {object}  model.APIResponse "Success" "Example: {\"message\": \"pong\"}"
 // @Router       /app/ping [get]
-func handlePing(c *gin.Context) {
+func (h *Handler) handlePing(c *gin.Context) {
 	c.JSON(http.StatusOK, model.APIResponse{
 		Message: "pong",
 	})
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// audit records an event caused by the current request. The logged in user
+// is the actor unless the event names one.
+func (h *Handler) audit(ctx *gin.Context, event model.AuditEvent) {
+	if event.ActorID == 0 {
+		event.ActorID = ctx.GetInt64(config.JWT_LOGGED_IN_USER)
+	}
+	event.IPAddress = ctx.ClientIP()
+	event.UserAgent = ctx.Request.UserAgent()
+
+	model.RecordAudit(ctx.Request.Context(), h.Store.Audits, event)
+}
+
+// auditLogin records a successful login of the user
+func (h *Handler) auditLogin(ctx *gin.Context, user model.User, method string) {
+	login := user.AuditEvent(model.AuditActionLogin)
+	login.ActorID = user.ID
+	login.Details = map[string]string{"method": method}
+	h.audit(ctx, login)
+}
+
+// auditOtpSent records an OTP request, attributed to the user the email
+// belongs to if there is one
+func (h *Handler) auditOtpSent(ctx *gin.Context, otp model.Otp) {
+	event := model.AuditEvent{
+		Action:     model.AuditActionOtpSent,
+		TargetType: model.AuditTargetOtp,
+		TargetID:   strconv.FormatInt(otp.ID, 10),
+		Details:    map[string]string{"type": string(otp.Type), "action": string(otp.Action)},
+	}
+
+	if otp.Type == model.OtpTypeEmail {
+		if user, userErr := h.Store.Users.GetByEmail(ctx.Request.Context(), otp.Key); userErr == nil {
+			event.UserID = user.ID
+		}
+	}
+
+	h.audit(ctx, event)
+}
+
+// @Summary      Account Activity
+// @Description  Lists the audit events of the logged in user, newest first: logins, failed logins, OTP requests, token refreshes, account and link changes.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Produce      json
+// @Param        action  query  string  false  "Action, e.g. login or login_failed"
+// @Param        since   query  string  false  "Events at or after this time (RFC 3339)"
+// @Param        until   query  string  false  "Events before this time (RFC 3339)"
+// @Param        limit   query  int     false  "Page size, up to 200"  default(50)
+// @Param        offset  query  int     false  "Results to skip"
+// @Success      200  {object}  model.APIResponse{data=model.AuditEventsResponse} "Success"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Router       /user/audit [get]
+func (h *Handler) handleUserAudit(ctx *gin.Context) {
+	var filter model.UserAuditFilter
+	bindErr := ctx.ShouldBindQuery(&filter)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+
+	events, eventsErr := model.UserAuditEvents(ctx.Request.Context(), h.Store.Audits, ctx.GetInt64(config.JWT_LOGGED_IN_USER), filter)
+	if eventsErr != nil {
+		utils.HandleError(ctx, eventsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Audit events fetched successfully",
+		Data:    model.AuditEventsResponse{Events: events},
+	})
+}
+
+// @Summary      Search Audit Log
+// @Description  Lists audit events of all users, newest first. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        user_id      query  int     false  "User the event concerns"
+// @Param        actor_id     query  int     false  "User who caused the event"
+// @Param        action       query  string  false  "Action, e.g. login_failed or link_status_change"
+// @Param        target_type  query  string  false  "Target type"  Enums(user, url, otp)
+// @Param        target_id    query  string  false  "Target ID"
+// @Param        ip           query  string  false  "Client IP address"
+// @Param        since        query  string  false  "Events at or after this time (RFC 3339)"
+// @Param        until        query  string  false  "Events before this time (RFC 3339)"
+// @Param        limit        query  int     false  "Page size, up to 200"  default(50)
+// @Param        offset       query  int     false  "Results to skip"
+// @Success      200  {object}  model.APIResponse{data=model.AuditEventsResponse} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Forbidden" "Example: {\"code\": \"forbidden\", \"message\": \"You don't have permission to access this resource\"}"
+// @Router       /admin/audit [get]
+func (h *Handler) handleAdminSearchAudit(ctx *gin.Context) {
+	var filter model.AuditFilter
+	bindErr := ctx.ShouldBindQuery(&filter)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+
+	events, eventsErr := model.SearchAuditEvents(ctx.Request.Context(), h.Store.Audits, filter)
+	if eventsErr != nil {
+		utils.HandleError(ctx, eventsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Audit events fetched successfully",
+		Data:    model.AuditEventsResponse{Events: events},
+	})
+}
@@ -0,0 +1,196 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/middleware"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// @Summary      Bulk Create Short URLs
+// @Description  Creates many short URLs at once from a JSON array or a CSV file, sent as the text/csv body or as the "file" field of a multipart form. CSV files need a header row with a url column and optionally code and expires_at; Bitly and TinyURL exports are accepted as is. Every row gets a result with the created code or its error. With mode=transaction nothing is created unless all rows succeed. Every row counts against the link creation rate limit, so an upload can have at most as many rows as the plan's burst and is refused with 429 when fewer links are left.
+// @Security     BearerAuth
+// @Tags         URL
+// @Accept       json,text/csv,multipart/form-data
+// @Produce      json
+// @Param        mode   query     string                 false  "best_effort creates the valid rows, transaction all rows or none"  Enums(best_effort, transaction)  default(best_effort)
+// @Param        links  body      []model.CreateShortUrl  false  "Links to create, when sending JSON"
+// @Param        file   formData  file                   false  "CSV file, when sending a multipart form"
+// @Success      200  {object}  model.APIResponse{data=model.BulkCreateUrlsResponse} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid upload" "Example: {\"code\": \"bulk_exceeds_rate_limit\", \"message\": \"Up to 10 links can be created at once on your plan\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      422  {object}  model.APIResponse{data=model.BulkCreateUrlsResponse} "Transaction failed, nothing was created"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /url/bulk [post]
+func (h *Handler) handleBulkCreateUrls(ctx *gin.Context) {
+	var query model.BulkCreateQuery
+	bindErr := ctx.ShouldBindQuery(&query)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+	if query.Mode == "" {
+		query.Mode = model.BulkModeBestEffort
+	}
+
+	rows, rowsErr := readBulkRows(ctx)
+	if rowsErr != nil {
+		utils.HandleError(ctx, rowsErr)
+		return
+	}
+	if len(rows) == 0 {
+		utils.HandleError(ctx, utils.BadRequest("bulk_empty", "No links to create"))
+		return
+	}
+	if len(rows) > config.Config.BULK.MaxRows {
+		utils.HandleError(ctx, utils.BadRequest("bulk_too_many_rows", fmt.Sprintf("Up to %d links can be created at once", config.Config.BULK.MaxRows)))
+		return
+	}
+
+	// Rows count like single links, a batch can't exceed the burst of the plan
+	policy := h.RateLimits.LinkCreate
+	if limit := policy.LimitFor(ctx); limit.Rate > 0 && len(rows) > limit.Burst {
+		utils.HandleError(ctx, utils.BadRequest("bulk_exceeds_rate_limit", fmt.Sprintf("Up to %d links can be created at once on your plan", limit.Burst)))
+		return
+	}
+	if limitErr := middleware.AllowN(ctx, h.Store.RateLimiter, policy, len(rows)); limitErr != nil {
+		utils.HandleError(ctx, limitErr)
+		return
+	}
+
+	for i := range rows {
+		if rows[i].Err == nil {
+			rows[i].Err = binding.Validator.ValidateStruct(&rows[i].Link)
+		}
+	}
+
+	response, created, createErr := model.CreateUrls(ctx.Request.Context(), h.Store.Urls, h.Safety, ctx.GetInt64(config.JWT_LOGGED_IN_USER), rows, query.Mode)
+	if createErr != nil {
+		utils.HandleError(ctx, createErr)
+		return
+	}
+
+	for _, url := range created {
+		event := url.AuditEvent(model.AuditActionLinkCreate)
+		event.Changes = map[string]model.AuditChange{"url": {After: url.Url}}
+		event.Details = map[string]string{"source": "bulk"}
+		if url.Status == model.UrlStatusQuarantined {
+			event.Details["quarantined"] = url.SafetyReason
+		}
+		h.audit(ctx, event)
+	}
+
+	status := http.StatusOK
+	message := fmt.Sprintf("%d of %d short URLs created", response.Created, len(rows))
+	if query.Mode == model.BulkModeTransaction && response.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+		message = "No short URLs created, fix the failed rows and try again"
+	}
+
+	ctx.JSON(status, model.APIResponse{
+		Message: message,
+		Data:    response,
+	})
+}
+
+// readBulkRows reads the links of a bulk request from its JSON or CSV body
+func readBulkRows(ctx *gin.Context) ([]model.BulkUrlRow, error) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, config.Config.BULK.MaxUploadBytes)
+
+	var rows []model.BulkUrlRow
+	var readErr error
+
+	switch ctx.ContentType() {
+	case binding.MIMEJSON:
+		var links []model.CreateShortUrl
+		if decodeErr := json.NewDecoder(ctx.Request.Body).Decode(&links); decodeErr != nil {
+			readErr = decodeErr
+			break
+		}
+		for _, link := range links {
+			rows = append(rows, model.BulkUrlRow{Link: link})
+		}
+	case "text/csv":
+		rows, readErr = model.ParseUrlsCSV(ctx.Request.Body)
+	case binding.MIMEMultipartPOSTForm:
+		file, fileErr := ctx.FormFile("file")
+		if fileErr != nil {
+			readErr = fileErr
+			break
+		}
+
+		upload, openErr := file.Open()
+		if openErr != nil {
+			return nil, utils.Internal(openErr)
+		}
+		defer upload.Close()
+
+		rows, readErr = model.ParseUrlsCSV(upload)
+	default:
+		return nil, utils.BadRequest("bulk_content_type_invalid", "Send links as application/json, text/csv or a multipart form with a CSV file")
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(readErr, &maxBytesErr) {
+		return nil, utils.BadRequest("bulk_upload_too_large", fmt.Sprintf("Uploads can be up to %d bytes", maxBytesErr.Limit))
+	}
+	if readErr != nil && utils.AsAppError(readErr).Kind == utils.ErrorKindInternal {
+		return nil, utils.BadRequest("invalid_request", readErr.Error())
+	}
+	return rows, readErr
+}
+
+// @Summary      Export Short URLs
+// @Description  Downloads all links of the authenticated user with their click counts, oldest first. The CSV export can be uploaded to /url/bulk again.
+// @Security     BearerAuth
+// @Tags         URL
+// @Produce      text/csv,json
+// @Param        format  query  string  false  "File format"  Enums(csv, json)  default(csv)
+// @Success      200  {array}   model.UrlWithShortCode "Links, as CSV unless format=json"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /url/export [get]
+func (h *Handler) handleExportUrls(ctx *gin.Context) {
+	var query model.ExportUrlsQuery
+	bindErr := ctx.ShouldBindQuery(&query)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+	if query.Format == "" {
+		query.Format = model.ExportFormatCSV
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if query.Format == model.ExportFormatJSON {
+		contentType = "application/json; charset=utf-8"
+	}
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="links-%s.%s"`, time.Now().UTC().Format("2006-01-02"), query.Format))
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Status(http.StatusOK)
+
+	exportErr := model.ExportUrls(ctx.Request.Context(), h.Store.Urls, ctx.GetInt64(config.JWT_LOGGED_IN_USER), query.Format, ctx.Writer)
+	if exportErr == nil {
+		return
+	}
+
+	// Once rows went out the status can't change anymore, the client sees a
+	// truncated file
+	if !ctx.Writer.Written() {
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		utils.HandleError(ctx, exportErr)
+		return
+	}
+	utils.Log.Error("Export of URLs failed: ", exportErr)
+	ctx.Abort()
+}
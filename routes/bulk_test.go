@@ -0,0 +1,50 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+)
+
+func bulkLinks(prefix string, n int) []map[string]any {
+	links := make([]map[string]any, n)
+	for i := range links {
+		links[i] = map[string]any{"url": fmt.Sprintf("https://example.com/%s/%d", prefix, i), "code": fmt.Sprintf("%s%d", prefix, i)}
+	}
+	return links
+}
+
+func TestBulkRowsCountAgainstLinkCreateLimit(t *testing.T) {
+	rule := config.Config.RATELIMIT.LinkCreate
+	config.Config.RATELIMIT.LinkCreate = config.RateLimitRule{Rate: 5, Burst: 5, Period: time.Hour}
+	t.Cleanup(func() { config.Config.RATELIMIT.LinkCreate = rule })
+
+	s := newTestServer(t)
+	auth := map[string]string{"Authorization": s.signUp("bulk@example.com", "Passw0rd!")}
+
+	resp, out := s.do(http.MethodPost, "/url/bulk", bulkLinks("big", 6), auth)
+	if resp.StatusCode != http.StatusBadRequest || out["code"] != "bulk_exceeds_rate_limit" {
+		t.Fatalf("6 rows with a burst of 5: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out = s.do(http.MethodPost, "/url/bulk", bulkLinks("ok", 3), auth)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("RateLimit-Remaining") != "2" {
+		t.Fatalf("3 rows: %d %q %v", resp.StatusCode, resp.Header.Get("RateLimit-Remaining"), out)
+	}
+	resp, out = s.do(http.MethodPost, "/url/register", map[string]any{"url": "https://example.com/single"}, auth)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("single link: %d %v", resp.StatusCode, out)
+	}
+
+	// One link left, the upload is refused as a whole
+	resp, out = s.do(http.MethodPost, "/url/bulk", bulkLinks("late", 2), auth)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("2 rows with 1 link left: %d %v", resp.StatusCode, out)
+	}
+	if resp, _ := s.do(http.MethodGet, "/late0", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("refused upload created links: %d", resp.StatusCode)
+	}
+}
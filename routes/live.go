@@ -0,0 +1,151 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// liveRetry tells EventSource clients how long to wait before reconnecting
+const liveRetry = 3 * time.Second
+
+// @Summary      Live Clicks
+// @Description  Streams the clicks on one of your links as they happen, as Server-Sent Events. Every click is a `click` event with the referrer domain, country, device and whether it came from a bot; nothing identifying the visitor is sent. Comments are sent every LIVE_HEARTBEAT_INTERVAL to keep the connection open. Clicks a slow reader can't keep up with are dropped and reported in a `dropped` event, readers not taking events within LIVE_WRITE_TIMEOUT are disconnected. Streams end after LIVE_MAX_DURATION with an `end` event and clients reconnect after the `retry` delay; they also end with an `end` event when the link is deleted or the account suspended. Each user can have LIVE_MAX_STREAMS streams open at once. Links are addressed by their code like the other /url routes. Clicks made while not connected are not replayed.
+// @Security     BearerAuth
+// @Tags         URL
+// @Produce      text/event-stream
+// @Param        code  path  string  true  "Short URL code"
+// @Success      200  {string}  string "event: click\ndata: {\"code\":\"abc\",\"at\":\"2026-01-01T12:00:00Z\",\"bot\":false,\"country\":\"DE\",\"device\":\"mobile\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Too many streams" "Example: {\"code\": \"live_streams_exceeded\", \"message\": \"Too many live streams open at once, close one first\"}"
+// @Router       /url/{code}/live [get]
+func (h *Handler) handleLiveClicks(ctx *gin.Context) {
+	userID := ctx.GetInt64(config.JWT_LOGGED_IN_USER)
+	url, urlErr := model.WatchableUrl(ctx.Request.Context(), h.Store, ctx.Param("code"), userID)
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return
+	}
+
+	if err := h.LiveViewers.Acquire(userID); err != nil {
+		utils.HandleError(ctx, err)
+		return
+	}
+	defer h.LiveViewers.Release(userID)
+
+	sub, subErr := model.WatchClicks(ctx.Request.Context(), h.Store.Live, url.ID)
+	if subErr != nil {
+		utils.HandleError(ctx, subErr)
+		return
+	}
+	defer sub.Close()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no") // Nginx would buffer the stream otherwise
+	ctx.Status(http.StatusOK)
+
+	stream := liveStream{ctx: ctx, controller: http.NewResponseController(ctx.Writer)}
+	if !stream.write(fmt.Sprintf("retry: %d\n\n", liveRetry.Milliseconds())) {
+		return
+	}
+
+	heartbeat := time.NewTicker(max(config.Config.LIVE.HeartbeatInterval, time.Second))
+	defer heartbeat.Stop()
+	var end <-chan time.Time
+	if maxDuration := config.Config.LIVE.MaxDuration; maxDuration > 0 {
+		timer := time.NewTimer(maxDuration)
+		defer timer.Stop()
+		end = timer.C
+	}
+
+	utils.Log.Info("Streaming live clicks of ", url.Code, " to user ", url.UserID)
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-end:
+			stream.event("end", gin.H{"reason": "max_duration"})
+			return
+		case <-heartbeat.C:
+			// Deleting the link or suspending its owner ends the stream
+			if !h.stillWatchable(ctx, url) {
+				stream.event("end", gin.H{"reason": "unavailable"})
+				return
+			}
+			if !stream.dropped(sub) || !stream.write(": heartbeat\n\n") {
+				return
+			}
+		case click := <-sub.Events():
+			if !stream.dropped(sub) || !stream.event("click", click) {
+				return
+			}
+		}
+	}
+}
+
+// stillWatchable checks the link and its owner again. Errors reading them
+// keep the stream open, the next heartbeat checks again.
+func (h *Handler) stillWatchable(ctx *gin.Context, url model.Url) bool {
+	_, err := model.WatchableUrl(ctx.Request.Context(), h.Store, url.Code, url.UserID)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, model.ErrUrlNotFound), errors.Is(err, model.ErrUserNotFound), errors.Is(err, model.ErrUserSuspended):
+		utils.Log.Info("Ending live stream of ", url.Code, ": ", err)
+		return false
+	default:
+		utils.Log.Error("Error checking live stream of ", url.Code, ": ", err)
+		return true
+	}
+}
+
+// liveStream writes Server-Sent Events. Every write must reach the client
+// within LIVE_WRITE_TIMEOUT, a stalled client ends the stream instead of
+// piling up clicks.
+type liveStream struct {
+	ctx        *gin.Context
+	controller *http.ResponseController
+}
+
+func (s liveStream) write(data string) bool {
+	if timeout := config.Config.LIVE.WriteTimeout; timeout > 0 {
+		_ = s.controller.SetWriteDeadline(time.Now().Add(timeout)) // Not every writer supports deadlines
+	}
+
+	if _, err := s.ctx.Writer.WriteString(data); err != nil {
+		utils.Log.Info("Live stream closed: ", err)
+		return false
+	}
+	if err := s.controller.Flush(); err != nil {
+		utils.Log.Info("Live stream closed: ", err)
+		return false
+	}
+	return true
+}
+
+func (s liveStream) event(name string, data any) bool {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		utils.Log.Error("Error encoding live event: ", err)
+		return true
+	}
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", name, payload))
+}
+
+// dropped reports the clicks dropped since the last event, if any
+func (s liveStream) dropped(sub *model.LiveSubscription) bool {
+	if dropped := sub.Dropped(); dropped > 0 {
+		return s.event("dropped", gin.H{"dropped": dropped})
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package routes
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+)
+
+// openLiveStream starts watching the link and returns the status and the
+// lines of the stream
+func (s *testServer) openLiveStream(code string, jwt string) (int, <-chan string) {
+	s.t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"/url/"+code+"/live", nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("Authorization", jwt)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	lines := make(chan string, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return resp.StatusCode, lines
+}
+
+// nextEvent returns the name and data of the next event of the stream
+func nextEvent(t *testing.T, lines <-chan string) (string, string) {
+	t.Helper()
+
+	var name string
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if event, found := strings.CutPrefix(line, "event: "); found {
+				name = event
+			} else if data, found := strings.CutPrefix(line, "data: "); found && name != "" {
+				return name, data
+			}
+		case <-timeout:
+			t.Fatal("no event within 5s")
+		}
+	}
+}
+
+func TestLiveClicks(t *testing.T) {
+	old := config.Config.LIVE.HeartbeatInterval
+	t.Cleanup(func() { config.Config.LIVE.HeartbeatInterval = old })
+	config.Config.LIVE.HeartbeatInterval = time.Second
+	s := newTestServer(t)
+
+	owner := s.signUp("watcher@example.com", "Passw0rd!")
+	resp, out := s.do(http.MethodPost, "/url/register", map[string]any{"url": "https://example.com/", "code": "watched"}, map[string]string{"Authorization": owner})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("register: %d %v", resp.StatusCode, out)
+	}
+
+	// Links of other users don't exist for them
+	other := s.signUp("other@example.com", "Passw0rd!")
+	if status, _ := s.openLiveStream("watched", other); status != http.StatusNotFound {
+		t.Fatalf("other user's stream: %d, want 404", status)
+	}
+
+	status, lines := s.openLiveStream("watched", owner)
+	if status != http.StatusOK {
+		t.Fatalf("owner's stream: %d", status)
+	}
+	s.do(http.MethodGet, "/watched", nil, map[string]string{"User-Agent": "Mozilla/5.0", "Accept-Language": "en", "Referer": "https://news.example.org/item"})
+	if name, data := nextEvent(t, lines); name != "click" || !strings.Contains(data, `"referrer_domain":"news.example.org"`) || strings.Contains(data, "Mozilla") {
+		t.Fatalf("event %s: %s", name, data)
+	}
+
+	// Suspending the owner ends the stream at the next heartbeat
+	url, _ := s.store.Urls.GetByCode(context.Background(), "watched")
+	if err := s.store.Users.UpdateStatus(context.Background(), url.UserID, model.UserStatusSuspended); err != nil {
+		t.Fatal(err)
+	}
+	if name, data := nextEvent(t, lines); name != "end" || !strings.Contains(data, "unavailable") {
+		t.Fatalf("event %s: %s, want the stream to end", name, data)
+	}
+}
+
+func TestLiveStreamsPerUser(t *testing.T) {
+	old := config.Config.LIVE.MaxStreams
+	t.Cleanup(func() { config.Config.LIVE.MaxStreams = old })
+	config.Config.LIVE.MaxStreams = 1
+	s := newTestServer(t)
+
+	jwt := s.signUp("tabs@example.com", "Passw0rd!")
+	for _, code := range []string{"first", "second"} {
+		resp, out := s.do(http.MethodPost, "/url/register", map[string]any{"url": "https://example.com/", "code": code}, map[string]string{"Authorization": jwt})
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("register: %d %v", resp.StatusCode, out)
+		}
+	}
+
+	if status, _ := s.openLiveStream("first", jwt); status != http.StatusOK {
+		t.Fatalf("first stream: %d", status)
+	}
+	if status, _ := s.openLiveStream("second", jwt); status != http.StatusTooManyRequests {
+		t.Fatalf("second stream: %d, want 429", status)
+	}
+}
@@ -0,0 +1,183 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/oidc"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	oidcStateCookie = "oidc_auth"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+var (
+	errOidcProviderNotFound = utils.NotFound("sso_provider_not_found", "Unknown sign-in provider")
+	errOidcStateInvalid     = utils.BadRequest("sso_state_invalid", "Sign-in request is invalid or has expired. Please try again.")
+	errOidcLoginFailed      = utils.Unauthorized("sso_login_failed", "Sign-in with the identity provider failed")
+)
+
+// OidcProvidersResponse lists the configured SSO providers
+type OidcProvidersResponse struct {
+	Providers []string `json:"providers"`
+}
+
+func (h *Handler) OidcRoutes(router *gin.RouterGroup) {
+	router.GET("", h.rateLimit(h.RateLimits.Default), h.handleListOidcProviders)
+	router.GET("/:provider/login", h.rateLimit(h.RateLimits.Login), h.handleOidcLogin)
+	router.GET("/:provider/callback", h.rateLimit(h.RateLimits.Login), h.handleOidcCallback)
+}
+
+// @Summary      List SSO Providers
+// @Description  Lists the OpenID Connect providers users can sign in with.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  model.APIResponse{data=OidcProvidersResponse} "Success" "Example: {\"message\": \"Success\", \"data\": {\"providers\": [\"google\"]}}"
+// @Router       /auth/oidc [get]
+func (h *Handler) handleListOidcProviders(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Success",
+		Data:    OidcProvidersResponse{Providers: h.OIDC.Names()},
+	})
+}
+
+// @Summary      SSO Login
+// @Description  Redirects to the identity provider to sign in. The request state is kept in a short-lived encrypted cookie.
+// @Tags         Auth
+// @Param        provider  path  string  true  "Provider name from OIDC_PROVIDERS"
+// @Success      302  "Redirect to the identity provider"
+// @Failure      404  {object}  utils.ErrorResponse "Unknown provider" "Example: {\"code\": \"sso_provider_not_found\", \"message\": \"Unknown sign-in provider\"}"
+// @Failure      503  {object}  utils.ErrorResponse "Provider unavailable" "Example: {\"code\": \"sso_provider_unavailable\", \"message\": \"Identity provider is not reachable\"}"
+// @Router       /auth/oidc/{provider}/login [get]
+func (h *Handler) handleOidcLogin(ctx *gin.Context) {
+	provider, ok := h.OIDC.Get(ctx.Param("provider"))
+	if !ok {
+		utils.HandleError(ctx, errOidcProviderNotFound)
+		return
+	}
+
+	authURL, authRequest, authErr := provider.AuthCodeURL(ctx.Request.Context(), oidcStateTTL)
+	if authErr != nil {
+		utils.HandleError(ctx, utils.Unavailable("sso_provider_unavailable", "Identity provider is not reachable").Wrap(authErr))
+		return
+	}
+
+	state, _ := json.Marshal(authRequest)
+	encrypted, encryptErr := utils.Encrypt(string(state))
+	if encryptErr != nil {
+		utils.HandleError(ctx, utils.Internal(encryptErr))
+		return
+	}
+
+	h.setOidcStateCookie(ctx, encrypted, int(oidcStateTTL.Seconds()))
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary      SSO Callback
+// @Description  Completes an SSO login and returns a JWT token pair. Unknown identities are linked to the user with the same verified email, or a new user is created. Accounts with TOTP enabled get a model.TotpChallengeResponse instead, to be completed at /user/login/2fa.
+// @Tags         Auth
+// @Produce      json
+// @Param        provider  path   string  true  "Provider name from OIDC_PROVIDERS"
+// @Param        code      query  string  true  "Authorization code"
+// @Param        state     query  string  true  "State from the login redirect"
+// @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success" "Example: {\"message\": \"User logged in successfully !\", \"data\": {\"token\": \"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"}}"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid state" "Example: {\"code\": \"sso_state_invalid\", \"message\": \"Sign-in request is invalid or has expired. Please try again.\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Login failed" "Example: {\"code\": \"sso_login_failed\", \"message\": \"Sign-in with the identity provider failed\"}"
+// @Failure      403  {object}  utils.ErrorResponse "Unverified email" "Example: {\"code\": \"sso_email_unverified\", \"message\": \"Your identity provider did not confirm a verified email address\"}"
+// @Router       /auth/oidc/{provider}/callback [get]
+func (h *Handler) handleOidcCallback(ctx *gin.Context) {
+	provider, ok := h.OIDC.Get(ctx.Param("provider"))
+	if !ok {
+		utils.HandleError(ctx, errOidcProviderNotFound)
+		return
+	}
+
+	authRequest, stateErr := readOidcState(ctx, provider.Name())
+	// The state cookie is single use, whatever the outcome
+	h.setOidcStateCookie(ctx, "", -1)
+	if stateErr != nil {
+		utils.HandleError(ctx, stateErr)
+		return
+	}
+
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		utils.HandleError(ctx, errOidcLoginFailed.Wrap(utils.BadRequest(providerErr, ctx.Query("error_description"))))
+		return
+	}
+
+	claims, exchangeErr := provider.Exchange(ctx.Request.Context(), ctx.Query("code"), authRequest)
+	if exchangeErr != nil {
+		utils.HandleError(ctx, errOidcLoginFailed.Wrap(exchangeErr))
+		return
+	}
+
+	// Checked before LoginWithIdentity, a locked out login must not create or link an account
+	guardErr := h.LoginGuard.Check(ctx.Request.Context(), claims.Email, ctx.ClientIP())
+	if guardErr != nil {
+		utils.HandleError(ctx, guardErr)
+		return
+	}
+
+	user, loginErr := model.LoginWithIdentity(ctx.Request.Context(), h.Store.Users, h.Store.Identities, model.ExternalIdentity{
+		Provider:      provider.Name(),
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	})
+	if loginErr != nil {
+		utils.HandleError(ctx, loginErr)
+		return
+	}
+
+	// A linked identity may sign in to an account with another email
+	if !strings.EqualFold(user.Email, claims.Email) {
+		guardErr = h.LoginGuard.Check(ctx.Request.Context(), user.Email, ctx.ClientIP())
+		if guardErr != nil {
+			utils.HandleError(ctx, guardErr)
+			return
+		}
+	}
+
+	if h.challengeSecondFactor(ctx, user, "sso:"+provider.Name()) {
+		return
+	}
+	h.respondLogin(ctx, user, "sso:"+provider.Name())
+}
+
+// readOidcState restores the login request from the state cookie and checks
+// it belongs to this callback
+func readOidcState(ctx *gin.Context, providerName string) (oidc.AuthRequest, error) {
+	var authRequest oidc.AuthRequest
+
+	cookie, cookieErr := ctx.Cookie(oidcStateCookie)
+	if cookieErr != nil || cookie == "" {
+		return authRequest, errOidcStateInvalid
+	}
+
+	state, decryptErr := utils.Decrypt(cookie)
+	if decryptErr != nil {
+		return authRequest, errOidcStateInvalid.Wrap(decryptErr)
+	}
+
+	if jsonErr := json.Unmarshal([]byte(state), &authRequest); jsonErr != nil {
+		return authRequest, errOidcStateInvalid.Wrap(jsonErr)
+	}
+
+	if authRequest.Provider != providerName || authRequest.State == "" || authRequest.State != ctx.Query("state") || time.Now().After(authRequest.ExpiresAt) {
+		return authRequest, errOidcStateInvalid
+	}
+
+	return authRequest, nil
+}
+
+func (h *Handler) setOidcStateCookie(ctx *gin.Context, value string, maxAge int) {
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, value, maxAge, "/auth/oidc", "", config.Config.APP.EnableHTTPS, true)
+}
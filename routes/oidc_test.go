@@ -0,0 +1,237 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/oidc"
+	"kgoel085.com/url-shortner/oidc/oidctest"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// newOidcTestServer serves the routes with the stub provider configured
+func newOidcTestServer(t *testing.T) (*testServer, *oidctest.Server) {
+	idp := oidctest.NewServer(t, "client-1")
+
+	providers := config.Config.OIDC.Providers
+	config.Config.OIDC.Providers = []string{"stub"}
+	t.Cleanup(func() { config.Config.OIDC.Providers = providers })
+	t.Setenv("OIDC_STUB_ISSUER", idp.Issuer())
+	t.Setenv("OIDC_STUB_CLIENT_ID", "client-1")
+
+	return newTestServer(t), idp
+}
+
+// startOidcLogin follows the login redirect and returns the state cookie
+// with the query of the authorization request
+func (s *testServer) startOidcLogin() (*http.Cookie, url.Values) {
+	s.t.Helper()
+
+	resp, _ := s.do(http.MethodGet, "/auth/oidc/stub/login", nil, nil)
+	if resp.StatusCode != http.StatusFound {
+		s.t.Fatalf("login: %d", resp.StatusCode)
+	}
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return cookie, location.Query()
+		}
+	}
+	s.t.Fatal("login set no state cookie")
+	return nil, nil
+}
+
+func (s *testServer) oidcCallback(code string, state string, cookie string) (*http.Response, map[string]any) {
+	s.t.Helper()
+
+	query := url.Values{"code": {code}, "state": {state}}
+	return s.do(http.MethodGet, "/auth/oidc/stub/callback?"+query.Encode(), nil, map[string]string{"Cookie": oidcStateCookie + "=" + cookie})
+}
+
+func TestOidcLogin(t *testing.T) {
+	s, idp := newOidcTestServer(t)
+
+	cookie, auth := s.startOidcLogin()
+	code := idp.IssueCode(idp.Sign(idp.Claims(auth.Get("nonce"), "sub-1", "frank@example.com")), auth.Get("code_challenge"))
+
+	resp, out := s.oidcCallback(code, auth.Get("state"), cookie.Value)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("callback: %d %v", resp.StatusCode, out)
+	}
+	if token, _ := out["data"].(map[string]any)["token"].(string); token == "" {
+		t.Fatalf("callback returned no token: %v", out)
+	}
+
+	// The state cookie works once
+	resp, out = s.oidcCallback(code, auth.Get("state"), "")
+	if resp.StatusCode != http.StatusBadRequest || out["code"] != "sso_state_invalid" {
+		t.Fatalf("callback without cookie: %d %v", resp.StatusCode, out)
+	}
+}
+
+func TestOidcCallbackRejects(t *testing.T) {
+	s, idp := newOidcTestServer(t)
+
+	expiredState := func(state string) string {
+		request, _ := json.Marshal(oidc.AuthRequest{Provider: "stub", State: state, Nonce: "n", CodeVerifier: "v", ExpiresAt: time.Now().Add(-time.Second)})
+		encrypted, err := utils.Encrypt(string(request))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encrypted
+	}
+
+	tests := []struct {
+		name     string
+		callback func(cookie string, auth url.Values) (*http.Response, map[string]any)
+		wantCode string
+	}{
+		{
+			name: "tampered state cookie",
+			callback: func(cookie string, auth url.Values) (*http.Response, map[string]any) {
+				tampered := []byte(cookie)
+				tampered[len(tampered)/2] ^= 1
+				return s.oidcCallback(idp.IssueCode(idp.Sign(idp.Claims(auth.Get("nonce"), "sub-2", "gina@example.com")), auth.Get("code_challenge")), auth.Get("state"), string(tampered))
+			},
+			wantCode: "sso_state_invalid",
+		},
+		{
+			name: "expired state cookie",
+			callback: func(cookie string, auth url.Values) (*http.Response, map[string]any) {
+				return s.oidcCallback(idp.IssueCode(idp.Sign(idp.Claims("n", "sub-2", "gina@example.com")), auth.Get("code_challenge")), auth.Get("state"), expiredState(auth.Get("state")))
+			},
+			wantCode: "sso_state_invalid",
+		},
+		{
+			name: "state of another login",
+			callback: func(cookie string, auth url.Values) (*http.Response, map[string]any) {
+				return s.oidcCallback(idp.IssueCode(idp.Sign(idp.Claims(auth.Get("nonce"), "sub-2", "gina@example.com")), auth.Get("code_challenge")), "other-state", cookie)
+			},
+			wantCode: "sso_state_invalid",
+		},
+		{
+			name: "wrong nonce",
+			callback: func(cookie string, auth url.Values) (*http.Response, map[string]any) {
+				return s.oidcCallback(idp.IssueCode(idp.Sign(idp.Claims("other-nonce", "sub-2", "gina@example.com")), auth.Get("code_challenge")), auth.Get("state"), cookie)
+			},
+			wantCode: "sso_login_failed",
+		},
+		{
+			name: "wrong code verifier",
+			callback: func(cookie string, auth url.Values) (*http.Response, map[string]any) {
+				return s.oidcCallback(idp.IssueCode(idp.Sign(idp.Claims(auth.Get("nonce"), "sub-2", "gina@example.com")), "other-challenge"), auth.Get("state"), cookie)
+			},
+			wantCode: "sso_login_failed",
+		},
+		{
+			name: "unverified email",
+			callback: func(cookie string, auth url.Values) (*http.Response, map[string]any) {
+				claims := idp.Claims(auth.Get("nonce"), "sub-2", "gina@example.com")
+				claims["email_verified"] = false
+				return s.oidcCallback(idp.IssueCode(idp.Sign(claims), auth.Get("code_challenge")), auth.Get("state"), cookie)
+			},
+			wantCode: "sso_email_unverified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie, auth := s.startOidcLogin()
+
+			resp, out := tt.callback(cookie.Value, auth)
+			if resp.StatusCode == http.StatusOK || out["code"] != tt.wantCode {
+				t.Fatalf("callback: %d %v, want %s", resp.StatusCode, out, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestOidcLoginAsksForTotp(t *testing.T) {
+	s, idp := newOidcTestServer(t)
+	ctx := context.Background()
+
+	signIn := func() map[string]any {
+		t.Helper()
+		cookie, auth := s.startOidcLogin()
+		code := idp.IssueCode(idp.Sign(idp.Claims(auth.Get("nonce"), "sub-3", "hank@example.com")), auth.Get("code_challenge"))
+		resp, out := s.oidcCallback(code, auth.Get("state"), cookie.Value)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("callback: %d %v", resp.StatusCode, out)
+		}
+		return out["data"].(map[string]any)
+	}
+	signIn()
+
+	user, err := s.store.Users.GetByEmail(ctx, "hank@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup, err := user.BeginTotpEnrollment(ctx, s.store.Totps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enrollCode, _ := utils.TotpCode(setup.Secret, utils.TotpStep(time.Now()))
+	if _, err := user.ConfirmTotpEnrollment(ctx, s.store.Totps, enrollCode); err != nil {
+		t.Fatal(err)
+	}
+
+	data := signIn()
+	if data["totp_required"] != true || data["token"] != nil {
+		t.Fatalf("SSO login with TOTP enabled: %v", data)
+	}
+	challenge := data["challenge"].(string)
+
+	resp, out := s.do(http.MethodPost, "/user/login/2fa", map[string]any{"challenge": challenge, "totp_code": enrollCode}, nil)
+	if resp.StatusCode != http.StatusUnauthorized || out["code"] != "totp_code_used" {
+		t.Fatalf("challenge with a used code: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out = s.do(http.MethodPost, "/user/login/2fa", map[string]any{"challenge": "A" + challenge, "totp_code": enrollCode}, nil)
+	if resp.StatusCode != http.StatusUnauthorized || out["code"] != "totp_challenge_invalid" {
+		t.Fatalf("tampered challenge: %d %v", resp.StatusCode, out)
+	}
+
+	next, _ := utils.TotpCode(setup.Secret, utils.TotpStep(time.Now())+1)
+	resp, out = s.do(http.MethodPost, "/user/login/2fa", map[string]any{"challenge": challenge, "totp_code": next}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("challenge with the next code: %d %v", resp.StatusCode, out)
+	}
+	if token, _ := out["data"].(map[string]any)["token"].(string); token == "" {
+		t.Fatalf("completed login returned no token: %v", out)
+	}
+}
+
+func TestOidcLoginChecksLockoutBeforeCreatingUsers(t *testing.T) {
+	s, idp := newOidcTestServer(t)
+	ctx := context.Background()
+
+	login := config.Config.LOGIN
+	t.Cleanup(func() { config.Config.LOGIN = login })
+	config.Config.LOGIN.IPMaxAttempts = 1
+
+	if _, err := model.NewLoginGuard(s.store.Counters).RecordFailure(ctx, "someone@example.com", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	cookie, auth := s.startOidcLogin()
+	code := idp.IssueCode(idp.Sign(idp.Claims(auth.Get("nonce"), "sub-4", "iris@example.com")), auth.Get("code_challenge"))
+	resp, out := s.oidcCallback(code, auth.Get("state"), cookie.Value)
+	if resp.StatusCode != http.StatusTooManyRequests || out["code"] != "login_locked" {
+		t.Fatalf("callback from a locked IP: %d %v", resp.StatusCode, out)
+	}
+
+	if _, err := s.store.Users.GetByEmail(ctx, "iris@example.com"); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("locked out SSO login created a user: %v", err)
+	}
+}
@@ -1,18 +1,18 @@
 package routes
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"kgoel085.com/url-shortner/mail"
 	"kgoel085.com/url-shortner/model"
 	"kgoel085.com/url-shortner/utils"
 )
 
-func OtpRoutes(router *gin.RouterGroup) {
-	router.POST("/send", handleSendOTP)
-	router.POST("/verify", handleVerifyOTP)
+func (h *Handler) OtpRoutes(router *gin.RouterGroup) {
+	router.POST("/send", h.rateLimit(h.RateLimits.OtpSend), h.handleSendOTP)
+	router.POST("/verify", h.rateLimit(h.RateLimits.Default), h.handleVerifyOTP)
 }
 
 // @Summary      Verify OTP
@@ -23,8 +23,9 @@ func OtpRoutes(router *gin.RouterGroup) {
 // @Param        otpVerifyRequest  body  model.VerifyOtp  true  "OTP verify payload"
 // @Success      200  {object}  model.APIResponse "Success" "Example: {\"message\": \"OTP verified successfully\"}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Invalid OTP code\"}"
+// @Failure      410  {object}  utils.ErrorResponse "OTP expired" "Example: {\"code\": \"otp_expired\", \"message\": \"OTP has expired. Please request a new one.\"}"
 // @Router       /otp/verify [post]
-func handleVerifyOTP(ctx *gin.Context) {
+func (h *Handler) handleVerifyOTP(ctx *gin.Context) {
 	var otpVerifyRequest model.VerifyOtp
 	payloadErr := ctx.ShouldBindBodyWithJSON(&otpVerifyRequest)
 
@@ -33,9 +34,9 @@ func handleVerifyOTP(ctx *gin.Context) {
 		return
 	}
 
-	otpErr := otpVerifyRequest.Verify()
+	otpErr := otpVerifyRequest.Verify(ctx.Request.Context(), h.Store.Otps)
 	if otpErr != nil {
-		utils.HandleValidationError(ctx, otpErr)
+		utils.HandleError(ctx, otpErr)
 		return
 	}
 
@@ -45,15 +46,17 @@ func handleVerifyOTP(ctx *gin.Context) {
 }
 
 // @Summary      Send OTP
-// @Description  Sends an OTP to the user for verification.
+// @Description  Sends an OTP to the user for verification. Email OTPs are mailed, phone OTPs (E.164 key) go out by SMS. Login OTPs for emails without an account get the same answer but are never sent.
 // @Tags         OTP
 // @Accept       json
 // @Produce      json
 // @Param        otpRequest  body  model.SendOtp  true  "OTP request payload"
 // @Success      200  {object}  model.APIResponse{data=model.SendOTPResponse} "Success" "Example: {\"message\": \"OTP sent successfully\", \"data\": {\"id\": \"123\", \"token\": \"abcde12345\"}}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Invalid OTP type or action type\"}"
+// @Failure      429  {object}  utils.ErrorResponse "OTP sent recently" "Example: {\"code\": \"otp_recently_sent\", \"message\": \"OTP already sent recently\"}"
+// @Failure      503  {object}  utils.ErrorResponse "No SMS provider configured" "Example: {\"code\": \"otp_type_unavailable\", \"message\": \"OTPs of this type can't be delivered, try another type\"}"
 // @Router       /otp/send [post]
-func handleSendOTP(ctx *gin.Context) {
+func (h *Handler) handleSendOTP(ctx *gin.Context) {
 	var otpRequest model.SendOtp
 	payloadErr := ctx.ShouldBindBodyWithJSON(&otpRequest)
 
@@ -63,7 +66,23 @@ func handleSendOTP(ctx *gin.Context) {
 	}
 
 	if (!otpRequest.Type.IsValid()) || (!otpRequest.Action.IsValid()) {
-		utils.HandleValidationError(ctx, fmt.Errorf("Invalid OTP type or action type"))
+		utils.HandleError(ctx, utils.BadRequest("otp_type_invalid", "Invalid OTP type or action type"))
+		return
+	}
+
+	if otpRequest.Action == model.OtpActionTypeMagicLogin {
+		utils.HandleError(ctx, utils.BadRequest("otp_action_invalid", "Magic links are requested via /user/magic-link"))
+		return
+	}
+
+	if !h.OtpSenders.Supports(otpRequest.Type) {
+		utils.HandleError(ctx, utils.Unavailable("otp_type_unavailable", "OTPs of this type can't be delivered, try another type"))
+		return
+	}
+
+	keyErr := otpRequest.ValidateKey()
+	if keyErr != nil {
+		utils.HandleError(ctx, keyErr)
 		return
 	}
 
@@ -72,14 +91,18 @@ func handleSendOTP(ctx *gin.Context) {
 		Type:   otpRequest.Type,
 		Action: otpRequest.Action,
 	}
-	otpErr := otp.Generate()
+	otpErr := otp.Generate(ctx.Request.Context(), h.Store.Otps, h.Store.Users)
 	if otpErr != nil {
-		utils.HandleValidationError(ctx, otpErr)
+		utils.HandleError(ctx, otpErr)
 		return
 	}
 
-	// Send Email
-	go mail.SendOtpUserMail(otp)
+	h.auditOtpSent(ctx, otp)
+
+	// Deliver via email or sms depending on the OTP type
+	if otp.Deliverable() {
+		go h.OtpSenders.Send(context.WithoutCancel(ctx.Request.Context()), otp)
+	}
 	ctx.JSON(http.StatusOK, model.APIResponse{
 		Message: "OTP sent successfully",
 		Data:    model.SendOTPResponse{ID: fmt.Sprintf("%d", otp.ID), Token: otp.Token},
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPhoneOtpsNeedAnSmsProvider(t *testing.T) {
+	s := newTestServer(t) // SMS_PROVIDER isn't set
+
+	resp, out := s.do(http.MethodPost, "/otp/send", map[string]any{"type": "phone", "action": "signup", "key": "+14155550123"}, nil)
+	if resp.StatusCode != http.StatusServiceUnavailable || out["code"] != "otp_type_unavailable" {
+		t.Fatalf("phone OTP without a provider: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out = s.do(http.MethodPost, "/otp/send", map[string]any{"type": "email", "action": "signup", "key": "dan@example.com"}, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("email OTP: %d %v", resp.StatusCode, out)
+	}
+}
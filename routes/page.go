@@ -0,0 +1,96 @@
+package routes
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// UrlWarningPageOptions fill the page shown instead of redirecting to a
+// flagged destination
+type UrlWarningPageOptions struct {
+	APP_NAME     string
+	TITLE        string
+	MESSAGE      string
+	REASON       string
+	DESTINATION  string // Empty to hide it
+	CONTINUE_URL string // Empty when visitors can't continue
+}
+
+// UrlPreviewPageOptions fill the preview page of a link
+type UrlPreviewPageOptions struct {
+	APP_NAME string
+	model.UrlPreview
+}
+
+// UrlCardPageOptions fill the Open Graph page served to crawlers unfurling
+// a link
+type UrlCardPageOptions struct {
+	APP_NAME string
+	model.SocialCard
+}
+
+//go:embed template/url-warning.html
+var urlWarningTemplate string
+
+//go:embed template/url-preview.html
+var urlPreviewTemplate string
+
+//go:embed template/url-card.html
+var urlCardTemplate string
+
+var (
+	urlWarningPage = template.Must(template.New("url-warning").Parse(urlWarningTemplate))
+	urlPreviewPage = template.Must(template.New("url-preview").Parse(urlPreviewTemplate))
+	urlCardPage    = template.Must(template.New("url-card").Parse(urlCardTemplate))
+)
+
+// wantsHTML tells browsers from API clients, which get JSON errors instead
+// of pages
+func wantsHTML(ctx *gin.Context) bool {
+	return ctx.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEHTML
+}
+
+// prefersHTML is stricter than wantsHTML, clients not naming HTML over JSON
+// (curl, `Accept: */*`, no Accept header) get JSON. Previews are data, only
+// browsers get them as a page.
+func prefersHTML(ctx *gin.Context) bool {
+	return ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+func renderUrlWarning(ctx *gin.Context, status int, opts UrlWarningPageOptions) {
+	opts.APP_NAME = config.Config.APP.Name
+	renderPage(ctx, status, urlWarningPage, opts)
+}
+
+func renderUrlPreview(ctx *gin.Context, opts UrlPreviewPageOptions) {
+	opts.APP_NAME = config.Config.APP.Name
+	renderPage(ctx, http.StatusOK, urlPreviewPage, opts)
+}
+
+func renderUrlCard(ctx *gin.Context, opts UrlCardPageOptions) {
+	opts.APP_NAME = config.Config.APP.Name
+	renderPage(ctx, http.StatusOK, urlCardPage, opts)
+}
+
+// renderPage renders pages about links, which must not be cached or indexed
+// and must not leak the short link to destinations
+func renderPage(ctx *gin.Context, status int, page *template.Template, data any) {
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Referrer-Policy", "no-referrer")
+	ctx.Header("X-Robots-Tag", "noindex")
+	ctx.Header("Content-Type", "text/html; charset=utf-8")
+	ctx.Status(status)
+
+	if err := page.Execute(ctx.Writer, data); err != nil {
+		utils.Log.Error("Error rendering ", page.Name(), " page: ", err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	ctx.Abort()
+}
@@ -0,0 +1,46 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// @Summary      Preview Short URL
+// @Description  Shows where a short link goes without following it and without counting a click: destination, page title and description, favicon, the owner's description and the creation date. Browsers get an HTML page, other clients JSON. /{code}+ is the same. Destinations of quarantined links aren't requested.
+// @Tags         URL
+// @Produce      json,html
+// @Param        code  path  string  true  "Short URL code"
+// @Success      200  {object}  model.APIResponse{data=model.UrlPreview} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Blocked" "Example: {\"code\": \"url_blocked\", \"message\": \"This link was disabled after reports of abuse\"}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Failure      410  {object}  utils.ErrorResponse "Inactive or expired" "Example: {\"code\": \"url_expired\", \"message\": \"URL has expired\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /{code}/preview [get]
+func (h *Handler) handleUrlPreview(ctx *gin.Context) {
+	h.previewUrl(ctx, ctx.Param("code"))
+}
+
+func (h *Handler) previewUrl(ctx *gin.Context, code string) {
+	utils.Log.Info("Preview URL by code:", code)
+
+	url, ok := h.availableUrl(ctx, code)
+	if !ok {
+		return
+	}
+
+	preview := url.Preview(ctx.Request.Context(), h.Preview)
+
+	if prefersHTML(ctx) {
+		renderUrlPreview(ctx, UrlPreviewPageOptions{UrlPreview: preview})
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "URL preview fetched successfully",
+		Data:    preview,
+	})
+}
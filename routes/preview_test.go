@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestPreviewIsNotAClick(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	// .invalid never resolves, the preview has no page metadata
+	url := model.Url{UserID: 1, Code: "peek", Url: "https://destination.invalid/page", Description: "Team notes", Status: model.UrlStatusActive}
+	if err := s.store.Urls.Save(ctx, &url); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/peek/preview", "/peek+"} {
+		resp, out := s.do(http.MethodGet, path, nil, nil)
+		data, _ := out["data"].(map[string]any)
+		if resp.StatusCode != http.StatusOK || data["url"] != url.Url || data["description"] != "Team notes" {
+			t.Fatalf("%s as API client: %d %v", path, resp.StatusCode, out)
+		}
+
+		resp, page := s.getPage(path)
+		if resp.StatusCode != http.StatusOK || !strings.Contains(page, "https://destination.invalid/page") {
+			t.Fatalf("%s as browser: %d %s", path, resp.StatusCode, page)
+		}
+		// Nothing on the page is loaded from the destination
+		if strings.Contains(page, "<img") {
+			t.Fatalf("%s embeds a resource of the destination: %s", path, page)
+		}
+	}
+
+	// Clicks are recorded after the redirect, one redirect after the previews
+	// must leave exactly one click
+	resp, _ := s.do(http.MethodGet, "/peek", nil, nil)
+	if resp.StatusCode != http.StatusPermanentRedirect {
+		t.Fatalf("redirect: %d", resp.StatusCode)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if clicks, _ := s.store.Analytics.ListByUser(ctx, 1); len(clicks) > 0 {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if clicks, _ := s.store.Analytics.ListByUser(ctx, 1); len(clicks) != 1 {
+		t.Fatalf("%d clicks after 4 previews and a redirect, want 1", len(clicks))
+	}
+}
@@ -0,0 +1,246 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/mail"
+	"kgoel085.com/url-shortner/middleware"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+var errUrlBlocked = utils.Forbidden("url_blocked", "This link was disabled after reports of abuse")
+
+func (h *Handler) ReportRoutes(router *gin.RouterGroup) {
+	// Reports of signed-in users count towards blocking links automatically
+	router.POST("/:code", middleware.AuthenticateOptional(h.Store.Users), h.rateLimit(h.RateLimits.Report), h.handleReportUrl)
+}
+
+// @Summary      Report Link
+// @Description  Reports a short link as malicious. No login needed, one open report per link and IP address (IPv6 per /64 network) or user. Links are blocked once ABUSE_AUTO_BLOCK_REPORTS signed-in users reported them, anonymous reports only go to the moderation queue.
+// @Security     BearerAuth
+// @Tags         URL
+// @Accept       json
+// @Produce      json
+// @Param        code    path  string                   true  "Short URL code"
+// @Param        report  body  model.CreateAbuseReport  true  "Report"
+// @Success      201  {object}  model.APIResponse "Success" "Example: {\"message\": \"Thanks, the link was reported\"}"
+// @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"reason\", \"error\": \"must be one of phishing malware spam other\"}]}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Failure      409  {object}  utils.ErrorResponse "Already reported" "Example: {\"code\": \"report_exists\", \"message\": \"You already reported this link\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /report/{code} [post]
+func (h *Handler) handleReportUrl(ctx *gin.Context) {
+	var create model.CreateAbuseReport
+	payloadErr := ctx.ShouldBindJSON(&create)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	url, urlErr := h.Store.Urls.GetByCode(ctx.Request.Context(), ctx.Param("code"))
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return
+	}
+
+	oldStatus := url.Status
+	report, blocked, reportErr := model.ReportUrl(ctx.Request.Context(), h.Store.Urls, h.Store.AbuseReports, &url, create, ctx.ClientIP(), ctx.GetInt64(config.JWT_LOGGED_IN_USER))
+	if reportErr != nil {
+		utils.HandleError(ctx, reportErr)
+		return
+	}
+
+	event := url.AuditEvent(model.AuditActionLinkReport)
+	event.Details = map[string]string{"report_id": strconv.FormatInt(report.ID, 10), "reason": string(report.Reason)}
+	h.audit(ctx, event)
+
+	if blocked {
+		event := url.AuditEvent(model.AuditActionLinkStatusChange)
+		event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: url.Status}}
+		event.Details = map[string]string{"reason": "abuse_reports"}
+		h.audit(ctx, event)
+
+		h.notifyLinkBlocked(ctx, url, "automatically after several reports of abuse")
+	}
+
+	ctx.JSON(http.StatusCreated, model.APIResponse{
+		Message: "Thanks, the link was reported",
+	})
+}
+
+// @Summary      Search Abuse Reports
+// @Description  Lists abuse reports, newest first. status=open is the moderation queue. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        status  query  string  false  "Status"  Enums(open, dismissed, actioned)
+// @Param        code    query  string  false  "Short code of the reported link"
+// @Param        limit   query  int     false  "Page size, up to 200"  default(50)
+// @Param        offset  query  int     false  "Results to skip"
+// @Success      200  {object}  model.APIResponse{data=model.AbuseReportsResponse} "Success"
+// @Failure      403  {object}  utils.ErrorResponse "Forbidden" "Example: {\"code\": \"forbidden\", \"message\": \"You don't have permission to access this resource\"}"
+// @Router       /admin/reports [get]
+func (h *Handler) handleAdminSearchReports(ctx *gin.Context) {
+	var filter model.AbuseReportFilter
+	bindErr := ctx.ShouldBindQuery(&filter)
+	if bindErr != nil {
+		utils.HandleValidationError(ctx, bindErr)
+		return
+	}
+
+	reports, reportsErr := model.SearchAbuseReports(ctx.Request.Context(), h.Store.AbuseReports, filter)
+	if reportsErr != nil {
+		utils.HandleError(ctx, reportsErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Reports fetched successfully",
+		Data:    model.AbuseReportsResponse{Reports: reports},
+	})
+}
+
+// @Summary      Get Abuse Report
+// @Description  Returns the report with the reported link and all reports on it. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        id  path  int  true  "Report ID"
+// @Success      200  {object}  model.APIResponse{data=model.AbuseReportResponse} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"report_not_found\", \"message\": \"Report not found\"}"
+// @Router       /admin/reports/{id} [get]
+func (h *Handler) handleAdminGetReport(ctx *gin.Context) {
+	id, idErr := reportID(ctx)
+	if idErr != nil {
+		utils.HandleError(ctx, idErr)
+		return
+	}
+
+	report, reportErr := model.GetAbuseReport(ctx.Request.Context(), h.Store.Urls, h.Store.AbuseReports, id)
+	if reportErr != nil {
+		utils.HandleError(ctx, reportErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Report fetched successfully",
+		Data:    report,
+	})
+}
+
+// @Summary      Dismiss Abuse Reports
+// @Description  Closes all open reports on the reported link as unfounded. A link blocked automatically is reactivated. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        id  path  int  true  "Report ID"
+// @Success      200  {object}  model.APIResponse{data=model.AbuseReportResolution} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"report_not_found\", \"message\": \"Report not found\"}"
+// @Router       /admin/reports/{id}/dismiss [post]
+func (h *Handler) handleAdminDismissReport(ctx *gin.Context) {
+	url, urlErr := h.reportedUrl(ctx)
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return
+	}
+
+	oldStatus := url.Status
+	moderatorID := ctx.GetInt64(config.JWT_LOGGED_IN_USER)
+	resolved, dismissErr := model.DismissAbuseReports(ctx.Request.Context(), h.Store.Urls, h.Store.AbuseReports, &url, moderatorID)
+	if dismissErr != nil {
+		utils.HandleError(ctx, dismissErr)
+		return
+	}
+
+	event := url.AuditEvent(model.AuditActionReportDismiss)
+	event.Details = map[string]string{"reports": strconv.FormatInt(resolved, 10)}
+	if url.Status != oldStatus {
+		event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: url.Status}}
+	}
+	h.audit(ctx, event)
+
+	utils.Log.Info(resolved, " reports on URL ", url.Code, " dismissed by ", moderatorID)
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Reports dismissed",
+		Data:    model.AbuseReportResolution{Url: url, Resolved: resolved},
+	})
+}
+
+// @Summary      Block Reported Link
+// @Description  Blocks the reported link, closes its open reports and notifies the owner. Visitors see a warning page. Needs the support role.
+// @Security     BearerAuth
+// @Tags         Admin
+// @Produce      json
+// @Param        id  path  int  true  "Report ID"
+// @Success      200  {object}  model.APIResponse{data=model.AbuseReportResolution} "Success"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"report_not_found\", \"message\": \"Report not found\"}"
+// @Router       /admin/reports/{id}/disable [post]
+func (h *Handler) handleAdminDisableReportedUrl(ctx *gin.Context) {
+	url, urlErr := h.reportedUrl(ctx)
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return
+	}
+
+	oldStatus := url.Status
+	moderatorID := ctx.GetInt64(config.JWT_LOGGED_IN_USER)
+	resolved, blockErr := model.BlockReportedUrl(ctx.Request.Context(), h.Store.Urls, h.Store.AbuseReports, &url, moderatorID)
+	if blockErr != nil {
+		utils.HandleError(ctx, blockErr)
+		return
+	}
+
+	if url.Status != oldStatus {
+		event := url.AuditEvent(model.AuditActionLinkStatusChange)
+		event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: url.Status}}
+		event.Details = map[string]string{"reason": "abuse_reports", "reports": strconv.FormatInt(resolved, 10)}
+		h.audit(ctx, event)
+
+		h.notifyLinkBlocked(ctx, url, "by our moderators after reports of abuse")
+	}
+
+	utils.Log.Info("URL ", url.Code, " blocked by ", moderatorID, ", ", resolved, " reports closed")
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "URL blocked",
+		Data:    model.AbuseReportResolution{Url: url, Resolved: resolved},
+	})
+}
+
+// reportedUrl loads the link of the report in the :id path parameter
+func (h *Handler) reportedUrl(ctx *gin.Context) (model.Url, error) {
+	id, idErr := reportID(ctx)
+	if idErr != nil {
+		return model.Url{}, idErr
+	}
+
+	report, reportErr := h.Store.AbuseReports.GetByID(ctx.Request.Context(), id)
+	if reportErr != nil {
+		return model.Url{}, reportErr
+	}
+
+	return h.Store.Urls.GetByCode(ctx.Request.Context(), report.Code)
+}
+
+func reportID(ctx *gin.Context) (int64, error) {
+	id, parseErr := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if parseErr != nil || id <= 0 {
+		return 0, utils.BadRequest("report_id_invalid", "Invalid report ID")
+	}
+	return id, nil
+}
+
+// notifyLinkBlocked emails the owner of a blocked link in the background
+func (h *Handler) notifyLinkBlocked(ctx *gin.Context, url model.Url, reason string) {
+	owner, ownerErr := h.Store.Users.GetByID(ctx.Request.Context(), url.UserID)
+	if ownerErr != nil {
+		utils.Log.Error("Failed to load owner for link blocked email:", ownerErr)
+		return
+	}
+
+	go mail.SendLinkBlockedMail(context.WithoutCancel(ctx.Request.Context()), owner, url, reason)
+}
@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+)
+
+func TestReportCountsSignedInReporters(t *testing.T) {
+	s := newTestServer(t)
+	blockAfter := config.Config.ABUSE.AutoBlockReports
+	config.Config.ABUSE.AutoBlockReports = 1
+	t.Cleanup(func() { config.Config.ABUSE.AutoBlockReports = blockAfter })
+
+	url := model.Url{UserID: 1, Code: "reported", Url: "https://reported.example/", Status: model.UrlStatusActive}
+	if err := s.store.Urls.Save(context.Background(), &url); err != nil {
+		t.Fatal(err)
+	}
+	report := map[string]any{"reason": "phishing"}
+
+	resp, out := s.do(http.MethodPost, "/report/reported", report, map[string]string{"Authorization": "Bearer garbage"})
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("report with a bad token: %d %v", resp.StatusCode, out)
+	}
+
+	resp, out = s.do(http.MethodPost, "/report/reported", report, nil)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("anonymous report: %d %v", resp.StatusCode, out)
+	}
+	if resp, _ := s.do(http.MethodGet, "/reported", nil, nil); resp.StatusCode != http.StatusPermanentRedirect {
+		t.Fatalf("link after an anonymous report: %d", resp.StatusCode)
+	}
+
+	// One open report per address, signed in or not
+	jwt := s.signUp("reporter@example.com", "Passw0rd!")
+	resp, out = s.do(http.MethodPost, "/report/reported", report, map[string]string{"Authorization": jwt})
+	if resp.StatusCode != http.StatusConflict || out["code"] != "report_exists" {
+		t.Fatalf("second report from the address: %d %v", resp.StatusCode, out)
+	}
+
+	if _, err := s.store.AbuseReports.Resolve(context.Background(), url.ID, model.AbuseReportStatusDismissed, 1, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	resp, out = s.do(http.MethodPost, "/report/reported", report, map[string]string{"Authorization": jwt})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("signed-in report: %d %v", resp.StatusCode, out)
+	}
+	if resp, out := s.do(http.MethodGet, "/reported", nil, jsonAccept); resp.StatusCode != http.StatusForbidden || out["code"] != "url_blocked" {
+		t.Fatalf("link after a signed-in report: %d %v", resp.StatusCode, out)
+	}
+}
@@ -1,25 +1,82 @@
 package routes
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"kgoel085.com/url-shortner/bots"
 	"kgoel085.com/url-shortner/config"
 	"kgoel085.com/url-shortner/docs"
+	"kgoel085.com/url-shortner/mail"
 	"kgoel085.com/url-shortner/middleware"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/oidc"
+	"kgoel085.com/url-shortner/preview"
+	"kgoel085.com/url-shortner/safety"
+	"kgoel085.com/url-shortner/sms"
 )
 
-func SetUpRouter(server *gin.Engine) {
+// Handler carries the dependencies shared by every route handler
+type Handler struct {
+	Store      *model.Store
+	RateLimits middleware.RateLimitPolicies
+	LoginGuard *model.LoginGuard
+	OtpSenders model.OtpSenders
+	OIDC       *oidc.Registry
+	Safety     model.UrlChecker
+	Preview    model.MetadataFetcher
+	Bots       model.BotClassifier
+	// ClickPrivacy remembers the modes of link owners for recording clicks
+	ClickPrivacy *model.ClickPrivacyCache
+	// LiveViewers caps the live streams each user has open
+	LiveViewers *model.LiveViewers
+	// Authenticate is shared by all route groups, so they share its cache
+	Authenticate gin.HandlerFunc
+}
+
+func NewHandler(store *model.Store) *Handler {
+	return &Handler{
+		Store:        store,
+		RateLimits:   middleware.LoadRateLimitPolicies(),
+		LoginGuard:   model.NewLoginGuard(store.Counters),
+		OtpSenders:   newOtpSenders(),
+		OIDC:         oidc.NewRegistry(&http.Client{Timeout: 10 * time.Second}),
+		Safety:       safety.Init(),
+		Preview:      preview.NewFetcher(safety.NewHTTPClient(config.Config.PREVIEW.FetchTimeout)),
+		Bots:         bots.Init(),
+		ClickPrivacy: model.NewClickPrivacyCache(store.Users),
+		LiveViewers:  model.NewLiveViewers(),
+		Authenticate: middleware.Authenticate(store.Users),
+	}
+}
+
+// newOtpSenders delivers email OTPs by mail and phone OTPs by SMS, if an
+// SMS provider is configured
+func newOtpSenders() model.OtpSenders {
+	senders := model.OtpSenders{model.OtpTypeEmail: mail.OtpSender{}}
+	if smsSender, ok := sms.NewOtpSender(); ok {
+		senders[model.OtpTypePhone] = smsSender
+	}
+	return senders
+}
+
+func SetUpRouter(server *gin.Engine, store *model.Store) {
 	setUpSwagger(server)
 
-	// Initialize global rate limiter middleware
-	server.Use(middleware.GlobalRateLimit)
+	h := NewHandler(store)
 
-	// Initialize all routes
-	AppRoutes(server.Group("/app"))
-	UserRoutes(server.Group("/user"))
-	OtpRoutes(server.Group("/otp"))
-	UrlShorterRoutes(server.Group("/"))
+	// Initialize all routes, each group applies its own rate limit policies
+	h.WellKnownRoutes(server.Group("/.well-known"))
+	h.AppRoutes(server.Group("/app"))
+	h.UserRoutes(server.Group("/user"))
+	h.OtpRoutes(server.Group("/otp"))
+	h.OidcRoutes(server.Group("/auth/oidc"))
+	h.AdminRoutes(server.Group("/admin"))
+	h.ReportRoutes(server.Group("/report"))
+	h.UrlShorterRoutes(server.Group("/"))
 }
 
 func setUpSwagger(server *gin.Engine) {
@@ -43,3 +100,7 @@ func setUpSwagger(server *gin.Engine) {
 	// Swagger docs route
 	server.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 }
+
+func (h *Handler) rateLimit(policy middleware.RateLimitPolicy) gin.HandlerFunc {
+	return middleware.RateLimit(h.Store.RateLimiter, policy)
+}
@@ -0,0 +1,276 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/db/memory"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/proto"
+	"kgoel085.com/url-shortner/utils"
+	"kgoel085.com/url-shortner/validator"
+)
+
+// testOtpCode is the only code OTP_ALPHABET allows in the tests
+const testOtpCode = "777777"
+
+func TestMain(m *testing.M) {
+	keysDir, err := os.MkdirTemp("", "jwt-keys-")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(keysDir)
+
+	env := map[string]string{
+		"HOST":                    "localhost",
+		"PROJECT_ID":              "test",
+		"ENCRYPTION_KEY":          "0123456789abcdef0123456789abcdef",
+		"DB_NAME":                 "test",
+		"DB_PWD":                  "test",
+		"STORAGE_DRIVER":          "memory",
+		"GRPC_EMAIL_SERVICE_ADDR": "",
+		"OTP_ALPHABET":            "77",
+		"OTP_LENGTH":              "6",
+		"JWT_KEYS_DIR":            keysDir,
+		"RATE_LIMIT_DEFAULT":      "1000/1m",
+		"RATE_LIMIT_REDIRECT":     "1000/1m",
+		"RATE_LIMIT_OTP_SEND":     "1000/1m",
+		"RATE_LIMIT_LOGIN":        "1000/1m",
+		"RATE_LIMIT_LINK_CREATE":  "1000/1m",
+		"RATE_LIMIT_ANALYTICS":    "1000/1m",
+		"RATE_LIMIT_REPORT":       "1000/1m",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+
+	gin.SetMode(gin.TestMode)
+	utils.InitLogger()
+	config.LoadConfig()
+	utils.InitJwtKeys()
+	proto.ClientManager = proto.NewManager() // No email service, mails are logged as failed
+	validator.LoadCustomBindings()
+
+	os.Exit(m.Run())
+}
+
+// testServer serves the routes over a fresh in-memory store
+type testServer struct {
+	t      *testing.T
+	url    string
+	store  *model.Store
+	client *http.Client
+}
+
+func newTestServer(t *testing.T) *testServer {
+	store := memory.NewStore()
+	engine := gin.New()
+	SetUpRouter(engine, store)
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{
+		// Redirects are what the tests check
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &testServer{t: t, url: server.URL, store: store, client: client}
+}
+
+// do sends body as JSON and decodes the JSON answer, if any
+func (s *testServer) do(method string, path string, body any, headers map[string]string) (*http.Response, map[string]any) {
+	s.t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, s.url+path, &payload)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	out := map[string]any{}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp, out
+}
+
+// sendOtp requests an email OTP for the action and returns its token
+func (s *testServer) sendOtp(email string, action string) string {
+	s.t.Helper()
+
+	resp, out := s.do(http.MethodPost, "/otp/send", map[string]any{"type": "email", "action": action, "key": email}, nil)
+	if resp.StatusCode != http.StatusOK {
+		s.t.Fatalf("send %s OTP: %d %v", action, resp.StatusCode, out)
+	}
+	return out["data"].(map[string]any)["token"].(string)
+}
+
+// signUp creates an account and returns the access token of a login
+func (s *testServer) signUp(email string, password string) string {
+	s.t.Helper()
+
+	token := s.sendOtp(email, "signup")
+	resp, out := s.do(http.MethodPost, "/user/sign-up", map[string]any{"email": email, "password": password, "otp_token": token, "otp_code": testOtpCode}, nil)
+	if resp.StatusCode != http.StatusCreated {
+		s.t.Fatalf("sign up: %d %v", resp.StatusCode, out)
+	}
+
+	return s.login(email, password)
+}
+
+// login returns the access token of a fresh login, carrying the current role
+func (s *testServer) login(email string, password string) string {
+	s.t.Helper()
+
+	token := s.sendOtp(email, "login")
+	resp, out := s.do(http.MethodPost, "/user/login", map[string]any{"email": email, "password": password, "otp_token": token, "otp_code": testOtpCode}, nil)
+	if resp.StatusCode != http.StatusOK {
+		s.t.Fatalf("login: %d %v", resp.StatusCode, out)
+	}
+	return out["data"].(map[string]any)["token"].(string)
+}
+
+func TestSignUpLoginRegisterRedirect(t *testing.T) {
+	s := newTestServer(t)
+	jwt := s.signUp("alice@example.com", "Passw0rd!")
+	auth := map[string]string{"Authorization": jwt}
+
+	resp, out := s.do(http.MethodPost, "/url/register", map[string]any{"url": "https://example.com/landing", "code": "hello"}, auth)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("register: %d %v", resp.StatusCode, out)
+	}
+
+	resp, _ = s.do(http.MethodGet, "/hello", nil, map[string]string{"User-Agent": "Mozilla/5.0", "Accept-Language": "en"})
+	if resp.StatusCode != http.StatusPermanentRedirect || resp.Header.Get("Location") != "https://example.com/landing" {
+		t.Fatalf("redirect: %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, out = s.do(http.MethodPost, "/url/register", map[string]any{"url": "https://example.com/other", "code": "hello"}, auth)
+	if resp.StatusCode != http.StatusConflict || out["code"] != "url_code_exists" {
+		t.Fatalf("duplicate code: %d %v", resp.StatusCode, out)
+	}
+
+	resp, _ = s.do(http.MethodPost, "/url/register", map[string]any{"url": "https://example.com", "code": "anon"}, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("register without token: %d", resp.StatusCode)
+	}
+
+	resp, _ = s.do(http.MethodGet, "/missing", nil, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown code: %d", resp.StatusCode)
+	}
+}
+
+func TestSignUpTwice(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("bob@example.com", "Passw0rd!")
+
+	token := s.sendOtp("bob@example.com", "signup")
+	resp, out := s.do(http.MethodPost, "/user/sign-up", map[string]any{"email": "bob@example.com", "password": "Passw0rd!", "otp_token": token, "otp_code": testOtpCode}, nil)
+	if resp.StatusCode != http.StatusConflict || out["code"] != "user_exists" {
+		t.Fatalf("second sign up: %d %v", resp.StatusCode, out)
+	}
+}
+
+func TestUnknownAccountsLookLikeKnownOnes(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("carol@example.com", "Passw0rd!")
+
+	for _, email := range []string{"carol@example.com", "nobody@example.com"} {
+		resp, out := s.do(http.MethodPost, "/user/login", map[string]any{"email": email, "password": "Wrong0rd!", "otp_token": "x", "otp_code": testOtpCode}, nil)
+		if resp.StatusCode != http.StatusUnauthorized || out["code"] != "invalid_credentials" || out["message"] != "Invalid email or password !" {
+			t.Fatalf("login as %s: %d %v", email, resp.StatusCode, out)
+		}
+	}
+
+	// carol's login OTP was just sent by signUp, so only the magic link is
+	// compared for her
+	resp, out := s.do(http.MethodPost, "/otp/send", map[string]any{"type": "email", "action": "login", "key": "nobody@example.com"}, nil)
+	if resp.StatusCode != http.StatusOK || out["message"] != "OTP sent successfully" {
+		t.Fatalf("login OTP for unknown email: %d %v", resp.StatusCode, out)
+	}
+
+	for _, email := range []string{"carol@example.com", "nobody@example.com"} {
+		resp, out := s.do(http.MethodPost, "/user/magic-link", map[string]any{"email": email}, nil)
+		if resp.StatusCode != http.StatusOK || out["message"] != "Sign-in link sent" {
+			t.Fatalf("magic link for %s: %d %v", email, resp.StatusCode, out)
+		}
+	}
+}
+
+func TestFailedLoginsDontKeepTheEmail(t *testing.T) {
+	s := newTestServer(t)
+	s.signUp("erin@example.com", "Passw0rd!")
+
+	for _, email := range []string{"erin@example.com", "Passw0rd!@example.com"} {
+		s.do(http.MethodPost, "/user/login", map[string]any{"email": email, "password": "Wrong0rd!", "otp_token": "x", "otp_code": testOtpCode}, nil)
+	}
+
+	events, err := s.store.Audits.Search(context.Background(), model.AuditFilter{Action: model.AuditActionLoginFailed, Pagination: model.Pagination{Limit: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("%d failed logins recorded, want 2", len(events))
+	}
+	for _, event := range events {
+		if _, ok := event.Details["email"]; ok || event.Details["reason"] != "invalid_credentials" {
+			t.Errorf("failed login details %v", event.Details)
+		}
+	}
+	// Newest first, only the existing account is attributed
+	if events[0].UserID != 0 || events[1].UserID == 0 {
+		t.Errorf("failed logins attributed to %d and %d", events[1].UserID, events[0].UserID)
+	}
+}
+
+func TestSuspendedUserTokensAreRefused(t *testing.T) {
+	s := newTestServer(t)
+	jwt := s.signUp("judy@example.com", "Passw0rd!")
+	auth := map[string]string{"Authorization": jwt}
+
+	if resp, out := s.do(http.MethodGet, "/user/me", nil, auth); resp.StatusCode != http.StatusOK {
+		t.Fatalf("me: %d %v", resp.StatusCode, out)
+	}
+
+	user, err := s.store.Users.GetByEmail(context.Background(), "judy@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Suspend(context.Background(), s.store.Users, s.store.RefreshTokens); err != nil {
+		t.Fatal(err)
+	}
+
+	// The status read by the request above is still cached, without the
+	// cache the token is refused right away
+	ttl := config.Config.JWT.UserStatusCacheTTL
+	config.Config.JWT.UserStatusCacheTTL = 0
+	t.Cleanup(func() { config.Config.JWT.UserStatusCacheTTL = ttl })
+
+	for _, path := range []string{"/user/me", "/url/list"} {
+		resp, out := s.do(http.MethodGet, path, nil, auth)
+		if resp.StatusCode != http.StatusForbidden || out["code"] != "user_suspended" {
+			t.Fatalf("%s after suspension: %d %v", path, resp.StatusCode, out)
+		}
+	}
+}
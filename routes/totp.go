@@ -0,0 +1,200 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func (h *Handler) TotpRoutes(router *gin.RouterGroup) {
+	router.Use(h.Authenticate)
+
+	router.GET("", h.rateLimit(h.RateLimits.Default), h.handleTotpStatus)
+	router.POST("/setup", h.rateLimit(h.RateLimits.Default), h.handleTotpSetup)
+	router.POST("/enable", h.rateLimit(h.RateLimits.Login), h.handleTotpEnable)
+	router.POST("/re-enroll", h.rateLimit(h.RateLimits.Login), h.handleTotpReenroll)
+	router.POST("/disable", h.rateLimit(h.RateLimits.Login), h.handleTotpDisable)
+}
+
+// loggedInUser loads the user the request was authenticated as
+func (h *Handler) loggedInUser(ctx *gin.Context) (model.User, error) {
+	return h.Store.Users.GetByID(ctx.Request.Context(), ctx.GetInt64(config.JWT_LOGGED_IN_USER))
+}
+
+// @Summary      TOTP Status
+// @Description  Whether TOTP two-factor authentication is enabled and how many recovery codes are left.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  model.APIResponse{data=model.TotpStatusResponse} "Success"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Router       /user/2fa/totp [get]
+func (h *Handler) handleTotpStatus(ctx *gin.Context) {
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	status, statusErr := user.TotpStatus(ctx.Request.Context(), h.Store.Totps)
+	if statusErr != nil {
+		utils.HandleError(ctx, statusErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "TOTP status",
+		Data:    status,
+	})
+}
+
+// @Summary      Set Up TOTP
+// @Description  Creates a TOTP secret to add to an authenticator app, via the otpauth URI or QR code. Confirm it with /user/2fa/totp/enable.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  model.APIResponse{data=model.TotpSetupResponse} "Success"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      409  {object}  utils.ErrorResponse "Already enabled" "Example: {\"code\": \"totp_already_enabled\", \"message\": \"Two-factor authentication is already enabled, re-enroll to change the authenticator\"}"
+// @Router       /user/2fa/totp/setup [post]
+func (h *Handler) handleTotpSetup(ctx *gin.Context) {
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	setup, setupErr := user.BeginTotpEnrollment(ctx.Request.Context(), h.Store.Totps)
+	if setupErr != nil {
+		utils.HandleError(ctx, setupErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Scan the QR code with your authenticator app, then confirm with a code",
+		Data:    setup,
+	})
+}
+
+// @Summary      Enable TOTP
+// @Description  Confirms the pending TOTP secret with a code from the authenticator app. Returns one-time recovery codes, shown only once.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        confirmTotp  body  model.ConfirmTotp  true  "Code from the authenticator app"
+// @Success      200  {object}  model.APIResponse{data=model.TotpEnabledResponse} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid code" "Example: {\"code\": \"totp_code_invalid\", \"message\": \"Invalid authenticator code\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /user/2fa/totp/enable [post]
+func (h *Handler) handleTotpEnable(ctx *gin.Context) {
+	var confirmTotp model.ConfirmTotp
+	payloadErr := ctx.ShouldBindJSON(&confirmTotp)
+
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	recoveryCodes, confirmErr := user.ConfirmTotpEnrollment(ctx.Request.Context(), h.Store.Totps, confirmTotp.Code)
+	if confirmErr != nil {
+		utils.HandleError(ctx, confirmErr)
+		return
+	}
+
+	h.audit(ctx, user.AuditEvent(model.AuditActionTotpEnable))
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Two-factor authentication enabled. Store the recovery codes somewhere safe, they are shown only once.",
+		Data:    model.TotpEnabledResponse{RecoveryCodes: recoveryCodes},
+	})
+}
+
+// @Summary      Re-enroll TOTP
+// @Description  Replaces the authenticator after checking a current TOTP or recovery code. The old authenticator keeps working until the new one is confirmed with /user/2fa/totp/enable.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        secondFactor  body  model.SecondFactor  true  "Current TOTP or recovery code"
+// @Success      200  {object}  model.APIResponse{data=model.TotpSetupResponse} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Not enabled" "Example: {\"code\": \"totp_not_enabled\", \"message\": \"Two-factor authentication is not enabled for this account\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Invalid code" "Example: {\"code\": \"totp_code_invalid\", \"message\": \"Invalid authenticator code\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /user/2fa/totp/re-enroll [post]
+func (h *Handler) handleTotpReenroll(ctx *gin.Context) {
+	var secondFactor model.SecondFactor
+	payloadErr := ctx.ShouldBindJSON(&secondFactor)
+
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	setup, setupErr := user.ReenrollTotp(ctx.Request.Context(), h.Store.Totps, secondFactor)
+	if setupErr != nil {
+		utils.HandleError(ctx, setupErr)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Scan the QR code with your authenticator app, then confirm with a code",
+		Data:    setup,
+	})
+}
+
+// @Summary      Disable TOTP
+// @Description  Turns off TOTP two-factor authentication after checking a current TOTP or recovery code. Login falls back to email OTPs.
+// @Security     BearerAuth
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        secondFactor  body  model.SecondFactor  true  "Current TOTP or recovery code"
+// @Success      200  {object}  model.APIResponse "Success" "Example: {\"message\": \"Two-factor authentication disabled\"}"
+// @Failure      400  {object}  utils.ErrorResponse "Not enabled" "Example: {\"code\": \"totp_not_enabled\", \"message\": \"Two-factor authentication is not enabled for this account\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Invalid code" "Example: {\"code\": \"recovery_code_invalid\", \"message\": \"Invalid or already used recovery code\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
+// @Router       /user/2fa/totp/disable [post]
+func (h *Handler) handleTotpDisable(ctx *gin.Context) {
+	var secondFactor model.SecondFactor
+	payloadErr := ctx.ShouldBindJSON(&secondFactor)
+
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, userErr := h.loggedInUser(ctx)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
+	}
+
+	disableErr := user.DisableTotp(ctx.Request.Context(), h.Store.Totps, secondFactor)
+	if disableErr != nil {
+		utils.HandleError(ctx, disableErr)
+		return
+	}
+
+	h.audit(ctx, user.AuditEvent(model.AuditActionTotpDisable))
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Two-factor authentication disabled",
+	})
+}
@@ -1,46 +1,64 @@
 package routes
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"kgoel085.com/url-shortner/config"
 	"kgoel085.com/url-shortner/mail"
-	"kgoel085.com/url-shortner/middleware"
 	"kgoel085.com/url-shortner/model"
 	"kgoel085.com/url-shortner/utils"
 )
 
-func UrlShorterRoutes(router *gin.RouterGroup) {
-	router.GET("/", handleRoot)
-	router.GET("/:code", handleGetUrls)
+// proceedTTL is how long the continue link of a warning page works, so it
+// can't be passed around in place of the short link
+const proceedTTL = 10 * time.Minute
+
+var errUrlQuarantined = utils.Forbidden("url_quarantined", "This link was flagged as unsafe. Open it in a browser to see the warning.")
+
+func (h *Handler) UrlShorterRoutes(router *gin.RouterGroup) {
+	router.GET("/", h.rateLimit(h.RateLimits.Default), h.handleRoot)
+	router.GET("/:code", h.rateLimit(h.RateLimits.Redirect), h.handleGetUrls)
+	router.GET("/:code/preview", h.rateLimit(h.RateLimits.Redirect), h.handleUrlPreview)
 
 	authenticated := router.Group("/url")
-	authenticated.Use(middleware.Authenticate)
+	authenticated.Use(h.Authenticate) // Before rate limiting so limits apply per user
 
-	authenticated.POST("/register", handleShortUrl)
-	authenticated.GET("/list", handleListUrls)
+	authenticated.POST("/register", h.rateLimit(h.RateLimits.LinkCreate), h.handleShortUrl)
+	authenticated.GET("/list", h.rateLimit(h.RateLimits.Analytics), h.handleListUrls)
+	// Every row is counted against link_create once the upload is read
+	authenticated.POST("/bulk", h.rateLimit(h.RateLimits.Default), h.handleBulkCreateUrls)
+	authenticated.GET("/export", h.rateLimit(h.RateLimits.Analytics), h.handleExportUrls)
+	authenticated.GET("/:code/analytics", h.rateLimit(h.RateLimits.Analytics), h.handleUrlAnalytics)
+	authenticated.GET("/:code/live", h.rateLimit(h.RateLimits.Analytics), h.handleLiveClicks)
 }
 
-func handleRoot(ctx *gin.Context) {
+func (h *Handler) handleRoot(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{
 		"message": fmt.Sprintf("Welcome to %s URL Shortener Service", config.Config.APP.Name),
 	})
 }
 
 // @Summary      List User URLs
-// @Description  Get all shortened URLs for the authenticated user.
+// @Description  Get all shortened URLs for the authenticated user. click_count leaves out clicks of crawlers, scanners and health checkers unless include_bots=true, which adds them and reports them in bot_click_count. unique_visitors has the unique visitors of today and all-time, see /url/{code}/analytics.
 // @Security     BearerAuth
 // @Tags         URL
 // @Accept       json
 // @Produce      json
-// @Param        status  query  string  false  "Filter by URL status"
+// @Param        status        query  string  false  "Filter by URL status"
+// @Param        include_bots  query  bool    false  "Count clicks of bots too"
 // @Success      200  {object}  model.APIResponse{data=model.GetUrlsByUserResponse} "Success" "Example: {\"message\": \"URLs fetched successfully\", \"data\": {\"urls\": [{\"code\": \"abc123\", \"url\": \"https://example.com\"}]}}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Invalid URL status !\"}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
 // @Router       /url/list [get]
-func handleListUrls(ctx *gin.Context) {
+func (h *Handler) handleListUrls(ctx *gin.Context) {
 	loggedInUser := ctx.GetInt64(config.JWT_LOGGED_IN_USER)
 	utils.Log.Info("Get URLs for user:", loggedInUser)
 
@@ -49,15 +67,23 @@ func handleListUrls(ctx *gin.Context) {
 	if status != "" {
 		urlStatus := model.UrlStatus(status)
 		if !urlStatus.IsValid() {
-			utils.HandleValidationError(ctx, fmt.Errorf("Invalid URL status !"))
+			utils.HandleError(ctx, utils.BadRequest("url_status_invalid", "Invalid URL status !"))
 			return
 		}
 		filters.Status = urlStatus
 	}
+	if includeBots := ctx.Query("include_bots"); includeBots != "" {
+		include, parseErr := strconv.ParseBool(includeBots)
+		if parseErr != nil {
+			utils.HandleError(ctx, utils.BadRequest("include_bots_invalid", "include_bots must be true or false !"))
+			return
+		}
+		filters.IncludeBots = include
+	}
 
-	urls, urlsErr := model.GetUrlsByUser(loggedInUser, filters)
+	urls, urlsErr := model.GetUrlsByUser(ctx.Request.Context(), h.Store.Urls, h.Store.Visitors, loggedInUser, filters)
 	if urlsErr != nil {
-		utils.HandleValidationError(ctx, urlsErr)
+		utils.HandleError(ctx, urlsErr)
 		return
 	}
 
@@ -68,47 +94,78 @@ func handleListUrls(ctx *gin.Context) {
 }
 
 // @Summary      Redirect Short URL
-// @Description  Redirects to the original URL using the short code.
+// @Description  Redirects to the original URL using the short code. Links quarantined by the safety checks show browsers a warning page instead, its continue link carries a proceed token that works for a few minutes. Links blocked after abuse reports show a warning page without a way to continue. A code ending in + shows the preview of the link instead, see /{code}/preview. Crawlers of chat apps and social networks (Slackbot, Twitterbot, facebookexternalhit, ...) get a page with the Open Graph tags of the link and aren't counted as clicks.
 // @Tags         URL
 // @Accept       json
-// @Produce      json
-// @Param        code  path  string  true  "Short URL code"
-// @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"URL is not active\"}"
-// @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"URL has expired\"}"
+// @Produce      json,html
+// @Param        code     path   string  true   "Short URL code"
+// @Param        proceed  query  string  false  "Token of the warning page to continue to a quarantined destination"
+// @Success      200  {string}  string "Warning page of a quarantined link, or the Open Graph card for crawlers of chat apps and social networks"
+// @Failure      403  {object}  utils.ErrorResponse "Quarantined or blocked, for non-browser clients" "Example: {\"code\": \"url_quarantined\", \"message\": \"This link was flagged as unsafe. Open it in a browser to see the warning.\"}"
+// @Failure      404  {object}  utils.ErrorResponse "Not found" "Example: {\"code\": \"url_not_found\", \"message\": \"no URL found for the provided code\"}"
+// @Failure      410  {object}  utils.ErrorResponse "Inactive or expired" "Example: {\"code\": \"url_expired\", \"message\": \"URL has expired\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
 // @Router       /{code} [get]
-func handleGetUrls(ctx *gin.Context) {
+func (h *Handler) handleGetUrls(ctx *gin.Context) {
 	code := ctx.Param("code")
+
+	// "/abc+" previews the link like "/abc/preview"
+	if previewCode, isPreview := strings.CutSuffix(code, "+"); isPreview {
+		h.previewUrl(ctx, previewCode)
+		return
+	}
+
 	utils.Log.Info("Get URL by code:", code)
 
-	url, urlErr := model.GetUrlByCode(code)
-	if urlErr != nil {
-		utils.HandleValidationError(ctx, urlErr)
+	url, ok := h.availableUrl(ctx, code)
+	if !ok {
 		return
 	}
 
-	if url.Status != model.UrlStatusActive {
-		utils.HandleValidationError(ctx, fmt.Errorf("URL is not active"))
+	// Chat apps and social networks get a card instead of the redirect, their
+	// hits aren't clicks
+	if h.Bots.IsUnfurlCrawler(ctx.Request.UserAgent()) {
+		utils.Log.Info("Serving link card of ", url.Code, " to crawler: ", ctx.Request.UserAgent())
+		renderUrlCard(ctx, UrlCardPageOptions{SocialCard: url.SocialCard(ctx.Request.Context(), h.Preview)})
 		return
 	}
 
-	if !url.ExpiryAt.IsZero() && url.ExpiryAt.Before(time.Now()) {
-		updateErr := url.UpdateStatus(model.UrlStatusExpired)
-		if updateErr != nil {
-			utils.Log.Error("Failed to update URL status to expired:", updateErr)
+	// Flagged links warn visitors first, they can still continue on their own
+	if url.Status == model.UrlStatusQuarantined && !validProceedToken(url.Code, ctx.Query("proceed")) {
+		if !wantsHTML(ctx) {
+			utils.HandleError(ctx, errUrlQuarantined)
+			return
 		}
-		utils.HandleValidationError(ctx, fmt.Errorf("URL has expired"))
+
+		renderUrlWarning(ctx, http.StatusOK, UrlWarningPageOptions{
+			TITLE:        "Warning: this link may be unsafe",
+			MESSAGE:      "The destination of this short link was flagged by our safety checks. It may try to steal your passwords or install malware.",
+			REASON:       url.SafetyReason,
+			DESTINATION:  url.Url,
+			CONTINUE_URL: proceedUrl(url.Code),
+		})
 		return
 	}
 
-	// Log analytics data
+	// Log analytics data, bots are tagged so reports can leave them out
+	verdict := h.Bots.Classify(ctx.ClientIP(), ctx.Request.Header)
 	analytics := model.Analytics{
 		UrlID:     url.ID,
 		IPAddress: ctx.ClientIP(),
 		UserAgent: ctx.Request.UserAgent(),
 		Referrer:  ctx.Request.Referer(),
+		Bot:       verdict.Bot,
+		BotRule:   verdict.Rule,
+		Country:   model.CountryCode(ctx.GetHeader(config.Config.ANALYTICS.CountryHeader)),
 	}
+	doNotTrack := config.Config.PRIVACY.HonorDoNotTrack && utils.DoNotTrack(ctx.Request.Header)
+	analyticsCtx := context.WithoutCancel(ctx.Request.Context()) // Outlives the redirect response
 	go func() {
-		if err := analytics.Save(); err != nil {
+		privacy := model.ClickPrivacyNone
+		if !doNotTrack {
+			privacy = h.ClickPrivacy.Get(analyticsCtx, url.UserID)
+		}
+		if err := model.RecordClick(analyticsCtx, h.Store, url, &analytics, privacy); err != nil {
 			utils.Log.Error("Failed to save analytics data:", err)
 		}
 	}()
@@ -117,6 +174,86 @@ func handleGetUrls(ctx *gin.Context) {
 	ctx.Redirect(http.StatusPermanentRedirect, url.Url)
 }
 
+// proceedToken lets a visitor who saw the warning page continue to the
+// destination of the link for proceedTTL
+type proceedToken struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// proceedUrl is the continue link of the warning page, empty when the token
+// can't be created so visitors can't continue
+func proceedUrl(code string) string {
+	token, _ := json.Marshal(proceedToken{Code: code, ExpiresAt: time.Now().Add(proceedTTL)})
+	encrypted, err := utils.Encrypt(string(token))
+	if err != nil {
+		utils.Log.Error("Error creating proceed token of ", code, ": ", err)
+		return ""
+	}
+	return "/" + code + "?" + neturl.Values{"proceed": {encrypted}}.Encode()
+}
+
+func validProceedToken(code string, encrypted string) bool {
+	if encrypted == "" {
+		return false
+	}
+
+	decrypted, decryptErr := utils.Decrypt(encrypted)
+	if decryptErr != nil {
+		return false
+	}
+
+	var token proceedToken
+	if err := json.Unmarshal([]byte(decrypted), &token); err != nil {
+		return false
+	}
+	return token.Code == code && time.Now().Before(token.ExpiresAt)
+}
+
+// availableUrl loads the link with the code. Blocked, inactive and expired
+// links are answered right away and ok is false.
+func (h *Handler) availableUrl(ctx *gin.Context, code string) (url model.Url, ok bool) {
+	url, urlErr := h.Store.Urls.GetByCode(ctx.Request.Context(), code)
+	if urlErr != nil {
+		utils.HandleError(ctx, urlErr)
+		return url, false
+	}
+
+	if url.Status == model.UrlStatusBlocked {
+		if !wantsHTML(ctx) {
+			utils.HandleError(ctx, errUrlBlocked)
+			return url, false
+		}
+
+		renderUrlWarning(ctx, http.StatusForbidden, UrlWarningPageOptions{
+			TITLE:   "This link has been disabled",
+			MESSAGE: "This short link was reported for abuse, e.g. phishing or malware, and disabled. You can't continue to its destination.",
+		})
+		return url, false
+	}
+
+	if url.Status != model.UrlStatusActive && url.Status != model.UrlStatusQuarantined {
+		utils.HandleError(ctx, utils.Gone("url_inactive", "URL is not active"))
+		return url, false
+	}
+
+	if !url.ExpiryAt.IsZero() && url.ExpiryAt.Before(time.Now()) {
+		oldStatus := url.Status
+		updateErr := url.UpdateStatus(ctx.Request.Context(), h.Store.Urls, model.UrlStatusExpired)
+		if updateErr != nil {
+			utils.Log.Error("Failed to update URL status to expired:", updateErr)
+		} else {
+			event := url.AuditEvent(model.AuditActionLinkStatusChange)
+			event.Changes = map[string]model.AuditChange{"status": {Before: oldStatus, After: url.Status}}
+			h.audit(ctx, event)
+		}
+		utils.HandleError(ctx, utils.Gone("url_expired", "URL has expired"))
+		return url, false
+	}
+
+	return url, true
+}
+
 // @Summary      Register Short URL
 // @Description  Create a new short URL for the authenticated user.
 // @Security     BearerAuth
@@ -126,8 +263,11 @@ func handleGetUrls(ctx *gin.Context) {
 // @Param        createUrl  body  model.CreateShortUrl  true  "Create short URL payload"
 // @Success      200  {object}  model.APIResponse{data=model.CreateShortUrlResponse} "Success" "Example: {\"message\": \"Short URL created successfully\", \"data\": {\"short_url\": \"https://short.ly/abc123\"}}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"url\", \"error\": \"invalid URL\"}]}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"unauthorized\", \"message\": \"Unauthorized !\"}"
+// @Failure      409  {object}  utils.ErrorResponse "Code taken" "Example: {\"code\": \"url_code_exists\", \"message\": \"URL code already exists !\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited" "Example: {\"code\": \"rate_limited\", \"message\": \"Too Many Requests\"}"
 // @Router       /url/register [post]
-func handleShortUrl(ctx *gin.Context) {
+func (h *Handler) handleShortUrl(ctx *gin.Context) {
 	var createUrl model.CreateShortUrl
 	payloadErr := ctx.ShouldBindJSON(&createUrl)
 	if payloadErr != nil {
@@ -139,21 +279,47 @@ func handleShortUrl(ctx *gin.Context) {
 	createUrl.UserID = loggedInUser
 
 	// Validate payload
-	url, urlErr := createUrl.Validate()
+	url, urlErr := createUrl.Validate(ctx.Request.Context(), h.Store.Urls)
 	if urlErr != nil {
-		utils.HandleValidationError(ctx, urlErr)
+		utils.HandleError(ctx, urlErr)
 		return
 	}
 
-	urlErr = url.Save()
+	url.CheckSafety(ctx.Request.Context(), h.Safety)
+
+	urlErr = h.Store.Urls.Save(ctx.Request.Context(), &url)
 	if urlErr != nil {
-		utils.HandleValidationError(ctx, urlErr)
+		utils.HandleError(ctx, urlErr)
 		return
 	}
 
-	go mail.SendShortUrlUserMail(url)
+	event := url.AuditEvent(model.AuditActionLinkCreate)
+	event.Changes = map[string]model.AuditChange{"url": {After: url.Url}}
+	if !url.ExpiryAt.IsZero() {
+		event.Changes["expiry_at"] = model.AuditChange{After: url.ExpiryAt}
+	}
+	if url.Status == model.UrlStatusQuarantined {
+		event.Details = map[string]string{"quarantined": url.SafetyReason}
+	}
+	h.audit(ctx, event)
+
+	response := model.CreateShortUrlResponse{ShortUrl: utils.GetShortUrl(url.Code), Status: url.Status, SafetyReason: url.SafetyReason}
+	if url.Status == model.UrlStatusQuarantined {
+		ctx.JSON(http.StatusOK, model.APIResponse{
+			Message: "Short URL created but quarantined, visitors will see a warning before they are redirected",
+			Data:    response,
+		})
+		return
+	}
+
+	user, userErr := h.Store.Users.GetByID(ctx.Request.Context(), loggedInUser)
+	if userErr != nil {
+		utils.Log.Error("Failed to load user for URL registered email:", userErr)
+	} else {
+		go mail.SendShortUrlUserMail(context.WithoutCancel(ctx.Request.Context()), user, url)
+	}
 	ctx.JSON(http.StatusOK, model.APIResponse{
 		Message: "Short URL created successfully",
-		Data:    model.CreateShortUrlResponse{ShortUrl: utils.GetShortUrl(url.Code)},
+		Data:    response,
 	})
 }
@@ -0,0 +1,226 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// getPage requests path as a browser would and returns the page
+func (s *testServer) getPage(path string) (*http.Response, string) {
+	s.t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, s.url+path, nil)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	page, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp, string(page)
+}
+
+// jsonAccept makes requests of an API client, clients not asking for JSON
+// get warning pages like browsers
+var jsonAccept = map[string]string{"Accept": "application/json"}
+
+var continueLink = regexp.MustCompile(`class="continue" href="([^"]+)"`)
+
+func TestQuarantinedLinkNeedsWarningPageToken(t *testing.T) {
+	s := newTestServer(t)
+	for _, code := range []string{"flagged", "other"} {
+		url := model.Url{UserID: 1, Code: code, Url: "https://" + code + ".example/", Status: model.UrlStatusQuarantined, SafetyReason: "Domain is on the blocklist"}
+		if err := s.store.Urls.Save(context.Background(), &url); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, out := s.do(http.MethodGet, "/flagged", nil, jsonAccept)
+	if resp.StatusCode != http.StatusForbidden || out["code"] != "url_quarantined" || strings.Contains(out["message"].(string), "proceed") {
+		t.Fatalf("API client: %d %v", resp.StatusCode, out)
+	}
+
+	// Clients not naming a format, like browsers following a link from an
+	// app, get the page
+	resp, _ = s.do(http.MethodGet, "/flagged", nil, nil)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("client without Accept: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	for _, proceed := range []string{"1", "true", "garbage"} {
+		resp, out := s.do(http.MethodGet, "/flagged?proceed="+proceed, nil, jsonAccept)
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("proceed=%s: %d %v", proceed, resp.StatusCode, out)
+		}
+	}
+
+	resp, page := s.getPage("/flagged")
+	match := continueLink.FindStringSubmatch(page)
+	if resp.StatusCode != http.StatusOK || match == nil {
+		t.Fatalf("warning page: %d %s", resp.StatusCode, page)
+	}
+	continueUrl := html.UnescapeString(match[1])
+
+	resp, _ = s.do(http.MethodGet, continueUrl, nil, nil)
+	if resp.StatusCode != http.StatusPermanentRedirect || resp.Header.Get("Location") != "https://flagged.example/" {
+		t.Fatalf("continue link: %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// The token is only good for the link it was issued for
+	_, query, _ := strings.Cut(continueUrl, "?")
+	resp, _ = s.do(http.MethodGet, "/other?"+query, nil, jsonAccept)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("token of another link: %d", resp.StatusCode)
+	}
+}
+
+func TestExpiredProceedTokenIsRefused(t *testing.T) {
+	token, _ := json.Marshal(proceedToken{Code: "flagged", ExpiresAt: time.Now().Add(-time.Second)})
+	expired, err := utils.Encrypt(string(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validProceedToken("flagged", expired) {
+		t.Error("expired token accepted")
+	}
+}
+
+func TestRedirectStoresClicksAsTheOwnerAllows(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	owner := model.User{Email: "owner@example.com", Password: "Passw0rd!"}
+	if err := owner.Save(ctx, s.store.Users); err != nil {
+		t.Fatal(err)
+	}
+	if err := owner.SetClickPrivacy(ctx, s.store.Users, model.ClickPrivacyTruncated); err != nil {
+		t.Fatal(err)
+	}
+	url := model.Url{UserID: owner.ID, Code: "private", Url: "https://example.com/", Status: model.UrlStatusActive}
+	if err := s.store.Urls.Save(ctx, &url); err != nil {
+		t.Fatal(err)
+	}
+
+	// clicksAfter waits for the clicks recorded after the redirect returned
+	clicksAfter := func(headers map[string]string, want int) []model.Analytics {
+		t.Helper()
+		s.do(http.MethodGet, "/private", nil, headers)
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if clicks, _ := s.store.Analytics.ListByUser(ctx, owner.ID); len(clicks) == want {
+				return clicks
+			}
+		}
+		t.Fatalf("click %d wasn't recorded", want)
+		return nil
+	}
+
+	clicks := clicksAfter(map[string]string{"User-Agent": "Mozilla/5.0"}, 1)
+	if clicks[0].IPAddress != "127.0.0.0" || clicks[0].UserAgent != "Mozilla/5.0" {
+		t.Fatalf("truncated click: %+v", clicks[0])
+	}
+
+	for i, header := range []string{"DNT", "Sec-GPC"} {
+		clicks = clicksAfter(map[string]string{"User-Agent": "Mozilla/5.0", header: "1"}, i+2)
+		for _, click := range clicks[1:] {
+			if click.IPAddress != "" || click.UserAgent != "" {
+				t.Fatalf("click with %s: 1 kept %q %q", header, click.IPAddress, click.UserAgent)
+			}
+		}
+	}
+}
+
+func TestCrawlersGetTheLinkCard(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	links := []model.Url{
+		{UserID: 1, Code: "launch", Url: "https://example.com/launch", Status: model.UrlStatusActive,
+			OgTitle: `Launch <party> & "friends"`, OgDescription: "Join us", OgImage: "https://cdn.example/card.png"},
+		{UserID: 1, Code: "flagged", Url: "https://flagged.example/", Status: model.UrlStatusQuarantined,
+			OgTitle: "Free prizes", OgImage: "https://cdn.example/prize.png"},
+	}
+	for i := range links {
+		if err := s.store.Urls.Save(ctx, &links[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	crawl := func(path string, userAgent string) (*http.Response, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, s.url+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := s.client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		page, _ := io.ReadAll(resp.Body)
+		return resp, string(page)
+	}
+
+	for _, userAgent := range []string{
+		"Slackbot-LinkExpanding 1.0 (https://api.slack.com/robots)",
+		"facebookexternalhit/1.1 (https://www.facebook.com/externalhit_uatext.php)",
+		"Mozilla/5.0 (compatible; Discordbot/2.0; https://discordapp.com)",
+	} {
+		resp, page := crawl("/launch", userAgent)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %d, want the card", userAgent, resp.StatusCode)
+		}
+		for _, tag := range []string{
+			`<meta property="og:title" content="Launch &lt;party&gt; &amp; &#34;friends&#34;">`,
+			`<meta property="og:description" content="Join us">`,
+			`<meta property="og:image" content="https://cdn.example/card.png">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+		} {
+			if !strings.Contains(page, tag) {
+				t.Fatalf("%s: card without %s:\n%s", userAgent, tag, page)
+			}
+		}
+		if strings.Contains(page, "<party>") {
+			t.Fatalf("%s: owner title not escaped:\n%s", userAgent, page)
+		}
+	}
+
+	// The owner's tags of a flagged link are replaced by a warning
+	_, page := crawl("/flagged", "Twitterbot/1.0")
+	if !strings.Contains(page, `content="Warning: this link may be unsafe"`) || strings.Contains(page, "Free prizes") || strings.Contains(page, "og:image") {
+		t.Fatalf("quarantined card:\n%s", page)
+	}
+
+	// Cards aren't clicks, the one redirect after them is
+	if resp, _ := crawl("/launch", "Mozilla/5.0"); resp.StatusCode != http.StatusPermanentRedirect {
+		t.Fatalf("browser: %d, want the redirect", resp.StatusCode)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if clicks, _ := s.store.Analytics.ListByUser(ctx, 1); len(clicks) > 0 {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if clicks, _ := s.store.Analytics.ListByUser(ctx, 1); len(clicks) != 1 {
+		t.Fatalf("%d clicks after 4 cards and a redirect, want 1", len(clicks))
+	}
+}
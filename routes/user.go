@@ -1,8 +1,9 @@
 package routes
 
 import (
-	"errors"
+	"context"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"kgoel085.com/url-shortner/config"
@@ -12,11 +13,19 @@ import (
 	"kgoel085.com/url-shortner/utils"
 )
 
-func UserRoutes(router *gin.RouterGroup) {
-	router.POST("/sign-up", handleSignUp)
-	router.POST("/login", handleLogin)
-	router.POST("/refresh-token", middleware.AuthenticateRefreshToken, handleRefreshToken) // Reuse login handler to issue new JWT
-	router.POST("/verify-credentials", handleVerifyCredentials)
+func (h *Handler) UserRoutes(router *gin.RouterGroup) {
+	// One policy per route, so no request counts against two limits
+	router.POST("/sign-up", h.rateLimit(h.RateLimits.Default), h.handleSignUp)
+	router.POST("/login", h.rateLimit(h.RateLimits.Login), h.handleLogin)
+	router.POST("/login/2fa", h.rateLimit(h.RateLimits.Login), h.handleTotpLogin)
+	router.POST("/refresh-token", h.rateLimit(h.RateLimits.Default), middleware.AuthenticateRefreshToken(h.Store.RefreshTokens), h.handleRefreshToken) // Reuse login handler to issue new JWT
+	router.POST("/verify-credentials", h.rateLimit(h.RateLimits.Login), h.handleVerifyCredentials)
+	router.POST("/magic-link", h.rateLimit(h.RateLimits.OtpSend), h.handleSendMagicLink)
+	router.GET("/magic/:token", h.rateLimit(h.RateLimits.Login), h.handleMagicLogin)
+	router.GET("/export/:token", h.rateLimit(h.RateLimits.Login), h.handleDownloadExport)
+
+	h.TotpRoutes(router.Group("/2fa/totp"))
+	h.AccountRoutes(router.Group(""))
 }
 
 // @Summary      User Refresh Token
@@ -27,33 +36,37 @@ func UserRoutes(router *gin.RouterGroup) {
 // @Produce      json
 // @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success" "Example: {\"message\": \"User logged in successfully !\", \"data\": {\"token\": \"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"}}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"email\", \"error\": \"invalid email\"}]}"
+// @Failure      401  {object}  utils.ErrorResponse "Unauthorized" "Example: {\"code\": \"refresh_token_used\", \"message\": \"Unauthorized - Refresh token has been used\"}"
 // @Router       /user/refresh-token [post]
-func handleRefreshToken(ctx *gin.Context) {
+func (h *Handler) handleRefreshToken(ctx *gin.Context) {
 	loggedInUser := ctx.GetInt64(config.JWT_LOGGED_IN_USER)
 	utils.Log.Info("Refresh token for user:", loggedInUser)
 
 	headerToken := ctx.Request.Header.Get("Authorization")
 	if headerToken == "" {
-		utils.HandleValidationError(ctx, errors.New("Authorization token missing"))
+		utils.HandleError(ctx, utils.Unauthorized("token_missing", "Authorization token missing"))
 		return
 	}
 
-	user := model.User{
-		ID: loggedInUser,
+	user, userErr := h.Store.Users.GetByID(ctx.Request.Context(), loggedInUser)
+	if userErr != nil {
+		utils.HandleError(ctx, userErr)
+		return
 	}
 
 	token, tokenErr := user.GenerateJWT()
 	if tokenErr != nil {
-		utils.HandleValidationError(ctx, tokenErr)
+		utils.HandleError(ctx, tokenErr)
 		return
 	}
 
-	refreshToken, refreshTokenErr := user.GenerateRefreshJWT()
+	refreshToken, refreshTokenErr := user.GenerateRefreshJWT(ctx.Request.Context(), h.Store.RefreshTokens)
 	if refreshTokenErr != nil {
-		utils.HandleValidationError(ctx, refreshTokenErr)
+		utils.HandleError(ctx, refreshTokenErr)
 		return
 	}
 
+	h.audit(ctx, user.AuditEvent(model.AuditActionTokenRefresh))
 	ctx.JSON(http.StatusOK, model.APIResponse{
 		Message: "Token refreshed successfully !",
 		Data:    model.LoginUserResponse{Token: token, RefreshToken: refreshToken},
@@ -61,15 +74,18 @@ func handleRefreshToken(ctx *gin.Context) {
 }
 
 // @Summary      User Login
-// @Description  Login with email, password, and OTP. Returns JWT token on success.
+// @Description  Login with email, password, and OTP. Users with TOTP enabled can send `totp_code` or `recovery_code` instead of `otp_token`/`otp_code`. Returns JWT token on success.
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param        loginUser  body  model.LoginUser  true  "Login payload"
 // @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success" "Example: {\"message\": \"User logged in successfully !\", \"data\": {\"token\": \"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"}}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"email\", \"error\": \"invalid email\"}]}"
+// @Failure      401  {object}  utils.ErrorResponse "Invalid credentials" "Example: {\"code\": \"invalid_credentials\", \"message\": \"Invalid email or password !\"}"
+// @Failure      410  {object}  utils.ErrorResponse "OTP expired" "Example: {\"code\": \"otp_attempts_exceeded\", \"message\": \"Too many wrong codes. Please request a new OTP.\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited or locked out" "Example: {\"code\": \"login_locked\", \"message\": \"Too many failed login attempts, please try again later\"}"
 // @Router       /user/login [post]
-func handleLogin(ctx *gin.Context) {
+func (h *Handler) handleLogin(ctx *gin.Context) {
 	var loginUser model.LoginUser
 	payloadErr := ctx.ShouldBindJSON(&loginUser)
 
@@ -84,42 +100,52 @@ func handleLogin(ctx *gin.Context) {
 		Password: loginUser.Password,
 	}
 
-	userCredsErr := user.ValidateCredentials() // Validate email and password
-	if userCredsErr != nil {
-		utils.HandleValidationError(ctx, userCredsErr)
+	guardErr := h.LoginGuard.Check(ctx.Request.Context(), user.Email, ctx.ClientIP())
+	if guardErr != nil {
+		utils.HandleError(ctx, guardErr)
 		return
 	}
 
-	utils.Log.Info("User credentials validated, proceeding to OTP verification...")
-	otpVerify := model.VerifyOtp{
-		Token:  loginUser.OtpToken,
-		Otp:    loginUser.OtpCode,
-		Action: string(model.OtpActionTypeLogin),
-	}
-
-	otpErr := otpVerify.VerifyWithUpdate() // Validate OTP and update its status to 'success' if valid
-	if otpErr != nil {
-		utils.HandleValidationError(ctx, otpErr)
+	userCredsErr := user.ValidateCredentials(ctx.Request.Context(), h.Store.Users) // Validate email and password
+	if userCredsErr != nil {
+		h.recordLoginFailure(ctx, user, userCredsErr)
+		utils.HandleError(ctx, userCredsErr)
 		return
 	}
 
-	utils.Log.Info("OTP verified successfully, generating JWT...")
-	token, tokenErr := user.GenerateJWT()
-	if tokenErr != nil {
-		utils.HandleValidationError(ctx, tokenErr)
+	var otpErr error
+	loginMethod := "email_otp"
+	if loginUser.TotpCode != "" || loginUser.RecoveryCode != "" {
+		loginMethod = "totp"
+		if loginUser.TotpCode == "" {
+			loginMethod = "recovery_code"
+		}
+		utils.Log.Info("User credentials validated, proceeding to TOTP verification...")
+		otpErr = user.VerifySecondFactor(ctx.Request.Context(), h.Store.Totps, model.SecondFactor{
+			TotpCode:     loginUser.TotpCode,
+			RecoveryCode: loginUser.RecoveryCode,
+		})
+	} else {
+		utils.Log.Info("User credentials validated, proceeding to OTP verification...")
+		otpVerify := model.VerifyOtp{
+			Token:  loginUser.OtpToken,
+			Otp:    loginUser.OtpCode,
+			Action: string(model.OtpActionTypeLogin),
+		}
+		otpErr = otpVerify.VerifyWithUpdate(ctx.Request.Context(), h.Store.Otps) // Validate OTP and update its status to 'success' if valid
+	}
+	if otpErr != nil {
+		h.recordLoginFailure(ctx, user, otpErr)
+		utils.HandleError(ctx, otpErr)
 		return
 	}
 
-	refreshToken, refreshTokenErr := user.GenerateRefreshJWT()
-	if refreshTokenErr != nil {
-		utils.HandleValidationError(ctx, refreshTokenErr)
-		return
+	if successErr := h.LoginGuard.RecordSuccess(ctx.Request.Context(), user.Email); successErr != nil {
+		utils.Log.Error("Error clearing failed logins: ", successErr)
 	}
 
-	ctx.JSON(http.StatusOK, model.APIResponse{
-		Message: "User logged in successfully !",
-		Data:    model.LoginUserResponse{Token: token, RefreshToken: refreshToken},
-	})
+	utils.Log.Info("Second factor verified successfully, generating JWT...")
+	h.respondLogin(ctx, user, loginMethod)
 }
 
 // @Summary      Verify User Credentials
@@ -130,8 +156,10 @@ func handleLogin(ctx *gin.Context) {
 // @Param        userCreds  body  model.UserCredentials  true  "User credentials payload"
 // @Success      200  {object}  model.APIResponse "Success" "Example: {\"message\": \"User credentials are valid.\"}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"password\", \"error\": \"password too weak\"}]}"
+// @Failure      401  {object}  utils.ErrorResponse "Invalid credentials" "Example: {\"code\": \"invalid_credentials\", \"message\": \"Invalid email or password !\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited or locked out" "Example: {\"code\": \"login_locked\", \"message\": \"Too many failed login attempts, please try again later\"}"
 // @Router       /user/verify-credentials [post]
-func handleVerifyCredentials(ctx *gin.Context) {
+func (h *Handler) handleVerifyCredentials(ctx *gin.Context) {
 	var userCreds model.UserCredentials
 	payloadErr := ctx.ShouldBindBodyWithJSON(&userCreds)
 
@@ -146,9 +174,16 @@ func handleVerifyCredentials(ctx *gin.Context) {
 		Password: userCreds.Password,
 	}
 
-	userErr := user.ValidateCredentials()
+	guardErr := h.LoginGuard.Check(ctx.Request.Context(), user.Email, ctx.ClientIP())
+	if guardErr != nil {
+		utils.HandleError(ctx, guardErr)
+		return
+	}
+
+	userErr := user.ValidateCredentials(ctx.Request.Context(), h.Store.Users)
 	if userErr != nil {
-		utils.HandleValidationError(ctx, userErr)
+		h.recordLoginFailure(ctx, user, userErr)
+		utils.HandleError(ctx, userErr)
 		return
 	}
 
@@ -157,6 +192,174 @@ func handleVerifyCredentials(ctx *gin.Context) {
 	})
 }
 
+// @Summary      Request Magic Link
+// @Description  Emails a one-time sign-in link. The link only works on the device (browser) that requested it. Emails without an account get the same answer but no mail.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        magicLinkRequest  body  model.MagicLinkRequest  true  "Magic link payload"
+// @Success      200  {object}  model.APIResponse "Success" "Example: {\"message\": \"Sign-in link sent\"}"
+// @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"email\", \"error\": \"invalid email\"}]}"
+// @Failure      429  {object}  utils.ErrorResponse "Link sent recently" "Example: {\"code\": \"otp_recently_sent\", \"message\": \"OTP already sent recently\"}"
+// @Router       /user/magic-link [post]
+func (h *Handler) handleSendMagicLink(ctx *gin.Context) {
+	var magicLinkRequest model.MagicLinkRequest
+	payloadErr := ctx.ShouldBindJSON(&magicLinkRequest)
+
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	otp := model.Otp{
+		Key:         magicLinkRequest.Email,
+		Type:        model.OtpTypeEmail,
+		Action:      model.OtpActionTypeMagicLogin,
+		Fingerprint: model.DeviceFingerprint(ctx.Request.UserAgent(), ctx.GetHeader("Accept-Language")),
+	}
+	otpErr := otp.Generate(ctx.Request.Context(), h.Store.Otps, h.Store.Users)
+	if otpErr != nil {
+		utils.HandleError(ctx, otpErr)
+		return
+	}
+
+	h.auditOtpSent(ctx, otp)
+	if otp.Deliverable() {
+		go mail.SendMagicLinkMail(context.WithoutCancel(ctx.Request.Context()), otp)
+	}
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Sign-in link sent",
+	})
+}
+
+// @Summary      Magic Link Login
+// @Description  Exchanges a magic link for a JWT token pair. Links are single-use, expire quickly and must be opened on the requesting device. Links opened while the account or IP is locked out are refused and keep working afterwards. Accounts with TOTP enabled get a model.TotpChallengeResponse instead, to be completed at /user/login/2fa.
+// @Tags         Auth
+// @Produce      json
+// @Param        token  path  string  true  "Token from the emailed link"
+// @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success" "Example: {\"message\": \"User logged in successfully !\", \"data\": {\"token\": \"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9...\"}}"
+// @Failure      400  {object}  utils.ErrorResponse "Invalid link" "Example: {\"code\": \"magic_link_invalid\", \"message\": \"Sign-in link is invalid or was already used\"}"
+// @Failure      403  {object}  utils.ErrorResponse "Other device" "Example: {\"code\": \"magic_link_device_mismatch\", \"message\": \"Open the sign-in link on the device you requested it from\"}"
+// @Failure      410  {object}  utils.ErrorResponse "Link expired" "Example: {\"code\": \"magic_link_expired\", \"message\": \"Sign-in link has expired. Please request a new one.\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited or locked out" "Example: {\"code\": \"login_locked\", \"message\": \"Too many failed login attempts, please try again later\"}"
+// @Router       /user/magic/{token} [get]
+func (h *Handler) handleMagicLogin(ctx *gin.Context) {
+	fingerprint := model.DeviceFingerprint(ctx.Request.UserAgent(), ctx.GetHeader("Accept-Language"))
+
+	user, loginErr := model.LoginWithMagicLink(ctx.Request.Context(), h.Store.Otps, h.Store.Users, h.LoginGuard, ctx.ClientIP(), ctx.Param("token"), fingerprint)
+	if loginErr != nil {
+		utils.HandleError(ctx, loginErr)
+		return
+	}
+
+	if h.challengeSecondFactor(ctx, user, "magic_link") {
+		return
+	}
+	h.respondLogin(ctx, user, "magic_link")
+}
+
+// @Summary      Complete Two-Factor Login
+// @Description  Exchanges the challenge returned by a magic link or SSO login to an account with TOTP enabled, together with a TOTP or recovery code, for the token pair.
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        login  body  model.CompleteTotpLogin  true  "Challenge and second factor"
+// @Success      200  {object}  model.APIResponse{data=model.LoginUserResponse} "Success"
+// @Failure      400  {object}  utils.ErrorResponse "Validation error"
+// @Failure      401  {object}  utils.ErrorResponse "Invalid or expired challenge, invalid code" "Example: {\"code\": \"totp_challenge_invalid\", \"message\": \"Sign-in has expired, please sign in again\"}"
+// @Failure      429  {object}  utils.ErrorResponse "Rate limited or locked out" "Example: {\"code\": \"login_locked\", \"message\": \"Too many failed login attempts, please try again later\"}"
+// @Router       /user/login/2fa [post]
+func (h *Handler) handleTotpLogin(ctx *gin.Context) {
+	var login model.CompleteTotpLogin
+	payloadErr := ctx.ShouldBindJSON(&login)
+	if payloadErr != nil {
+		utils.HandleValidationError(ctx, payloadErr)
+		return
+	}
+
+	user, challenge, challengeErr := model.OpenTotpChallenge(ctx.Request.Context(), h.Store.Users, login.Challenge)
+	if challengeErr != nil {
+		utils.HandleError(ctx, challengeErr)
+		return
+	}
+
+	guardErr := h.LoginGuard.Check(ctx.Request.Context(), user.Email, ctx.ClientIP())
+	if guardErr != nil {
+		utils.HandleError(ctx, guardErr)
+		return
+	}
+
+	verifyErr := user.VerifySecondFactor(ctx.Request.Context(), h.Store.Totps, login.SecondFactor)
+	if verifyErr != nil {
+		h.recordLoginFailure(ctx, user, verifyErr)
+		utils.HandleError(ctx, verifyErr)
+		return
+	}
+
+	if successErr := h.LoginGuard.RecordSuccess(ctx.Request.Context(), user.Email); successErr != nil {
+		utils.Log.Error("Error clearing failed logins: ", successErr)
+	}
+
+	secondFactor := "totp"
+	if login.TotpCode == "" {
+		secondFactor = "recovery_code"
+	}
+	h.respondLogin(ctx, user, challenge.Method+"+"+secondFactor)
+}
+
+// respondLogin hands out the token pair for a completed sign-in
+func (h *Handler) respondLogin(ctx *gin.Context, user model.User, method string) {
+	token, tokenErr := user.GenerateJWT()
+	if tokenErr != nil {
+		utils.HandleError(ctx, tokenErr)
+		return
+	}
+
+	refreshToken, refreshTokenErr := user.GenerateRefreshJWT(ctx.Request.Context(), h.Store.RefreshTokens)
+	if refreshTokenErr != nil {
+		utils.HandleError(ctx, refreshTokenErr)
+		return
+	}
+
+	h.auditLogin(ctx, user, method)
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "User logged in successfully !",
+		Data:    model.LoginUserResponse{Token: token, RefreshToken: refreshToken},
+	})
+}
+
+// challengeSecondFactor answers a sign-in that didn't ask for the second
+// factor with a TOTP challenge when the user has TOTP enabled. It reports
+// whether it responded.
+func (h *Handler) challengeSecondFactor(ctx *gin.Context, user model.User, method string) bool {
+	hasTotp, totpErr := user.HasTotp(ctx.Request.Context(), h.Store.Totps)
+	if totpErr != nil {
+		utils.HandleError(ctx, totpErr)
+		return true
+	}
+	if !hasTotp {
+		return false
+	}
+
+	// Suspended users get the same answer with or without the second factor
+	if activeErr := user.CheckActive(); activeErr != nil {
+		utils.HandleError(ctx, activeErr)
+		return true
+	}
+
+	challenge, challengeErr := user.NewTotpChallenge(method)
+	if challengeErr != nil {
+		utils.HandleError(ctx, challengeErr)
+		return true
+	}
+
+	ctx.JSON(http.StatusOK, model.APIResponse{
+		Message: "Two-factor authentication required, complete the login at /user/login/2fa",
+		Data:    model.TotpChallengeResponse{TotpRequired: true, Challenge: challenge},
+	})
+	return true
+}
+
 // @Summary      User Sign Up
 // @Description  Register a new user with email, password, and OTP verification.
 // @Tags         Auth
@@ -165,8 +368,9 @@ func handleVerifyCredentials(ctx *gin.Context) {
 // @Param        userToSignUp  body  model.SignUpUser  true  "Sign up payload"
 // @Success      201  {object}  model.APIResponse "Success" "Example: {\"message\": \"User signed up successfully !\"}"
 // @Failure      400  {object}  utils.ErrorResponse "Validation error" "Example: {\"message\": \"Request failed\", \"errors\": [{\"field\": \"otp_code\", \"error\": \"invalid OTP\"}]}"
+// @Failure      409  {object}  utils.ErrorResponse "User exists" "Example: {\"code\": \"user_exists\", \"message\": \"user already exists !\"}"
 // @Router       /user/sign-up [post]
-func handleSignUp(ctx *gin.Context) {
+func (h *Handler) handleSignUp(ctx *gin.Context) {
 	var userToSignUp model.SignUpUser
 	payloadErr := ctx.ShouldBindBodyWithJSON(&userToSignUp)
 
@@ -186,29 +390,62 @@ func handleSignUp(ctx *gin.Context) {
 		Otp:    userToSignUp.OtpCode,
 		Action: string(model.OtpActionTypeSignUp),
 	}
-	otpErr := otpVerify.Verify()
+	otpErr := otpVerify.VerifyWithUpdate(ctx.Request.Context(), h.Store.Otps) // Use the OTP up before saving, so it works once
 	if otpErr != nil {
-		utils.HandleValidationError(ctx, otpErr)
+		utils.HandleError(ctx, otpErr)
 		return
 	}
 
 	utils.Log.Info("OTP verified successfully")
 
-	saveErr := user.Save()
+	saveErr := user.Save(ctx.Request.Context(), h.Store.Users)
 	if saveErr != nil {
-		utils.HandleValidationError(ctx, saveErr)
+		utils.HandleError(ctx, saveErr)
 		return
 	}
 
-	otpVerifyErr := otpVerify.VerifyWithUpdate() // Mark OTP as success, but ignore any error
-	if otpVerifyErr != nil {
-		utils.Log.Error("Error updating OTP status to success: \n", otpVerifyErr)
-	}
-
 	utils.Log.Info("User signed up successfully: ", user.Email)
+	signUp := user.AuditEvent(model.AuditActionSignUp)
+	signUp.ActorID = user.ID
+	h.audit(ctx, signUp)
 
-	go mail.SendSignedUpUserMail(user)
+	go mail.SendSignedUpUserMail(context.WithoutCancel(ctx.Request.Context()), user)
 	ctx.JSON(http.StatusCreated, model.APIResponse{
 		Message: "User signed up successfully !",
 	})
 }
+
+// recordLoginFailure counts a rejected login towards the brute-force limits and
+// warns the account owner once it gets locked. Infrastructure errors (timeouts,
+// internal errors) are not the caller's fault and are not counted.
+func (h *Handler) recordLoginFailure(ctx *gin.Context, user model.User, loginErr error) {
+	switch utils.AsAppError(loginErr).Kind {
+	case utils.ErrorKindUnauthorized, utils.ErrorKindNotFound, utils.ErrorKindBadRequest, utils.ErrorKindGone:
+	default:
+		return
+	}
+
+	// The attempted email isn't kept, it may be a typo, a password typed into
+	// the wrong field or someone else's address. Known accounts are the target.
+	failedLogin := model.AuditEvent{
+		UserID:     user.ID,
+		Action:     model.AuditActionLoginFailed,
+		TargetType: model.AuditTargetUser,
+		Details:    map[string]string{"reason": utils.AsAppError(loginErr).Code},
+	}
+	if user.ID != 0 {
+		failedLogin.TargetID = strconv.FormatInt(user.ID, 10)
+	}
+	h.audit(ctx, failedLogin)
+
+	failure, failureErr := h.LoginGuard.RecordFailure(ctx.Request.Context(), user.Email, ctx.ClientIP())
+	if failureErr != nil {
+		utils.Log.Error("Error recording failed login: ", failureErr)
+		return
+	}
+
+	if failure.LockedOut && user.ID != 0 {
+		utils.Log.Warn("Account locked after failed logins: ", user.Email)
+		go mail.SendSuspiciousLoginMail(context.WithoutCancel(ctx.Request.Context()), user, failure, ctx.ClientIP(), ctx.Request.UserAgent())
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func (h *Handler) WellKnownRoutes(router *gin.RouterGroup) {
+	router.GET("/jwks.json", h.rateLimit(h.RateLimits.Default), h.handleJwks)
+}
+
+// @Summary      JSON Web Key Set
+// @Description  Public keys our JWTs are signed with, looked up by the `kid` header. Keys are rotated on a schedule, so verifiers should refresh this on unknown kids.
+// @Tags         Auth
+// @Produce      json
+// @Success      200  {object}  utils.JwkSet "Success" "Example: {\"keys\": [{\"kty\": \"RSA\", \"kid\": \"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs\", \"use\": \"sig\", \"alg\": \"RS256\", \"n\": \"...\", \"e\": \"AQAB\"}]}"
+// @Router       /.well-known/jwks.json [get]
+func (h *Handler) handleJwks(ctx *gin.Context) {
+	// Shorter than the activation delay of new keys
+	ctx.Header("Cache-Control", "public, max-age=600")
+	ctx.JSON(http.StatusOK, utils.JwtKeySet())
+}
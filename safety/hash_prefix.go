@@ -0,0 +1,124 @@
+package safety
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// hashPrefixList holds SHA-256 prefixes of unsafe URL expressions, like the
+// lists of the Safe Browsing API. The file is the whole list, there is no
+// full hash lookup, so a matching prefix flags the URL.
+type hashPrefixList struct {
+	prefixes map[int]map[string]bool // By prefix length in bytes
+	count    int
+}
+
+// loadHashPrefixList reads a file of hex encoded prefixes, 4 to 32 bytes
+// long, one per line
+func loadHashPrefixList(path string) (*hashPrefixList, error) {
+	list := &hashPrefixList{prefixes: map[int]map[string]bool{}}
+	if path == "" {
+		return list, nil
+	}
+
+	readErr := readListFile(path, func(line string) error {
+		prefix, err := hex.DecodeString(line)
+		if err != nil {
+			return err
+		}
+		if len(prefix) < 4 || len(prefix) > sha256.Size {
+			return fmt.Errorf("hash prefix must be 4 to 32 bytes long, got %d", len(prefix))
+		}
+
+		if list.prefixes[len(prefix)] == nil {
+			list.prefixes[len(prefix)] = map[string]bool{}
+		}
+		list.prefixes[len(prefix)][string(prefix)] = true
+		list.count++
+		return nil
+	})
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return list, nil
+}
+
+func (l *hashPrefixList) matches(target *url.URL) bool {
+	if l == nil || l.count == 0 {
+		return false
+	}
+
+	for _, expression := range urlExpressions(target) {
+		hash := sha256.Sum256([]byte(expression))
+		for length, prefixes := range l.prefixes {
+			if prefixes[string(hash[:length])] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (l *hashPrefixList) size() int {
+	if l == nil {
+		return 0
+	}
+	return l.count
+}
+
+// urlExpressions lists the host suffix and path prefix combinations a URL is
+// looked up by, e.g. for http://a.b.example.com/1/2.html?x=1:
+// a.b.example.com/1/2.html?x=1, a.b.example.com/1/2.html, a.b.example.com/,
+// a.b.example.com/1/, b.example.com/1/2.html?x=1 and so on, down to example.com
+func urlExpressions(target *url.URL) []string {
+	host := normalizeHost(target.Hostname())
+
+	hosts := []string{host}
+	if net.ParseIP(host) == nil {
+		labels := strings.Split(host, ".")
+		if len(labels) > 5 {
+			labels = labels[len(labels)-5:]
+		}
+		for i := 0; len(labels)-i >= 2 && len(hosts) < 5; i++ {
+			if suffix := strings.Join(labels[i:], "."); suffix != host {
+				hosts = append(hosts, suffix)
+			}
+		}
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	var paths []string
+	if target.RawQuery != "" {
+		paths = append(paths, path+"?"+target.RawQuery)
+	}
+	paths = append(paths, path)
+
+	prefix := "/"
+	components := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(components) && len(paths) < 6; i++ {
+		if prefix != path {
+			paths = append(paths, prefix)
+		}
+		if components[i] == "" || i == len(components)-1 {
+			break
+		}
+		prefix += components[i] + "/"
+	}
+
+	expressions := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			expressions = append(expressions, h+p)
+		}
+	}
+	return expressions
+}
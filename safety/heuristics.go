@@ -0,0 +1,206 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// latinLookalikes are Cyrillic and Greek letters that look like Latin ones
+const latinLookalikes = "аеорсухіјѕԁӏԛԝвкмнтαορτυικνϲ"
+
+var scripts = []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Armenian, unicode.Han, unicode.Arabic, unicode.Hebrew}
+
+// checkHost flags destinations that are typical for phishing: IP address
+// hosts, credentials in the URL and internationalized domains imitating
+// Latin ones. It returns the reason or an empty string.
+func checkHost(target *url.URL) string {
+	host := normalizeHost(target.Hostname())
+
+	if isIPLiteral(host) {
+		return "Host is an IP address"
+	}
+
+	if target.User != nil {
+		return "URL contains credentials, often used to disguise the real host"
+	}
+
+	for _, label := range strings.Split(host, ".") {
+		if !strings.HasPrefix(label, "xn--") {
+			continue
+		}
+
+		decoded, err := idna.Punycode.ToUnicode(label)
+		if err != nil {
+			return "Host has an invalid punycode label"
+		}
+		if isLookalike(decoded) {
+			return fmt.Sprintf("Host imitates a Latin domain (%s)", decoded)
+		}
+	}
+
+	return ""
+}
+
+// isIPLiteral also catches the decimal, hex and octal forms browsers accept,
+// e.g. http://3232235777 or http://0xC0A80001
+func isIPLiteral(host string) bool {
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return true
+	}
+
+	for _, part := range strings.Split(host, ".") {
+		part = strings.TrimPrefix(part, "0x")
+		if part == "" || strings.IndexFunc(part, func(r rune) bool { return !unicode.Is(unicode.ASCII_Hex_Digit, r) }) >= 0 {
+			return false
+		}
+	}
+	// All labels numeric, a real domain has a top level domain with letters
+	last := host[strings.LastIndex(host, ".")+1:]
+	return strings.IndexFunc(last, func(r rune) bool { return r < '0' || r > '9' }) < 0 || strings.HasPrefix(last, "0x")
+}
+
+// isLookalike reports labels that mix scripts or consist only of letters
+// looking like Latin ones, e.g. "аррӏе" in Cyrillic
+func isLookalike(label string) bool {
+	var seen *unicode.RangeTable
+	allLookalikes := true
+
+	for _, r := range label {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+
+		for _, script := range scripts {
+			if unicode.Is(script, r) {
+				if seen != nil && seen != script {
+					return true
+				}
+				seen = script
+				break
+			}
+		}
+
+		if !strings.ContainsRune(latinLookalikes, r) {
+			allLookalikes = false
+		}
+	}
+
+	return seen != nil && seen != unicode.Latin && allLookalikes
+}
+
+// followRedirects requests the destination and returns the URLs it redirects
+// to, or tooMany when the chain is longer than max. Unreachable destinations
+// end the chain, they aren't flagged.
+func followRedirects(ctx context.Context, client *http.Client, target *url.URL, max int) (hops []*url.URL, tooMany bool) {
+	current := target
+	for i := 0; i <= max; i++ {
+		next, err := redirectLocation(ctx, client, current)
+		if err != nil || next == nil {
+			return hops, false
+		}
+
+		hops = append(hops, next)
+		current = next
+	}
+	return hops, true
+}
+
+func redirectLocation(ctx context.Context, client *http.Client, target *url.URL) (*url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return nil, nil
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// NewHTTPClient returns a client for following redirects of destinations.
+// It doesn't follow redirects itself and refuses to connect to private
+// addresses, so links can't be used to probe the internal network.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if isPrivateAddress(host) {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+var errPrivateAddress = errors.New("destination resolves to a private address")
+
+// reservedPrefixes are ranges that aren't covered by the net.IP predicates
+// but don't reach the public internet either
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This network"
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT, cloud metadata like 100.100.100.200
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, embeds any IPv4 address
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// isPrivateAddress reports addresses of the local network, the host itself
+// and other ranges a destination must not resolve to. Hosts that aren't IP
+// addresses count as private.
+func isPrivateAddress(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+	addr = addr.Unmap().WithZone("")
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(2 * time.Second)
+	for name, url := range map[string]string{
+		"loopback":           server.URL,
+		"loopback ipv6":      "http://[::1]/",
+		"private":            "http://10.0.0.1/",
+		"private 192.168":    "http://192.168.1.1/",
+		"private ipv6":       "http://[fd00::1]/",
+		"link-local":         "http://169.254.169.254/latest/meta-data/",
+		"link-local ipv6":    "http://[fe80::1]/",
+		"unspecified":        "http://0.0.0.0/",
+		"this network":       "http://0.1.2.3/",
+		"carrier-grade nat":  "http://100.100.100.200/latest/meta-data/",
+		"nat64":              "http://[64:ff9b::a9fe:a9fe]/",
+		"multicast":          "http://224.0.0.1/",
+		"multicast ipv6":     "http://[ff02::1]/",
+		"ipv4-mapped":        "http://[::ffff:127.0.0.1]/",
+		"localhost hostname": "http://localhost/",
+	} {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(context.Background(), http.MethodGet, url, nil)
+			resp, err := client.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("GET %s succeeded, want it refused", url)
+			}
+			if !errors.Is(err, errPrivateAddress) {
+				t.Fatalf("GET %s = %v, want %v", url, err, errPrivateAddress)
+			}
+		})
+	}
+}
+
+func TestHTTPClientDoesNotFollowRedirects(t *testing.T) {
+	client := NewHTTPClient(time.Second)
+	if client.CheckRedirect == nil {
+		t.Fatal("CheckRedirect is nil, redirects would be followed")
+	}
+	if err := client.CheckRedirect(nil, nil); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Fatalf("CheckRedirect() = %v, want %v", err, http.ErrUseLastResponse)
+	}
+}
+
+func TestIsPrivateAddress(t *testing.T) {
+	for host, want := range map[string]bool{
+		"93.184.216.34":      false,
+		"2606:2800:220:1::1": false,
+		"100.63.255.255":     false,
+		"100.64.0.1":         true,
+		"100.127.255.255":    true,
+		"172.16.0.1":         true,
+		"fe80::1%eth0":       true,
+		"64:ff9b::808:808":   true,
+		"::ffff:10.0.0.1":    true,
+		"255.255.255.255":    true,
+		"example.com":        true,
+	} {
+		if got := isPrivateAddress(host); got != want {
+			t.Errorf("isPrivateAddress(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
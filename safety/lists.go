@@ -0,0 +1,122 @@
+package safety
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// hostList matches hosts against domains, IP addresses and CIDR ranges.
+// A domain matches its subdomains too.
+type hostList struct {
+	domains map[string]bool
+	nets    []*net.IPNet
+}
+
+// loadHostList reads list files with one domain, IP address or CIDR range
+// per line. Blank lines and lines starting with # are skipped, so hosts
+// files and most published blocklists can be used as they are.
+func loadHostList(paths []string) (*hostList, error) {
+	list := &hostList{domains: map[string]bool{}}
+
+	for _, path := range paths {
+		readErr := readListFile(path, func(line string) error {
+			fields := strings.Fields(line)
+			// hosts file format: "0.0.0.0 example.com"
+			entry := fields[len(fields)-1]
+
+			if strings.Contains(entry, "/") {
+				_, ipNet, err := net.ParseCIDR(entry)
+				if err != nil {
+					return err
+				}
+				list.nets = append(list.nets, ipNet)
+				return nil
+			}
+
+			if ip := net.ParseIP(entry); ip != nil {
+				list.nets = append(list.nets, singleIPNet(ip))
+				return nil
+			}
+
+			list.domains[normalizeHost(entry)] = true
+			return nil
+		})
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+
+	return list, nil
+}
+
+func (l *hostList) contains(host string) bool {
+	if l == nil {
+		return false
+	}
+
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		for _, ipNet := range l.nets {
+			if ipNet.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for domain := host; domain != ""; {
+		if l.domains[domain] {
+			return true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+	return false
+}
+
+func (l *hostList) size() int {
+	if l == nil {
+		return 0
+	}
+	return len(l.domains) + len(l.nets)
+}
+
+func singleIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// readListFile calls fn with every line that isn't blank or a comment
+func readListFile(path string, fn func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		if err := fn(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+	}
+	return scanner.Err()
+}
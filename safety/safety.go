@@ -0,0 +1,213 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+const (
+	CheckBlocklist  = "blocklist"
+	CheckHashPrefix = "hash_prefix"
+	CheckHeuristics = "heuristics"
+)
+
+// lists are the checks loaded from files, replaced as a whole on reload
+type lists struct {
+	allow    *hostList
+	block    *hostList
+	prefixes *hashPrefixList
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Checker runs the blocklists, hash prefixes and heuristics on destinations.
+// List files are reloaded when they change, so they can be updated without a
+// restart.
+type Checker struct {
+	client *http.Client // Follows redirects, nil skips that heuristic
+
+	mu     sync.RWMutex
+	lists  lists
+	stamps map[string]fileStamp
+}
+
+var (
+	checker     *Checker
+	checkerOnce sync.Once
+)
+
+// Init loads the configured lists and starts watching them for changes
+func Init() *Checker {
+	checkerOnce.Do(func() {
+		var client *http.Client
+		if config.Config.SAFETY.MaxRedirects > 0 {
+			client = NewHTTPClient(10 * time.Second)
+		}
+
+		checker = NewChecker(client)
+		if err := checker.Reload(); err != nil {
+			utils.Log.Error("Failed to load URL safety lists: ", err)
+		}
+		go checker.maintain()
+	})
+	return checker
+}
+
+// NewChecker creates a checker without lists, Reload loads them. client is
+// used to follow redirects of destinations.
+func NewChecker(client *http.Client) *Checker {
+	return &Checker{client: client, stamps: map[string]fileStamp{}}
+}
+
+var _ model.UrlChecker = (*Checker)(nil)
+
+func (c *Checker) Check(ctx context.Context, rawUrl string) (model.UrlVerdict, error) {
+	target, parseErr := url.Parse(rawUrl)
+	if parseErr != nil || target.Hostname() == "" {
+		return model.UrlVerdict{}, fmt.Errorf("invalid URL %q", rawUrl)
+	}
+
+	c.mu.RLock()
+	current := c.lists
+	c.mu.RUnlock()
+
+	host := normalizeHost(target.Hostname())
+	if current.allow.contains(host) {
+		return model.UrlVerdict{}, nil
+	}
+
+	if verdict := current.checkLists(target); verdict.Flagged {
+		return verdict, nil
+	}
+
+	if reason := checkHost(target); reason != "" {
+		return model.UrlVerdict{Flagged: true, Check: CheckHeuristics, Reason: reason}, nil
+	}
+
+	if c.client == nil {
+		return model.UrlVerdict{}, nil
+	}
+
+	// Every hop of the redirect chain has to pass the lists as well
+	hops, tooMany := followRedirects(ctx, c.client, target, config.Config.SAFETY.MaxRedirects)
+	if tooMany {
+		return model.UrlVerdict{Flagged: true, Check: CheckHeuristics, Reason: "Too many redirects"}, nil
+	}
+	for _, hop := range hops {
+		if current.allow.contains(normalizeHost(hop.Hostname())) {
+			continue
+		}
+		if verdict := current.checkLists(hop); verdict.Flagged {
+			verdict.Reason = "Redirects to a flagged destination: " + verdict.Reason
+			return verdict, nil
+		}
+	}
+
+	return model.UrlVerdict{}, nil
+}
+
+func (l lists) checkLists(target *url.URL) model.UrlVerdict {
+	if l.block.contains(normalizeHost(target.Hostname())) {
+		return model.UrlVerdict{Flagged: true, Check: CheckBlocklist, Reason: "Domain is on the blocklist"}
+	}
+	if l.prefixes.matches(target) {
+		return model.UrlVerdict{Flagged: true, Check: CheckHashPrefix, Reason: "URL is on the list of unsafe URLs"}
+	}
+	return model.UrlVerdict{}
+}
+
+// Reload reads the list files again if any of them changed
+func (c *Checker) Reload() error {
+	paths := configuredFiles()
+
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		stamps[path] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	c.mu.RLock()
+	unchanged := c.lists.block != nil && sameStamps(c.stamps, stamps)
+	c.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	allow, allowErr := loadHostList(config.Config.SAFETY.Allowlists)
+	if allowErr != nil {
+		return allowErr
+	}
+	block, blockErr := loadHostList(config.Config.SAFETY.Blocklists)
+	if blockErr != nil {
+		return blockErr
+	}
+	prefixes, prefixErr := loadHashPrefixList(config.Config.SAFETY.HashPrefixes)
+	if prefixErr != nil {
+		return prefixErr
+	}
+
+	c.mu.Lock()
+	c.lists = lists{allow: allow, block: block, prefixes: prefixes}
+	c.stamps = stamps
+	c.mu.Unlock()
+
+	utils.Log.Info("Loaded URL safety lists: ", block.size(), " blocked hosts, ", allow.size(), " allowed hosts, ", prefixes.size(), " hash prefixes")
+	return nil
+}
+
+func (c *Checker) maintain() {
+	if config.Config.SAFETY.ReloadInterval <= 0 || len(configuredFiles()) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(config.Config.SAFETY.ReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := c.Reload(); err != nil {
+			utils.Log.Error("Failed to reload URL safety lists: ", err)
+		}
+	}
+}
+
+func configuredFiles() []string {
+	var paths []string
+	paths = append(paths, config.Config.SAFETY.Allowlists...)
+	paths = append(paths, config.Config.SAFETY.Blocklists...)
+	if config.Config.SAFETY.HashPrefixes != "" {
+		paths = append(paths, config.Config.SAFETY.HashPrefixes)
+	}
+	return paths
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		if other, ok := b[path]; !ok || !other.modTime.Equal(stamp.modTime) || other.size != stamp.size {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,172 @@
+package safety
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func init() {
+	utils.InitLogger()
+}
+
+// writeList writes a list file to the test's temporary directory
+func writeList(t *testing.T, name string, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// hashPrefix is the hex encoded 4 byte prefix of an URL expression
+func hashPrefix(expression string) string {
+	hash := sha256.Sum256([]byte(expression))
+	return hex.EncodeToString(hash[:4])
+}
+
+func newListChecker(t *testing.T) *Checker {
+	t.Helper()
+
+	config.Config.SAFETY.Blocklists = []string{writeList(t, "block.txt", "# Phishing\nevil.com\n0.0.0.0 hosts-file.org\n203.0.113.0/24\n198.51.100.7\n")}
+	config.Config.SAFETY.Allowlists = []string{writeList(t, "allow.txt", "cleared.evil.com\n")}
+	config.Config.SAFETY.HashPrefixes = writeList(t, "prefixes.txt", hashPrefix("phish.net/login/")+"\n")
+	t.Cleanup(func() {
+		config.Config.SAFETY.Blocklists, config.Config.SAFETY.Allowlists, config.Config.SAFETY.HashPrefixes = nil, nil, ""
+	})
+
+	checker := NewChecker(nil)
+	if err := checker.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	return checker
+}
+
+func TestCheck(t *testing.T) {
+	checker := newListChecker(t)
+
+	tests := []struct {
+		url   string
+		check string // Empty when the URL isn't flagged
+	}{
+		{"https://example.com/", ""},
+		{"https://evil.com/x", CheckBlocklist},
+		{"https://a.b.EVIL.com./x", CheckBlocklist},
+		{"https://notevil.com/", ""},
+		{"https://hosts-file.org/", CheckBlocklist},
+		{"http://203.0.113.99/", CheckBlocklist},
+		{"http://198.51.100.7/", CheckBlocklist},
+		{"http://198.51.100.8/", CheckHeuristics},
+		{"https://cleared.evil.com/", ""},
+		{"http://phish.net/login/x.html?a=1", CheckHashPrefix},
+		{"https://www.phish.net/login/", CheckHashPrefix},
+		{"https://phish.net/other", ""},
+		{"http://3232235777/", CheckHeuristics},
+		{"http://0xC0A80001/", CheckHeuristics},
+		{"https://user:pw@example.com/", CheckHeuristics},
+		{"https://xn--80ak6aa92e.com/", CheckHeuristics}, // аррӏе in Cyrillic
+		{"https://xn--pypal-4ve.com/", CheckHeuristics},  // pаypal with a Cyrillic а
+		{"https://xn--e1afmkfd.xn--p1ai/", ""},           // пример.рф, Cyrillic but no lookalike
+		{"https://xn--mnchen-3ya.de/", ""},               // münchen.de
+	}
+	for _, test := range tests {
+		verdict, err := checker.Check(context.Background(), test.url)
+		if err != nil {
+			t.Fatalf("Check(%q) error = %v", test.url, err)
+		}
+		if verdict.Flagged != (test.check != "") || verdict.Check != test.check {
+			t.Errorf("Check(%q) = %+v, want check %q", test.url, verdict, test.check)
+		}
+	}
+
+	if _, err := checker.Check(context.Background(), "not a url"); err == nil {
+		t.Error("Check() of an invalid URL succeeded")
+	}
+}
+
+func TestReloadPicksUpChangedLists(t *testing.T) {
+	checker := newListChecker(t)
+	if verdict, _ := checker.Check(context.Background(), "https://new.example/"); verdict.Flagged {
+		t.Fatalf("new.example flagged before it was listed: %+v", verdict)
+	}
+
+	path := config.Config.SAFETY.Blocklists[0]
+	if err := os.WriteFile(path, []byte("new.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if verdict, _ := checker.Check(context.Background(), "https://new.example/"); verdict.Check != CheckBlocklist {
+		t.Errorf("new.example after reload = %+v, want blocklisted", verdict)
+	}
+	if verdict, _ := checker.Check(context.Background(), "https://evil.com/"); verdict.Flagged {
+		t.Errorf("evil.com after it was removed = %+v, want not flagged", verdict)
+	}
+}
+
+func TestReloadRejectsInvalidLists(t *testing.T) {
+	for name, prefixes := range map[string]string{
+		"not hex":   "xyz\n",
+		"too short": "abcd\n",
+		"too long":  hex.EncodeToString(make([]byte, 33)) + "\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			config.Config.SAFETY.HashPrefixes = writeList(t, "prefixes.txt", prefixes)
+			defer func() { config.Config.SAFETY.HashPrefixes = "" }()
+
+			if err := NewChecker(nil).Reload(); err == nil {
+				t.Error("Reload() succeeded")
+			}
+		})
+	}
+}
+
+func TestUrlExpressions(t *testing.T) {
+	target, _ := url.Parse("http://a.b.example.com/1/2.html?x=1")
+	want := []string{
+		"a.b.example.com/1/2.html?x=1", "a.b.example.com/1/2.html", "a.b.example.com/", "a.b.example.com/1/",
+		"b.example.com/1/2.html?x=1", "b.example.com/1/2.html", "b.example.com/", "b.example.com/1/",
+		"example.com/1/2.html?x=1", "example.com/1/2.html", "example.com/", "example.com/1/",
+	}
+	if got := urlExpressions(target); !slices.Equal(got, want) {
+		t.Errorf("urlExpressions() =\n%q\nwant\n%q", got, want)
+	}
+
+	// IP addresses are looked up as they are
+	target, _ = url.Parse("http://1.2.3.4/")
+	if got := urlExpressions(target); !slices.Equal(got, []string{"1.2.3.4/"}) {
+		t.Errorf("urlExpressions() of an IP address = %q", got)
+	}
+}
+
+func TestIsLookalike(t *testing.T) {
+	for label, want := range map[string]bool{
+		"apple":   false,
+		"аррӏе":   true,  // All Cyrillic lookalikes
+		"pаypal":  true,  // Latin and Cyrillic mixed
+		"пример":  false, // Cyrillic, not imitating Latin
+		"münchen": false,
+		"例子":      false,
+	} {
+		if got := isLookalike(label); got != want {
+			t.Errorf("isLookalike(%q) = %v, want %v", label, got, want)
+		}
+	}
+}
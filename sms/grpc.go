@@ -0,0 +1,19 @@
+package sms
+
+import (
+	"context"
+
+	"kgoel085.com/url-shortner/config"
+	smsClient "kgoel085.com/url-shortner/proto/sms"
+)
+
+// GRPCProvider sends messages through the SMS gRPC service
+type GRPCProvider struct{}
+
+func (GRPCProvider) Send(ctx context.Context, toPhone string, message string) error {
+	return smsClient.SendSmsViaGRPC(ctx, smsClient.GrpcSendSmsRequest{
+		ToPhone:   toPhone,
+		Content:   message,
+		ProjectId: config.Config.APP.ProjectID,
+	})
+}
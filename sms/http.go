@@ -0,0 +1,65 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPGateway posts messages as JSON to a generic SMS gateway:
+// {"from": "...", "to": "+14155550123", "message": "..."}
+type HTTPGateway struct {
+	URL    string
+	Token  string // Sent as a bearer token when set
+	From   string
+	client *http.Client
+}
+
+func NewHTTPGateway(url, token, from string, client *http.Client) *HTTPGateway {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPGateway{URL: url, Token: token, From: from, client: client}
+}
+
+type httpGatewayRequest struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Message string `json:"message"`
+}
+
+func (g *HTTPGateway) Send(ctx context.Context, toPhone string, message string) error {
+	if g.URL == "" {
+		return fmt.Errorf("sms gateway url not configured")
+	}
+
+	body, err := json.Marshal(httpGatewayRequest{From: g.From, To: toPhone, Message: message})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.Token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sms gateway responded %d: %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
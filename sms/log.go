@@ -0,0 +1,15 @@
+package sms
+
+import (
+	"context"
+
+	"kgoel085.com/url-shortner/utils"
+)
+
+// LogProvider only logs messages, for local development
+type LogProvider struct{}
+
+func (LogProvider) Send(ctx context.Context, toPhone string, message string) error {
+	utils.Log.Info("SMS to ", toPhone, ": ", message)
+	return nil
+}
@@ -0,0 +1,71 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"kgoel085.com/url-shortner/config"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/utils"
+)
+
+// Provider delivers a text message to an E.164 phone number
+type Provider interface {
+	Send(ctx context.Context, toPhone string, message string) error
+}
+
+// NewProvider returns the provider selected by SMS_PROVIDER, nil when it
+// is not set. There is no default, codes must never end up in the log of a
+// deployment that just forgot to configure SMS.
+func NewProvider() (Provider, error) {
+	switch config.Config.SMS.Provider {
+	case "http":
+		if config.Config.SMS.HTTPURL == "" {
+			return nil, fmt.Errorf("SMS_PROVIDER is http but SMS_HTTP_URL is not set")
+		}
+		return NewHTTPGateway(config.Config.SMS.HTTPURL, config.Config.SMS.HTTPToken, config.Config.SMS.From, &http.Client{Timeout: config.Config.TIMEOUT.Mail}), nil
+	case "grpc":
+		if config.Config.GRPC.SmsServiceAddr == "" {
+			return nil, fmt.Errorf("SMS_PROVIDER is grpc but GRPC_SMS_SERVICE_ADDR is not set")
+		}
+		return GRPCProvider{}, nil
+	case "log":
+		utils.Log.Warn("SMS_PROVIDER is log, phone OTPs are written to the log and not delivered. Don't use it outside local development.")
+		return LogProvider{}, nil
+	case "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown SMS_PROVIDER %q", config.Config.SMS.Provider)
+}
+
+// OtpSender delivers phone OTPs through a Provider
+type OtpSender struct {
+	Provider Provider
+}
+
+// NewOtpSender returns the sender of phone OTPs, ok is false when no
+// provider is configured and phone OTPs are unavailable
+func NewOtpSender() (sender OtpSender, ok bool) {
+	provider, err := NewProvider()
+	if err != nil {
+		utils.Log.Error("Phone OTPs are disabled: ", err)
+		return OtpSender{}, false
+	}
+	if provider == nil {
+		utils.Log.Info("SMS_PROVIDER is not set, phone OTPs are disabled")
+		return OtpSender{}, false
+	}
+	return OtpSender{Provider: provider}, true
+}
+
+func (s OtpSender) Send(ctx context.Context, o model.Otp) error {
+	message := fmt.Sprintf("%s is your %s %s code. It expires in %d minutes, do not share it.", o.OtpCode, config.Config.APP.Name, o.Action, config.Config.OTP.ExpiryMinutes)
+
+	sendErr := s.Provider.Send(ctx, o.Key, message)
+	if sendErr != nil {
+		utils.Log.Error("Error sending OTP sms: ", sendErr)
+	}
+
+	return sendErr
+}
@@ -0,0 +1,177 @@
+package sms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"kgoel085.com/url-shortner/config"
+	smspb "kgoel085.com/url-shortner/grpc/sms"
+	"kgoel085.com/url-shortner/model"
+	"kgoel085.com/url-shortner/proto"
+	"kgoel085.com/url-shortner/utils"
+)
+
+func init() {
+	utils.InitLogger()
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		httpURL  string
+		grpcAddr string
+		want     string // Type of the provider, empty for none
+		wantErr  bool
+	}{
+		{name: "not set", provider: ""},
+		{name: "log", provider: "log", want: "sms.LogProvider"},
+		{name: "http", provider: "http", httpURL: "https://sms.example/send", want: "*sms.HTTPGateway"},
+		{name: "http without url", provider: "http", wantErr: true},
+		{name: "grpc", provider: "grpc", grpcAddr: "localhost:8012", want: "sms.GRPCProvider"},
+		{name: "grpc without address", provider: "grpc", wantErr: true},
+		{name: "unknown", provider: "carrier-pigeon", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.Config.SMS.Provider, config.Config.SMS.HTTPURL, config.Config.GRPC.SmsServiceAddr = test.provider, test.httpURL, test.grpcAddr
+			t.Cleanup(func() {
+				config.Config.SMS.Provider, config.Config.SMS.HTTPURL, config.Config.GRPC.SmsServiceAddr = "", "", ""
+			})
+
+			provider, err := NewProvider()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewProvider() error = %v, want error %v", err, test.wantErr)
+			}
+			got := ""
+			if provider != nil {
+				got = fmt.Sprintf("%T", provider)
+			}
+			if got != test.want {
+				t.Fatalf("NewProvider() = %s, want %s", got, test.want)
+			}
+
+			if _, ok := NewOtpSender(); ok != (test.want != "") {
+				t.Fatalf("NewOtpSender() ok = %v, want %v", ok, test.want != "")
+			}
+		})
+	}
+}
+
+func TestHTTPGateway(t *testing.T) {
+	var received httpGatewayRequest
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+		if received.To == "+15550000000" {
+			http.Error(w, "number blocked", http.StatusUnprocessableEntity)
+		}
+	}))
+	defer server.Close()
+
+	gateway := NewHTTPGateway(server.URL, "secret", "Shortner", nil)
+	if err := gateway.Send(context.Background(), "+14155550123", "123456 is your code"); err != nil {
+		t.Fatal(err)
+	}
+	if received != (httpGatewayRequest{From: "Shortner", To: "+14155550123", Message: "123456 is your code"}) || authorization != "Bearer secret" {
+		t.Errorf("gateway received %+v with authorization %q", received, authorization)
+	}
+
+	err := gateway.Send(context.Background(), "+15550000000", "123456 is your code")
+	if err == nil || !strings.Contains(err.Error(), "422") || !strings.Contains(err.Error(), "number blocked") {
+		t.Errorf("Send() to a blocked number error = %v, want the gateway's answer", err)
+	}
+}
+
+// smsService answers like the SMS gRPC service, numbers it can't deliver to
+// get an empty ID
+type smsService struct {
+	smspb.UnimplementedSmsServiceServer
+	received []*smspb.SendSmsRequest
+}
+
+func (s *smsService) SendSms(ctx context.Context, req *smspb.SendSmsRequest) (*smspb.SendSmsResponse, error) {
+	s.received = append(s.received, req)
+	if req.ToPhone == "+15550000000" {
+		return &smspb.SendSmsResponse{}, nil
+	}
+	return &smspb.SendSmsResponse{Id: "sms-1"}, nil
+}
+
+func TestGRPCProvider(t *testing.T) {
+	config.Config.APP.ProjectID = "test"
+	config.Config.TIMEOUT.Mail = 5 * time.Second
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &smsService{}
+	server := grpc.NewServer()
+	smspb.RegisterSmsServiceServer(server, service)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	manager := proto.ClientManager
+	proto.ClientManager = proto.NewManager()
+	t.Cleanup(func() {
+		proto.ClientManager.CloseAll()
+		proto.ClientManager = manager
+	})
+
+	// Not connected yet
+	if err := (GRPCProvider{}).Send(context.Background(), "+14155550123", "123456 is your code"); err == nil {
+		t.Fatal("Send() without a connection succeeded")
+	}
+
+	if _, err := proto.ClientManager.Connect(string(proto.SmsServiceClientType), listener.Addr().String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := (GRPCProvider{}).Send(context.Background(), "+14155550123", "123456 is your code"); err != nil {
+		t.Fatal(err)
+	}
+	if len(service.received) != 1 || service.received[0].ToPhone != "+14155550123" || service.received[0].Content != "123456 is your code" || service.received[0].ProjectId != "test" {
+		t.Errorf("service received %v", service.received)
+	}
+
+	if err := (GRPCProvider{}).Send(context.Background(), "+15550000000", "123456 is your code"); err == nil {
+		t.Error("Send() without a message ID succeeded")
+	}
+}
+
+// recordingProvider remembers the messages it was asked to send
+type recordingProvider struct {
+	messages map[string]string
+}
+
+func (p *recordingProvider) Send(ctx context.Context, toPhone string, message string) error {
+	p.messages[toPhone] = message
+	return nil
+}
+
+func TestOtpSender(t *testing.T) {
+	config.Config.APP.Name = "Shortner"
+	config.Config.OTP.ExpiryMinutes = 5
+
+	provider := &recordingProvider{messages: map[string]string{}}
+	otp := model.Otp{Key: "+14155550123", OtpCode: "123456", Action: model.OtpActionTypeSignUp}
+	if err := (OtpSender{Provider: provider}).Send(context.Background(), otp); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "123456 is your Shortner signup code. It expires in 5 minutes, do not share it."
+	if got := provider.messages["+14155550123"]; got != want {
+		t.Errorf("message = %q, want %q", got, want)
+	}
+}
@@ -1,12 +1,19 @@
 package utils
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/sirupsen/logrus"
+	"kgoel085.com/url-shortner/config"
 	customValidator "kgoel085.com/url-shortner/validator"
 )
 
@@ -18,45 +25,249 @@ type ErrorDetail struct {
 
 // ErrorResponse is a generic error response for API errors
 type ErrorResponse struct {
+	Code    string        `json:"code" example:"validation_failed"`
 	Message string        `json:"message" example:"Request failed"`
 	Errors  []ErrorDetail `json:"errors,omitempty"`
 }
 
+// ProblemResponse is the RFC 7807 (application/problem+json) variant of ErrorResponse
+type ProblemResponse struct {
+	Type     string        `json:"type" example:"about:blank"`
+	Title    string        `json:"title" example:"Not Found"`
+	Status   int           `json:"status" example:"404"`
+	Detail   string        `json:"detail" example:"no URL found for the provided code"`
+	Instance string        `json:"instance" example:"/abc123"`
+	Code     string        `json:"code" example:"url_not_found"`
+	Errors   []ErrorDetail `json:"errors,omitempty"`
+}
+
+const problemContentType = "application/problem+json"
+
+type ErrorKind string
+
+const (
+	ErrorKindBadRequest   ErrorKind = "bad_request"
+	ErrorKindNotFound     ErrorKind = "not_found"
+	ErrorKindConflict     ErrorKind = "conflict"
+	ErrorKindUnauthorized ErrorKind = "unauthorized"
+	ErrorKindForbidden    ErrorKind = "forbidden"
+	ErrorKindGone         ErrorKind = "gone"
+	ErrorKindRateLimited  ErrorKind = "rate_limited"
+	ErrorKindTimeout      ErrorKind = "timeout"
+	ErrorKindUnavailable  ErrorKind = "unavailable"
+	ErrorKindInternal     ErrorKind = "internal"
+)
+
+var errorKindStatus = map[ErrorKind]int{
+	ErrorKindBadRequest:   http.StatusBadRequest,
+	ErrorKindNotFound:     http.StatusNotFound,
+	ErrorKindConflict:     http.StatusConflict,
+	ErrorKindUnauthorized: http.StatusUnauthorized,
+	ErrorKindForbidden:    http.StatusForbidden,
+	ErrorKindGone:         http.StatusGone,
+	ErrorKindRateLimited:  http.StatusTooManyRequests,
+	ErrorKindTimeout:      http.StatusGatewayTimeout,
+	ErrorKindUnavailable:  http.StatusServiceUnavailable,
+	ErrorKindInternal:     http.StatusInternalServerError,
+}
+
+// AppError is a domain error with a stable machine-readable code. Message is
+// returned to clients as is, Err is only ever logged.
+type AppError struct {
+	Kind       ErrorKind
+	Code       string
+	Message    string
+	Err        error
+	RetryAfter time.Duration // Sent as Retry-After when set
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches app errors by kind and code, so a sentinel still matches after
+// a cause has been attached with Wrap
+func (e *AppError) Is(target error) bool {
+	t, ok := target.(*AppError)
+	return ok && t.Kind == e.Kind && t.Code == e.Code
+}
+
+// Wrap returns a copy of the error carrying cause for logging
+func (e *AppError) Wrap(cause error) *AppError {
+	wrapped := *e
+	wrapped.Err = cause
+	return &wrapped
+}
+
+// WithRetryAfter returns a copy of the error telling clients when to retry
+func (e *AppError) WithRetryAfter(d time.Duration) *AppError {
+	withRetry := *e
+	withRetry.RetryAfter = d
+	return &withRetry
+}
+
+// Status returns the HTTP status code the error maps to
+func (e *AppError) Status() int {
+	if status, ok := errorKindStatus[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func newAppError(kind ErrorKind, code, message string) *AppError {
+	return &AppError{Kind: kind, Code: code, Message: message}
+}
+
+func BadRequest(code, message string) *AppError {
+	return newAppError(ErrorKindBadRequest, code, message)
+}
+
+func NotFound(code, message string) *AppError {
+	return newAppError(ErrorKindNotFound, code, message)
+}
+
+func Conflict(code, message string) *AppError {
+	return newAppError(ErrorKindConflict, code, message)
+}
+
+func Unauthorized(code, message string) *AppError {
+	return newAppError(ErrorKindUnauthorized, code, message)
+}
+
+func Forbidden(code, message string) *AppError {
+	return newAppError(ErrorKindForbidden, code, message)
+}
+
+func Gone(code, message string) *AppError {
+	return newAppError(ErrorKindGone, code, message)
+}
+
+func RateLimited(code, message string) *AppError {
+	return newAppError(ErrorKindRateLimited, code, message)
+}
+
+func Unavailable(code, message string) *AppError {
+	return newAppError(ErrorKindUnavailable, code, message)
+}
+
+// Internal hides err from clients behind a generic message
+func Internal(err error) *AppError {
+	return &AppError{Kind: ErrorKindInternal, Code: "internal_error", Message: "Something went wrong, please try again later", Err: err}
+}
+
+// AsAppError classifies any error into an AppError. Unknown errors become
+// internal errors so their details never reach the client.
+func AsAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &AppError{Kind: ErrorKindTimeout, Code: "timeout", Message: "Request timed out, please try again", Err: err}
+	case errors.Is(err, context.Canceled):
+		return &AppError{Kind: ErrorKindUnavailable, Code: "request_cancelled", Message: "Request was cancelled", Err: err}
+	}
+
+	return Internal(err)
+}
+
+// HandleValidationError responds to request binding errors. Field level
+// validation failures are listed individually, anything else (malformed
+// JSON, wrong types) is reported as an invalid request.
 func HandleValidationError(ctx *gin.Context, err error) {
 	if err == nil {
 		return
 	}
 
-	var ve validator.ValidationErrors
-
-	if errors.As(err, &ve) {
-		out := make([]ErrorDetail, len(ve))
-		for i, fe := range ve {
-			out[i] = ErrorDetail{
-				Field: fe.Field(),
-				Error: customValidator.MsgForTag(fe),
-			}
-		}
-
+	if out, ok := ValidationDetails(err); ok {
 		Log.WithFields(logrus.Fields{
 			"url":   ctx.Request.URL.Path,
 			"error": out,
 		}).Error("Request failed")
 
-		ctx.JSON(http.StatusBadRequest, ErrorResponse{
-			Message: "Request failed",
-			Errors:  out,
-		})
+		writeError(ctx, BadRequest("validation_failed", "Request failed"), out)
 		return
 	}
 
-	// Non-validation error
-	Log.WithFields(logrus.Fields{
+	HandleError(ctx, BadRequest("invalid_request", err.Error()))
+}
+
+// ValidationDetails lists the field errors of a failed struct validation
+func ValidationDetails(err error) ([]ErrorDetail, bool) {
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return nil, false
+	}
+
+	out := make([]ErrorDetail, len(ve))
+	for i, fe := range ve {
+		out[i] = ErrorDetail{
+			Field: fe.Field(),
+			Error: customValidator.MsgForTag(fe),
+		}
+	}
+	return out, true
+}
+
+// HandleError aborts the request with the status and code the error maps to
+func HandleError(ctx *gin.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	appErr := AsAppError(err)
+
+	fields := logrus.Fields{
 		"url":   ctx.Request.URL.Path,
+		"code":  appErr.Code,
 		"error": err.Error(),
-	}).Error("Request failed")
+	}
+	if appErr.Kind == ErrorKindInternal {
+		Log.WithFields(fields).Error("Request failed")
+	} else {
+		Log.WithFields(fields).Warn("Request failed")
+	}
+
+	writeError(ctx, appErr, nil)
+}
+
+func writeError(ctx *gin.Context, appErr *AppError, details []ErrorDetail) {
+	status := appErr.Status()
+
+	if appErr.RetryAfter > 0 {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(appErr.RetryAfter.Seconds()))))
+	}
+
+	if wantsProblemJSON(ctx) {
+		ctx.Header("Content-Type", problemContentType)
+		ctx.AbortWithStatusJSON(status, ProblemResponse{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Detail:   appErr.Message,
+			Instance: ctx.Request.URL.Path,
+			Code:     appErr.Code,
+			Errors:   details,
+		})
+		return
+	}
 
-	ctx.JSON(http.StatusBadRequest, ErrorResponse{
-		Message: err.Error(),
+	ctx.AbortWithStatusJSON(status, ErrorResponse{
+		Code:    appErr.Code,
+		Message: appErr.Message,
+		Errors:  details,
 	})
 }
+
+func wantsProblemJSON(ctx *gin.Context) bool {
+	return config.Config.APP.ProblemJSON || strings.Contains(ctx.GetHeader("Accept"), problemContentType)
+}
@@ -1,7 +1,11 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,101 +19,183 @@ const (
 	RefreshJwtType JwtType = "refresh"
 )
 
+// JwtClaims holds the application claims carried by our tokens
+type JwtClaims struct {
+	UserID int64
+	Plan   string
+	Role   string
+	ID     string // jti, empty for legacy tokens
+}
+
 type GenerateJwtWithClaims struct {
 	Claims      jwt.MapClaims `binding:"required"`
-	SecretKey   string        `binding:"required"`
+	Type        JwtType       `binding:"required"`
 	ExpiryInMin int64         `binding:"required"`
 }
 
-func GenerateLoginJWT(userID int64) (string, error) {
-	expiryInMin := config.Config.JWT.ExpiryMinutes
-	jwtSecretKey := config.Config.JWT.SecretKey
-
-	Log.Info("JWT GENERATION CONFIG ::", expiryInMin, jwtSecretKey)
+func GenerateLoginJWT(userID int64, plan string, role string) (string, error) {
 	payload := GenerateJwtWithClaims{
 		Claims: jwt.MapClaims{
-			"userId": userID,
+			"sub":  strconv.FormatInt(userID, 10),
+			"plan": plan,
+			"role": role,
 		},
-		SecretKey:   jwtSecretKey,
-		ExpiryInMin: expiryInMin,
-	}
-
-	token, err := generateJwtWithClaims(payload)
-	if err != nil {
-		return token, err
+		Type:        LoginJwtType,
+		ExpiryInMin: config.Config.JWT.ExpiryMinutes,
 	}
 
-	return token, nil
+	return generateJwtWithClaims(payload)
 }
 
 func GenerateRefreshJWT(userID int64) (string, error) {
-	expiryInMin := config.Config.JWT.RefreshExpiryMinutes
-	jwtSecretKey := config.Config.JWT.RefreshSecretKey
-
-	Log.Info("Refresh JWT GENERATION CONFIG ::", expiryInMin, jwtSecretKey)
 	payload := GenerateJwtWithClaims{
 		Claims: jwt.MapClaims{
-			"userId": userID,
+			"sub": strconv.FormatInt(userID, 10),
 		},
-		SecretKey:   jwtSecretKey,
-		ExpiryInMin: expiryInMin,
-	}
-
-	token, err := generateJwtWithClaims(payload)
-	if err != nil {
-		return token, err
+		Type:        RefreshJwtType,
+		ExpiryInMin: config.Config.JWT.RefreshExpiryMinutes,
 	}
 
-	return token, nil
+	return generateJwtWithClaims(payload)
 }
 
 func generateJwtWithClaims(payload GenerateJwtWithClaims) (string, error) {
-	claims := payload.Claims
-	claims["exp"] = time.Now().Add(time.Minute * time.Duration(payload.ExpiryInMin)).Unix()
+	key, keyErr := InitJwtKeys().signingKey()
+	if keyErr != nil {
+		return "", keyErr
+	}
 
-	Log.Info("Generating JWT with claims: ", claims)
-	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := jwtToken.SignedString([]byte(payload.SecretKey))
-	if err != nil {
-		return token, err
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
 	}
 
-	return token, nil
+	now := time.Now()
+	claims := payload.Claims
+	claims["iss"] = JwtIssuer()
+	claims["aud"] = config.Config.JWT.Audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(time.Minute * time.Duration(payload.ExpiryInMin)).Unix()
+	claims["jti"] = hex.EncodeToString(jti)
+	claims["token_type"] = string(payload.Type)
+
+	Log.Info("Generating ", payload.Type, " JWT for ", claims["sub"], " with key ", key.kid)
+	jwtToken := jwt.NewWithClaims(key.method, claims)
+	jwtToken.Header["kid"] = key.kid
+
+	return jwtToken.SignedString(key.private)
+}
+
+// JwtIssuer is the iss claim of our tokens
+func JwtIssuer() string {
+	if config.Config.JWT.Issuer != "" {
+		return config.Config.JWT.Issuer
+	}
+	return strings.TrimSuffix(GetShortUrl(""), "/")
 }
 
-func ValidateJWT(token string, tokenType JwtType) (int64, error) {
+func ValidateJWT(token string, tokenType JwtType) (JwtClaims, error) {
+	var jwtClaims JwtClaims
 	if tokenType == "" {
-		return 0, fmt.Errorf("token type is required")
+		return jwtClaims, fmt.Errorf("token type is required")
+	}
+
+	if isLegacyJWT(token) {
+		return validateLegacyJWT(token, tokenType)
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(JwtIssuer()),
+		jwt.WithAudience(config.Config.JWT.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+
+	parsedToken, err := parser.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := InitJwtKeys().verificationKey(kid)
+		if !ok {
+			return nil, fmt.Errorf("Unknown signing key !")
+		}
+		if key.method.Alg() != token.Method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method !")
+		}
+
+		return key.private.Public(), nil
+	})
+
+	if err != nil {
+		return jwtClaims, fmt.Errorf("Could not parse token - %s!", err.Error())
+	}
+
+	if !parsedToken.Valid {
+		return jwtClaims, fmt.Errorf("Invalid Token !")
+	}
+
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return jwtClaims, fmt.Errorf("Invalid claims !")
+	}
+
+	if claimType, _ := claims["token_type"].(string); claimType != string(tokenType) {
+		return jwtClaims, fmt.Errorf("Invalid token type !")
 	}
 
+	sub, _ := claims["sub"].(string)
+	userID, subErr := strconv.ParseInt(sub, 10, 64)
+	if subErr != nil {
+		return jwtClaims, fmt.Errorf("Invalid subject !")
+	}
+
+	jwtClaims.UserID = userID
+	jwtClaims.Plan, _ = claims["plan"].(string)
+	jwtClaims.Role, _ = claims["role"].(string)
+	jwtClaims.ID, _ = claims["jti"].(string)
+	return jwtClaims, nil
+}
+
+// isLegacyJWT reports whether the token was signed with JWT_SECRET or
+// JWT_REFRESH_SECRET by an older release
+func isLegacyJWT(token string) bool {
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	return err == nil && parsed.Method == jwt.SigningMethodHS256
+}
+
+// validateLegacyJWT accepts HS256 tokens while their secret is still
+// configured, so deploying asymmetric signing logs nobody out
+func validateLegacyJWT(token string, tokenType JwtType) (JwtClaims, error) {
+	var jwtClaims JwtClaims
+
 	jwtSecretKey := config.Config.JWT.SecretKey
 	if tokenType == RefreshJwtType {
 		jwtSecretKey = config.Config.JWT.RefreshSecretKey
 	}
+	if jwtSecretKey == "" {
+		return jwtClaims, fmt.Errorf("Unexpected signing method !")
+	}
 
 	parsedToken, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		_, ok := token.Method.(*jwt.SigningMethodHMAC)
-		if !ok {
-			return "", fmt.Errorf("Unexpected signing method !")
-		}
-
 		return []byte(jwtSecretKey), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
 
 	if err != nil {
-		return 0, fmt.Errorf("Could not parse token - %s!", err.Error())
+		return jwtClaims, fmt.Errorf("Could not parse token - %s!", err.Error())
 	}
 
 	if !parsedToken.Valid {
-		return 0, fmt.Errorf("Invalid Token !")
+		return jwtClaims, fmt.Errorf("Invalid Token !")
 	}
 
 	claims, ok := parsedToken.Claims.(jwt.MapClaims)
 	if !ok {
-		return 0, fmt.Errorf("Invalid claims !")
+		return jwtClaims, fmt.Errorf("Invalid claims !")
 	}
 
 	userId, _ := claims["userId"].(float64)
+	plan, _ := claims["plan"].(string)
 
-	return int64(userId), nil
+	jwtClaims.UserID = int64(userId)
+	jwtClaims.Plan = plan
+	return jwtClaims, nil
 }
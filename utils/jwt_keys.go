@@ -0,0 +1,460 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"kgoel085.com/url-shortner/config"
+)
+
+const (
+	// A new key is published in the JWKS this long before it starts signing,
+	// so verifiers caching the key set pick it up in time
+	jwtKeyActivationDelay = 15 * time.Minute
+	jwtKeyCreatedHeader   = "Created"
+	jwtKeyReloadInterval  = time.Minute
+	jwtKeyMinReload       = 10 * time.Second // Unknown kids trigger a reload at most this often
+	jwtKeyLockFile        = ".rotate.lock"
+	jwtKeyLockStale       = 30 * time.Second // A lock this old was left behind by a crashed instance
+	jwtKeyLockWait        = 50 * time.Millisecond
+)
+
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	createdAt time.Time
+	path      string
+}
+
+// Jwk is the public part of a signing key as published in the JWKS
+type Jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JwkSet is the document served at /.well-known/jwks.json
+type JwkSet struct {
+	Keys []Jwk `json:"keys"`
+}
+
+// JwtKeyRing holds the keys tokens are signed and verified with. Keys live as
+// PEM files in JWT_KEYS_DIR so every instance sharing the directory accepts
+// the tokens of the others.
+type JwtKeyRing struct {
+	refreshMu sync.Mutex // Serializes reloads so a rotation only happens once
+	mu        sync.RWMutex
+	keys      []*jwtKey // Newest first
+	loadedAt  time.Time
+}
+
+var (
+	jwtKeys     *JwtKeyRing
+	jwtKeysOnce sync.Once
+)
+
+// InitJwtKeys loads the signing keys, creating the first one if needed, and
+// starts the scheduled rotation
+func InitJwtKeys() *JwtKeyRing {
+	jwtKeysOnce.Do(func() {
+		jwtKeys = &JwtKeyRing{}
+		if err := jwtKeys.refresh(); err != nil {
+			Log.Fatal("Failed to load JWT signing keys: ", err)
+		}
+		go jwtKeys.maintain()
+	})
+	return jwtKeys
+}
+
+// JwtKeySet returns the public keys tokens may currently be signed with
+func JwtKeySet() JwkSet {
+	return InitJwtKeys().keySet()
+}
+
+// maintain reloads keys written by other instances and rotates them on schedule
+func (r *JwtKeyRing) maintain() {
+	ticker := time.NewTicker(jwtKeyReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := r.refresh(); err != nil {
+			Log.Error("Failed to refresh JWT signing keys: ", err)
+		}
+	}
+}
+
+// refresh reloads the keys directory, generates a key when rotation is due
+// and drops keys no unexpired token can be signed with anymore
+func (r *JwtKeyRing) refresh() error {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	keys, err := loadJwtKeys(config.Config.JWT.KeysDir)
+	if err != nil {
+		return err
+	}
+
+	if jwtRotationDue(keys) {
+		if keys, err = rotateJwtKeys(config.Config.JWT.KeysDir); err != nil {
+			return err
+		}
+	}
+
+	keys = pruneJwtKeys(keys)
+
+	r.mu.Lock()
+	r.keys = keys
+	r.loadedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// signingKey is the newest key that has been published long enough
+func (r *JwtKeyRing) signingKey() (*jwtKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.keys) == 0 {
+		return nil, fmt.Errorf("no JWT signing key available")
+	}
+
+	for _, key := range r.keys {
+		if time.Since(key.createdAt) >= jwtKeyActivationDelay {
+			return key, nil
+		}
+	}
+	return r.keys[len(r.keys)-1], nil // Only fresh keys, e.g. on first start
+}
+
+// verificationKey looks a key up by kid, reloading once for keys another
+// instance just created
+func (r *JwtKeyRing) verificationKey(kid string) (*jwtKey, bool) {
+	if key, ok := r.find(kid); ok {
+		return key, true
+	}
+
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) >= jwtKeyMinReload
+	r.mu.RUnlock()
+	if !stale {
+		return nil, false
+	}
+
+	if err := r.refresh(); err != nil {
+		Log.Error("Failed to refresh JWT signing keys: ", err)
+		return nil, false
+	}
+	return r.find(kid)
+}
+
+func (r *JwtKeyRing) find(kid string) (*jwtKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.kid == kid {
+			return key, true
+		}
+	}
+	return nil, false
+}
+
+func (r *JwtKeyRing) keySet() JwkSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	set := JwkSet{Keys: make([]Jwk, 0, len(r.keys))}
+	for _, key := range r.keys {
+		set.Keys = append(set.Keys, publicJwk(key))
+	}
+	return set
+}
+
+// rotateJwtKeys generates a new key while holding the directory lock. Keys are
+// re-read under the lock, so when several instances find rotation due at the
+// same time only the first one generates a key and the others load it.
+func rotateJwtKeys(dir string) ([]*jwtKey, error) {
+	unlock, err := lockJwtKeys(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	keys, err := loadJwtKeys(dir)
+	if err != nil || !jwtRotationDue(keys) {
+		return keys, err
+	}
+
+	key, err := generateJwtKey(config.Config.JWT.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := saveJwtKey(dir, key); err != nil {
+		return nil, err
+	}
+	Log.Info("Generated JWT signing key ", key.kid)
+	return append([]*jwtKey{key}, keys...), nil
+}
+
+// lockJwtKeys takes the rotation lock of the keys directory, shared by every
+// instance using it. The lock file is created exclusively, a stale one is
+// taken over.
+func lockJwtKeys(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, jwtKeyLockFile)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) >= jwtKeyLockStale {
+			Log.Error("Removing stale JWT key rotation lock ", path)
+			os.Remove(path)
+			continue
+		}
+		time.Sleep(jwtKeyLockWait)
+	}
+}
+
+// jwtRotationDue reports whether a new key has to be generated
+func jwtRotationDue(keys []*jwtKey) bool {
+	if len(keys) == 0 {
+		return true
+	}
+
+	newest := keys[0]
+	if newest.method.Alg() != config.Config.JWT.Algorithm {
+		return true // JWT_ALGORITHM changed
+	}
+
+	rotation := config.Config.JWT.KeyRotation
+	return rotation > 0 && time.Since(newest.createdAt) >= rotation
+}
+
+// pruneJwtKeys deletes keys that were replaced longer ago than the longest
+// token lifetime. The newest key is always kept.
+func pruneJwtKeys(keys []*jwtKey) []*jwtKey {
+	rotation := config.Config.JWT.KeyRotation
+	if rotation <= 0 || len(keys) < 2 {
+		return keys
+	}
+
+	maxTokenLife := time.Duration(max(config.Config.JWT.ExpiryMinutes, config.Config.JWT.RefreshExpiryMinutes)) * time.Minute
+	retention := rotation + jwtKeyActivationDelay + maxTokenLife
+
+	kept := keys[:1]
+	for _, key := range keys[1:] {
+		if time.Since(key.createdAt) < retention {
+			kept = append(kept, key)
+			continue
+		}
+
+		if err := os.Remove(key.path); err != nil && !os.IsNotExist(err) {
+			Log.Error("Failed to remove retired JWT signing key ", key.kid, ": ", err)
+		}
+		Log.Info("Retired JWT signing key ", key.kid)
+	}
+	return kept
+}
+
+func loadJwtKeys(dir string) ([]*jwtKey, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*jwtKey
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+
+		key, keyErr := readJwtKey(filepath.Join(dir, entry.Name()))
+		if keyErr != nil {
+			Log.Error("Skipping JWT signing key ", entry.Name(), ": ", keyErr)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].createdAt.After(keys[j].createdAt) })
+	return keys, nil
+}
+
+func readJwtKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	key, err := newJwtKey(signer)
+	if err != nil {
+		return nil, err
+	}
+
+	key.path = path
+
+	// Keys we generate record their creation time, others fall back to the file's
+	if created, parseErr := time.Parse(time.RFC3339, block.Headers[jwtKeyCreatedHeader]); parseErr == nil {
+		key.createdAt = created
+	} else if info, statErr := os.Stat(path); statErr == nil {
+		key.createdAt = info.ModTime()
+	}
+	return key, nil
+}
+
+func generateJwtKey(algorithm string) (*jwtKey, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch algorithm {
+	case jwt.SigningMethodRS256.Alg():
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q, use RS256 or EdDSA", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := newJwtKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	key.createdAt = time.Now().UTC()
+	return key, nil
+}
+
+func saveJwtKey(dir string, key *jwtKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	block := &pem.Block{
+		Type:    "PRIVATE KEY",
+		Headers: map[string]string{jwtKeyCreatedHeader: key.createdAt.Format(time.RFC3339)},
+		Bytes:   der,
+	}
+
+	// Write to a temp file first so other instances never read a partial key
+	tmp, err := os.CreateTemp(dir, ".jwt-key-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := pem.Encode(tmp, block); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	key.path = filepath.Join(dir, key.kid+".pem")
+	return os.Rename(tmp.Name(), key.path)
+}
+
+// newJwtKey derives the signing method and kid (RFC 7638 thumbprint) of a key
+func newJwtKey(signer crypto.Signer) (*jwtKey, error) {
+	key := &jwtKey{private: signer}
+
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PrivateKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", signer)
+	}
+
+	jwk := publicJwk(key)
+	var thumbprintInput []byte
+	if jwk.Kty == "RSA" {
+		thumbprintInput, _ = json.Marshal(struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N})
+	} else {
+		thumbprintInput, _ = json.Marshal(struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X})
+	}
+
+	thumbprint := sha256.Sum256(thumbprintInput)
+	key.kid = base64.RawURLEncoding.EncodeToString(thumbprint[:])
+	return key, nil
+}
+
+func publicJwk(key *jwtKey) Jwk {
+	jwk := Jwk{Kid: key.kid, Use: "sig", Alg: key.method.Alg()}
+
+	switch public := key.private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+	return jwk
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"kgoel085.com/url-shortner/config"
+)
+
+func init() { InitLogger() }
+
+// useJwtKeysDir points the JWT config at an empty keys directory
+func useJwtKeysDir(t *testing.T) string {
+	t.Helper()
+
+	old := config.Config.JWT
+	t.Cleanup(func() { config.Config.JWT = old })
+
+	dir := t.TempDir()
+	config.Config.JWT.KeysDir = dir
+	config.Config.JWT.Algorithm = jwt.SigningMethodEdDSA.Alg()
+	config.Config.JWT.KeyRotation = 720 * time.Hour
+	config.Config.JWT.ExpiryMinutes = 15
+	config.Config.JWT.RefreshExpiryMinutes = 60
+	config.Config.JWT.Audience = "test"
+	config.Config.JWT.Issuer = "https://sho.rt"
+	return dir
+}
+
+// useJwtKeyRing makes InitJwtKeys return ring for the rest of the test binary
+func useJwtKeyRing(ring *JwtKeyRing) {
+	jwtKeysOnce.Do(func() {})
+	jwtKeys = ring
+}
+
+// saveAgedJwtKey stores a key created age ago
+func saveAgedJwtKey(t *testing.T, dir string, algorithm string, age time.Duration) *jwtKey {
+	t.Helper()
+
+	key, err := generateJwtKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.createdAt = time.Now().Add(-age).UTC().Truncate(time.Second)
+	if err := saveJwtKey(dir, key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func pemFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestJwtKeyRotation(t *testing.T) {
+	dir := useJwtKeysDir(t)
+
+	ring := &JwtKeyRing{}
+	if err := ring.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if files := pemFiles(t, dir); len(files) != 1 {
+		t.Fatalf("first start wrote %d keys, want 1", len(files))
+	}
+	first, err := ring.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ring.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if files := pemFiles(t, dir); len(files) != 1 {
+		t.Fatalf("refresh before rotation is due wrote %d keys, want 1", len(files))
+	}
+
+	// Once the newest key is a rotation old a new one is added
+	dir = useJwtKeysDir(t)
+	old := saveAgedJwtKey(t, dir, jwt.SigningMethodEdDSA.Alg(), 721*time.Hour)
+	ring = &JwtKeyRing{}
+	if err := ring.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ring.keys) != 2 || ring.keys[1].kid != old.kid {
+		t.Fatalf("keys after rotation %v, want a new key before %s", ring.keys, old.kid)
+	}
+	if set := ring.keySet(); len(set.Keys) != 2 {
+		t.Errorf("key set has %d keys, want the new key published right away", len(set.Keys))
+	}
+
+	// The new key only signs after the activation delay
+	if key, _ := ring.signingKey(); key.kid != old.kid {
+		t.Errorf("signing with %s, want the old key %s until the new one is active", key.kid, old.kid)
+	}
+	ring.keys[0].createdAt = time.Now().Add(-jwtKeyActivationDelay)
+	if key, _ := ring.signingKey(); key.kid != ring.keys[0].kid {
+		t.Errorf("signing with %s, want the activated key %s", key.kid, ring.keys[0].kid)
+	}
+
+	if first.kid == "" || first.method != jwt.SigningMethodEdDSA {
+		t.Errorf("first key %+v, want an EdDSA key with a kid", first)
+	}
+}
+
+func TestJwtKeyRotationOnAlgorithmChange(t *testing.T) {
+	dir := useJwtKeysDir(t)
+	saveAgedJwtKey(t, dir, jwt.SigningMethodRS256.Alg(), time.Hour)
+
+	ring := &JwtKeyRing{}
+	if err := ring.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ring.keys) != 2 || ring.keys[0].method != jwt.SigningMethodEdDSA {
+		t.Fatalf("keys %v, want a new EdDSA key after JWT_ALGORITHM changed", ring.keys)
+	}
+}
+
+func TestPruneJwtKeys(t *testing.T) {
+	dir := useJwtKeysDir(t)
+
+	// Retention is rotation + activation delay + the longest token lifetime (60m)
+	retention := config.Config.JWT.KeyRotation + jwtKeyActivationDelay + time.Hour
+	newest := saveAgedJwtKey(t, dir, jwt.SigningMethodEdDSA.Alg(), 0)
+	recent := saveAgedJwtKey(t, dir, jwt.SigningMethodEdDSA.Alg(), retention-time.Hour)
+	retired := saveAgedJwtKey(t, dir, jwt.SigningMethodEdDSA.Alg(), retention+time.Hour)
+
+	kept := pruneJwtKeys([]*jwtKey{newest, recent, retired})
+	if len(kept) != 2 || kept[0] != newest || kept[1] != recent {
+		t.Fatalf("kept %v, want the newest and the recent key", kept)
+	}
+	if _, err := os.Stat(retired.path); !os.IsNotExist(err) {
+		t.Errorf("retired key file still there: %v", err)
+	}
+
+	// The newest key is never pruned, however old it is
+	kept = pruneJwtKeys([]*jwtKey{retired})
+	if len(kept) != 1 {
+		t.Errorf("kept %d keys, want the only key kept", len(kept))
+	}
+
+	config.Config.JWT.KeyRotation = 0
+	kept = pruneJwtKeys([]*jwtKey{newest, retired})
+	if len(kept) != 2 {
+		t.Errorf("kept %d keys with rotation disabled, want all", len(kept))
+	}
+}
+
+func TestConcurrentJwtKeyRotation(t *testing.T) {
+	dir := useJwtKeysDir(t)
+
+	// Replicas sharing the directory start at the same moment
+	rings := make([]*JwtKeyRing, 8)
+	var wg sync.WaitGroup
+	for i := range rings {
+		rings[i] = &JwtKeyRing{}
+		wg.Add(1)
+		go func(ring *JwtKeyRing) {
+			defer wg.Done()
+			if err := ring.refresh(); err != nil {
+				t.Error(err)
+			}
+		}(rings[i])
+	}
+	wg.Wait()
+
+	if files := pemFiles(t, dir); len(files) != 1 {
+		t.Fatalf("%d keys generated, want 1", len(files))
+	}
+	for _, ring := range rings {
+		if len(ring.keys) != 1 || ring.keys[0].kid != rings[0].keys[0].kid {
+			t.Fatalf("replica loaded %v, want the one shared key", ring.keys)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, jwtKeyLockFile)); !os.IsNotExist(err) {
+		t.Errorf("rotation lock left behind: %v", err)
+	}
+}
+
+func TestJwtKeyRotationTakesOverStaleLock(t *testing.T) {
+	dir := useJwtKeysDir(t)
+
+	lock := filepath.Join(dir, jwtKeyLockFile)
+	if err := os.WriteFile(lock, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	stale := time.Now().Add(-2 * jwtKeyLockStale)
+	if err := os.Chtimes(lock, stale, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	ring := &JwtKeyRing{}
+	if err := ring.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ring.keys) != 1 {
+		t.Errorf("%d keys, want a key generated after taking over the lock", len(ring.keys))
+	}
+}
+
+func TestValidateJWTSelectsKeyByKid(t *testing.T) {
+	dir := useJwtKeysDir(t)
+	saveAgedJwtKey(t, dir, jwt.SigningMethodEdDSA.Alg(), 2*time.Hour)
+	saveAgedJwtKey(t, dir, jwt.SigningMethodEdDSA.Alg(), time.Hour)
+
+	ring := &JwtKeyRing{}
+	if err := ring.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	useJwtKeyRing(ring)
+
+	// Tokens signed with either key verify against the key their kid names
+	for _, key := range ring.keys {
+		token := signTestJwt(t, key, key.kid)
+		claims, err := ValidateJWT(token, LoginJwtType)
+		if err != nil || claims.UserID != 7 {
+			t.Errorf("token signed with %s: %+v %v", key.kid, claims, err)
+		}
+	}
+
+	for name, token := range map[string]string{
+		"unknown kid": signTestJwt(t, ring.keys[0], "unknown"),
+		"wrong kid":   signTestJwt(t, ring.keys[0], ring.keys[1].kid),
+	} {
+		if _, err := ValidateJWT(token, LoginJwtType); err == nil {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+
+	token, err := GenerateLoginJWT(7, "free", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateJWT(token, RefreshJwtType); err == nil || !strings.Contains(err.Error(), "token type") {
+		t.Errorf("login token accepted as refresh token: %v", err)
+	}
+}
+
+func signTestJwt(t *testing.T, key *jwtKey, kid string) string {
+	t.Helper()
+
+	now := time.Now()
+	token := jwt.NewWithClaims(key.method, jwt.MapClaims{
+		"sub":        "7",
+		"iss":        JwtIssuer(),
+		"aud":        config.Config.JWT.Audience,
+		"iat":        now.Unix(),
+		"exp":        now.Add(time.Minute).Unix(),
+		"token_type": string(LoginJwtType),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key.private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestValidateLegacyJWT(t *testing.T) {
+	useJwtKeysDir(t)
+	config.Config.JWT.SecretKey = "login-secret"
+	config.Config.JWT.RefreshSecretKey = "refresh-secret"
+
+	legacy := func(secret string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"userId": 7,
+			"plan":   "pro",
+			"exp":    time.Now().Add(time.Minute).Unix(),
+		})
+		signed, err := token.SignedString([]byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	claims, err := ValidateJWT(legacy("login-secret"), LoginJwtType)
+	if err != nil || claims.UserID != 7 || claims.Plan != "pro" {
+		t.Fatalf("legacy login token: %+v %v", claims, err)
+	}
+	if _, err := ValidateJWT(legacy("refresh-secret"), RefreshJwtType); err != nil {
+		t.Errorf("legacy refresh token rejected: %v", err)
+	}
+	if _, err := ValidateJWT(legacy("refresh-secret"), LoginJwtType); err == nil {
+		t.Error("refresh token accepted as login token")
+	}
+
+	// Once the secret is removed legacy tokens stop working
+	config.Config.JWT.SecretKey = ""
+	if _, err := ValidateJWT(legacy("login-secret"), LoginJwtType); err == nil {
+		t.Error("legacy token accepted without JWT_SECRET")
+	}
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"kgoel085.com/url-shortner/config"
+)
+
+// OtpHashPrefix marks a stored OTP as hashed, rows without it predate hashing
+const OtpHashPrefix = "hmac-sha256:"
+
+// GenerateOtpCode picks length characters uniformly from alphabet using crypto/rand
+func GenerateOtpCode(length int, alphabet string) (string, error) {
+	chars := []rune(alphabet)
+	if length <= 0 || len(chars) < 2 {
+		return "", fmt.Errorf("invalid OTP settings, length %d with %d character alphabet", length, len(chars))
+	}
+
+	max := big.NewInt(int64(len(chars)))
+	var code strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code.WriteRune(chars[n.Int64()])
+	}
+
+	return code.String(), nil
+}
+
+func otpSecret() []byte {
+	if config.Config.OTP.Secret != "" {
+		return []byte(config.Config.OTP.Secret)
+	}
+	return []byte(config.Config.APP.EncryptionKey)
+}
+
+// HashOtp returns the keyed hash of an OTP code that is stored instead of the code
+func HashOtp(code string) string {
+	mac := hmac.New(sha256.New, otpSecret())
+	mac.Write([]byte(code))
+	return OtpHashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recoveryCodeSecret keys recovery code hashes apart from OTPs, so a leaked
+// OTP_SECRET doesn't also open up the recovery code table
+func recoveryCodeSecret() []byte {
+	if config.Config.TOTP.RecoverySecret != "" {
+		return []byte(config.Config.TOTP.RecoverySecret)
+	}
+	mac := hmac.New(sha256.New, []byte(config.Config.APP.EncryptionKey))
+	mac.Write([]byte("totp-recovery-codes"))
+	return mac.Sum(nil)
+}
+
+// HashRecoveryCode returns the keyed hash of a TOTP recovery code
+func HashRecoveryCode(code string) string {
+	mac := hmac.New(sha256.New, recoveryCodeSecret())
+	mac.Write([]byte(code))
+	return OtpHashPrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// CompareOtp checks code against a stored hash in constant time
+func CompareOtp(hash, code string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(HashOtp(code))) == 1
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"kgoel085.com/url-shortner/config"
+)
+
+func TestGenerateOtpCode(t *testing.T) {
+	seen := map[rune]int{}
+	for i := 0; i < 200; i++ {
+		code, err := GenerateOtpCode(6, "ABCDEF")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 6 {
+			t.Fatalf("code %q, want 6 characters", code)
+		}
+		for _, char := range code {
+			if !strings.ContainsRune("ABCDEF", char) {
+				t.Fatalf("code %q has %q, not in the alphabet", code, char)
+			}
+			seen[char]++
+		}
+	}
+	// 1200 draws leave no character out unless the draw is broken
+	if len(seen) != 6 {
+		t.Errorf("only %d of 6 characters drawn: %v", len(seen), seen)
+	}
+
+	code, err := GenerateOtpCode(4, "αβ")
+	if err != nil || len([]rune(code)) != 4 {
+		t.Errorf("non-ASCII alphabet: %q %v", code, err)
+	}
+
+	for _, invalid := range []struct {
+		length   int
+		alphabet string
+	}{{0, "0123456789"}, {6, ""}, {6, "7"}} {
+		if _, err := GenerateOtpCode(invalid.length, invalid.alphabet); err == nil {
+			t.Errorf("length %d with alphabet %q accepted", invalid.length, invalid.alphabet)
+		}
+	}
+}
+
+func TestHashOtp(t *testing.T) {
+	old := config.Config.OTP.Secret
+	t.Cleanup(func() { config.Config.OTP.Secret = old })
+	config.Config.OTP.Secret = "first-secret"
+
+	hash := HashOtp("123456")
+	if !strings.HasPrefix(hash, OtpHashPrefix) || strings.Contains(hash, "123456") {
+		t.Fatalf("hash %q", hash)
+	}
+	if !CompareOtp(hash, "123456") || CompareOtp(hash, "123457") {
+		t.Error("CompareOtp doesn't tell the right code from a wrong one")
+	}
+	// Recovery codes are keyed apart from OTPs
+	if HashRecoveryCode("123456") == hash {
+		t.Error("recovery code hash equals the OTP hash")
+	}
+
+	config.Config.OTP.Secret = "second-secret"
+	if CompareOtp(hash, "123456") {
+		t.Error("hash still matches after OTP_SECRET changed")
+	}
+}
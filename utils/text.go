@@ -0,0 +1,13 @@
+package utils
+
+import "strings"
+
+// FirstNonEmpty returns the first value that isn't blank
+func FirstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
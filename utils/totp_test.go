@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 seed "12345678901234567890" of RFC 6238 appendix B
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCodeRFC6238Vectors(t *testing.T) {
+	// The RFC lists 8 digit codes, 6 digit codes are their last 6 digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, vector := range vectors {
+		code, err := TotpCode(rfc6238Secret, TotpStep(time.Unix(vector.unix, 0)))
+		if err != nil || code != vector.code {
+			t.Errorf("code at %d = %q %v, want %q", vector.unix, code, err, vector.code)
+		}
+	}
+}
+
+func TestValidateTotpSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	previous, _ := TotpCode(rfc6238Secret, TotpStep(now)-1)
+	older, _ := TotpCode(rfc6238Secret, TotpStep(now)-2)
+
+	if step, ok := ValidateTotp(rfc6238Secret, previous, now, 1); !ok || step != TotpStep(now)-1 {
+		t.Fatalf("code one step old: %d %v", step, ok)
+	}
+	if _, ok := ValidateTotp(rfc6238Secret, older, now, 1); ok {
+		t.Fatal("code two steps old was accepted with a skew of 1")
+	}
+	if _, ok := ValidateTotp(rfc6238Secret, previous, now, 0); ok {
+		t.Fatal("code one step old was accepted without skew")
+	}
+}
@@ -0,0 +1,12 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DoNotTrack tells whether the browser asks not to be tracked, with the
+// DNT or the Global Privacy Control (Sec-GPC) header
+func DoNotTrack(header http.Header) bool {
+	return strings.TrimSpace(header.Get("DNT")) == "1" || strings.TrimSpace(header.Get("Sec-GPC")) == "1"
+}
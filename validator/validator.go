@@ -2,12 +2,16 @@ package validator
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/dlclark/regexp2"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 )
 
+var validate = validator.New()
+
 func LoadCustomBindings() {
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
 		// Register custom tag "strongpwd"
@@ -15,6 +19,29 @@ func LoadCustomBindings() {
 	}
 }
 
+var e164Regex = regexp.MustCompile(`^\+[1-9][0-9]{1,14}$`)
+
+// IsEmail applies the same rules as the "email" binding tag
+func IsEmail(email string) bool {
+	return validate.Var(email, "required,email") == nil
+}
+
+// IsE164 reports whether phone is an E.164 number, e.g. +14155550123
+func IsE164(phone string) bool {
+	return e164Regex.MatchString(phone)
+}
+
+// NormalizePhone strips the spaces, dashes, dots and brackets people type in phone numbers
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+}
+
 func strongPassword(fl validator.FieldLevel) bool {
 	password := fl.Field().String()
 	// Example rule: at least 5 chars, one number, one special char
@@ -27,6 +54,8 @@ func MsgForTag(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
 		return "This field is required"
+	case "required_without", "required_without_all":
+		return fmt.Sprintf("This field is required unless %s is set", fe.Param())
 	case "email":
 		return "Invalid email format"
 	case "min":